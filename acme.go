@@ -0,0 +1,153 @@
+package responder
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
+	"strings"
+
+	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/xenolf/lego/lego"
+	"github.com/xenolf/lego/registration"
+)
+
+// Well-known ACME CAs, for Options.ACMECA
+const (
+	// LetsEncryptCA - Let's Encrypt's production CA (the default)
+	LetsEncryptCA = "letsencrypt"
+	// LetsEncryptStagingCA - Let's Encrypt's staging CA, with much higher
+	// rate limits, for testing. Its certificates aren't trusted.
+	LetsEncryptStagingCA = "letsencrypt-staging"
+	// ZeroSSLCA - ZeroSSL's CA, which needs External Account Binding keys
+	// (see Options.ACMEEABKeyID)
+	ZeroSSLCA = "zerossl"
+)
+
+// acmeDirectories - the directory URLs of the well-known CAs
+var acmeDirectories = map[string]string{
+	LetsEncryptCA:        certmagic.LetsEncryptProductionCA,
+	LetsEncryptStagingCA: certmagic.LetsEncryptStagingCA,
+	ZeroSSLCA:            "https://acme.zerossl.com/v2/DV90",
+}
+
+// acmeDirectory - the directory URL of the CA, which is a well-known CA's
+// name or a directory URL. It's empty for the default CA.
+func acmeDirectory(ca string) (string, error) {
+	if ca == "" {
+		return "", nil
+	}
+	if dir, ok := acmeDirectories[ca]; ok {
+		return dir, nil
+	}
+	u, err := url.Parse(ca)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", errors.Errorf("invalid ACME CA %q - must be %s, %s, %s, or an https:// directory URL", ca, LetsEncryptCA, LetsEncryptStagingCA, ZeroSSLCA)
+	}
+	return ca, nil
+}
+
+// validateACME - check the ACME CA options
+func (o Options) validateACME() error {
+	dir, err := acmeDirectory(o.ACMECA)
+	if err != nil {
+		return err
+	}
+	switch {
+	case (o.ACMEEABKeyID == "") != (o.ACMEEABHMACKey == ""):
+		return errors.New("ACME External Account Binding needs both a key ID and an HMAC key")
+	case dir == acmeDirectories[ZeroSSLCA] && o.ACMEEABKeyID == "":
+		return errors.New("ZeroSSL needs External Account Binding keys - see https://zerossl.com/documentation/acme/")
+	}
+	return nil
+}
+
+// configureACME - point certmagic at the configured CA and account
+func (r *Responder) configureACME() {
+	if r.acmeCA != "" {
+		certmagic.CA = r.acmeCA
+	}
+	if r.acmeEmail != "" {
+		certmagic.Email = r.acmeEmail
+	}
+}
+
+// eabKeys - External Account Binding credentials
+type eabKeys struct {
+	KeyID   string
+	HMACKey string
+}
+
+// acmeUser - an ACME account, in the form certmagic stores them
+type acmeUser struct {
+	Email        string
+	Registration *registration.Resource
+	key          crypto.PrivateKey
+}
+
+func (u *acmeUser) GetEmail() string                        { return u.Email }
+func (u *acmeUser) GetRegistration() *registration.Resource { return u.Registration }
+func (u *acmeUser) GetPrivateKey() crypto.PrivateKey        { return u.key }
+
+// registerACMEAccount - register an account with External Account Binding
+// (when configured), before certmagic needs one. certmagic can't register
+// with EAB itself, but it uses any account it finds in storage, so the
+// account is stored where certmagic looks for it. Accounts already in
+// storage are reused.
+func (r *Responder) registerACMEAccount() error {
+	if r.acmeEAB.KeyID == "" {
+		return nil
+	}
+	storage := certmagic.DefaultStorage
+	email := strings.ToLower(strings.TrimSpace(certmagic.Email))
+	regKey := certmagic.StorageKeys.UserReg(certmagic.CA, email)
+	if storage.Exists(regKey) {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate ACME account key")
+	}
+	user := &acmeUser{Email: email, key: key}
+	cfg := lego.NewConfig(user)
+	cfg.CADirURL = certmagic.CA
+	client, err := lego.NewClient(cfg)
+	if err != nil {
+		return errors.Wrapf(err, "failed to reach ACME CA %s", certmagic.CA)
+	}
+	user.Registration, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+		TermsOfServiceAgreed: true,
+		Kid:                  r.acmeEAB.KeyID,
+		HmacEncoded:          r.acmeEAB.HMACKey,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to register ACME account with %s", certmagic.CA)
+	}
+
+	reg, err := json.MarshalIndent(user, "", "\t")
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	err = storage.Store(certmagic.StorageKeys.UserPrivateKey(certmagic.CA, email),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		return errors.Wrap(err, "failed to store ACME account key")
+	}
+	err = storage.Store(regKey, reg)
+	if err != nil {
+		return errors.Wrap(err, "failed to store ACME account")
+	}
+	log.Info().Str("ca", certmagic.CA).Str("account", user.Registration.URI).Msg("Registered ACME account")
+	return nil
+}
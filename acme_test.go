@@ -0,0 +1,107 @@
+package responder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mholt/certmagic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACMEOptions(t *testing.T) {
+	o := Options{Repos: []string{"foo/bar"}, Domain: "hooks.example.com"}
+	assert.NoError(t, o.Validate())
+
+	o.ACMECA = LetsEncryptStagingCA
+	assert.NoError(t, o.Validate())
+	o.ACMECA = "https://acme.example.com/directory"
+	assert.NoError(t, o.Validate())
+	o.ACMECA = "http://acme.example.com/directory"
+	assert.Error(t, o.Validate())
+	o.ACMECA = "buypass"
+	assert.Error(t, o.Validate())
+
+	o.ACMECA = ZeroSSLCA
+	assert.EqualError(t, o.Validate(), "ZeroSSL needs External Account Binding keys - see https://zerossl.com/documentation/acme/")
+	o.ACMEEABKeyID = "kid"
+	assert.Error(t, o.Validate())
+	o.ACMEEABHMACKey = "aG1hYw"
+	assert.NoError(t, o.Validate())
+
+	dir, err := acmeDirectory(ZeroSSLCA)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://acme.zerossl.com/v2/DV90", dir)
+	dir, err = acmeDirectory("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", dir)
+}
+
+func TestRegisterACMEAccount(t *testing.T) {
+	var srv *httptest.Server
+	registrations := 0
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		switch req.URL.Path {
+		case "/directory":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"newNonce":   srv.URL + "/nonce",
+				"newAccount": srv.URL + "/account",
+				"newOrder":   srv.URL + "/order",
+				"revokeCert": srv.URL + "/revoke",
+				"keyChange":  srv.URL + "/key",
+			})
+		case "/nonce":
+		case "/account":
+			registrations++
+			var jws struct{ Payload string }
+			body, _ := ioutil.ReadAll(req.Body)
+			assert.NoError(t, json.Unmarshal(body, &jws))
+			payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+			assert.NoError(t, err)
+			var account struct {
+				TermsOfServiceAgreed   bool            `json:"termsOfServiceAgreed"`
+				ExternalAccountBinding json.RawMessage `json:"externalAccountBinding"`
+			}
+			assert.NoError(t, json.Unmarshal(payload, &account))
+			assert.True(t, account.TermsOfServiceAgreed)
+			assert.NotEmpty(t, account.ExternalAccountBinding)
+
+			w.Header().Set("Location", srv.URL+"/account/1")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"status": "valid"}`))
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(ca, email string, storage certmagic.Storage) {
+		certmagic.CA, certmagic.Email, certmagic.DefaultStorage = ca, email, storage
+	}(certmagic.CA, certmagic.Email, certmagic.DefaultStorage)
+	storage := &certmagic.FileStorage{Path: t.TempDir()}
+	certmagic.DefaultStorage = storage
+
+	r := &Responder{acmeCA: srv.URL + "/directory", acmeEmail: "Me@example.com"}
+	r.configureACME()
+	assert.NoError(t, r.registerACMEAccount())
+	assert.Equal(t, 0, registrations)
+
+	r.acmeEAB = eabKeys{"kid", base64.RawURLEncoding.EncodeToString([]byte("hmac"))}
+	assert.NoError(t, r.registerACMEAccount())
+	assert.Equal(t, 1, registrations)
+
+	reg, err := storage.Load(certmagic.StorageKeys.UserReg(certmagic.CA, "me@example.com"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(reg), srv.URL+"/account/1")
+	key, err := storage.Load(certmagic.StorageKeys.UserPrivateKey(certmagic.CA, "me@example.com"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(key), "EC PRIVATE KEY")
+
+	// the stored account is reused
+	assert.NoError(t, r.registerACMEAccount())
+	assert.Equal(t, 1, registrations)
+}
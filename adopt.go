@@ -0,0 +1,114 @@
+package responder
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/rs/zerolog/log"
+)
+
+// callbackPrefix - the start of every per-repo hook's callback URL on the
+// domain
+func (r *Responder) callbackPrefix(domain string) string {
+	return callbackScheme() + domain + r.pathPrefix + "gh-callback/"
+}
+
+// ownHook - whether the hook delivers to one of this Responder's callback
+// paths, on any of its domains (whether or not it's from this run)
+func (r *Responder) ownHook(h *github.Hook) bool {
+	u, _ := h.Config["url"].(string)
+	for _, d := range r.servedDomains() {
+		if r.onDomain(u, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// onDomain - whether the callback URL is one of this Responder's callback
+// paths on the domain
+func (r *Responder) onDomain(u, domain string) bool {
+	if strings.HasPrefix(u, r.callbackPrefix(domain)) {
+		return true
+	}
+	return r.callbackPath != "" && strings.HasPrefix(u, callbackScheme()+domain+r.callbackPath)
+}
+
+// ownHooks lists the repo's hooks delivering to this Responder's domain and
+// path prefix
+func (r *Responder) ownHooks(ctx context.Context, p *pacer, repo repository) ([]*github.Hook, error) {
+	var own []*github.Hook
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		var hooks []*github.Hook
+		var resp *github.Response
+		err := withPacedRetries(ctx, "listing hooks", p, func() (_ *github.Response, err error) {
+			hooks, resp, err = r.ghclient.Repositories.ListHooks(ctx, repo.owner, repo.name, opt)
+			return resp, err
+		})
+		err = repoHooksError(resp, err)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hooks {
+			if r.ownHook(h) {
+				own = append(own, h)
+			}
+		}
+		if resp.NextPage == 0 {
+			return own, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// adoptHook updates the repo's existing hook delivering to this Responder's
+// domain and path prefix to match the target, creating one if there's none.
+// Any further such hooks are stale, and are removed. Inactive hooks (see
+// DeactivateHooks) are reactivated. The returned cleanup function follows
+// the shutdown policy - by default leaving the hook in place, to be adopted
+// again on restart.
+func (r *Responder) adoptHook(ctx context.Context, p *pacer, t hookTarget) (func() error, error) {
+	owner, repoName := t.repo.owner, t.repo.name
+	existing, err := r.ownHooks(ctx, p, t.repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return r.createHook(ctx, p, t)
+	}
+
+	id := existing[0].GetID()
+	hook := *t.hook
+	hook.Active = github.Bool(true)
+	var resp *github.Response
+	err = withPacedRetries(ctx, "updating hook", p, func() (_ *github.Response, err error) {
+		_, resp, err = r.ghclient.Repositories.EditHook(ctx, owner, repoName, id, &hook)
+		return resp, err
+	})
+	err = hookError(resp, err)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().
+		Str("repo", owner+"/"+repoName).
+		Int64("hook_id", id).
+		Str("callback", t.hook.Config["url"].(string)).
+		Msg("Adopted WebHook")
+
+	for _, stale := range existing[1:] {
+		resp, err := r.ghclient.Repositories.DeleteHook(ctx, owner, repoName, stale.GetID())
+		err = hookError(resp, err)
+		if err != nil {
+			log.Warn().Err(err).Int64("hook_id", stale.GetID()).Msg("failed to delete stale webhook")
+		}
+	}
+
+	return r.hookCleanup(ctx, t.repo, id), nil
+}
+
+// keepHook - the cleanup function for adopted hooks, which are left in place
+func keepHook() error {
+	return nil
+}
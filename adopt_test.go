@@ -0,0 +1,122 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStableEndpoint(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/", hookSecret: "s3cret"}
+	primary := r.newEndpoint(nil, nil)
+	r.endpoints = []*endpoint{primary}
+	other := r.newEndpoint(nil, nil)
+
+	assert.Equal(t, "s3cret", primary.secret)
+	assert.NotEqual(t, "s3cret", other.secret)
+	assert.NotEqual(t, primary.callbackURL, other.callbackURL)
+	assert.True(t, r.ownHook(hookTo(primary.callbackURL)))
+
+	// the same on restart
+	r2 := &Responder{domain: "example.com", pathPrefix: "/", hookSecret: "s3cret"}
+	assert.Equal(t, primary.callbackURL, r2.newEndpoint(nil, nil).callbackURL)
+
+	r2.hookSecret = "other"
+	assert.NotEqual(t, primary.callbackURL, r2.newEndpoint(nil, nil).callbackURL)
+}
+
+func hookTo(url string) *github.Hook {
+	return &github.Hook{Config: map[string]interface{}{"url": url}}
+}
+
+func TestAdoptHooks(t *testing.T) {
+	var mu sync.Mutex
+	var created, edited, deleted []string
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method + " " + req.URL.Path {
+		case "GET /repos/a/old/hooks":
+			fmt.Fprint(w, `[
+				{"id":1,"config":{"url":"https://other.example.com/gh-callback/x"}},
+				{"id":2,"config":{"url":"https://example.com/gh-callback/old"}},
+				{"id":3,"config":{"url":"https://example.com/gh-callback/older"}}
+			]`)
+		case "GET /repos/a/new/hooks":
+			fmt.Fprint(w, `[]`)
+		case "POST /repos/a/new/hooks":
+			created = append(created, "a/new")
+			fmt.Fprint(w, `{"id":4}`)
+		case "PATCH /repos/a/old/hooks/2":
+			hook := map[string]interface{}{}
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&hook))
+			edited = append(edited, hook["config"].(map[string]interface{})["url"].(string))
+			fmt.Fprint(w, `{"id":2}`)
+		case "DELETE /repos/a/old/hooks/3":
+			deleted = append(deleted, "3")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}, "a/old", "a/new")
+	r.adoptHooks = true
+
+	cleanup, err := r.RegisterE(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/new"}, created)
+	assert.Equal(t, []string{r.endpoints[0].callbackURL}, edited)
+	assert.Equal(t, []string{"3"}, deleted)
+
+	// adopted hooks are left in place
+	assert.NoError(t, cleanup())
+}
+
+func TestDeactivateHooks(t *testing.T) {
+	var mu sync.Mutex
+	active := map[string]bool{}
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method + " " + req.URL.Path {
+		case "GET /repos/a/b/hooks":
+			fmt.Fprint(w, `[{"id":2,"active":false,"config":{"url":"https://example.com/gh-callback/old"}}]`)
+		case "PATCH /repos/a/b/hooks/2":
+			hook := map[string]interface{}{}
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&hook))
+			active["2"] = hook["active"].(bool)
+			fmt.Fprint(w, `{"id":2}`)
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}, "a/b")
+	r.adoptHooks = true
+	r.shutdownPolicy = DeactivateHooks
+
+	cleanup, err := r.RegisterE(context.Background(), nil)
+	assert.NoError(t, err)
+	// reactivated on startup
+	assert.True(t, active["2"])
+
+	// deactivated, not deleted, on shutdown
+	assert.NoError(t, cleanup())
+	assert.False(t, active["2"])
+}
+
+func TestHookShutdownPolicy(t *testing.T) {
+	assert.NoError(t, HookShutdownPolicy("").validate())
+	assert.NoError(t, DeactivateHooks.validate())
+	assert.Error(t, HookShutdownPolicy("explode").validate())
+
+	o := Options{HookShutdownPolicy: DeactivateHooks}.withDefaults()
+	assert.True(t, o.AdoptHooks)
+	assert.Equal(t, KeepHooks, Options{AdoptHooks: true}.withDefaults().HookShutdownPolicy)
+	assert.Equal(t, DeleteHooks, Options{}.withDefaults().HookShutdownPolicy)
+}
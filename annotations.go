@@ -0,0 +1,310 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// AnnotationLevel - the severity of an annotation
+type AnnotationLevel string
+
+// Annotation levels, matching GitHub's check run annotation levels
+const (
+	AnnotationNotice  AnnotationLevel = "notice"
+	AnnotationWarning AnnotationLevel = "warning"
+	AnnotationFailure AnnotationLevel = "failure"
+)
+
+// maxCheckRunAnnotations - GitHub accepts at most 50 annotations per request
+const maxCheckRunAnnotations = 50
+
+// Annotation - a structured finding about a file, reported by a handler with
+// Annotate
+type Annotation struct {
+	Path string
+	// Line - the line the annotation applies to. Optional.
+	Line    int
+	Level   AnnotationLevel
+	Title   string
+	Message string
+}
+
+func (a Annotation) String() string {
+	loc := a.Path
+	if a.Line > 0 {
+		loc += fmt.Sprintf(":%d", a.Line)
+	}
+	s := fmt.Sprintf("%s [%s]", loc, a.Level)
+	if a.Title != "" {
+		s += " " + a.Title + ":"
+	}
+	return s + " " + a.Message
+}
+
+// AnnotationRenderer - renders the annotations reported by a delivery's
+// handlers, once they've all finished
+type AnnotationRenderer interface {
+	RenderAnnotations(ctx context.Context, eventType string, payload []byte, annotations []Annotation) error
+}
+
+// Annotate - report annotations for the delivery being handled. Once all
+// handlers have finished, the delivery's annotations are rendered by the
+// Responder's AnnotationRenderer (see SetAnnotationRenderer). Does nothing
+// if the context didn't come from a Responder.
+func Annotate(ctx context.Context, annotations ...Annotation) {
+	if c, ok := ctx.Value(annotationsKey).(*annotationCollector); ok {
+		c.add(annotations)
+	}
+}
+
+// SetAnnotationRenderer - render annotations with ar. By default they're
+// logged (see LogAnnotations).
+func (r *Responder) SetAnnotationRenderer(ar AnnotationRenderer) {
+	r.annotationRenderer = ar
+}
+
+type annotationCollector struct {
+	mu   sync.Mutex
+	list []Annotation
+}
+
+func (c *annotationCollector) add(annotations []Annotation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list = append(c.list, annotations...)
+}
+
+func (c *annotationCollector) annotations() []Annotation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Annotation(nil), c.list...)
+}
+
+// collectAnnotations adds an annotation collector to the handlers' context,
+// and renders the collected annotations once the handlers are done
+func (r *Responder) collectAnnotations(ctx context.Context) (context.Context, func(wg *sync.WaitGroup, eventType string, payload []byte)) {
+	c := &annotationCollector{}
+	render := func(wg *sync.WaitGroup, eventType string, payload []byte) {
+		go func() {
+			wg.Wait()
+			anns := c.annotations()
+			if len(anns) == 0 {
+				return
+			}
+			ar := r.annotationRenderer
+			if ar == nil {
+				ar = LogAnnotations{}
+			}
+			err := ar.RenderAnnotations(ctx, eventType, payload, anns)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Int("annotations", len(anns)).Msg("failed to render annotations")
+			}
+		}()
+	}
+	return context.WithValue(ctx, annotationsKey, c), render
+}
+
+// LogAnnotations - renders annotations to the log
+type LogAnnotations struct{}
+
+// RenderAnnotations - log each annotation, at a level matching its severity
+func (LogAnnotations) RenderAnnotations(ctx context.Context, eventType string, payload []byte, annotations []Annotation) error {
+	log := zerolog.Ctx(ctx)
+	for _, a := range annotations {
+		e := log.Info()
+		switch a.Level {
+		case AnnotationWarning:
+			e = log.Warn()
+		case AnnotationFailure:
+			e = log.Error()
+		}
+		e.Str("path", a.Path).Int("line", a.Line).Str("title", a.Title).Msg(a.Message)
+	}
+	return nil
+}
+
+// CheckRunAnnotations - renders annotations as a completed check run on the
+// event's head commit, using the handlers' GitHub client. The conclusion is
+// "failure" if any annotation is a failure, "neutral" if any is a warning,
+// and "success" otherwise. Annotations for events without a head commit
+// (anything but push, pull_request, check_suite, and check_run) are logged
+// instead.
+type CheckRunAnnotations struct {
+	// Name of the check run
+	Name string
+}
+
+// RenderAnnotations - create the check run
+func (cr CheckRunAnnotations) RenderAnnotations(ctx context.Context, eventType string, payload []byte, annotations []Annotation) error {
+	hc := parseHeadCommit(payload)
+	if hc.sha == "" || hc.owner == "" {
+		return LogAnnotations{}.RenderAnnotations(ctx, eventType, payload, annotations)
+	}
+	client := GitHubClient(ctx)
+	if client == nil {
+		return errors.New("no GitHub client to create the check run with")
+	}
+
+	conclusion := "success"
+	converted := make([]*github.CheckRunAnnotation, len(annotations))
+	for i, a := range annotations {
+		switch {
+		case a.Level == AnnotationFailure:
+			conclusion = "failure"
+		case a.Level == AnnotationWarning && conclusion == "success":
+			conclusion = "neutral"
+		}
+		line := a.Line
+		if line < 1 {
+			line = 1
+		}
+		level := a.Level
+		if level == "" {
+			level = AnnotationNotice
+		}
+		converted[i] = &github.CheckRunAnnotation{
+			Path:            github.String(a.Path),
+			StartLine:       github.Int(line),
+			EndLine:         github.Int(line),
+			AnnotationLevel: github.String(string(level)),
+			Title:           optionalString(a.Title),
+			Message:         github.String(a.Message),
+		}
+	}
+	output := func(batch []*github.CheckRunAnnotation) *github.CheckRunOutput {
+		return &github.CheckRunOutput{
+			Title:       github.String(cr.Name),
+			Summary:     github.String(fmt.Sprintf("%d annotations", len(annotations))),
+			Annotations: batch,
+		}
+	}
+
+	first := converted
+	if len(first) > maxCheckRunAnnotations {
+		first = first[:maxCheckRunAnnotations]
+	}
+	now := &github.Timestamp{Time: time.Now()}
+	run, _, err := client.Checks.CreateCheckRun(ctx, hc.owner, hc.repo, github.CreateCheckRunOptions{
+		Name:        cr.Name,
+		HeadBranch:  hc.branch,
+		HeadSHA:     hc.sha,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(conclusion),
+		CompletedAt: now,
+		Output:      output(first),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create check run")
+	}
+	// the rest are added in batches
+	for i := maxCheckRunAnnotations; i < len(converted); i += maxCheckRunAnnotations {
+		end := i + maxCheckRunAnnotations
+		if end > len(converted) {
+			end = len(converted)
+		}
+		_, _, err = client.Checks.UpdateCheckRun(ctx, hc.owner, hc.repo, run.GetID(), github.UpdateCheckRunOptions{
+			Name:   cr.Name,
+			Output: output(converted[i:end]),
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to add annotations to check run")
+		}
+	}
+	return nil
+}
+
+// ReviewAnnotations - renders annotations as a pull request review comment,
+// listing them in the review body, for pull_request events. Line-anchored
+// review comments need diff positions, which annotations don't carry. Other
+// events are logged instead.
+type ReviewAnnotations struct{}
+
+// RenderAnnotations - create the review
+func (ReviewAnnotations) RenderAnnotations(ctx context.Context, eventType string, payload []byte, annotations []Annotation) error {
+	hc := parseHeadCommit(payload)
+	if eventType != "pull_request" || hc.number == 0 {
+		return LogAnnotations{}.RenderAnnotations(ctx, eventType, payload, annotations)
+	}
+	client := GitHubClient(ctx)
+	if client == nil {
+		return errors.New("no GitHub client to create the review with")
+	}
+
+	lines := make([]string, len(annotations))
+	for i, a := range annotations {
+		lines[i] = "- " + a.String()
+	}
+	_, _, err := client.PullRequests.CreateReview(ctx, hc.owner, hc.repo, hc.number, &github.PullRequestReviewRequest{
+		CommitID: optionalString(hc.sha),
+		Body:     github.String(strings.Join(lines, "\n")),
+		Event:    github.String("COMMENT"),
+	})
+	return errors.Wrap(err, "failed to create review")
+}
+
+// headCommit - where an event's annotations belong
+type headCommit struct {
+	owner, repo string
+	sha, branch string
+	number      int
+}
+
+// parseHeadCommit finds the head commit of push, pull_request, check_suite,
+// and check_run events
+func parseHeadCommit(payload []byte) headCommit {
+	var p struct {
+		Ref         string `json:"ref"`
+		After       string `json:"after"`
+		PullRequest struct {
+			Number int `json:"number"`
+			Head   struct {
+				SHA string `json:"sha"`
+				Ref string `json:"ref"`
+			} `json:"head"`
+		} `json:"pull_request"`
+		CheckSuite struct {
+			HeadSHA    string `json:"head_sha"`
+			HeadBranch string `json:"head_branch"`
+		} `json:"check_suite"`
+		CheckRun struct {
+			HeadSHA string `json:"head_sha"`
+		} `json:"check_run"`
+		Repo struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	// errors are ignored, as not all payloads have these fields
+	_ = json.Unmarshal(payload, &p)
+
+	hc := headCommit{number: p.PullRequest.Number}
+	if parts := strings.SplitN(p.Repo.FullName, "/", 2); len(parts) == 2 {
+		hc.owner, hc.repo = parts[0], parts[1]
+	}
+	switch {
+	case p.PullRequest.Head.SHA != "":
+		hc.sha, hc.branch = p.PullRequest.Head.SHA, p.PullRequest.Head.Ref
+	case p.CheckSuite.HeadSHA != "":
+		hc.sha, hc.branch = p.CheckSuite.HeadSHA, p.CheckSuite.HeadBranch
+	case p.CheckRun.HeadSHA != "":
+		hc.sha = p.CheckRun.HeadSHA
+	case p.After != "" && strings.Trim(p.After, "0") != "":
+		// pushes deleting a branch have an all-zero 'after'
+		hc.sha, hc.branch = p.After, strings.TrimPrefix(p.Ref, "refs/heads/")
+	}
+	return hc
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,115 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type renderFunc func(ctx context.Context, eventType string, payload []byte, annotations []Annotation) error
+
+func (f renderFunc) RenderAnnotations(ctx context.Context, eventType string, payload []byte, annotations []Annotation) error {
+	return f(ctx, eventType, payload, annotations)
+}
+
+func TestCollectAnnotations(t *testing.T) {
+	rendered := make(chan []Annotation, 1)
+	r := &Responder{}
+	r.SetAnnotationRenderer(renderFunc(func(ctx context.Context, eventType string, payload []byte, annotations []Annotation) error {
+		rendered <- annotations
+		return nil
+	}))
+
+	ctx, render := r.collectAnnotations(context.Background())
+	a := Annotation{Path: "main.go", Line: 3, Level: AnnotationWarning, Message: "hmm"}
+	routes := []Route{{Handler: func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		Annotate(ctx, a)
+	}}, {Handler: func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		Annotate(ctx, a, a)
+	}}}
+	render(dispatch(ctx, routes, "push", "1", nil), "push", nil)
+	assert.Len(t, <-rendered, 3)
+
+	// nothing to render
+	ctx, render = r.collectAnnotations(context.Background())
+	render(&sync.WaitGroup{}, "push", nil)
+	assert.Empty(t, rendered)
+
+	// harmless outside a Responder
+	Annotate(context.Background(), a)
+}
+
+func TestAnnotationString(t *testing.T) {
+	a := Annotation{Path: "main.go", Line: 3, Level: AnnotationFailure, Title: "lint", Message: "bad"}
+	assert.Equal(t, "main.go:3 [failure] lint: bad", a.String())
+	a = Annotation{Path: "README.md", Level: AnnotationNotice, Message: "typo"}
+	assert.Equal(t, "README.md [notice] typo", a.String())
+}
+
+func TestParseHeadCommit(t *testing.T) {
+	hc := parseHeadCommit([]byte(`{"ref":"refs/heads/main","after":"abc","repository":{"full_name":"foo/bar"}}`))
+	assert.Equal(t, headCommit{owner: "foo", repo: "bar", sha: "abc", branch: "main"}, hc)
+
+	hc = parseHeadCommit([]byte(`{"after":"0000000000000000000000000000000000000000","repository":{"full_name":"foo/bar"}}`))
+	assert.Equal(t, "", hc.sha)
+
+	hc = parseHeadCommit([]byte(`{"pull_request":{"number":4,"head":{"sha":"def","ref":"fix"}},"repository":{"full_name":"foo/bar"}}`))
+	assert.Equal(t, headCommit{owner: "foo", repo: "bar", sha: "def", branch: "fix", number: 4}, hc)
+
+	hc = parseHeadCommit([]byte(`{"check_suite":{"head_sha":"123","head_branch":"main"}}`))
+	assert.Equal(t, headCommit{sha: "123", branch: "main"}, hc)
+
+	assert.Equal(t, headCommit{}, parseHeadCommit([]byte(`not json`)))
+}
+
+func TestCheckRunAnnotations(t *testing.T) {
+	var created map[string]interface{}
+	var updates int
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "POST":
+			assert.Equal(t, "/repos/foo/bar/check-runs", req.URL.Path)
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&created))
+			_, _ = w.Write([]byte(`{"id": 7}`))
+		case "PATCH":
+			assert.Equal(t, "/repos/foo/bar/check-runs/7", req.URL.Path)
+			updates++
+			_, _ = w.Write([]byte(`{"id": 7}`))
+		}
+	})
+	ctx := withGitHubClient(context.Background(), r.ghclient)
+
+	anns := make([]Annotation, 120)
+	for i := range anns {
+		anns[i] = Annotation{Path: "main.go", Level: AnnotationWarning, Message: "hmm"}
+	}
+	payload := []byte(`{"after":"abc","ref":"refs/heads/main","repository":{"full_name":"foo/bar"}}`)
+	err := CheckRunAnnotations{Name: "lint"}.RenderAnnotations(ctx, "push", payload, anns)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", created["head_sha"])
+	assert.Equal(t, "neutral", created["conclusion"])
+	assert.Len(t, created["output"].(map[string]interface{})["annotations"], 50)
+	assert.Equal(t, 2, updates)
+}
+
+func TestReviewAnnotations(t *testing.T) {
+	var review map[string]interface{}
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/repos/foo/bar/pulls/4/reviews", req.URL.Path)
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&review))
+		_, _ = w.Write([]byte(`{}`))
+	})
+	ctx := withGitHubClient(context.Background(), r.ghclient)
+
+	payload := []byte(`{"pull_request":{"number":4,"head":{"sha":"def"}},"repository":{"full_name":"foo/bar"}}`)
+	err := ReviewAnnotations{}.RenderAnnotations(ctx, "pull_request", payload, []Annotation{
+		{Path: "main.go", Line: 1, Level: AnnotationFailure, Message: "bad"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "COMMENT", review["event"])
+	assert.Equal(t, "- main.go:1 [failure] bad", review["body"])
+}
@@ -0,0 +1,115 @@
+package responder
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Installation - a GitHub App installation, as learned from installation and
+// installation_repositories events
+type Installation struct {
+	ID        int64
+	Account   string
+	Suspended bool
+	// Repos - the repositories the installation has access to ('owner/repo').
+	// Empty when the installation has access to all repositories (or when no
+	// event listing them has been seen).
+	Repos []string
+}
+
+// Installations - the GitHub App installations known to the Responder. In App
+// mode (see Options.AppSecret), installations are tracked from the
+// installation and installation_repositories events GitHub sends, so only
+// installations created or changed while the Responder is running are known.
+func (r *Responder) Installations() []Installation {
+	return r.installations.list()
+}
+
+// appPayload - the fields of installation and installation_repositories
+// events that are tracked
+type appPayload struct {
+	Action       string `json:"action"`
+	Installation struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	} `json:"installation"`
+	Repositories        []appRepo `json:"repositories"`
+	RepositoriesAdded   []appRepo `json:"repositories_added"`
+	RepositoriesRemoved []appRepo `json:"repositories_removed"`
+}
+
+type appRepo struct {
+	FullName string `json:"full_name"`
+}
+
+type installationRegistry struct {
+	mu   sync.Mutex
+	byID map[int64]*installation
+}
+
+type installation struct {
+	account   string
+	suspended bool
+	repos     map[string]bool
+}
+
+// track updates the registry from installation-related events - other events
+// are ignored
+func (reg *installationRegistry) track(eventType string, payload []byte) {
+	if eventType != "installation" && eventType != "installation_repositories" {
+		return
+	}
+	p := appPayload{}
+	if err := json.Unmarshal(payload, &p); err != nil || p.Installation.ID == 0 {
+		return
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.byID == nil {
+		reg.byID = map[int64]*installation{}
+	}
+	id := p.Installation.ID
+	if eventType == "installation" && p.Action == "deleted" {
+		delete(reg.byID, id)
+		return
+	}
+	inst, ok := reg.byID[id]
+	if !ok {
+		inst = &installation{repos: map[string]bool{}}
+		reg.byID[id] = inst
+	}
+	inst.account = p.Installation.Account.Login
+
+	switch p.Action {
+	case "suspend":
+		inst.suspended = true
+	case "unsuspend":
+		inst.suspended = false
+	}
+	for _, repo := range append(p.Repositories, p.RepositoriesAdded...) {
+		inst.repos[repo.FullName] = true
+	}
+	for _, repo := range p.RepositoriesRemoved {
+		delete(inst.repos, repo.FullName)
+	}
+}
+
+func (reg *installationRegistry) list() []Installation {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	out := make([]Installation, 0, len(reg.byID))
+	for id, inst := range reg.byID {
+		i := Installation{ID: id, Account: inst.account, Suspended: inst.suspended}
+		for repo := range inst.repos {
+			i.Repos = append(i.Repos, repo)
+		}
+		sort.Strings(i.Repos)
+		out = append(out, i)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
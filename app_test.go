@@ -0,0 +1,39 @@
+package responder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallationRegistry(t *testing.T) {
+	reg := &installationRegistry{}
+	assert.Empty(t, reg.list())
+
+	reg.track("push", []byte(`{"installation": {"id": 1}}`))
+	assert.Empty(t, reg.list())
+
+	reg.track("installation", []byte(`{
+		"action": "created",
+		"installation": {"id": 2, "account": {"login": "octo"}},
+		"repositories": [{"full_name": "octo/b"}, {"full_name": "octo/a"}]
+	}`))
+	reg.track("installation", []byte(`{"action": "created", "installation": {"id": 1, "account": {"login": "cat"}}}`))
+	assert.Equal(t, []Installation{
+		{ID: 1, Account: "cat"},
+		{ID: 2, Account: "octo", Repos: []string{"octo/a", "octo/b"}},
+	}, reg.list())
+
+	reg.track("installation_repositories", []byte(`{
+		"action": "added",
+		"installation": {"id": 2, "account": {"login": "octo"}},
+		"repositories_added": [{"full_name": "octo/c"}],
+		"repositories_removed": [{"full_name": "octo/a"}]
+	}`))
+	reg.track("installation", []byte(`{"action": "suspend", "installation": {"id": 2, "account": {"login": "octo"}}}`))
+	assert.Equal(t, Installation{ID: 2, Account: "octo", Suspended: true, Repos: []string{"octo/b", "octo/c"}}, reg.list()[1])
+
+	reg.track("installation", []byte(`{"action": "deleted", "installation": {"id": 1}}`))
+	reg.track("installation", []byte(`not json`))
+	assert.Len(t, reg.list(), 1)
+}
@@ -0,0 +1,203 @@
+package responder
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// appAuth authenticates as a GitHub App, and hands out clients authenticated
+// as the App's installations. Installation tokens are cached, and refreshed
+// shortly before they expire.
+type appAuth struct {
+	id  int64
+	key *rsa.PrivateKey
+	// quota - enforces handlers' API call budgets, if any
+	quota *apiQuota
+	// appClient - authenticated as the App itself (with a JWT)
+	appClient *github.Client
+	// transport - for outbound requests (see Options.Proxy)
+	transport http.RoundTripper
+	now       func() time.Time
+
+	mu      sync.Mutex
+	clients map[int64]*github.Client
+}
+
+func newAppAuth(id int64, pemKey []byte, base *github.Client, transport http.RoundTripper) (*appAuth, error) {
+	key, err := parsePrivateKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	a := &appAuth{
+		id:        id,
+		key:       key,
+		now:       time.Now,
+		clients:   map[int64]*github.Client{},
+		transport: transport,
+	}
+	if a.transport == nil {
+		a.transport = http.DefaultTransport
+	}
+	a.appClient = github.NewClient(&http.Client{Transport: &jwtTransport{a, a.transport}})
+	a.appClient.BaseURL = base.BaseURL
+	a.appClient.UploadURL = base.UploadURL
+	return a, nil
+}
+
+func parsePrivateKey(pemKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("invalid GitHub App private key - expected PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid GitHub App private key")
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid GitHub App private key - must be RSA")
+	}
+	return key, nil
+}
+
+// jwt - a token authenticating as the App, valid for 9 minutes (GitHub allows
+// at most 10). Issued a minute in the past, to allow for clock drift.
+func (a *appAuth) jwt() (string, error) {
+	now := a.now()
+	token, err := signJWT(a.key, map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.id, 10),
+	})
+	return token, errors.Wrap(err, "failed to sign App JWT")
+}
+
+// signJWT - an RS256 JWT with the claims, signed with the key
+func signJWT(key *rsa.PrivateKey, claims map[string]interface{}) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(body)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// client - a client authenticated as the installation
+func (a *appAuth) client(installationID int64) *github.Client {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.clients[installationID]; ok {
+		return c
+	}
+	ts := oauth2.ReuseTokenSource(nil, &installationTokenSource{a, installationID})
+	c := github.NewClient(&http.Client{Transport: a.quota.transport(&oauth2.Transport{Source: ts, Base: a.transport})})
+	c.BaseURL = a.appClient.BaseURL
+	c.UploadURL = a.appClient.UploadURL
+	a.clients[installationID] = c
+	return c
+}
+
+// configureWebhook points the App's webhook at the URL, with the secret
+func (a *appAuth) configureWebhook(ctx context.Context, url, secret string) error {
+	config := map[string]string{
+		"url":          url,
+		"content_type": "json",
+		"secret":       secret,
+	}
+	err := withRetries(ctx, "configure App webhook", func() (*github.Response, error) {
+		req, err := a.appClient.NewRequest("PATCH", "app/hook/config", config)
+		if err != nil {
+			return nil, err
+		}
+		return a.appClient.Do(ctx, req, nil)
+	})
+	return errors.Wrap(err, "failed to configure the GitHub App's webhook")
+}
+
+type installationTokenSource struct {
+	a  *appAuth
+	id int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	tok, _, err := s.a.appClient.Apps.CreateInstallationToken(ctx, s.id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create token for installation %d", s.id)
+	}
+	return &oauth2.Token{
+		AccessToken: tok.GetToken(),
+		TokenType:   "token",
+		// refresh a little early, so tokens don't expire mid-request
+		Expiry: tok.GetExpiresAt().Add(-time.Minute),
+	}, nil
+}
+
+// jwtTransport authenticates requests as the App
+type jwtTransport struct {
+	a    *appAuth
+	base http.RoundTripper
+}
+
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.a.jwt()
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers mustn't modify the request
+	req2 := new(http.Request)
+	*req2 = *req
+	req2.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		req2.Header[k] = v
+	}
+	req2.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req2)
+}
+
+// InstallationClient - a GitHub client authenticated as the given App
+// installation. Requires Options.AppID and Options.AppPrivateKey. Tokens are
+// cached and refreshed automatically.
+//
+// Handlers don't usually need this - in App mode, GitHubClient returns a
+// client for the installation the event came from.
+func (r *Responder) InstallationClient(installationID int64) (*github.Client, error) {
+	if r.app == nil {
+		return nil, errors.New("GitHub App authentication not configured - need an App ID and private key")
+	}
+	return r.app.client(installationID), nil
+}
+
+// clientFor - the client handlers should use for an event - scoped to the
+// event's installation, when authenticating as an App
+func (r *Responder) clientFor(installationID int64) *github.Client {
+	if r.app != nil && installationID != 0 {
+		return r.app.client(installationID)
+	}
+	return r.ghclient
+}
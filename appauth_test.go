@@ -0,0 +1,132 @@
+package responder
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAppKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, p := testAppKey(t)
+	parsed, err := parsePrivateKey(p)
+	assert.NoError(t, err)
+	assert.Equal(t, key.N, parsed.N)
+
+	_, err = parsePrivateKey([]byte("not pem"))
+	assert.Error(t, err)
+	_, err = parsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("junk")}))
+	assert.Error(t, err)
+}
+
+func TestAppJWT(t *testing.T) {
+	key, p := testAppKey(t)
+	a, err := newAppAuth(42, p, github.NewClient(nil), nil)
+	assert.NoError(t, err)
+	now := time.Unix(1500000000, 0)
+	a.now = func() time.Time { return now }
+
+	token, err := a.jwt()
+	assert.NoError(t, err)
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig))
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+	claims := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(b, &claims))
+	assert.Equal(t, "42", claims["iss"])
+	assert.Equal(t, float64(now.Unix()-60), claims["iat"])
+	assert.Equal(t, float64(now.Unix()+540), claims["exp"])
+}
+
+func TestInstallationClient(t *testing.T) {
+	var tokens int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/app/installations/7/access_tokens":
+			assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "Bearer "))
+			n := atomic.AddInt32(&tokens, 1)
+			fmt.Fprintf(w, `{"token":"tok%d","expires_at":"%s"}`, n, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case req.URL.Path == "/repos/o/r":
+			assert.Equal(t, "token tok1", req.Header.Get("Authorization"))
+			fmt.Fprint(w, `{"name":"r"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	base := github.NewClient(nil)
+	base.BaseURL, _ = url.Parse(srv.URL + "/")
+	_, p := testAppKey(t)
+	a, err := newAppAuth(42, p, base, nil)
+	assert.NoError(t, err)
+	r := &Responder{ghclient: base, app: a}
+
+	assert.Equal(t, base, r.clientFor(0))
+	c := r.clientFor(7)
+	assert.NotEqual(t, base, c)
+	c2, err := r.InstallationClient(7)
+	assert.NoError(t, err)
+	assert.Equal(t, c, c2)
+
+	for i := 0; i < 2; i++ {
+		repo, _, err := c.Repositories.Get(context.Background(), "o", "r")
+		assert.NoError(t, err)
+		assert.Equal(t, "r", repo.GetName())
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokens), "token should be cached")
+
+	_, err = (&Responder{}).InstallationClient(7)
+	assert.Error(t, err)
+}
+
+func TestConfigureWebhook(t *testing.T) {
+	var config map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "PATCH", req.Method)
+		assert.Equal(t, "/app/hook/config", req.URL.Path)
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "Bearer "))
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&config))
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+
+	base := github.NewClient(nil)
+	base.BaseURL, _ = url.Parse(srv.URL + "/")
+	_, p := testAppKey(t)
+	a, err := newAppAuth(42, p, base, nil)
+	assert.NoError(t, err)
+
+	err = a.configureWebhook(context.Background(), "https://example.com/app", "s3cr3t")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"url": "https://example.com/app", "content_type": "json", "secret": "s3cr3t"}, config)
+}
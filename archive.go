@@ -0,0 +1,140 @@
+package responder
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// KeyWrapper - encrypts the per-file data keys of encrypted delivery files
+// (see RecordEncryptedDeliveries). Implement this to wrap keys with a KMS.
+type KeyWrapper interface {
+	WrapKey(key []byte) ([]byte, error)
+}
+
+// KeyUnwrapper - decrypts data keys wrapped by a KeyWrapper
+type KeyUnwrapper interface {
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// RSAKeyWrapper - wraps data keys with an RSA public key (RSA-OAEP), so the
+// responder can archive deliveries without being able to read them back
+type RSAKeyWrapper struct {
+	Key *rsa.PublicKey
+}
+
+// RSAKeyUnwrapper - unwraps data keys wrapped by an RSAKeyWrapper
+type RSAKeyUnwrapper struct {
+	Key *rsa.PrivateKey
+}
+
+// NewRSAKeyWrapper - a KeyWrapper for the given PEM-encoded RSA public key.
+// A private key is also accepted, in which case its public half is used.
+func NewRSAKeyWrapper(pemKey []byte) (*RSAKeyWrapper, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("invalid archive key - expected PEM")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return &RSAKeyWrapper{key}, nil
+	}
+	if k, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		key, ok := k.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("invalid archive key - must be RSA")
+		}
+		return &RSAKeyWrapper{key}, nil
+	}
+	key, err := parsePrivateKey(pemKey)
+	if err != nil {
+		return nil, errors.New("invalid archive key - expected an RSA public or private key")
+	}
+	return &RSAKeyWrapper{&key.PublicKey}, nil
+}
+
+// NewRSAKeyUnwrapper - a KeyUnwrapper for the given PEM-encoded RSA private
+// key
+func NewRSAKeyUnwrapper(pemKey []byte) (*RSAKeyUnwrapper, error) {
+	key, err := parsePrivateKey(pemKey)
+	if err != nil {
+		return nil, errors.New("invalid archive key - expected an RSA private key")
+	}
+	return &RSAKeyUnwrapper{key}, nil
+}
+
+// WrapKey - encrypt the data key with the public key
+func (w *RSAKeyWrapper) WrapKey(key []byte) ([]byte, error) {
+	return rsa.EncryptOAEP(sha256.New(), rand.Reader, w.Key, key, nil)
+}
+
+// UnwrapKey - decrypt the data key with the private key
+func (u *RSAKeyUnwrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, u.Key, wrapped, nil)
+}
+
+// sealedDelivery - an envelope-encrypted delivery file. The delivery is
+// encrypted with a random AES-256-GCM data key, which is stored wrapped by a
+// KeyWrapper.
+type sealedDelivery struct {
+	EncryptedKey []byte `json:"encrypted_key"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// seal encrypts a delivery file's contents
+func seal(w KeyWrapper, plaintext []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, errors.Wrap(err, "failed to generate data key")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	s := sealedDelivery{Nonce: make([]byte, gcm.NonceSize())}
+	if _, err = io.ReadFull(rand.Reader, s.Nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	s.Ciphertext = gcm.Seal(nil, s.Nonce, plaintext, nil)
+	s.EncryptedKey, err = w.WrapKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to wrap data key")
+	}
+	return json.Marshal(s)
+}
+
+// open decrypts a sealed delivery file's contents
+func (s *sealedDelivery) open(u KeyUnwrapper) ([]byte, error) {
+	key, err := u.UnwrapKey(s.EncryptedKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data key")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	plaintext, err := gcm.Open(nil, s.Nonce, s.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data key")
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,97 @@
+package responder
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRSAKeyWrapper(t *testing.T) {
+	key, priv := testAppKey(t)
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	for _, p := range [][]byte{
+		priv,
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}),
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}),
+	} {
+		w, err := NewRSAKeyWrapper(p)
+		assert.NoError(t, err)
+		assert.Equal(t, key.N, w.Key.N)
+	}
+
+	_, err = NewRSAKeyWrapper([]byte("not pem"))
+	assert.Error(t, err)
+	_, err = NewRSAKeyUnwrapper(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+	assert.Error(t, err)
+}
+
+func TestSealOpen(t *testing.T) {
+	_, priv := testAppKey(t)
+	w, err := NewRSAKeyWrapper(priv)
+	assert.NoError(t, err)
+	u, err := NewRSAKeyUnwrapper(priv)
+	assert.NoError(t, err)
+
+	b, err := seal(w, []byte("hello"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "hello")
+
+	s := &sealedDelivery{}
+	assert.NoError(t, json.Unmarshal(b, s))
+	plain, err := s.open(u)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(plain))
+
+	// tampering is detected
+	s.Ciphertext[0] ^= 1
+	_, err = s.open(u)
+	assert.Error(t, err)
+
+	// a different key can't open it
+	_, other := testAppKey(t)
+	ou, err := NewRSAKeyUnwrapper(other)
+	assert.NoError(t, err)
+	s.Ciphertext[0] ^= 1
+	_, err = s.open(ou)
+	assert.Error(t, err)
+}
+
+func TestRecordEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, priv := testAppKey(t)
+	w, err := NewRSAKeyWrapper(priv)
+	assert.NoError(t, err)
+	u, err := NewRSAKeyUnwrapper(priv)
+	assert.NoError(t, err)
+
+	r := &Responder{}
+	assert.NoError(t, r.RecordEncryptedDeliveries(dir, w))
+	payload := []byte(`{"secret":"private-repo-data"}`)
+	id, err := r.recorder.Put(&Delivery{EventType: "push", DeliveryID: "abc", Headers: http.Header{}, Payload: payload})
+	assert.NoError(t, err)
+	path := filepath.Join(dir, id)
+
+	raw, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "private-repo-data")
+
+	_, err = ReadDelivery(path)
+	assert.Error(t, err)
+
+	d, err := OpenDelivery(path, u)
+	assert.NoError(t, err)
+	assert.Equal(t, "push", d.EventType)
+	assert.JSONEq(t, string(payload), string(d.Payload))
+}
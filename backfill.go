@@ -0,0 +1,106 @@
+package responder
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// backfillNamespace - the state store namespace of backfill checkpoints
+	backfillNamespace = "responder.backfill"
+	// backfillMaxAge - how far back Backfill looks, without a checkpoint.
+	// GitHub only redelivers deliveries from the last 3 days.
+	backfillMaxAge = 72 * time.Hour
+)
+
+// Backfill - ask GitHub to redeliver the deliveries to the watched repos'
+// hooks that failed - typically because the Responder was down - since the
+// last backfill. Without a state store to keep the last backfill's
+// checkpoint in (see SetStateStore), it looks back 3 days, as far as GitHub
+// allows. Deliveries that were since redelivered successfully are skipped.
+//
+// Only hooks that outlive the Responder have missed deliveries, so this is
+// for use with Options.AdoptHooks. Call it once listening, so the
+// redeliveries are received - RegisterAndListen does, with Options.Backfill.
+//
+// Returns the number of deliveries redelivered. Failures don't stop the
+// backfilling of other repos, and are returned as a *RedeliveryError.
+func (r *Responder) Backfill(ctx context.Context) (int, error) {
+	rerr := r.eachOwnHook(ctx, func(repo repository, hookID int64) (int, error) {
+		return r.backfillHook(ctx, repo, hookID)
+	})
+	log.Info().
+		Int("redelivered", rerr.Redelivered).
+		Int("failed", len(rerr.Failed)).
+		Msg("Backfilled missed deliveries")
+	if len(rerr.Failed) > 0 {
+		return rerr.Redelivered, rerr
+	}
+	return rerr.Redelivered, nil
+}
+
+// backfillHook redelivers the hook's failed deliveries since its checkpoint,
+// then moves the checkpoint to its latest delivery
+func (r *Responder) backfillHook(ctx context.Context, repo repository, hookID int64) (int, error) {
+	key := repo.owner + "/" + repo.name + "/" + strconv.FormatInt(hookID, 10)
+	checkpoint := r.backfillCheckpoint(key)
+	cutoff := r.clock().Add(-backfillMaxAge)
+
+	var latest int64
+	handled := map[string]bool{}
+	var failed []hookDelivery
+	err := r.eachHookDelivery(ctx, repo, hookID, func(d hookDelivery) bool {
+		if d.ID <= checkpoint || d.DeliveredAt.Before(cutoff) {
+			return false
+		}
+		if d.ID > latest {
+			latest = d.ID
+		}
+		// only the latest attempt of each delivery counts
+		if !handled[d.GUID] && !d.succeeded() {
+			failed = append(failed, d)
+		}
+		handled[d.GUID] = true
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, d := range failed {
+		err = r.redeliverHookDelivery(ctx, repo, hookID, d)
+		if err != nil {
+			// the checkpoint isn't moved, so it's tried again next time
+			return i, err
+		}
+	}
+	if latest > checkpoint {
+		r.setBackfillCheckpoint(key, latest)
+	}
+	return len(failed), nil
+}
+
+func (r *Responder) backfillCheckpoint(key string) int64 {
+	if r.state == nil {
+		return 0
+	}
+	b, err := r.state.Get(backfillNamespace, key)
+	if err != nil || b == nil {
+		return 0
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+func (r *Responder) setBackfillCheckpoint(key string, id int64) {
+	if r.state == nil {
+		return
+	}
+	err := r.state.Put(backfillNamespace, key, []byte(strconv.FormatInt(id, 10)))
+	if err != nil {
+		log.Warn().Err(err).Str("hook", key).Msg("failed to save backfill checkpoint")
+	}
+}
@@ -0,0 +1,64 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfill(t *testing.T) {
+	var mu sync.Mutex
+	var redelivered []string
+	recent := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	old := time.Now().Add(-100 * time.Hour).Format(time.RFC3339)
+	var base string
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method + " " + req.URL.Path {
+		case "GET /repos/a/b/hooks":
+			fmt.Fprint(w, `[{"id":1,"config":{"url":"https://example.com/gh-callback/x"}}]`)
+		case "GET /repos/a/b/hooks/1/deliveries":
+			if req.URL.Query().Get("cursor") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%srepos/a/b/hooks/1/deliveries?cursor=2>; rel="next"`, base))
+				fmt.Fprintf(w, `[
+					{"id":16,"guid":"g5","delivered_at":%q,"status_code":200},
+					{"id":15,"guid":"g4","delivered_at":%q,"status_code":502},
+					{"id":14,"guid":"g3","delivered_at":%q,"status_code":200},
+					{"id":13,"guid":"g3","delivered_at":%q,"status_code":502}
+				]`, recent, recent, recent, recent)
+				return
+			}
+			fmt.Fprintf(w, `[
+				{"id":12,"guid":"g2","delivered_at":%q,"status_code":0},
+				{"id":11,"guid":"g1","delivered_at":%q,"status_code":502}
+			]`, recent, old)
+		case "POST /repos/a/b/hooks/1/deliveries/15/attempts", "POST /repos/a/b/hooks/1/deliveries/12/attempts":
+			redelivered = append(redelivered, req.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}, "a/b")
+	base = r.ghclient.BaseURL.String()
+	s := NewMemoryStateStore()
+	r.SetStateStore(s)
+
+	n, err := r.Backfill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"/repos/a/b/hooks/1/deliveries/15/attempts", "/repos/a/b/hooks/1/deliveries/12/attempts"}, redelivered)
+
+	// nothing new since the checkpoint
+	b, _ := s.Get(backfillNamespace, "a/b/1")
+	assert.Equal(t, "16", string(b))
+	n, err = r.Backfill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,152 @@
+package responder
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CallbackPathStrategy - how the callback URL paths hooks deliver to are
+// chosen (see Options.CallbackPathStrategy)
+type CallbackPathStrategy string
+
+const (
+	// RandomCallbackPaths - a new random path for each endpoint on every
+	// run, so hooks from previous runs can't be reused. The default without
+	// a HookSecret or CallbackPath.
+	RandomCallbackPaths CallbackPathStrategy = "random"
+	// StableCallbackPaths - a path for each endpoint derived from
+	// HookSecret, the same on every run. The default with a HookSecret.
+	StableCallbackPaths CallbackPathStrategy = "stable"
+	// PerRepoCallbackPaths - like StableCallbackPaths, but each repo's hook
+	// gets its own path, derived from HookSecret and the repo
+	PerRepoCallbackPaths CallbackPathStrategy = "per-repo"
+	// FixedCallbackPaths - the primary endpoint is served at CallbackPath,
+	// and endpoints added with AddEndpoint at CallbackPath-1,
+	// CallbackPath-2, etc. The default with a CallbackPath.
+	FixedCallbackPaths CallbackPathStrategy = "fixed"
+)
+
+// CallbackPathStrategies - the valid callback path strategies
+var CallbackPathStrategies = []CallbackPathStrategy{RandomCallbackPaths, StableCallbackPaths, PerRepoCallbackPaths, FixedCallbackPaths}
+
+// pathStrategyOrDefault - the configured strategy, or the default for the
+// Responder's secret and path
+func (r *Responder) pathStrategyOrDefault() CallbackPathStrategy {
+	switch {
+	case r.pathStrategy != "":
+		return r.pathStrategy
+	case r.callbackPath != "":
+		return FixedCallbackPaths
+	case r.hookSecret != "":
+		return StableCallbackPaths
+	}
+	return RandomCallbackPaths
+}
+
+// derive - a hex string derived from the hook secret for the purpose and
+// i'th endpoint, the same across restarts
+func (r *Responder) derive(purpose string, i int) string {
+	mac := hmac.New(sha256.New, []byte(r.hookSecret))
+	fmt.Fprintf(mac, "%s/%d", purpose, i)
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// endpointSecret - the i'th endpoint's hook secret: the configured one for
+// the primary endpoint, derived from it for the rest, or random without one
+func (r *Responder) endpointSecret(i int) string {
+	switch {
+	case r.hookSecret == "":
+		return r.randomHex(16)
+	case i == 0:
+		return r.hookSecret
+	}
+	return r.derive("secret", i)
+}
+
+// endpointURL - the i'th endpoint's callback URL on the primary domain,
+// following the callback path strategy
+func (r *Responder) endpointURL(i int) string {
+	switch r.pathStrategyOrDefault() {
+	case FixedCallbackPaths:
+		path := r.callbackPath
+		if i > 0 {
+			path += "-" + strconv.Itoa(i)
+		}
+		return callbackScheme() + r.domain + path
+	case StableCallbackPaths, PerRepoCallbackPaths:
+		return r.callbackPrefix(r.domain) + r.derive("path", i)[:32]
+	}
+	return buildCallbackURL(r.domain, r.pathPrefix, r.newID())
+}
+
+// repoCallbackURL - the callback URL of the endpoint's hook in the repo on
+// the primary domain. Only the per-repo strategy gives repos their own
+// path.
+func (r *Responder) repoCallbackURL(ep *endpoint, repo repository) string {
+	if r.pathStrategyOrDefault() != PerRepoCallbackPaths {
+		return ep.callbackURL
+	}
+	for i, e := range r.endpoints {
+		if e == ep {
+			return r.callbackPrefix(r.domain) + r.derive("path/"+repoKey(repo), i)[:32]
+		}
+	}
+	return ep.callbackURL
+}
+
+// repoKey - what identifies the repo in its derived path: its ID when it
+// was given as one, so the path doesn't depend on resolving it, or its
+// (case insensitive) full name
+func repoKey(repo repository) string {
+	if repo.id != 0 {
+		return strconv.FormatInt(repo.id, 10)
+	}
+	return strings.ToLower(repo.owner + "/" + repo.name)
+}
+
+// endpointPaths - the URL paths the endpoint is served at: its own, and
+// with the per-repo strategy, each of its repos'
+func (r *Responder) endpointPaths(ep *endpoint) []string {
+	paths := []string{getPath(ep.callbackURL)}
+	if r.pathStrategyOrDefault() != PerRepoCallbackPaths {
+		return paths
+	}
+	for _, repo := range ep.repos {
+		paths = append(paths, getPath(r.repoCallbackURL(ep, repo)))
+	}
+	return paths
+}
+
+// validateCallbackPath checks the callback path strategy has what it needs
+func (o Options) validateCallbackPath() error {
+	switch o.CallbackPathStrategy {
+	case RandomCallbackPaths:
+	case StableCallbackPaths, PerRepoCallbackPaths:
+		if o.HookSecret == "" {
+			return errors.Errorf("the %s callback path strategy needs a hook secret", o.CallbackPathStrategy)
+		}
+	case FixedCallbackPaths:
+		if o.CallbackPath == "" {
+			return errors.New("the fixed callback path strategy needs a callback path")
+		}
+	default:
+		return errors.Errorf("unknown callback path strategy %q - must be one of %v", o.CallbackPathStrategy, CallbackPathStrategies)
+	}
+	if o.CallbackPath == "" {
+		return nil
+	}
+	if o.CallbackPathStrategy != FixedCallbackPaths {
+		return errors.Errorf("a callback path can only be given with the fixed callback path strategy, not %s", o.CallbackPathStrategy)
+	}
+	u, err := url.Parse(o.CallbackPath)
+	if err != nil || !strings.HasPrefix(o.CallbackPath, "/") || u.EscapedPath() != o.CallbackPath {
+		return errors.Errorf("invalid callback path %q - must be a plain URL path like '/webhooks/github'", o.CallbackPath)
+	}
+	return nil
+}
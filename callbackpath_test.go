@@ -0,0 +1,74 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallbackPathOptions(t *testing.T) {
+	o := Options{Domain: "example.com", Repos: []string{"a/b"}}
+	assert.Equal(t, RandomCallbackPaths, o.withDefaults().CallbackPathStrategy)
+	o.HookSecret = "s3cret"
+	assert.Equal(t, StableCallbackPaths, o.withDefaults().CallbackPathStrategy)
+	o.CallbackPath = "/webhooks/github"
+	assert.Equal(t, FixedCallbackPaths, o.withDefaults().CallbackPathStrategy)
+	assert.NoError(t, o.Validate())
+
+	o.CallbackPathStrategy = PerRepoCallbackPaths
+	assert.Contains(t, o.Validate().Error(), "fixed callback path strategy")
+	o.CallbackPath = ""
+	assert.NoError(t, o.Validate())
+	o.HookSecret = ""
+	assert.Contains(t, o.Validate().Error(), "hook secret")
+	o.CallbackPathStrategy = FixedCallbackPaths
+	assert.Contains(t, o.Validate().Error(), "needs a callback path")
+	o.CallbackPathStrategy = "uuid"
+	assert.Contains(t, o.Validate().Error(), "unknown callback path strategy")
+}
+
+func TestFixedCallbackPaths(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/", callbackPath: "/webhooks/github"}
+	primary := r.newEndpoint(nil, nil)
+	r.endpoints = []*endpoint{primary}
+	other := r.newEndpoint(nil, nil)
+
+	assert.Equal(t, "https://example.com/webhooks/github", primary.callbackURL)
+	assert.Equal(t, "https://example.com/webhooks/github-1", other.callbackURL)
+	assert.True(t, r.ownHook(hookTo(other.callbackURL)))
+	assert.False(t, r.ownHook(hookTo("https://example.com/other")))
+}
+
+func TestPerRepoCallbackPaths(t *testing.T) {
+	ab, _ := parseRepo("a/b")
+	cd, _ := parseRepo("c/d")
+	r := &Responder{domain: "example.com", pathPrefix: "/", hookSecret: "s3cret", pathStrategy: PerRepoCallbackPaths}
+	ep := r.newEndpoint([]repository{ab, cd}, []HookHandler{func(context.Context, string, string, []byte) {}})
+	r.endpoints = []*endpoint{ep}
+
+	abURL, err := r.callbackFor(ep, ab)
+	assert.NoError(t, err)
+	cdURL, _ := r.callbackFor(ep, cd)
+	assert.NotEqual(t, abURL, cdURL)
+	assert.NotEqual(t, ep.callbackURL, abURL)
+	assert.True(t, r.ownHook(hookTo(abURL)))
+
+	// the same on restart, whatever the case of the repo
+	r2 := &Responder{domain: "example.com", pathPrefix: "/", hookSecret: "s3cret", pathStrategy: PerRepoCallbackPaths}
+	AB, _ := parseRepo("A/B")
+	ep2 := r2.newEndpoint([]repository{AB}, nil)
+	r2.endpoints = []*endpoint{ep2}
+	abURL2, _ := r2.callbackFor(ep2, AB)
+	assert.Equal(t, abURL, abURL2)
+
+	// each repo's path is served
+	h := r.Handler()
+	for _, u := range []string{abURL, cdURL} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, u, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, u)
+	}
+}
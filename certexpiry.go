@@ -0,0 +1,124 @@
+package responder
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"sync"
+	"time"
+
+	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// certCheckInterval - how often the serving certificate's expiry is
+	// checked
+	certCheckInterval = time.Hour
+	// certNotifyInterval - how often OnCertExpiring hooks are run, while the
+	// certificate is expiring
+	certNotifyInterval = 24 * time.Hour
+)
+
+// CertExpiryHook - a function run when the serving certificate is about to
+// expire. See OnCertExpiring.
+type CertExpiryHook func(ctx context.Context, domain string, notAfter time.Time)
+
+// OnCertExpiring - run f when the serving certificate is within the
+// expiry warning window (see Options.CertExpiryWarning), at most once a day.
+// Certificates are normally renewed 30 days before they expire, so this
+// means renewal has been failing - e.g. page someone, before an expired
+// certificate makes every delivery fail.
+func (r *Responder) OnCertExpiring(f CertExpiryHook) {
+	r.certWatch.hooks = append(r.certWatch.hooks, f)
+}
+
+var certExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "tls",
+	Name:      "certificate_expiry_timestamp_seconds",
+	Help:      "The time the serving certificate expires, as a Unix timestamp, by domain.",
+}, []string{"domain"})
+
+// certWatcher watches the serving certificate's expiry
+type certWatcher struct {
+	domain string
+	window time.Duration
+	hooks  []CertExpiryHook
+	now    func() time.Time
+	// load returns the certificate, as stored by certmagic
+	load func(domain string) ([]byte, error)
+
+	mu       sync.Mutex
+	notified time.Time
+}
+
+func loadCertmagicCert(domain string) ([]byte, error) {
+	return certmagic.DefaultStorage.Load(certmagic.StorageKeys.SiteCert(certmagic.CA, domain))
+}
+
+// run checks the certificate every certCheckInterval, until the context is
+// cancelled
+func (w *certWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *certWatcher) check(ctx context.Context) {
+	notAfter, err := w.expiry()
+	if err != nil {
+		// the certificate may not have been obtained yet
+		log.Debug().Err(err).Str("domain", w.domain).Msg("couldn't check certificate expiry")
+		return
+	}
+	certExpiry.WithLabelValues(w.domain).Set(float64(notAfter.Unix()))
+
+	now := w.now()
+	left := notAfter.Sub(now)
+	if left > w.window {
+		return
+	}
+	log.Warn().
+		Str("domain", w.domain).
+		Time("notAfter", notAfter).
+		Dur("remaining", left).
+		Msg("certificate expires soon - renewal may be failing")
+
+	w.mu.Lock()
+	due := now.Sub(w.notified) >= certNotifyInterval
+	if due {
+		w.notified = now
+	}
+	w.mu.Unlock()
+	if due {
+		for _, h := range w.hooks {
+			h(ctx, w.domain, notAfter)
+		}
+	}
+}
+
+// expiry returns when the stored certificate expires
+func (w *certWatcher) expiry() (time.Time, error) {
+	b, err := w.load(w.domain)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to load certificate")
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return time.Time{}, errors.New("invalid certificate - expected PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid certificate")
+	}
+	return cert.NotAfter, nil
+}
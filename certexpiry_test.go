@@ -0,0 +1,72 @@
+package responder
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCert(t *testing.T, notAfter time.Time) []byte {
+	key, _ := testAppKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCertWatcher(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := now.Add(60 * 24 * time.Hour)
+	var notified []time.Time
+	r := &Responder{}
+	r.OnCertExpiring(func(ctx context.Context, domain string, na time.Time) {
+		notified = append(notified, na)
+	})
+	w := &r.certWatch
+	w.domain = "example.com"
+	w.window = 14 * 24 * time.Hour
+	w.now = func() time.Time { return now }
+	w.load = func(domain string) ([]byte, error) {
+		assert.Equal(t, "example.com", domain)
+		return testCert(t, notAfter), nil
+	}
+
+	exp, err := w.expiry()
+	assert.NoError(t, err)
+	assert.True(t, notAfter.Equal(exp))
+
+	w.check(context.Background())
+	assert.Empty(t, notified)
+
+	// renewal failed for a long time
+	now = now.Add(50 * 24 * time.Hour)
+	w.check(context.Background())
+	assert.Len(t, notified, 1)
+	// not again until a day has passed
+	now = now.Add(time.Hour)
+	w.check(context.Background())
+	assert.Len(t, notified, 1)
+	now = now.Add(24 * time.Hour)
+	w.check(context.Background())
+	assert.Len(t, notified, 2)
+
+	w.load = func(string) ([]byte, error) { return nil, errors.New("not found") }
+	w.check(context.Background())
+	assert.Len(t, notified, 2)
+	w.load = func(string) ([]byte, error) { return []byte("junk"), nil }
+	_, err = w.expiry()
+	assert.Error(t, err)
+}
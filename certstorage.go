@@ -0,0 +1,98 @@
+package responder
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mholt/certmagic"
+)
+
+const (
+	// certLockTTL - how long a certificate storage lock is held before it's
+	// considered abandoned (e.g. by a crashed replica), and can be taken
+	certLockTTL = 10 * time.Minute
+	// certLockPoll - how often a held lock is checked while waiting for it
+	certLockPoll = time.Second
+)
+
+// certStorage - the certificate storage configured by the options, or nil
+// for certmagic's default (a directory in the user's home)
+func (o Options) certStorage() certmagic.Storage {
+	switch {
+	case o.CertStorage != nil:
+		return o.CertStorage
+	case o.CertStoragePath != "":
+		return &certmagic.FileStorage{Path: o.CertStoragePath}
+	}
+	return nil
+}
+
+// notExistError - a missing key. certmagic.ErrNotExist is satisfied by any
+// error, so it can't tell missing keys from other errors.
+type notExistError struct {
+	key string
+}
+
+func (e *notExistError) Error() string {
+	return e.key + " not found"
+}
+
+// notExist - the error for a missing key
+func notExist(key string) error {
+	return &notExistError{key}
+}
+
+func isNotExist(err error) bool {
+	_, ok := err.(*notExistError)
+	return ok
+}
+
+// listKeys - the keys matching the prefix, for Storage.List: all keys under
+// it when recursive, or otherwise only its immediate children (which may be
+// "directories" of further keys)
+func listKeys(keys []string, prefix string, recursive bool) []string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	seen := map[string]bool{}
+	var listed []string
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if !recursive {
+			if i := strings.Index(k[len(prefix):], "/"); i >= 0 {
+				k = k[:len(prefix)+i]
+			}
+		}
+		if !seen[k] {
+			seen[k] = true
+			listed = append(listed, k)
+		}
+	}
+	sort.Strings(listed)
+	return listed
+}
+
+// isDir - whether the key only holds other keys, going by all the keys
+func isDir(keys []string, key string) bool {
+	for _, k := range keys {
+		if strings.HasPrefix(k, key+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForLock polls tryLock until the lock is taken, or tryLock fails
+func waitForLock(tryLock func() (bool, error)) error {
+	for {
+		ok, err := tryLock()
+		if ok || err != nil {
+			return err
+		}
+		time.Sleep(certLockPoll)
+	}
+}
@@ -0,0 +1,252 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
+)
+
+// dynamoCertStorage - certmagic storage in a DynamoDB table, with a string
+// partition key named "Key". Values are kept under certs/, and locks under
+// locks/, expiring after certLockTTL.
+type dynamoCertStorage struct {
+	awsCredentials
+	table    string
+	region   string
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+	owner    string
+}
+
+// NewDynamoDBCertStorage - certificate storage (see Options.CertStorage) in
+// the DynamoDB table in the AWS region, so replicas can share certificates.
+// The table needs a string partition key named "Key", and the credentials
+// need the dynamodb:GetItem, PutItem, DeleteItem, and Scan permissions on
+// it. The session token is only needed for temporary credentials.
+func NewDynamoDBCertStorage(table, region, accessKeyID, secretAccessKey, sessionToken string) certmagic.Storage {
+	return &dynamoCertStorage{
+		awsCredentials: awsCredentials{accessKeyID, secretAccessKey, sessionToken},
+		table:          table,
+		region:         region,
+		endpoint:       "https://dynamodb." + region + ".amazonaws.com/",
+		client:         &http.Client{Timeout: 30 * time.Second},
+		now:            time.Now,
+		owner:          randomOwner(),
+	}
+}
+
+// dynamoAttr - a DynamoDB attribute value
+type dynamoAttr struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+	B []byte  `json:"B,omitempty"`
+}
+
+type dynamoItem map[string]dynamoAttr
+
+func dynamoS(s string) dynamoAttr { return dynamoAttr{S: &s} }
+func dynamoN(n int64) dynamoAttr {
+	s := strconv.FormatInt(n, 10)
+	return dynamoAttr{N: &s}
+}
+
+// dynamoError - an error response from DynamoDB
+type dynamoError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *dynamoError) Error() string {
+	return "dynamodb: " + e.Type[strings.LastIndex(e.Type, "#")+1:] + ": " + e.Message
+}
+
+// conditionFailed - whether the error is from a failed condition
+func conditionFailed(err error) bool {
+	e, ok := errors.Cause(err).(*dynamoError)
+	return ok && strings.HasSuffix(e.Type, "#ConditionalCheckFailedException")
+}
+
+// do - a signed DynamoDB API call, decoding the response into out (if given)
+func (s *dynamoCertStorage) do(op string, in map[string]interface{}, out interface{}) error {
+	in["TableName"] = s.table
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+op)
+	s.sign(req, body, s.now(), s.region, "dynamodb")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "DynamoDB %s failed", op)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		e := &dynamoError{}
+		if json.NewDecoder(resp.Body).Decode(e) != nil || e.Type == "" {
+			return errors.Errorf("DynamoDB %s failed: %s", op, resp.Status)
+		}
+		return e
+	}
+	if out == nil {
+		return nil
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(out), "invalid response to DynamoDB %s", op)
+}
+
+func dataItemKey(key string) dynamoItem { return dynamoItem{"Key": dynamoS("certs/" + key)} }
+
+func (s *dynamoCertStorage) Store(key string, value []byte) error {
+	item := dataItemKey(key)
+	item["Value"] = dynamoAttr{B: value}
+	item["Modified"] = dynamoN(s.now().UnixNano())
+	err := s.do("PutItem", map[string]interface{}{"Item": item}, nil)
+	return errors.Wrapf(err, "failed to store %s", key)
+}
+
+func (s *dynamoCertStorage) get(key string) (dynamoItem, error) {
+	var out struct {
+		Item dynamoItem
+	}
+	err := s.do("GetItem", map[string]interface{}{"Key": dataItemKey(key), "ConsistentRead": true}, &out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s", key)
+	}
+	if out.Item == nil {
+		return nil, notExist(key)
+	}
+	return out.Item, nil
+}
+
+func (s *dynamoCertStorage) Load(key string) ([]byte, error) {
+	item, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return item["Value"].B, nil
+}
+
+func (s *dynamoCertStorage) Delete(key string) error {
+	var out struct {
+		Attributes dynamoItem
+	}
+	err := s.do("DeleteItem", map[string]interface{}{"Key": dataItemKey(key), "ReturnValues": "ALL_OLD"}, &out)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete %s", key)
+	}
+	if out.Attributes == nil {
+		return notExist(key)
+	}
+	return nil
+}
+
+func (s *dynamoCertStorage) Exists(key string) bool {
+	_, err := s.get(key)
+	return err == nil
+}
+
+// keys - all stored keys
+func (s *dynamoCertStorage) keys() ([]string, error) {
+	in := map[string]interface{}{
+		"FilterExpression":          "begins_with(#k, :p)",
+		"ProjectionExpression":      "#k",
+		"ExpressionAttributeNames":  map[string]string{"#k": "Key"},
+		"ExpressionAttributeValues": dynamoItem{":p": dynamoS("certs/")},
+	}
+	var keys []string
+	for {
+		var out struct {
+			Items            []dynamoItem
+			LastEvaluatedKey dynamoItem
+		}
+		err := s.do("Scan", in, &out)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list keys")
+		}
+		for _, item := range out.Items {
+			if k := item["Key"].S; k != nil {
+				keys = append(keys, strings.TrimPrefix(*k, "certs/"))
+			}
+		}
+		if out.LastEvaluatedKey == nil {
+			return keys, nil
+		}
+		in["ExclusiveStartKey"] = out.LastEvaluatedKey
+	}
+}
+
+func (s *dynamoCertStorage) List(prefix string, recursive bool) ([]string, error) {
+	keys, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	listed := listKeys(keys, prefix, recursive)
+	if len(listed) == 0 {
+		return nil, notExist(prefix)
+	}
+	return listed, nil
+}
+
+func (s *dynamoCertStorage) Stat(key string) (certmagic.KeyInfo, error) {
+	item, err := s.get(key)
+	if isNotExist(err) {
+		keys, kerr := s.keys()
+		if kerr == nil && isDir(keys, key) {
+			return certmagic.KeyInfo{Key: key}, nil
+		}
+	}
+	if err != nil {
+		return certmagic.KeyInfo{}, err
+	}
+	info := certmagic.KeyInfo{Key: key, Size: int64(len(item["Value"].B)), IsTerminal: true}
+	if m := item["Modified"].N; m != nil {
+		n, _ := strconv.ParseInt(*m, 10, 64)
+		info.Modified = time.Unix(0, n)
+	}
+	return info, nil
+}
+
+func (s *dynamoCertStorage) Lock(key string) error {
+	return waitForLock(func() (bool, error) {
+		now := s.now()
+		err := s.do("PutItem", map[string]interface{}{
+			"Item": dynamoItem{
+				"Key":     dynamoS("locks/" + key),
+				"Owner":   dynamoS(s.owner),
+				"Expires": dynamoN(now.Add(certLockTTL).Unix()),
+			},
+			"ConditionExpression":       "attribute_not_exists(#k) OR #e < :now",
+			"ExpressionAttributeNames":  map[string]string{"#k": "Key", "#e": "Expires"},
+			"ExpressionAttributeValues": dynamoItem{":now": dynamoN(now.Unix())},
+		}, nil)
+		if conditionFailed(err) {
+			return false, nil
+		}
+		return err == nil, errors.Wrapf(err, "failed to lock %s", key)
+	})
+}
+
+func (s *dynamoCertStorage) Unlock(key string) error {
+	err := s.do("DeleteItem", map[string]interface{}{
+		"Key":                       dynamoItem{"Key": dynamoS("locks/" + key)},
+		"ConditionExpression":       "#o = :o",
+		"ExpressionAttributeNames":  map[string]string{"#o": "Owner"},
+		"ExpressionAttributeValues": dynamoItem{":o": dynamoS(s.owner)},
+	}, nil)
+	if conditionFailed(err) {
+		// expired, and maybe taken by another replica since
+		return nil
+	}
+	return errors.Wrapf(err, "failed to unlock %s", key)
+}
@@ -0,0 +1,102 @@
+package responder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDynamoDB - just enough of DynamoDB for dynamoCertStorage
+func fakeDynamoDB(t *testing.T, items map[string]dynamoItem) *httptest.Server {
+	var mu sync.Mutex
+	conditionFailed := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`))
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Contains(t, req.Header.Get("Authorization"), "/dynamodb/aws4_request")
+		var in struct {
+			TableName                 string
+			Item                      dynamoItem
+			Key                       dynamoItem
+			ConditionExpression       string
+			ExpressionAttributeValues dynamoItem
+			ReturnValues              string
+		}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&in))
+		assert.Equal(t, "certs", in.TableName)
+		out := map[string]interface{}{}
+		switch strings.TrimPrefix(req.Header.Get("X-Amz-Target"), "DynamoDB_20120810.") {
+		case "PutItem":
+			k := *in.Item["Key"].S
+			if held, ok := items[k]; ok && in.ConditionExpression != "" {
+				expires, _ := strconv.ParseInt(*held["Expires"].N, 10, 64)
+				now, _ := strconv.ParseInt(*in.ExpressionAttributeValues[":now"].N, 10, 64)
+				if expires >= now {
+					conditionFailed(w)
+					return
+				}
+			}
+			items[k] = in.Item
+		case "GetItem":
+			if item, ok := items[*in.Key["Key"].S]; ok {
+				out["Item"] = item
+			}
+		case "DeleteItem":
+			k := *in.Key["Key"].S
+			item, ok := items[k]
+			if o := in.ExpressionAttributeValues[":o"].S; o != nil && (!ok || *item["Owner"].S != *o) {
+				conditionFailed(w)
+				return
+			}
+			if ok && in.ReturnValues == "ALL_OLD" {
+				out["Attributes"] = item
+			}
+			delete(items, k)
+		case "Scan":
+			var found []dynamoItem
+			keys := make([]string, 0, len(items))
+			for k := range items {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if strings.HasPrefix(k, *in.ExpressionAttributeValues[":p"].S) {
+					found = append(found, dynamoItem{"Key": dynamoS(k)})
+				}
+			}
+			out["Items"] = found
+		default:
+			t.Errorf("unexpected operation %s", req.Header.Get("X-Amz-Target"))
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func TestDynamoDBCertStorage(t *testing.T) {
+	items := map[string]dynamoItem{}
+	srv := fakeDynamoDB(t, items)
+	defer srv.Close()
+
+	s := NewDynamoDBCertStorage("certs", "us-east-1", "AKID", "secret", "").(*dynamoCertStorage)
+	s.endpoint = srv.URL
+	testCertStorage(t, s)
+	assert.Equal(t, []byte("key"), items["certs/acme/ca/sites/a.com/a.com.key"]["Value"].B)
+
+	// a lock held by another replica isn't released
+	items["locks/b.com"] = dynamoItem{"Key": dynamoS("locks/b.com"), "Owner": dynamoS("other"), "Expires": dynamoN(0)}
+	assert.NoError(t, s.Unlock("b.com"))
+	assert.Contains(t, items, "locks/b.com")
+	// but it's taken once it's expired
+	assert.NoError(t, s.Lock("b.com"))
+	assert.Equal(t, s.owner, *items["locks/b.com"]["Owner"].S)
+}
@@ -0,0 +1,320 @@
+package responder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
+)
+
+// serviceAccountDir - where Kubernetes mounts the pod's service account
+// credentials
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+const (
+	k8sStorageLabel = "github-responder/cert-storage"
+	k8sLockLabel    = "github-responder/cert-storage-lock"
+	k8sKeyNote      = "github-responder/key"
+	k8sModifiedNote = "github-responder/modified"
+	k8sOwnerNote    = "github-responder/lock-owner"
+	k8sExpiresNote  = "github-responder/lock-expires"
+)
+
+// kubernetesCertStorage - certmagic storage in Kubernetes secrets, one per
+// key, named by a hash of the key (which is kept in an annotation).
+// Locks are secrets too, expiring after certLockTTL.
+type kubernetesCertStorage struct {
+	baseURL   string
+	namespace string
+	prefix    string
+	client    *http.Client
+	// token - reads the service account token, which is re-read for each
+	// request, as it's rotated
+	token func() ([]byte, error)
+	now   func() time.Time
+	owner string
+}
+
+// k8sSecret - the parts of a Kubernetes secret used
+type k8sSecret struct {
+	APIVersion string            `json:"apiVersion,omitempty"`
+	Kind       string            `json:"kind,omitempty"`
+	Metadata   k8sMeta           `json:"metadata"`
+	Data       map[string][]byte `json:"data,omitempty"`
+}
+
+type k8sMeta struct {
+	Name            string            `json:"name"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+}
+
+// NewKubernetesCertStorage - certificate storage (see
+// Options.CertStorage) in Kubernetes secrets in the namespace (by default,
+// the pod's own), so replicas can share certificates. It must run in a
+// pod, with a service account allowed to get, list, create, update, and
+// delete secrets. Secrets are named and labelled with the prefix, e.g.
+// "github-responder".
+func NewKubernetesCertStorage(namespace, prefix string) (certmagic.Storage, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("Kubernetes certificate storage only works in a pod - $KUBERNETES_SERVICE_HOST isn't set")
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the Kubernetes CA certificate")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid Kubernetes CA certificate")
+	}
+	if namespace == "" {
+		ns, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the pod's namespace")
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &kubernetesCertStorage{
+		baseURL:   "https://" + net.JoinHostPort(host, port),
+		namespace: namespace,
+		prefix:    prefix,
+		client:    &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		token:     func() ([]byte, error) { return ioutil.ReadFile(serviceAccountDir + "/token") },
+		now:       time.Now,
+		owner:     randomOwner(),
+	}, nil
+}
+
+// secretName - the name of the secret holding the key (or its lock)
+func (s *kubernetesCertStorage) secretName(kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return s.prefix + "-" + kind + "-" + hex.EncodeToString(sum[:])[:40]
+}
+
+// do - a Kubernetes API request for the namespace's secrets, decoding the
+// response into out (if given). Returns the response status.
+func (s *kubernetesCertStorage) do(method, path string, body, out interface{}) (int, error) {
+	var b []byte
+	if body != nil {
+		var err error
+		b, err = json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequest(method, s.baseURL+"/api/v1/namespaces/"+s.namespace+"/secrets"+path, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	token, err := s.token()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read the service account token")
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrapf(err, "%s %s failed", method, req.URL.Path)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusConflict:
+		return resp.StatusCode, nil
+	case resp.StatusCode/100 != 2:
+		return resp.StatusCode, apiError(req, resp)
+	case out == nil:
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, errors.Wrapf(json.NewDecoder(resp.Body).Decode(out), "invalid response to %s %s", method, req.URL.Path)
+}
+
+func (s *kubernetesCertStorage) Store(key string, value []byte) error {
+	secret := k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: k8sMeta{
+			Name:   s.secretName("cert", key),
+			Labels: map[string]string{k8sStorageLabel: s.prefix},
+			Annotations: map[string]string{
+				k8sKeyNote:      key,
+				k8sModifiedNote: s.now().UTC().Format(time.RFC3339Nano),
+			},
+		},
+		Data: map[string][]byte{"value": value},
+	}
+	// update, or create if there's none (retrying the update if another
+	// replica created it meanwhile)
+	for i := 0; i < 2; i++ {
+		status, err := s.do(http.MethodPut, "/"+secret.Metadata.Name, secret, nil)
+		if err != nil || status != http.StatusNotFound {
+			return errors.Wrapf(err, "failed to store %s", key)
+		}
+		status, err = s.do(http.MethodPost, "", secret, nil)
+		if err != nil || status != http.StatusConflict {
+			return errors.Wrapf(err, "failed to store %s", key)
+		}
+	}
+	return errors.Errorf("failed to store %s - conflicting updates", key)
+}
+
+func (s *kubernetesCertStorage) get(key string) (*k8sSecret, error) {
+	secret := &k8sSecret{}
+	status, err := s.do(http.MethodGet, "/"+s.secretName("cert", key), nil, secret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s", key)
+	}
+	if status == http.StatusNotFound {
+		return nil, notExist(key)
+	}
+	return secret, nil
+}
+
+func (s *kubernetesCertStorage) Load(key string) ([]byte, error) {
+	secret, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data["value"], nil
+}
+
+func (s *kubernetesCertStorage) Delete(key string) error {
+	status, err := s.do(http.MethodDelete, "/"+s.secretName("cert", key), nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete %s", key)
+	}
+	if status == http.StatusNotFound {
+		return notExist(key)
+	}
+	return nil
+}
+
+func (s *kubernetesCertStorage) Exists(key string) bool {
+	_, err := s.get(key)
+	return err == nil
+}
+
+// keys - all stored keys
+func (s *kubernetesCertStorage) keys() ([]string, error) {
+	var list struct {
+		Items []k8sSecret `json:"items"`
+	}
+	q := url.Values{"labelSelector": {k8sStorageLabel + "=" + s.prefix}}
+	_, err := s.do(http.MethodGet, "?"+q.Encode(), nil, &list)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list keys")
+	}
+	keys := make([]string, 0, len(list.Items))
+	for _, secret := range list.Items {
+		if k := secret.Metadata.Annotations[k8sKeyNote]; k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (s *kubernetesCertStorage) List(prefix string, recursive bool) ([]string, error) {
+	keys, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	listed := listKeys(keys, prefix, recursive)
+	if len(listed) == 0 {
+		return nil, notExist(prefix)
+	}
+	return listed, nil
+}
+
+func (s *kubernetesCertStorage) Stat(key string) (certmagic.KeyInfo, error) {
+	secret, err := s.get(key)
+	if isNotExist(err) {
+		keys, kerr := s.keys()
+		if kerr == nil && isDir(keys, key) {
+			return certmagic.KeyInfo{Key: key}, nil
+		}
+	}
+	if err != nil {
+		return certmagic.KeyInfo{}, err
+	}
+	modified, _ := time.Parse(time.RFC3339Nano, secret.Metadata.Annotations[k8sModifiedNote])
+	return certmagic.KeyInfo{Key: key, Modified: modified, Size: int64(len(secret.Data["value"])), IsTerminal: true}, nil
+}
+
+func (s *kubernetesCertStorage) Lock(key string) error {
+	name := s.secretName("lock", key)
+	return waitForLock(func() (bool, error) {
+		lock := k8sSecret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata: k8sMeta{
+				Name:   name,
+				Labels: map[string]string{k8sLockLabel: s.prefix},
+				Annotations: map[string]string{
+					k8sKeyNote:     key,
+					k8sOwnerNote:   s.owner,
+					k8sExpiresNote: s.now().Add(certLockTTL).UTC().Format(time.RFC3339),
+				},
+			},
+		}
+		status, err := s.do(http.MethodPost, "", lock, nil)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to lock %s", key)
+		}
+		if status != http.StatusConflict {
+			return true, nil
+		}
+		// held - remove it if it's expired, to try again
+		held := &k8sSecret{}
+		status, err = s.do(http.MethodGet, "/"+name, nil, held)
+		if err != nil || status == http.StatusNotFound {
+			return false, errors.Wrapf(err, "failed to lock %s", key)
+		}
+		expires, _ := time.Parse(time.RFC3339, held.Metadata.Annotations[k8sExpiresNote])
+		if s.now().Before(expires) {
+			return false, nil
+		}
+		return false, s.deleteLock(name, held.Metadata.ResourceVersion)
+	})
+}
+
+// deleteLock deletes the lock, if it hasn't changed since it was read at the
+// resource version
+func (s *kubernetesCertStorage) deleteLock(name, resourceVersion string) error {
+	opts := map[string]interface{}{
+		"apiVersion":    "v1",
+		"kind":          "DeleteOptions",
+		"preconditions": map[string]string{"resourceVersion": resourceVersion},
+	}
+	_, err := s.do(http.MethodDelete, "/"+name, opts, nil)
+	return errors.Wrap(err, "failed to remove lock")
+}
+
+func (s *kubernetesCertStorage) Unlock(key string) error {
+	name := s.secretName("lock", key)
+	held := &k8sSecret{}
+	status, err := s.do(http.MethodGet, "/"+name, nil, held)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unlock %s", key)
+	}
+	if status == http.StatusNotFound || held.Metadata.Annotations[k8sOwnerNote] != s.owner {
+		// expired, and maybe taken by another replica since
+		return nil
+	}
+	return s.deleteLock(name, held.Metadata.ResourceVersion)
+}
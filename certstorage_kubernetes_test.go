@@ -0,0 +1,110 @@
+package responder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKubernetes - just enough of the Kubernetes secrets API for
+// kubernetesCertStorage
+func fakeKubernetes(t *testing.T, secrets map[string]*k8sSecret) *httptest.Server {
+	var mu sync.Mutex
+	version := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "Bearer t0ken", req.Header.Get("Authorization"))
+		const base = "/api/v1/namespaces/ns/secrets"
+		if !strings.HasPrefix(req.URL.Path, base) {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			return
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, base), "/")
+		var in k8sSecret
+		if req.Method == http.MethodPost || req.Method == http.MethodPut {
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&in))
+			version++
+			in.Metadata.ResourceVersion = strconv.Itoa(version)
+		}
+		switch {
+		case req.Method == http.MethodGet && name == "":
+			var items []*k8sSecret
+			sel := strings.SplitN(req.URL.Query().Get("labelSelector"), "=", 2)
+			for _, s := range secrets {
+				if s.Metadata.Labels[sel[0]] == sel[1] {
+					items = append(items, s)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		case req.Method == http.MethodPost:
+			if _, ok := secrets[in.Metadata.Name]; ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			secrets[in.Metadata.Name] = &in
+		case secrets[name] == nil:
+			w.WriteHeader(http.StatusNotFound)
+		case req.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(secrets[name])
+		case req.Method == http.MethodPut:
+			secrets[name] = &in
+		case req.Method == http.MethodDelete:
+			var opts struct {
+				Preconditions struct {
+					ResourceVersion string `json:"resourceVersion"`
+				} `json:"preconditions"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&opts)
+			if v := opts.Preconditions.ResourceVersion; v != "" && v != secrets[name].Metadata.ResourceVersion {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			delete(secrets, name)
+		}
+	}))
+}
+
+func TestKubernetesCertStorage(t *testing.T) {
+	secrets := map[string]*k8sSecret{}
+	srv := fakeKubernetes(t, secrets)
+	defer srv.Close()
+
+	now := time.Now()
+	s := &kubernetesCertStorage{
+		baseURL:   srv.URL,
+		namespace: "ns",
+		prefix:    "gr",
+		client:    srv.Client(),
+		token:     func() ([]byte, error) { return []byte("t0ken\n"), nil },
+		now:       func() time.Time { return now },
+		owner:     "me",
+	}
+	testCertStorage(t, s)
+	secret := secrets[s.secretName("cert", "acme/ca/sites/a.com/a.com.key")]
+	if assert.NotNil(t, secret) {
+		assert.Equal(t, "acme/ca/sites/a.com/a.com.key", secret.Metadata.Annotations[k8sKeyNote])
+		assert.Equal(t, []byte("key"), secret.Data["value"])
+	}
+
+	// a lock held by another replica isn't released, until it expires
+	other := *s
+	other.owner = "other"
+	assert.NoError(t, other.Lock("b.com"))
+	assert.NoError(t, s.Unlock("b.com"))
+	assert.Contains(t, secrets, s.secretName("lock", "b.com"))
+	now = now.Add(certLockTTL + time.Minute)
+	assert.NoError(t, s.Lock("b.com"))
+	assert.Equal(t, "me", secrets[s.secretName("lock", "b.com")].Metadata.Annotations[k8sOwnerNote])
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	_, err := NewKubernetesCertStorage("", "gr")
+	assert.Error(t, err)
+}
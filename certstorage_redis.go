@@ -0,0 +1,333 @@
+package responder
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
+)
+
+// redisTimeout - how long connecting to Redis, and each command's writes
+// and reads, may take - commands hold the storage's lock
+const redisTimeout = 10 * time.Second
+
+// redisUnlockScript - deletes the lock only if it's still held by the owner,
+// in one step, so a lock that expired and was taken by another replica
+// isn't released
+const redisUnlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+
+// redisCertStorage - certmagic storage in Redis. Values are kept under
+// <prefix>certs/, with their modification times, and locks under
+// <prefix>locks/, expiring after certLockTTL.
+type redisCertStorage struct {
+	prefix   string
+	password string
+	db       int
+	dial     func() (net.Conn, error)
+	timeout  time.Duration
+	// owner - identifies this storage's locks
+	owner string
+
+	mu   sync.Mutex
+	conn *redisConn
+}
+
+// redisValue - a stored value, with when it was stored
+type redisValue struct {
+	Value    []byte    `json:"value"`
+	Modified time.Time `json:"modified"`
+}
+
+// NewRedisCertStorage - certificate storage (see Options.CertStorage) in
+// the Redis server at the URL, like redis://:password@host:6379/0 (or
+// rediss:// for TLS), so replicas can share certificates. Keys are
+// prefixed with the prefix, e.g. "github-responder/".
+func NewRedisCertStorage(redisURL, prefix string) (certmagic.Storage, error) {
+	u, err := url.Parse(redisURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, errors.Errorf("invalid Redis URL %q - must be like redis://:password@host:6379/0", redisURL)
+	}
+	s := &redisCertStorage{prefix: prefix, owner: randomOwner(), timeout: redisTimeout}
+	if p, ok := u.User.Password(); ok {
+		s.password = p
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		s.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, errors.Errorf("invalid Redis database %q", db)
+		}
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	d := &net.Dialer{Timeout: redisTimeout}
+	s.dial = func() (net.Conn, error) {
+		if u.Scheme == "rediss" {
+			return tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+		}
+		return d.Dial("tcp", addr)
+	}
+	return s, nil
+}
+
+// randomOwner - a random identifier for a storage's locks
+func randomOwner() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *redisCertStorage) dataKey(key string) string { return s.prefix + "certs/" + key }
+func (s *redisCertStorage) lockKey(key string) string { return s.prefix + "locks/" + key }
+
+// do - run the command, connecting first if need be. The connection is
+// dropped after I/O errors, to reconnect on the next command.
+func (s *redisCertStorage) do(args ...string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		c, err := s.connect()
+		if err != nil {
+			return nil, err
+		}
+		s.conn = c
+	}
+	reply, err := s.conn.do(args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return reply, err
+}
+
+func (s *redisCertStorage) connect() (*redisConn, error) {
+	nc, err := s.dial()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to Redis")
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc), timeout: s.timeout}
+	if s.password != "" {
+		if _, err = c.do("AUTH", s.password); err != nil {
+			c.Close()
+			return nil, errors.Wrap(err, "failed to authenticate to Redis")
+		}
+	}
+	if s.db != 0 {
+		if _, err = c.do("SELECT", strconv.Itoa(s.db)); err != nil {
+			c.Close()
+			return nil, errors.Wrap(err, "failed to select Redis database")
+		}
+	}
+	return c, nil
+}
+
+func (s *redisCertStorage) Store(key string, value []byte) error {
+	b, err := json.Marshal(redisValue{Value: value, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = s.do("SET", s.dataKey(key), string(b))
+	return errors.Wrapf(err, "failed to store %s", key)
+}
+
+func (s *redisCertStorage) load(key string) (*redisValue, error) {
+	reply, err := s.do("GET", s.dataKey(key))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load %s", key)
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, notExist(key)
+	}
+	v := &redisValue{}
+	err = json.Unmarshal(b, v)
+	return v, errors.Wrapf(err, "invalid value stored at %s", key)
+}
+
+func (s *redisCertStorage) Load(key string) ([]byte, error) {
+	v, err := s.load(key)
+	if err != nil {
+		return nil, err
+	}
+	return v.Value, nil
+}
+
+func (s *redisCertStorage) Delete(key string) error {
+	reply, err := s.do("DEL", s.dataKey(key))
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete %s", key)
+	}
+	if n, _ := reply.(int64); n == 0 {
+		return notExist(key)
+	}
+	return nil
+}
+
+func (s *redisCertStorage) Exists(key string) bool {
+	reply, err := s.do("EXISTS", s.dataKey(key))
+	n, _ := reply.(int64)
+	return err == nil && n > 0
+}
+
+// keys - all stored keys
+func (s *redisCertStorage) keys() ([]string, error) {
+	pattern := redisGlobEscaper.Replace(s.dataKey("")) + "*"
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := s.do("SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list keys")
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return nil, errors.New("unexpected reply to SCAN")
+		}
+		next, _ := page[0].([]byte)
+		found, _ := page[1].([]interface{})
+		for _, k := range found {
+			if b, ok := k.([]byte); ok {
+				keys = append(keys, strings.TrimPrefix(string(b), s.dataKey("")))
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// redisGlobEscaper - escapes the characters special in SCAN MATCH patterns
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func (s *redisCertStorage) List(prefix string, recursive bool) ([]string, error) {
+	keys, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	listed := listKeys(keys, prefix, recursive)
+	if len(listed) == 0 {
+		return nil, notExist(prefix)
+	}
+	return listed, nil
+}
+
+func (s *redisCertStorage) Stat(key string) (certmagic.KeyInfo, error) {
+	v, err := s.load(key)
+	if isNotExist(err) {
+		keys, kerr := s.keys()
+		if kerr == nil && isDir(keys, key) {
+			return certmagic.KeyInfo{Key: key}, nil
+		}
+	}
+	if err != nil {
+		return certmagic.KeyInfo{}, err
+	}
+	return certmagic.KeyInfo{Key: key, Modified: v.Modified, Size: int64(len(v.Value)), IsTerminal: true}, nil
+}
+
+func (s *redisCertStorage) Lock(key string) error {
+	ttl := strconv.FormatInt(int64(certLockTTL/time.Millisecond), 10)
+	return waitForLock(func() (bool, error) {
+		reply, err := s.do("SET", s.lockKey(key), s.owner, "NX", "PX", ttl)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to lock %s", key)
+		}
+		return reply != nil, nil
+	})
+}
+
+// Unlock - release the lock, unless it's expired (and maybe been taken by
+// another replica since)
+func (s *redisCertStorage) Unlock(key string) error {
+	_, err := s.do("EVAL", redisUnlockScript, "1", s.lockKey(key), s.owner)
+	return errors.Wrapf(err, "failed to unlock %s", key)
+}
+
+// redisConn - a connection speaking the Redis protocol (RESP)
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	// timeout - the deadline for writing each command, and reading its
+	// reply (none when 0)
+	timeout time.Duration
+}
+
+// redisError - an error reply from Redis
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// do - send the command, and read the reply: a string (simple strings),
+// int64, []byte (bulk strings), []interface{} (arrays), or nil
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+	if c.timeout > 0 {
+		_ = c.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := io.WriteString(c, b.String())
+	if err != nil {
+		return nil, err
+	}
+	if c.timeout > 0 {
+		_ = c.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	return c.reply()
+}
+
+func (c *redisConn) reply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, buf)
+		return buf[:n], err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i], err = c.reply()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, errors.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,165 @@
+package responder
+
+import (
+	"bufio"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis - just enough of a Redis server for redisCertStorage
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (f *fakeRedis) serve(t *testing.T, ln net.Listener) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			rc := &redisConn{Conn: c, r: bufio.NewReader(c)}
+			for {
+				cmd, err := rc.reply()
+				if err != nil {
+					return
+				}
+				var args []string
+				for _, a := range cmd.([]interface{}) {
+					args = append(args, string(a.([]byte)))
+				}
+				_, _ = c.Write([]byte(f.exec(args)))
+			}
+		}()
+	}
+}
+
+func bulk(s string) string { return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n" }
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[1] != "s3cret" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "SET":
+		if len(args) > 3 && args[3] == "NX" {
+			if _, ok := f.data[args[1]]; ok {
+				return "$-1\r\n"
+			}
+		}
+		f.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "EVAL":
+		// only the unlock script is supported
+		if f.data[args[3]] != args[4] {
+			return ":0\r\n"
+		}
+		delete(f.data, args[3])
+		return ":1\r\n"
+	case "DEL", "EXISTS":
+		_, ok := f.data[args[1]]
+		if ok && args[0] == "DEL" {
+			delete(f.data, args[1])
+		}
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "SCAN":
+		pattern := strings.NewReplacer(`\*`, "*", `\?`, "?").Replace(args[3])
+		var keys []string
+		for k := range f.data {
+			if ok, _ := path.Match(strings.Replace(pattern, "/", "\x00", -1), strings.Replace(k, "/", "\x00", -1)); ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		reply := "*2\r\n" + bulk("0") + "*" + strconv.Itoa(len(keys)) + "\r\n"
+		for _, k := range keys {
+			reply += bulk(k)
+		}
+		return reply
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestRedisCertStorage(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln.Close()
+	f := &fakeRedis{data: map[string]string{}}
+	go f.serve(t, ln)
+
+	s, err := NewRedisCertStorage("redis://:s3cret@"+ln.Addr().String(), "gr/")
+	if !assert.NoError(t, err) {
+		return
+	}
+	testCertStorage(t, s)
+	_, ok := f.data["gr/certs/acme/ca/sites/a.com/a.com.key"]
+	assert.True(t, ok)
+
+	// a lock held by another replica isn't released
+	f.data["gr/locks/b.com"] = "other"
+	assert.NoError(t, s.Unlock("b.com"))
+	assert.Equal(t, "other", f.data["gr/locks/b.com"])
+	assert.NoError(t, s.Lock("c.com"))
+	assert.NotEmpty(t, f.data["gr/locks/c.com"])
+	assert.NoError(t, s.Unlock("c.com"))
+	_, ok = f.data["gr/locks/c.com"]
+	assert.False(t, ok)
+
+	s, _ = NewRedisCertStorage("redis://:wrong@"+ln.Addr().String(), "gr/")
+	assert.Contains(t, s.Store("x", nil).Error(), "WRONGPASS")
+
+	_, err = NewRedisCertStorage("http://localhost", "")
+	assert.Error(t, err)
+}
+
+func TestRedisCertStorageTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln.Close()
+	// a hung server, never replying
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	s, err := NewRedisCertStorage("redis://"+ln.Addr().String(), "gr/")
+	if !assert.NoError(t, err) {
+		return
+	}
+	s.(*redisCertStorage).timeout = 50 * time.Millisecond
+	start := time.Now()
+	assert.Error(t, s.Store("x", nil))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
@@ -0,0 +1,71 @@
+package responder
+
+import (
+	"testing"
+
+	"github.com/mholt/certmagic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListKeys(t *testing.T) {
+	keys := []string{"acme/ca/sites/a.com/a.com.crt", "acme/ca/sites/a.com/a.com.key", "acme/ca/users/x/x.json", "other"}
+	assert.Equal(t, []string{"acme/ca/sites", "acme/ca/users"}, listKeys(keys, "acme/ca", false))
+	assert.Equal(t, []string{"acme/ca/sites/a.com/a.com.crt", "acme/ca/sites/a.com/a.com.key"}, listKeys(keys, "acme/ca/sites/", true))
+	assert.Equal(t, []string{"acme", "other"}, listKeys(keys, "", false))
+	assert.Empty(t, listKeys(keys, "ocsp", true))
+
+	assert.True(t, isDir(keys, "acme/ca"))
+	assert.False(t, isDir(keys, "acme/c"))
+	assert.False(t, isDir(keys, "other"))
+}
+
+func TestCertStorageOptions(t *testing.T) {
+	assert.Nil(t, Options{}.certStorage())
+	assert.Equal(t, &certmagic.FileStorage{Path: "/certs"}, Options{CertStoragePath: "/certs"}.certStorage())
+
+	s := &certmagic.FileStorage{Path: "/other"}
+	o := Options{Domain: "example.com", Repos: []string{"a/b"}, CertStorage: s}
+	assert.Equal(t, s, o.certStorage())
+	assert.NoError(t, o.Validate())
+	o.CertStoragePath = "/certs"
+	assert.Error(t, o.Validate())
+}
+
+// testCertStorage exercises a certmagic.Storage
+func testCertStorage(t *testing.T, s certmagic.Storage) {
+	_, err := s.Load("acme/ca/sites/a.com/a.com.crt")
+	assert.True(t, isNotExist(err))
+	assert.False(t, s.Exists("acme/ca/sites/a.com/a.com.crt"))
+
+	assert.NoError(t, s.Store("acme/ca/sites/a.com/a.com.crt", []byte("cert")))
+	assert.NoError(t, s.Store("acme/ca/sites/a.com/a.com.key", []byte("key")))
+	assert.NoError(t, s.Store("acme/ca/sites/a.com/a.com.crt", []byte("renewed")))
+	b, err := s.Load("acme/ca/sites/a.com/a.com.crt")
+	assert.NoError(t, err)
+	assert.Equal(t, "renewed", string(b))
+	assert.True(t, s.Exists("acme/ca/sites/a.com/a.com.key"))
+
+	keys, err := s.List("acme/ca/sites", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"acme/ca/sites/a.com"}, keys)
+	keys, err = s.List("acme/ca/sites", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"acme/ca/sites/a.com/a.com.crt", "acme/ca/sites/a.com/a.com.key"}, keys)
+
+	info, err := s.Stat("acme/ca/sites/a.com/a.com.crt")
+	assert.NoError(t, err)
+	assert.True(t, info.IsTerminal)
+	assert.EqualValues(t, len("renewed"), info.Size)
+	assert.False(t, info.Modified.IsZero())
+	info, err = s.Stat("acme/ca/sites/a.com")
+	assert.NoError(t, err)
+	assert.False(t, info.IsTerminal)
+
+	assert.NoError(t, s.Delete("acme/ca/sites/a.com/a.com.crt"))
+	assert.True(t, isNotExist(s.Delete("acme/ca/sites/a.com/a.com.crt")))
+
+	assert.NoError(t, s.Lock("a.com"))
+	assert.NoError(t, s.Unlock("a.com"))
+	assert.NoError(t, s.Lock("a.com"))
+	assert.NoError(t, s.Unlock("a.com"))
+}
@@ -0,0 +1,179 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// checkNamespace - the state store namespace recorded check runs are kept in
+	checkNamespace = "responder.checks"
+	// checkRetention - how long recorded check runs are kept, for re-runs
+	checkRetention = 30 * 24 * time.Hour
+)
+
+// CheckRunRecord - a check run produced by a handler, as recorded with
+// RecordCheckRun
+type CheckRunRecord struct {
+	Handler    string    `json:"handler"`
+	ID         int64     `json:"id"`
+	SuiteID    int64     `json:"suiteID"`
+	Name       string    `json:"name"`
+	HeadSHA    string    `json:"headSHA"`
+	ExternalID string    `json:"externalID,omitempty"`
+	EventType  string    `json:"eventType"`
+	DeliveryID string    `json:"deliveryID"`
+	Created    time.Time `json:"created"`
+}
+
+// RecordCheckRun - remember that the handler produced the check run. When
+// it's re-run from GitHub (a "rerequested" check_run or check_suite event),
+// the delivery only goes to the handler that produced it, and PriorCheckRuns
+// gives the handler the record. Records are kept in the state store (see
+// SetStateStore) for 30 days.
+func RecordCheckRun(ctx context.Context, run *github.CheckRun) error {
+	sc, ok := ctx.Value(scheduleKey).(*scheduleContext)
+	name := handlerName(ctx)
+	if !ok || name == "" {
+		return errors.New("can't record a check run - the context didn't come from a Responder's handler")
+	}
+	if run.GetID() == 0 {
+		return errors.New("can't record a check run without an ID")
+	}
+	rec := CheckRunRecord{
+		Handler:    name,
+		ID:         run.GetID(),
+		SuiteID:    run.GetCheckSuite().GetID(),
+		Name:       run.GetName(),
+		HeadSHA:    run.GetHeadSHA(),
+		ExternalID: run.GetExternalID(),
+		EventType:  sc.d.EventType,
+		DeliveryID: sc.d.DeliveryID,
+		Created:    sc.r.clock(),
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode check run record")
+	}
+	s := sc.r.scheduleStore()
+	err = s.Put(checkNamespace, fmt.Sprintf("%d/%d", rec.SuiteID, rec.ID), b)
+	if err != nil {
+		return errors.Wrap(err, "failed to record check run")
+	}
+	pruneCheckRuns(s, rec.Created.Add(-checkRetention))
+	return nil
+}
+
+// PriorCheckRuns - when handling a re-run of check runs the handler recorded
+// (see RecordCheckRun), the records of those check runs. For a check_run
+// event there's just one, but a check_suite can have many.
+func PriorCheckRuns(ctx context.Context) []CheckRunRecord {
+	all, _ := ctx.Value(priorChecksKey).([]CheckRunRecord)
+	name := handlerName(ctx)
+	var mine []CheckRunRecord
+	for _, rec := range all {
+		if rec.Handler == name {
+			mine = append(mine, rec)
+		}
+	}
+	return mine
+}
+
+// pruneCheckRuns deletes the records created before the cutoff
+func pruneCheckRuns(s StateStore, cutoff time.Time) {
+	for _, rec := range loadCheckRuns(s, func(string) bool { return true }) {
+		if rec.Created.Before(cutoff) {
+			_ = s.Delete(checkNamespace, fmt.Sprintf("%d/%d", rec.SuiteID, rec.ID))
+		}
+	}
+}
+
+// loadCheckRuns loads the records with matching keys (suite ID/run ID)
+func loadCheckRuns(s StateStore, match func(key string) bool) []CheckRunRecord {
+	keys, err := s.Keys(checkNamespace)
+	if err != nil {
+		return nil
+	}
+	var recs []CheckRunRecord
+	for _, k := range keys {
+		if !match(k) {
+			continue
+		}
+		b, err := s.Get(checkNamespace, k)
+		if err != nil || b == nil {
+			continue
+		}
+		rec := CheckRunRecord{}
+		if json.Unmarshal(b, &rec) == nil {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// rerequestedChecks finds the recorded check runs a rerequested check_run
+// or check_suite event is re-running
+func (r *Responder) rerequestedChecks(eventType string, payload []byte) []CheckRunRecord {
+	if eventType != "check_run" && eventType != "check_suite" {
+		return nil
+	}
+	var p struct {
+		Action   string `json:"action"`
+		CheckRun struct {
+			ID int64 `json:"id"`
+		} `json:"check_run"`
+		CheckSuite struct {
+			ID int64 `json:"id"`
+		} `json:"check_suite"`
+	}
+	if json.Unmarshal(payload, &p) != nil || p.Action != "rerequested" {
+		return nil
+	}
+	var match func(string) bool
+	switch {
+	case eventType == "check_run" && p.CheckRun.ID != 0:
+		suffix := fmt.Sprintf("/%d", p.CheckRun.ID)
+		match = func(k string) bool { return strings.HasSuffix(k, suffix) }
+	case eventType == "check_suite" && p.CheckSuite.ID != 0:
+		prefix := fmt.Sprintf("%d/", p.CheckSuite.ID)
+		match = func(k string) bool { return strings.HasPrefix(k, prefix) }
+	default:
+		return nil
+	}
+	return loadCheckRuns(r.scheduleStore(), match)
+}
+
+// routeRerequested - for a re-run of recorded check runs, only the routes
+// that produced them, with the records in the context for PriorCheckRuns.
+// Other deliveries, and re-runs of check runs that weren't recorded (or
+// whose handlers no longer exist), go to all the routes.
+func (r *Responder) routeRerequested(ctx context.Context, log zerolog.Logger, eventType string, payload []byte, routes []Route) (context.Context, []Route) {
+	recs := r.rerequestedChecks(eventType, payload)
+	if len(recs) == 0 {
+		return ctx, routes
+	}
+	ctx = context.WithValue(ctx, priorChecksKey, recs)
+
+	handlers := map[string]bool{}
+	for _, rec := range recs {
+		handlers[rec.Handler] = true
+	}
+	var producers []Route
+	for _, rt := range routes {
+		if handlers[rt.name] {
+			producers = append(producers, rt.annotate("rerequested"))
+		}
+	}
+	if len(producers) == 0 {
+		return ctx, routes
+	}
+	log.Debug().Int("checkRuns", len(recs)).Int("handlers", len(producers)).Msg("routing check re-run to the handlers that produced it")
+	return ctx, producers
+}
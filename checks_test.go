@@ -0,0 +1,87 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordCheckRun(t *testing.T) {
+	run := &github.CheckRun{
+		ID:         github.Int64(42),
+		Name:       github.String("lint"),
+		HeadSHA:    github.String("abc"),
+		CheckSuite: &github.CheckSuite{ID: github.Int64(7)},
+	}
+	assert.Error(t, RecordCheckRun(context.Background(), run))
+
+	type call struct {
+		handler string
+		prior   []CheckRunRecord
+	}
+	calls := make(chan call, 2)
+	handler := func(name string) HookHandler {
+		return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			if eventType == "pull_request" {
+				if name == "lint" {
+					assert.NoError(t, RecordCheckRun(ctx, run))
+				}
+				return
+			}
+			calls <- call{name, PriorCheckRuns(ctx)}
+		}
+	}
+
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{handler("lint"), handler("other")})}
+	routes := r.endpoints[0].namedRoutes()
+
+	d := &Delivery{EventType: "pull_request", DeliveryID: "abc", Headers: http.Header{}, Payload: []byte(`{}`)}
+	ctx := r.handlerContext(zerolog.Nop(), &DeliveryInfo{}, d)
+	dispatch(ctx, routes, d.EventType, d.DeliveryID, d.Payload).Wait()
+
+	for _, tc := range []struct {
+		eventType string
+		payload   string
+	}{
+		{"check_run", `{"action":"rerequested","check_run":{"id":42}}`},
+		{"check_suite", `{"action":"rerequested","check_suite":{"id":7}}`},
+	} {
+		ctx, got := r.routeRerequested(ctx, zerolog.Nop(), tc.eventType, []byte(tc.payload), routes)
+		assert.Len(t, got, 1, tc.eventType)
+		dispatch(ctx, got, tc.eventType, "def", []byte(tc.payload)).Wait()
+		c := <-calls
+		assert.Equal(t, "lint", c.handler)
+		assert.Len(t, c.prior, 1)
+		assert.Equal(t, int64(42), c.prior[0].ID)
+		assert.Equal(t, "abc", c.prior[0].DeliveryID)
+		assert.Equal(t, "pull_request", c.prior[0].EventType)
+	}
+
+	// unknown check runs, and other actions, go to every route
+	for _, payload := range []string{
+		`{"action":"rerequested","check_run":{"id":43}}`,
+		`{"action":"created","check_run":{"id":42}}`,
+	} {
+		_, got := r.routeRerequested(ctx, zerolog.Nop(), "check_run", []byte(payload), routes)
+		assert.Len(t, got, 2)
+	}
+}
+
+func TestPruneCheckRuns(t *testing.T) {
+	s := NewMemoryStateStore()
+	now := time.Now()
+	for i, created := range []time.Time{now.Add(-2 * checkRetention), now} {
+		rec := fmt.Sprintf(`{"id":%d,"suiteID":1,"created":%q}`, i, created.Format(time.RFC3339))
+		assert.NoError(t, s.Put(checkNamespace, fmt.Sprintf("1/%d", i), []byte(rec)))
+	}
+	pruneCheckRuns(s, now.Add(-checkRetention))
+	keys, _ := s.Keys(checkNamespace)
+	assert.Equal(t, []string{"1/1"}, keys)
+}
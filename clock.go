@@ -0,0 +1,46 @@
+package responder
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// clock - the current time, from Options.Clock if set
+func (r *Responder) clock() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
+}
+
+// randomBytes - n bytes from Options.Random if set, or crypto/rand
+func (r *Responder) randomBytes(n int) []byte {
+	src := r.random
+	if src == nil {
+		src = rand.Reader
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(src, b); err != nil {
+		// like uuid.NewV4, as there's no sensible way to carry on
+		panic(errors.Wrap(err, "failed to read random bytes"))
+	}
+	return b
+}
+
+// randomHex - n random bytes, hex-encoded
+func (r *Responder) randomHex(n int) string {
+	return hex.EncodeToString(r.randomBytes(n))
+}
+
+// newID - a random (version 4) UUID
+func (r *Responder) newID() string {
+	b := r.randomBytes(16)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return uuid.FromBytesOrNil(b).String()
+}
@@ -0,0 +1,30 @@
+package responder
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectedClockAndRandom(t *testing.T) {
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := &Responder{
+		domain:     "example.com",
+		pathPrefix: "/",
+		now:        func() time.Time { return now },
+		random:     bytes.NewReader(bytes.Repeat([]byte{0xab}, 32)),
+	}
+	ep := r.newEndpoint(nil, nil)
+	assert.Equal(t, "https://example.com/gh-callback/abababab-abab-4bab-abab-abababababab", ep.callbackURL)
+	assert.Equal(t, "abababababababababababababababab", ep.secret)
+
+	assert.Panics(t, func() { r.newID() }, "the random source is exhausted")
+
+	r.maintenance = []MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}}
+	assert.True(t, r.InMaintenance())
+	assert.Equal(t, now, r.clock())
+	assert.WithinDuration(t, time.Now(), (&Responder{}).clock(), time.Minute)
+	assert.Len(t, (&Responder{}).newID(), 36)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -32,16 +33,37 @@ func defaultAction(ctx context.Context, eventType, deliveryID string, payload []
 	fmt.Println(string(pretty))
 }
 
-func execArgs(env []string, args ...string) responder.HookHandler {
+func execArgs(env []string, sb execSandbox, args ...string) responder.HookHandler {
+	handler := execArgsE(env, sb, args...)
 	return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		err := handler(ctx, eventType, deliveryID, payload)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg(err.Error())
+			responder.ReportFailure(ctx, err)
+		}
+	}
+}
+
+func execArgsE(env []string, sb execSandbox, args ...string) responder.HookHandlerE {
+	return func(ctx context.Context, eventType, deliveryID string, payload []byte) error {
 		log := log.Ctx(ctx)
+		if sb.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, sb.Timeout)
+			defer cancel()
+		}
 		name := args[0]
 		cmdArgs := args[1:]
 		cmdArgs = append(cmdArgs, eventType, deliveryID)
 		input := bytes.NewBuffer(payload)
 		// nolint: gosec
-		c := exec.Command(name, cmdArgs...)
+		c := exec.CommandContext(ctx, name, cmdArgs...)
 		c.Env = resolveEnv(env)
+		cleanup, err := sb.apply(c, deliveryID)
+		if err != nil {
+			return errors.Wrap(err, "failed to sandbox command")
+		}
+		defer cleanup()
 		log.Debug().
 			Int("size", len(payload)).
 			Str("command", name).
@@ -51,10 +73,11 @@ func execArgs(env []string, args ...string) responder.HookHandler {
 		c.Stdin = input
 		c.Stderr = os.Stderr
 		c.Stdout = os.Stdout
-		err := c.Run()
-		if err != nil {
-			log.Error().Err(err).Msg(err.Error())
+		err = c.Run()
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.Wrapf(err, "command timed out")
 		}
+		return err
 	}
 }
 
@@ -72,13 +95,22 @@ func resolveEnv(kvPairs []string) []string {
 		return os.Environ()
 	}
 
-	out := make([]string, len(kvPairs))
-	for i, kv := range kvPairs {
+	out := make([]string, 0, len(kvPairs))
+	for _, kv := range kvPairs {
 		parts := strings.SplitN(kv, "=", 2)
-		if len(parts) == 1 {
-			out[i] = parts[0] + "=" + os.Getenv(parts[0])
-		} else {
-			out[i] = kv
+		switch {
+		case len(parts) == 2:
+			out = append(out, kv)
+		case strings.HasSuffix(parts[0], "*"):
+			// inherit every variable with the prefix
+			prefix := strings.TrimSuffix(parts[0], "*")
+			for _, e := range os.Environ() {
+				if strings.HasPrefix(e, prefix) {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, parts[0]+"="+os.Getenv(parts[0]))
 		}
 	}
 	return out
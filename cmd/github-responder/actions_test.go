@@ -18,6 +18,12 @@ func TestResolveEnv(t *testing.T) {
 	pairs := []string{"foo=1", "bar=2", "baz", "USER"}
 	assert.EqualValues(t, expected, resolveEnv(pairs))
 
+	os.Setenv("SANDBOX_TEST_A", "a")
+	os.Setenv("SANDBOX_TEST_B", "b")
+	defer os.Unsetenv("SANDBOX_TEST_A")
+	defer os.Unsetenv("SANDBOX_TEST_B")
+	assert.ElementsMatch(t, []string{"SANDBOX_TEST_A=a", "SANDBOX_TEST_B=b", "foo=1"}, resolveEnv([]string{"SANDBOX_TEST_*", "foo=1"}))
+
 	assert.EqualValues(t, os.Environ(), resolveEnv(nil))
 	assert.EqualValues(t, os.Environ(), resolveEnv([]string{}))
 }
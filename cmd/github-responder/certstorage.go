@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/url"
+	"os"
+
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+)
+
+// certStorage - sets the certificate storage in opts from a --cert-storage
+// value: a directory, a redis:// URL, dynamodb://<table>?region=<region>, or
+// kubernetes[://<namespace>]
+func certStorage(opts *responder.Options, spec string) (err error) {
+	u, err := url.Parse(spec)
+	if err != nil || u.Scheme == "" {
+		opts.CertStoragePath = spec
+		return nil
+	}
+	switch u.Scheme {
+	case "redis", "rediss":
+		opts.CertStorage, err = responder.NewRedisCertStorage(spec, "github-responder/")
+		return err
+	case "dynamodb":
+		region := u.Query().Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		switch {
+		case u.Host == "":
+			return errors.New("dynamodb certificate storage needs a table name, as in dynamodb://<table>?region=<region>")
+		case region == "":
+			return errors.New("dynamodb certificate storage needs a region, in the URL or $AWS_REGION")
+		case id == "" || secret == "":
+			return errors.New("dynamodb certificate storage needs credentials in $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY")
+		}
+		opts.CertStorage = responder.NewDynamoDBCertStorage(u.Host, region, id, secret, os.Getenv("AWS_SESSION_TOKEN"))
+		return nil
+	case "kubernetes":
+		opts.CertStorage, err = responder.NewKubernetesCertStorage(u.Host, "github-responder")
+		return err
+	}
+	return errors.Errorf("unknown certificate storage %q - must be a directory, redis://, dynamodb://, or kubernetes://", spec)
+}
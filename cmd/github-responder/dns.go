@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+)
+
+// dnsProvider - the named DNS-01 challenge provider, with credentials from
+// the environment
+func dnsProvider(name string) (responder.DNSProvider, error) {
+	switch name {
+	case "cloudflare":
+		token := os.Getenv("CLOUDFLARE_API_TOKEN")
+		if token == "" {
+			token = os.Getenv("CF_DNS_API_TOKEN")
+		}
+		if token == "" {
+			return nil, errors.New("the cloudflare DNS provider needs an API token in $CLOUDFLARE_API_TOKEN")
+		}
+		return responder.NewCloudflareDNSProvider(token), nil
+	case "route53":
+		id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if id == "" || secret == "" {
+			return nil, errors.New("the route53 DNS provider needs credentials in $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY")
+		}
+		return responder.NewRoute53DNSProvider(id, secret, os.Getenv("AWS_SESSION_TOKEN")), nil
+	case "gcloud":
+		path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		if path == "" {
+			return nil, errors.New("the gcloud DNS provider needs the path to a service account key in $GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the Google service account key")
+		}
+		return responder.NewGoogleCloudDNSProvider(key)
+	}
+	return nil, errors.Errorf("unknown DNS provider %q - must be cloudflare, route53, or gcloud", name)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	responder "github.com/hairyhenderson/github-responder"
+)
+
+// explainDelivery - print how the responder would route the delivery (a
+// delivery file, or a delivery ID to find in dir), without running any
+// actions
+func explainDelivery(r *responder.Responder, delivery, dir, keyFile string) error {
+	path := delivery
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path, err = responder.FindDelivery(dir, delivery)
+		if err != nil {
+			return err
+		}
+	}
+	var key responder.KeyUnwrapper
+	if keyFile != "" {
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return err
+		}
+		key, err = responder.NewRSAKeyUnwrapper(b)
+		if err != nil {
+			return err
+		}
+	}
+	d, err := responder.OpenDelivery(path, key)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.Explain(context.Background(), d))
+}
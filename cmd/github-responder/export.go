@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/spf13/cobra"
+)
+
+var exportOutput string
+
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export DIR",
+		Short: "Export recorded deliveries in GitHub's hook deliveries API format",
+		Long: `Export the deliveries recorded in DIR (see --record) as a JSON array, in
+the same shape GitHub's hook deliveries API returns, for tooling built
+against that format.`,
+		Example: `  $ github-responder export ./deliveries -o deliveries.json`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			opts, err := replayOptions()
+			if err != nil {
+				return err
+			}
+			s, err := responder.NewDirDeliveryStore(args[0])
+			if err != nil {
+				return err
+			}
+			s.Unwrapper = opts.Key
+
+			var out io.Writer = os.Stdout
+			if exportOutput != "" && exportOutput != "-" {
+				f, err := os.Create(exportOutput)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			return responder.ExportDeliveries(out, s)
+		},
+	}
+
+	cmd.Flags().StringVarP(&exportOutput, "output", "o", "-", "File to write the export to (- for stdout)")
+	cmd.Flags().StringVar(&replayKeyFile, "key", "", "RSA private key (PEM file) to decrypt encrypted delivery files with")
+	return cmd
+}
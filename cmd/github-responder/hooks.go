@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hooksFormat    string
+	hooksSecretRef string
+	hooksOutput    string
+)
+
+func newExportHooksCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export-hooks",
+		Short: "Export the webhooks the responder would register, for infrastructure-as-code",
+		Long: `Write the webhook definitions (callback URL, events, and a reference to the
+secret) the responder would register for the repos, as Terraform
+github_repository_webhook resources or JSON. Manage the hooks with your
+infrastructure-as-code tooling, and run the responder with --observe and
+the same --hook-secret to serve their callbacks.`,
+		Example: `  $ github-responder export-hooks -d example.com -r owner/repo --hook-secret $SECRET > hooks.tf
+  $ github-responder --observe -d example.com -r owner/repo --hook-secret $SECRET ./handle_event.sh`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			write := responder.WriteHookDefinitionsTerraform
+			switch hooksFormat {
+			case "terraform", "hcl":
+			case "json":
+				write = responder.WriteHookDefinitionsJSON
+			default:
+				return errors.Errorf("unknown format %q - must be terraform or json", hooksFormat)
+			}
+
+			opts := responder.Options{
+				Repos:                repos,
+				Domain:               domain,
+				Events:               events,
+				PathPrefix:           pathPrefix,
+				HookSecret:           hookSecret,
+				Observe:              true,
+				CallbackPath:         callbackPath,
+				CallbackPathStrategy: responder.CallbackPathStrategy(pathStrategy),
+			}
+			if len(extraDomains) > 0 {
+				opts.Domains = extraDomains
+				opts.DomainFor = func(repo string) string { return hookDomains[repo] }
+			}
+			if len(hookConfig) > 0 {
+				opts.HookConfig = map[string]interface{}{}
+				for k, v := range hookConfig {
+					opts.HookConfig[k] = v
+				}
+			}
+			r, err := responder.NewWithOptions(opts)
+			if err != nil {
+				return err
+			}
+			defs, err := r.HookDefinitions(context.Background(), nil, hooksSecretRef)
+			if err != nil {
+				return err
+			}
+
+			var out io.Writer = os.Stdout
+			if hooksOutput != "" && hooksOutput != "-" {
+				f, err := os.Create(hooksOutput)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+			return write(out, defs)
+		},
+	}
+
+	cmd.Flags().StringVarP(&hooksFormat, "format", "f", "terraform", "Output format: terraform or json")
+	cmd.Flags().StringVar(&hooksSecretRef, "secret-ref", "var.github_webhook_secret", "Reference to the webhook secret to write in place of it, e.g. a Terraform variable")
+	cmd.Flags().StringVarP(&hooksOutput, "output", "o", "-", "File to write the hooks to (- for stdout)")
+	cmd.Flags().StringArrayVarP(&repos, "repo", "r", []string{}, "The GitHub repository to watch, in 'owner/repo' form (repo URLs and numeric IDs are also accepted). Specify multiple times to watch many repos.")
+	cmd.Flags().StringArrayVarP(&events, "events", "e", []string{"*"}, "The GitHub event type(s) to listen for. Specify multiple times to watch many events.")
+	cmd.Flags().StringVarP(&domain, "domain", "d", "", "The domain the responder serves")
+	cmd.Flags().StringArrayVar(&extraDomains, "extra-domain", []string{}, "Another domain the responder serves. Specify multiple times for many domains.")
+	cmd.Flags().StringToStringVar(&hookDomains, "hook-domain", map[string]string{}, "Point this repo's hook at one of the --extra-domain domains instead of --domain, in owner/repo=domain form")
+	cmd.Flags().StringVar(&hookSecret, "hook-secret", os.Getenv("RESPONDER_HOOK_SECRET"), "The webhook secret the callback URLs are derived from (defaults to $RESPONDER_HOOK_SECRET)")
+	cmd.Flags().StringVar(&pathPrefix, "path-prefix", "/", "The URL path prefix the responder serves endpoints under")
+	cmd.Flags().StringVar(&callbackPath, "callback-path", "", "The responder's fixed callback path (see --callback-path-strategy)")
+	cmd.Flags().StringVar(&pathStrategy, "callback-path-strategy", "", "How the responder chooses callback paths: 'stable', 'per-repo', or 'fixed'. Defaults to 'fixed' with --callback-path, and 'stable' otherwise.")
+	cmd.Flags().StringToStringVar(&hookConfig, "hook-config", nil, "Extra webhook config fields, in key=value form (e.g. insecure_ssl=1)")
+	return cmd
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// sdListenFDsStart - the first file descriptor systemd passes sockets on
+const sdListenFDsStart = 3
+
+// systemdListener - the first socket passed by systemd socket activation
+// (see sd_listen_fds(3))
+func systemdListener() (net.Listener, error) {
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+		return nil, errors.New("no socket passed by systemd - $LISTEN_PID isn't this process")
+	}
+	if n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS")); n < 1 {
+		return nil, errors.New("no socket passed by systemd - $LISTEN_FDS isn't set")
+	}
+	f := os.NewFile(sdListenFDsStart, "systemd-socket")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	return ln, errors.Wrap(err, "invalid socket passed by systemd")
+}
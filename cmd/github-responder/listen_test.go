@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemdListener(t *testing.T) {
+	os.Setenv("LISTEN_PID", "1")
+	defer os.Unsetenv("LISTEN_PID")
+	_, err := systemdListener()
+	assert.Contains(t, err.Error(), "LISTEN_PID")
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	_, err = systemdListener()
+	assert.Contains(t, err.Error(), "LISTEN_FDS")
+}
@@ -6,12 +6,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
 
 	responder "github.com/hairyhenderson/github-responder"
+	"github.com/hairyhenderson/github-responder/logrotate"
 	"github.com/hairyhenderson/github-responder/version"
 
 	"github.com/rs/zerolog"
@@ -26,6 +31,98 @@ var (
 	events   []string
 	env      []string
 	domain   string
+
+	extraDomains []string
+	hookDomains  map[string]string
+
+	httpPort       int
+	httpsPort      int
+	listenAddr     string
+	systemdSocket  bool
+	behindProxy    bool
+	trustedProxies []string
+	hookConfig     map[string]string
+	pathPrefix     string
+
+	registerConcurrency int
+	apiCallsPerHour     int
+	handlerAPICalls     int
+	appSecret           string
+	appID               int64
+	appInstallationID   int64
+	registerAppWebhook  bool
+	appKeyFile          string
+	clientCAFile        string
+	clientCertCallbacks bool
+	dispatchPings       bool
+	relayToken          string
+	apiURL              string
+	uploadURL           string
+	deliveryBudget      time.Duration
+	tokenFile           string
+	hookSecret          string
+	adoptHooks          bool
+	onShutdown          string
+	observe             bool
+	callbackPath        string
+	pathStrategy        string
+	hydratePayloads     bool
+	pruneStaleHooks     []string
+	maintenance         []string
+	crashDir            string
+	proxy               string
+	dnsProviderName     string
+	certStorageSpec     string
+	acmeCA              string
+	acmeEmail           string
+	acmeEABKeyID        string
+	synchronous         bool
+	maxConcurrency      int
+	maxQueued           int
+	maxInFlight         int
+	maxPayloadSize      int64
+	rateLimit           float64
+	rateLimitPerIP      float64
+	rateBurst           int
+	expectedAddrs       []string
+	strictDNS           bool
+	dedupTTL            time.Duration
+	diffWindow          time.Duration
+	skipPermCheck       bool
+	walDir              string
+	compression         string
+	queueSnapshot       string
+	backfill            bool
+	drainTimeout        time.Duration
+
+	callbackAllow []string
+	callbackDeny  []string
+	metricsAllow  []string
+	metricsDeny   []string
+	githubHookIPs bool
+
+	statusContext     string
+	repoMetricsBudget int
+
+	accessLog       string
+	accessLogSize   int64
+	accessLogRotate time.Duration
+	accessLogKeep   int
+
+	recordDir     string
+	recordKeyFile string
+	explain       string
+	explainKey    string
+	trackStatuses int
+	handlerAdmin  bool
+	redeliveries  bool
+	topology      bool
+	printTopology bool
+
+	remoteURL      string
+	remoteSecret   string
+	remoteTimeout  time.Duration
+	remoteAttempts int
 )
 
 func printVersion(name string) {
@@ -39,6 +136,8 @@ func newCmd() *cobra.Command {
 		Example: `  Run ./handle_event.sh every time a webhook event is received:
 
   $ github-responder -d example.com ./handle_event.sh`,
+		// the action is free-form, so don't treat it as a subcommand
+		Args: cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if verbose {
 				zerolog.SetGlobalLevel(zerolog.DebugLevel)
@@ -53,41 +152,362 @@ func newCmd() *cobra.Command {
 				Msg(cmd.CalledAs())
 			cmd.SilenceErrors = true
 			cmd.SilenceUsage = true
+			if err := sandbox.validate(); err != nil {
+				return err
+			}
 
+			if remoteURL != "" && len(args) > 0 {
+				return errors.New("can't run both an action command and a --remote worker")
+			}
+			remote := responder.RemoteOptions{
+				Secret:   remoteSecret,
+				Timeout:  remoteTimeout,
+				Attempts: remoteAttempts,
+			}
 			var action responder.HookHandler
-			if len(args) > 0 {
-				action = execArgs(env, args...)
-			} else {
+			switch {
+			case remoteURL != "" && statusContext != "":
+				action = responder.Gate(statusContext, responder.RemoteHandlerE(remoteURL, remote))
+			case remoteURL != "":
+				action = responder.RemoteHandler(remoteURL, remote)
+			case len(args) > 0 && statusContext != "":
+				action = responder.Gate(statusContext, execArgsE(env, sandbox, args...))
+			case len(args) > 0:
+				action = execArgs(env, sandbox, args...)
+			default:
 				log.Info().Msg("No action command given, will perform default")
 				action = defaultAction
 			}
 
-			r, err := responder.New(repos, domain, action)
+			opts := responder.Options{
+				Repos:      repos,
+				Domain:     domain,
+				Events:     events,
+				HTTPPort:   httpPort,
+				HTTPSPort:  httpsPort,
+				PathPrefix: pathPrefix,
+
+				RegisterConcurrency:      registerConcurrency,
+				APICallsPerHour:          apiCallsPerHour,
+				HandlerAPICallsPerHour:   handlerAPICalls,
+				AppSecret:                appSecret,
+				DispatchPings:            dispatchPings,
+				TrustedRelayToken:        relayToken,
+				BaseURL:                  apiURL,
+				UploadURL:                uploadURL,
+				DeliveryBudget:           deliveryBudget,
+				HookSecret:               hookSecret,
+				AdoptHooks:               adoptHooks,
+				HookShutdownPolicy:       responder.HookShutdownPolicy(onShutdown),
+				Observe:                  observe,
+				CallbackPath:             callbackPath,
+				CallbackPathStrategy:     responder.CallbackPathStrategy(pathStrategy),
+				HydrateTruncatedPayloads: hydratePayloads,
+				CrashReportDir:           crashDir,
+				Proxy:                    proxy,
+				Synchronous:              synchronous,
+				MaxConcurrency:           maxConcurrency,
+				MaxQueuedHandlers:        maxQueued,
+				MaxInFlightDeliveries:    maxInFlight,
+				MaxPayloadSize:           maxPayloadSize,
+				CallbackRateLimit:        rateLimit,
+				CallbackRateLimitPerIP:   rateLimitPerIP,
+				CallbackRateBurst:        rateBurst,
+				ExpectedAddresses:        expectedAddrs,
+				StrictDNS:                strictDNS,
+				DedupTTL:                 dedupTTL,
+				RedeliveryDiffWindow:     diffWindow,
+				SkipPermissionCheck:      skipPermCheck,
+				Backfill:                 backfill,
+				DrainTimeout:             drainTimeout,
+			}
+			if len(extraDomains) > 0 {
+				opts.Domains = extraDomains
+				opts.DomainFor = func(repo string) string { return hookDomains[repo] }
+			}
+			if tokenFile != "" {
+				opts.TokenSource = responder.NewFileTokenSource(tokenFile)
+			}
+			if len(hookConfig) > 0 {
+				opts.HookConfig = map[string]interface{}{}
+				for k, v := range hookConfig {
+					opts.HookConfig[k] = v
+				}
+			}
+			var err error
+			for _, m := range maintenance {
+				var w responder.MaintenanceWindow
+				w, err = parseMaintenanceWindow(m)
+				if err != nil {
+					return err
+				}
+				opts.MaintenanceWindows = append(opts.MaintenanceWindows, w)
+			}
+			opts.Addr = listenAddr
+			opts.BehindProxy = behindProxy
+			opts.TrustedProxies = trustedProxies
+			if systemdSocket {
+				opts.Listener, err = systemdListener()
+				if err != nil {
+					return err
+				}
+			}
+			if dnsProviderName != "" {
+				opts.DNSProvider, err = dnsProvider(dnsProviderName)
+				if err != nil {
+					return err
+				}
+			}
+			if compression != "" {
+				opts.Compression, err = responder.ParseCompression(compression)
+				if err != nil {
+					return err
+				}
+			}
+			opts.ACMECA = acmeCA
+			opts.ACMEEmail = acmeEmail
+			opts.ACMEEABKeyID = acmeEABKeyID
+			opts.ACMEEABHMACKey = os.Getenv("ACME_EAB_HMAC_KEY")
+			opts.AdminToken = os.Getenv("RESPONDER_ADMIN_TOKEN")
+			if certStorageSpec != "" {
+				err = certStorage(&opts, certStorageSpec)
+				if err != nil {
+					return err
+				}
+			}
+			opts.AppID = appID
+			opts.AppInstallationID = appInstallationID
+			opts.RegisterAppWebhook = registerAppWebhook
+			if appKeyFile != "" {
+				opts.AppPrivateKey, err = ioutil.ReadFile(appKeyFile)
+				if err != nil {
+					return err
+				}
+			}
+			if clientCAFile != "" {
+				opts.ClientCAs, err = ioutil.ReadFile(clientCAFile)
+				if err != nil {
+					return err
+				}
+			}
+			opts.ClientCertsOnCallbacks = clientCertCallbacks
+			if len(callbackAllow) > 0 || len(callbackDeny) > 0 {
+				opts.CallbackIPFilter, err = responder.NewIPFilter(callbackAllow, callbackDeny)
+				if err != nil {
+					return err
+				}
+			}
+			opts.GitHubHookIPs = githubHookIPs
+			if len(metricsAllow) > 0 || len(metricsDeny) > 0 {
+				opts.MetricsIPFilter, err = responder.NewIPFilter(metricsAllow, metricsDeny)
+				if err != nil {
+					return err
+				}
+				if len(metricsAllow) == 0 {
+					opts.MetricsIPFilter.Allow = responder.DefaultMetricsIPFilter.Allow
+				}
+			}
+			r, err := responder.NewWithOptions(opts, action)
+			if err != nil {
+				return err
+			}
+			if printTopology {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(r.Topology())
+			}
+			if explain != "" {
+				return explainDelivery(r, explain, recordDir, explainKey)
+			}
+			if repoMetricsBudget > 0 {
+				r.EnableRepoMetrics(repoMetricsBudget)
+			}
+			if accessLog != "" {
+				f := &logrotate.File{
+					Filename:   accessLog,
+					MaxSize:    accessLogSize * 1024 * 1024,
+					Interval:   accessLogRotate,
+					MaxBackups: accessLogKeep,
+				}
+				defer f.Close()
+				r.SetAccessLog(f)
+			}
+
+			if trackStatuses > 0 {
+				r.EnableStatusTracking(trackStatuses)
+			}
+			if handlerAdmin {
+				r.EnableHandlerAdmin()
+			}
+			if redeliveries {
+				r.EnableRedeliveryAdmin()
+			}
+			if topology {
+				r.EnableTopology()
+			}
+			if queueSnapshot != "" {
+				r.EnableQueueSnapshot(queueSnapshot)
+			}
+			if walDir != "" {
+				var wal *responder.FileStateStore
+				wal, err = responder.NewFileStateStore(walDir)
+				if err != nil {
+					return err
+				}
+				r.EnableWriteAheadLog(wal)
+			}
+			switch {
+			case recordDir != "" && recordKeyFile != "":
+				var key []byte
+				key, err = ioutil.ReadFile(recordKeyFile)
+				if err != nil {
+					return err
+				}
+				var w *responder.RSAKeyWrapper
+				w, err = responder.NewRSAKeyWrapper(key)
+				if err != nil {
+					return err
+				}
+				err = r.RecordEncryptedDeliveries(recordDir, w)
+			case recordDir != "":
+				err = r.RecordDeliveries(recordDir)
+			}
 			if err != nil {
 				return err
 			}
 
 			ctx := context.Background()
-			return r.RegisterAndListen(ctx, events)
+			if len(pruneStaleHooks) > 0 {
+				_, err = r.PruneStaleHooks(ctx, pruneStaleHooks...)
+				if err != nil {
+					log.Warn().Err(err).Msg("failed to prune some stale webhooks")
+				}
+			}
+			return r.RegisterAndListen(ctx, nil)
 		},
 	}
 	return rootCmd
 }
 
+// parseMaintenanceWindow parses a START/END window of RFC 3339 times
+func parseMaintenanceWindow(s string) (w responder.MaintenanceWindow, err error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return w, errors.Errorf("invalid maintenance window %q - must be in START/END form", s)
+	}
+	w.Start, err = time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return w, errors.Wrapf(err, "invalid maintenance window %q", s)
+	}
+	w.End, err = time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return w, errors.Wrapf(err, "invalid maintenance window %q", s)
+	}
+	return w, nil
+}
+
 func initFlags(command *cobra.Command) {
 	command.Flags().SortFlags = false
 
-	command.Flags().StringArrayVarP(&repos, "repo", "r", []string{}, "The GitHub repository to watch, in 'owner/repo' form. Specify multiple times to watch many repos.")
+	command.Flags().StringArrayVarP(&repos, "repo", "r", []string{}, "The GitHub repository to watch, in 'owner/repo' form (repo URLs and numeric IDs are also accepted). Specify multiple times to watch many repos.")
 	command.Flags().StringArrayVarP(&events, "events", "e", []string{"*"}, "The GitHub event type(s) to listen for. Specify multiple times to watch many events. See https://developer.github.com/webhooks/#events for the full list.")
 
-	command.Flags().IntVar(&certmagic.HTTPPort, "http", 80, "Port to listen on for HTTP traffic")
-	command.Flags().IntVar(&certmagic.HTTPSPort, "https", 443, "Port to listen on for HTTPS traffic")
+	command.Flags().StringToStringVar(&hookConfig, "hook-config", nil, "Extra webhook config fields, in key=value form (e.g. insecure_ssl=1). Specify multiple times to set many fields.")
+
+	command.Flags().StringVar(&hookSecret, "hook-secret", os.Getenv("RESPONDER_HOOK_SECRET"), "Use this webhook secret instead of a random one, keeping callback URLs the same across restarts (defaults to $RESPONDER_HOOK_SECRET)")
+	command.Flags().StringArrayVar(&pruneStaleHooks, "prune-stale-hooks", []string{}, "On startup, delete webhooks left by previous runs on this domain (the --domain or an --extra-domain), whose callback URLs can't be connected to. Only opt in domains no other instance is reachable through. Specify multiple times for many domains.")
+	command.Flags().BoolVar(&adoptHooks, "adopt-hooks", false, "Update webhooks left by previous runs instead of creating new ones, and leave them in place on exit")
+	command.Flags().BoolVar(&observe, "observe", false, "Never create, modify, or delete webhooks - only serve the callback of an existing hook managed elsewhere, whose secret is given with --hook-secret")
+	command.Flags().StringVar(&callbackPath, "callback-path", "", "A fixed URL path for the webhooks to deliver to, e.g. /webhooks/github. With --observe, the path the existing hook delivers to (defaults to the path derived from --hook-secret).")
+	command.Flags().StringVar(&pathStrategy, "callback-path-strategy", "", "How callback paths are chosen: 'random' (new each run), 'stable' (derived from --hook-secret), 'per-repo' (derived from --hook-secret and each repo), or 'fixed' (--callback-path). Defaults to 'fixed' with --callback-path, 'stable' with --hook-secret, and 'random' otherwise.")
+	command.Flags().StringVar(&onShutdown, "on-shutdown", "", "What to do with the webhooks on exit: 'delete', 'keep', or 'deactivate' (leave them inactive, and reactivate them on the next start - implies --adopt-hooks). Defaults to 'keep' with --adopt-hooks, and 'delete' otherwise.")
+	command.Flags().StringVar(&appSecret, "app-secret", os.Getenv("GITHUB_APP_WEBHOOK_SECRET"), "Act as the webhook receiver for a GitHub App with this webhook secret, instead of creating hooks for repos (defaults to $GITHUB_APP_WEBHOOK_SECRET)")
+	command.Flags().StringVar(&tokenFile, "token-file", "", "Read the GitHub API token from this file instead of $GITHUB_TOKEN, re-reading it every minute so it can be rotated")
+	command.Flags().StringVar(&apiURL, "api-url", "", "GitHub Enterprise Server API URL, e.g. https://github.example.com/api/v3/ (defaults to github.com)")
+	command.Flags().StringVar(&uploadURL, "upload-url", "", "GitHub Enterprise Server upload URL (defaults to /api/uploads/ on the API URL's host)")
+	command.Flags().StringVar(&relayToken, "relay-token", os.Getenv("RESPONDER_RELAY_TOKEN"), "Accept unsigned deliveries from internal relays that send this token in the X-Responder-Relay-Token header (defaults to $RESPONDER_RELAY_TOKEN)")
+	command.Flags().BoolVar(&hydratePayloads, "hydrate-payloads", false, "Fetch commits GitHub leaves out of large push payloads from the API, before running the action")
+	command.Flags().BoolVar(&dispatchPings, "dispatch-pings", false, "Also run the action for ping events (by default pings are only answered)")
+	command.Flags().DurationVar(&deliveryBudget, "delivery-budget", 0, "Cancel a delivery's action if it runs longer than this (e.g. 10m). 0 for no limit.")
+	command.Flags().StringArrayVar(&expectedAddrs, "expected-address", []string{}, "Warn before registering hooks if the domain doesn't resolve to one of these IPs or hostnames, e.g. the load balancer's (defaults to this host's addresses). Specify multiple times for many addresses.")
+	command.Flags().BoolVar(&strictDNS, "strict-dns", false, "Fail, rather than warn, when the domain doesn't resolve to the expected address")
+	command.Flags().BoolVar(&skipPermCheck, "skip-permission-check", false, "Don't check that the token can manage webhooks (has the admin:repo_hook scope) before registering them")
+	command.Flags().DurationVar(&drainTimeout, "drain-timeout", 30*time.Second, "On shutdown, wait this long for in-flight deliveries and actions to finish")
+	command.Flags().BoolVar(&backfill, "backfill", false, "With --adopt-hooks, ask GitHub to redeliver deliveries that failed while the responder was down")
+	command.Flags().StringVar(&queueSnapshot, "queue-snapshot", "", "With --max-concurrency, save the actions still queued on shutdown to this file, and run them on the next start")
+	command.Flags().StringVar(&walDir, "wal-dir", "", "Persist deliveries in this directory until the action succeeds, retrying them on startup if the process stopped or the action failed")
+	command.Flags().StringVar(&compression, "compression", "", "Compress deliveries in the write-ahead log, queue snapshot, and recordings with this format, optionally at a level (e.g. gzip, or gzip:9). Compressed files are always read back, whatever this is set to.")
+	command.Flags().DurationVar(&diffWindow, "redelivery-diff-window", 0, "Keep received payloads this long (e.g. 72h), and log how redeliveries' payloads differ from the originals. 0 to not diff redeliveries.")
+	command.Flags().DurationVar(&dedupTTL, "dedup-ttl", 0, "Ignore deliveries already handled within this long (e.g. 24h), by delivery ID. 0 to handle every delivery.")
+	command.Flags().IntVar(&maxConcurrency, "max-concurrency", 0, "Run at most this many actions at once, queueing the rest (0 for no limit)")
+	command.Flags().IntVar(&maxQueued, "max-queued", 0, "With --max-concurrency, reject deliveries with 503 once this many actions are waiting (defaults to 10 per --max-concurrency)")
+	command.Flags().IntVar(&maxInFlight, "max-in-flight", 0, "Reject deliveries with 503 while this many deliveries' actions are still running (0 for no limit)")
+	command.Flags().Int64Var(&maxPayloadSize, "max-payload-size", 0, "Reject deliveries with payloads larger than this many bytes with 413 (defaults to GitHub's 25MB cap)")
+	command.Flags().Float64Var(&rateLimit, "rate-limit", 0, "Accept at most this many webhook deliveries per second, rejecting more with 429 (0 for no limit)")
+	command.Flags().Float64Var(&rateLimitPerIP, "rate-limit-per-ip", 0, "Accept at most this many webhook deliveries per second from each address (or IPv6 /64), rejecting more with 429 (0 for no limit)")
+	command.Flags().IntVar(&rateBurst, "rate-burst", 0, "With --rate-limit or --rate-limit-per-ip, how many deliveries beyond the rate may be accepted at once (defaults to a second's worth)")
+	command.Flags().BoolVar(&synchronous, "sync", false, "Wait for the action before replying to GitHub, replying 500 when it fails, so failed deliveries can be redelivered from GitHub")
+	command.Flags().StringVar(&dnsProviderName, "dns-provider", "", "Obtain certificates with DNS challenges through this DNS provider, for hosts behind NAT or with port 80 blocked: 'cloudflare' ($CLOUDFLARE_API_TOKEN), 'route53' ($AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY, and $AWS_SESSION_TOKEN), or 'gcloud' (a service account key file at $GOOGLE_APPLICATION_CREDENTIALS)")
+	command.Flags().StringVar(&acmeEABKeyID, "acme-eab-key-id", "", "External Account Binding key ID, for CAs like ZeroSSL that need one (the HMAC key is read from $ACME_EAB_HMAC_KEY)")
+	command.Flags().StringVar(&certStorageSpec, "cert-storage", "", "Where to store certificates, so they survive restarts and can be shared between replicas: a directory, a 'redis://' URL, 'dynamodb://<table>?region=<region>' (with $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY), or 'kubernetes://[<namespace>]' for secrets in the cluster")
+	command.Flags().StringVar(&proxy, "proxy", "", "Send GitHub API and ACME traffic through this http(s):// or socks5:// proxy (by default $HTTPS_PROXY and $HTTP_PROXY are honoured)")
+	command.Flags().StringVar(&crashDir, "crash-dir", "", "Write a crash report here when the action panics, and remove the webhooks if the panic crashes the process")
+	command.Flags().StringArrayVar(&maintenance, "maintenance", []string{}, "Queue deliveries without running the action during this window, in START/END form (RFC 3339 times), and run them when it closes. Specify multiple times for many windows.")
+	command.Flags().Int64Var(&appInstallationID, "app-installation-id", 0, "With --app-id, use this installation's token to register webhooks, instead of $GITHUB_TOKEN")
+	command.Flags().BoolVar(&registerAppWebhook, "register-app-webhook", false, "With --app-secret and --app-id, point the GitHub App's webhook at this responder (replacing its current webhook URL)")
+	command.Flags().Int64Var(&appID, "app-id", 0, "Authenticate as the GitHub App with this ID, so actions can use installation tokens (requires --app-private-key)")
+	command.Flags().StringVar(&appKeyFile, "app-private-key", "", "Path to the GitHub App's private key (PEM)")
+	command.Flags().IntVar(&apiCallsPerHour, "api-calls-per-hour", 0, "Maximum GitHub API calls all handlers may make per hour (0 for no limit)")
+	command.Flags().IntVar(&handlerAPICalls, "handler-api-calls-per-hour", 0, "Maximum GitHub API calls each handler may make per hour (0 for no limit)")
+	command.Flags().IntVar(&registerConcurrency, "register-concurrency", 4, "Maximum number of webhooks to create at once, when watching many repos")
+	command.Flags().StringVar(&pathPrefix, "path-prefix", "/", "Serve all endpoints under this URL path, for running behind a path-routing ingress (e.g. /hooks/)")
+
+	command.Flags().StringArrayVar(&callbackAllow, "callback-allow", []string{}, "Only accept webhook deliveries from this CIDR (e.g. 192.30.252.0/22). Specify multiple times to allow many ranges. By default all addresses are allowed.")
+	command.Flags().StringArrayVar(&callbackDeny, "callback-deny", []string{}, "Reject webhook deliveries from this CIDR. Specify multiple times to deny many ranges.")
+	command.Flags().BoolVar(&githubHookIPs, "github-hook-ips", false, "Only accept webhook deliveries from the addresses GitHub sends webhooks from, fetched from its /meta API and refreshed hourly")
+	command.Flags().StringArrayVar(&metricsAllow, "metrics-allow", []string{}, "Only allow /metrics to be scraped from this CIDR. Specify multiple times to allow many ranges. By default only loopback, link-local, and 10.0.0.0/8 addresses are allowed.")
+	command.Flags().StringVar(&clientCAFile, "client-ca", "", "Require client certificates signed by a CA in this PEM bundle for /metrics, status, and admin endpoints (mutual TLS)")
+	command.Flags().BoolVar(&clientCertCallbacks, "client-certs-on-callbacks", false, "With --client-ca, require client certificates on webhook callbacks too, for setups where a relay forwards deliveries")
+	command.Flags().StringArrayVar(&metricsDeny, "metrics-deny", []string{}, "Deny /metrics to this CIDR. Specify multiple times to deny many ranges.")
+
+	command.Flags().IntVar(&httpPort, "http", 80, "Port to listen on for HTTP traffic")
+	command.Flags().IntVar(&httpsPort, "https", 443, "Port to listen on for HTTPS traffic")
+	command.Flags().StringVar(&listenAddr, "addr", "", "Serve webhook callbacks on this host:port (e.g. 127.0.0.1:8443) instead of all interfaces on the --https port (or --http port, with TLS disabled)")
+	command.Flags().BoolVar(&behindProxy, "behind-proxy", false, "Run behind a load balancer or ingress that terminates TLS: serve callbacks over plain HTTP (on --http or --addr), still registering https:// hooks, and honour X-Forwarded-For and X-Forwarded-Proto from trusted proxies")
+	command.Flags().StringArrayVar(&trustedProxies, "trusted-proxy", []string{}, "With --behind-proxy, trust forwarding headers from this CIDR. Specify multiple times for many ranges. Defaults to loopback and private addresses.")
+	command.Flags().BoolVar(&systemdSocket, "systemd-socket", false, "Serve webhook callbacks on the socket passed by systemd socket activation")
 
 	command.Flags().StringVarP(&domain, "domain", "d", "", "domain to serve - a cert will be acquired for this domain")
-	command.Flags().StringVarP(&certmagic.Email, "email", "m", "", "Email used for registration and recovery contact (optional, but recommended)")
-	command.Flags().StringVar(&certmagic.CA, "ca", certmagic.LetsEncryptProductionCA, "URL to certificate authority's ACME server directory. Change this to point to a different server for testing.")
+	command.Flags().StringArrayVar(&extraDomains, "extra-domain", []string{}, "Also serve this domain (e.g. during a DNS migration). Specify multiple times for many domains.")
+	command.Flags().StringToStringVar(&hookDomains, "hook-domain", map[string]string{}, "Point this repo's hook at one of the --extra-domain domains instead of --domain, in owner/repo=domain form")
+	command.Flags().StringVarP(&acmeEmail, "email", "m", "", "Email used for registration and recovery contact (optional, but recommended)")
+	command.Flags().StringVar(&acmeCA, "ca", "", "The ACME CA to obtain certificates from: 'letsencrypt' (the default), 'letsencrypt-staging' for testing, 'zerossl', or a CA's https:// directory URL")
+
+	command.Flags().StringArrayVar(&env, "env", []string{}, "Set environment variables in KEY=value form. Omit =value to inherit current KEY value, or use KEY_* to inherit every variable starting with KEY_. By default, actions are executed with the parent environment.")
+	addSandboxFlags(command)
+
+	command.Flags().StringVar(&remoteURL, "remote", "", "Instead of running an action command, POST each delivery to the worker at this URL, which responds with a JSON result like {\"status\": \"failure\", \"message\": \"...\", \"retry\": true}")
+	command.Flags().StringVar(&remoteSecret, "remote-secret", "", "Sign requests to the --remote worker with this secret, in an X-Hub-Signature header like GitHub's")
+	command.Flags().DurationVar(&remoteTimeout, "remote-timeout", 30*time.Second, "How long to wait for each call to the --remote worker")
+	command.Flags().IntVar(&remoteAttempts, "remote-attempts", 3, "How many times to call the --remote worker, when calls fail transiently")
+	command.Flags().StringVar(&statusContext, "status-context", "", "Report the action's result on pull requests as a commit status with this context, so it can be used as a required check.")
+
+	command.Flags().IntVar(&repoMetricsBudget, "repo-metrics", 0, "Label delivery metrics by repository, for up to this many of the busiest repos (others are labelled 'other'). 0 disables per-repo labels.")
+
+	command.Flags().StringVar(&accessLog, "access-log", "", "Write HTTP access logs to this file, instead of the main log")
+	command.Flags().Int64Var(&accessLogSize, "access-log-max-size", 100, "Rotate the access log when it reaches this size, in megabytes (0 for no limit)")
+	command.Flags().DurationVar(&accessLogRotate, "access-log-rotate", 0, "Rotate the access log at least this often (e.g. 24h - 0 to rotate by size only)")
+	command.Flags().IntVar(&accessLogKeep, "access-log-keep", 7, "Number of rotated access logs to keep (0 keeps all)")
 
-	command.Flags().StringArrayVar(&env, "env", []string{}, "Set environment variables in KEY=value form. Omit =value to inherit current KEY value. By default, actions are executed with the parent environment.")
+	command.Flags().IntVar(&trackStatuses, "track-status", 0, "Respond to deliveries with 202 Accepted and a Location for polling their processing status, remembering this many recent deliveries. 0 disables status tracking.")
+	command.Flags().BoolVar(&redeliveries, "redelivery-admin", false, "Serve <path-prefix>admin/deliveries to addresses allowed by --metrics-allow, listing the hooks' past deliveries, and asking GitHub to redeliver one with a POST to admin/deliveries/<delivery ID>/redeliver - POSTs need a client certificate (see --client-ca), or the token in $RESPONDER_ADMIN_TOKEN as a bearer token")
+	command.Flags().BoolVar(&topology, "topology", false, "Serve the effective processing topology (hooks, stages, handlers, and sinks, with secrets masked) as JSON at <path-prefix>topology, to addresses allowed by --metrics-allow")
+	command.Flags().BoolVar(&printTopology, "print-topology", false, "Print the effective processing topology as JSON and exit, instead of listening")
+	command.Flags().BoolVar(&handlerAdmin, "handler-admin", false, "Serve <path-prefix>handlers to addresses allowed by --metrics-allow, listing the handlers, and disabling or enabling them with POSTs to handlers/<name>/disable and handlers/<name>/enable")
+	command.Flags().StringVar(&recordDir, "record", "", "Record every validated delivery to a file in this directory, for use with the replay and offline commands")
+	command.Flags().StringVar(&explain, "explain", "", "Instead of listening, print how a recorded delivery (a delivery file, or a delivery ID to find in --record) would be routed with this configuration - which rules match, which actions would run, and why others wouldn't - without running anything")
+	command.Flags().StringVar(&explainKey, "explain-key", "", "RSA private key (PEM file) to decrypt an encrypted --explain delivery with")
+	command.Flags().StringVar(&recordKeyFile, "record-key", "", "Encrypt recorded deliveries for this RSA public key (PEM file) - decrypt them by passing the private key to the replay and offline commands' --key")
 
 	command.Flags().BoolVarP(&verbose, "verbose", "V", false, "Output extra logs")
 	command.Flags().BoolVarP(&printVer, "version", "v", false, "Print the version")
@@ -98,6 +518,7 @@ func main() {
 
 	command := newCmd()
 	initFlags(command)
+	command.AddCommand(newReplayCmd(), newOfflineCmd(), newProxyCmd(), newExportCmd(), newExportHooksCmd())
 	if err := command.Execute(); err != nil {
 		log.Error().Err(err).Msg(command.Name() + " failed")
 		os.Exit(1)
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	offlineWatch    string
+	offlineInterval time.Duration
+)
+
+func newOfflineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offline [FILE...] [-- ACTION]",
+		Short: "Run an action against delivery files, without a server or GitHub",
+		Long: `Run an action against delivery files, without starting a server or
+registering webhooks - useful for developing handlers.
+
+Files given as arguments are replayed in order. With --watch, delivery files
+in the directory are replayed as they're created or modified, until
+interrupted.`,
+		Example: `  $ github-responder offline fixtures/*.json -- ./handle_event.sh
+  $ github-responder offline --watch ./fixtures -- ./handle_event.sh`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if verbose {
+				zerolog.SetGlobalLevel(zerolog.DebugLevel)
+			}
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			if err := sandbox.validate(); err != nil {
+				return err
+			}
+
+			files, actionArgs := args, []string{}
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				files, actionArgs = args[:dash], args[dash:]
+			}
+			if len(files) == 0 && offlineWatch == "" {
+				return errors.New("must provide delivery files, or a directory to --watch")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			opts, err := replayOptions()
+			if err != nil {
+				return err
+			}
+			action := localAction(actionArgs)
+
+			for _, f := range files {
+				d, err := responder.OpenDelivery(f, opts.Key)
+				if err != nil {
+					return err
+				}
+				err = responder.Replay(ctx, d, opts, action)
+				if err != nil {
+					return err
+				}
+			}
+
+			if offlineWatch == "" {
+				return nil
+			}
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, os.Interrupt)
+			go func() {
+				<-c
+				cancel()
+			}()
+			return responder.WatchDeliveries(ctx, offlineWatch, offlineInterval, opts, action)
+		},
+	}
+
+	cmd.Flags().StringVarP(&offlineWatch, "watch", "w", "", "Watch this directory for delivery files")
+	cmd.Flags().DurationVar(&offlineInterval, "interval", time.Second, "How often to check the watched directory for changes")
+	cmd.Flags().StringVar(&replaySecret, "secret", "", "Validate deliveries' recorded signatures with this secret (by default signatures aren't checked)")
+	cmd.Flags().StringVar(&replayKeyFile, "key", "", "RSA private key (PEM file) to decrypt encrypted delivery files with")
+	cmd.Flags().StringArrayVar(&env, "env", []string{}, "Set environment variables in KEY=value form. Omit =value to inherit current KEY value, or use KEY_* to inherit every variable starting with KEY_. By default, actions are executed with the parent environment.")
+	addSandboxFlags(cmd)
+	cmd.Flags().BoolVarP(&verbose, "verbose", "V", false, "Output extra logs")
+	return cmd
+}
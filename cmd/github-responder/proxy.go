@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	proxyListen string
+	proxySecret string
+	proxyAllow  []string
+	proxyDeny   []string
+)
+
+func newProxyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "proxy UPSTREAM",
+		Short: "Validate webhook deliveries, and forward them unchanged to an upstream",
+		Long: `Validate webhook deliveries' signatures (and senders' addresses, with
+--allow/--deny), then forward the raw requests unchanged to UPSTREAM - so a
+service that can't validate deliveries itself gains validation without code
+changes, e.g. as a sidecar. No webhooks are registered.`,
+		Example: `  $ github-responder proxy --secret $SECRET --listen :8080 http://localhost:3000`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if verbose {
+				zerolog.SetGlobalLevel(zerolog.DebugLevel)
+			}
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+
+			upstream, err := url.Parse(args[0])
+			if err != nil || upstream.Host == "" {
+				return errors.Errorf("invalid upstream %q - must be a URL like http://localhost:3000", args[0])
+			}
+			if proxySecret == "" {
+				return errors.New("must provide the webhook secret")
+			}
+			var filter *responder.IPFilter
+			if len(proxyAllow) > 0 || len(proxyDeny) > 0 {
+				filter, err = responder.NewIPFilter(proxyAllow, proxyDeny)
+				if err != nil {
+					return err
+				}
+			}
+
+			log.Info().Str("listen", proxyListen).Str("upstream", upstream.String()).Msg("Verifying and forwarding deliveries")
+			return http.ListenAndServe(proxyListen, responder.NewVerifierProxy([]byte(proxySecret), upstream, filter))
+		},
+	}
+
+	cmd.Flags().StringVar(&proxyListen, "listen", ":8080", "Address to listen for deliveries on")
+	cmd.Flags().StringVar(&proxySecret, "secret", os.Getenv("GITHUB_WEBHOOK_SECRET"), "The webhook secret to validate deliveries with (defaults to $GITHUB_WEBHOOK_SECRET)")
+	cmd.Flags().StringArrayVar(&proxyAllow, "allow", []string{}, "Only accept deliveries from this CIDR. Specify multiple times to allow many ranges. By default all addresses are allowed.")
+	cmd.Flags().StringArrayVar(&proxyDeny, "deny", []string{}, "Reject deliveries from this CIDR. Specify multiple times to deny many ranges.")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "V", false, "Output extra logs")
+	return cmd
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+var (
+	replayDir     string
+	replaySecret  string
+	replayKeyFile string
+)
+
+func newReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay DELIVERY [ACTION]",
+		Short: "Replay a recorded delivery through an action",
+		Long: `Replay a recorded delivery through an action, without a server or webhook.
+
+DELIVERY is either a delivery file, or a delivery ID to find in --dir.`,
+		Example: `  $ github-responder replay ./fixtures/20190102T030405-72d3162e.json ./handle_event.sh`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if verbose {
+				zerolog.SetGlobalLevel(zerolog.DebugLevel)
+			}
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			if err := sandbox.validate(); err != nil {
+				return err
+			}
+
+			path := args[0]
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				path, err = responder.FindDelivery(replayDir, args[0])
+				if err != nil {
+					return err
+				}
+			}
+			opts, err := replayOptions()
+			if err != nil {
+				return err
+			}
+			d, err := responder.OpenDelivery(path, opts.Key)
+			if err != nil {
+				return err
+			}
+
+			return responder.Replay(context.Background(), d, opts, localAction(args[1:]))
+		},
+	}
+
+	cmd.Flags().StringVar(&replayDir, "dir", ".", "Directory to look for delivery files in, when given a delivery ID")
+	cmd.Flags().StringVar(&replaySecret, "secret", "", "Validate the delivery's recorded signature with this secret (by default the signature isn't checked)")
+	cmd.Flags().StringVar(&replayKeyFile, "key", "", "RSA private key (PEM file) to decrypt encrypted delivery files with")
+	cmd.Flags().StringArrayVar(&env, "env", []string{}, "Set environment variables in KEY=value form. Omit =value to inherit current KEY value, or use KEY_* to inherit every variable starting with KEY_. By default, actions are executed with the parent environment.")
+	addSandboxFlags(cmd)
+	cmd.Flags().BoolVarP(&verbose, "verbose", "V", false, "Output extra logs")
+	return cmd
+}
+
+// localAction - the action to run for replayed deliveries
+func localAction(args []string) responder.HookHandler {
+	if len(args) > 0 {
+		return execArgs(env, sandbox, args...)
+	}
+	return defaultAction
+}
+
+func replayOptions() (responder.ReplayOptions, error) {
+	opts := responder.ReplayOptions{}
+	if replaySecret != "" {
+		opts.Secret = []byte(replaySecret)
+	}
+	if replayKeyFile != "" {
+		key, err := ioutil.ReadFile(replayKeyFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Key, err = responder.NewRSAKeyUnwrapper(key)
+		if err != nil {
+			return opts, err
+		}
+	}
+	// handlers may need the API, but replaying shouldn't require it
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		opts.Client = github.NewClient(&http.Client{Transport: &oauth2.Transport{Source: ts}})
+	}
+	return opts, nil
+}
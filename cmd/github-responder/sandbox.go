@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// execSandbox - restrictions on the commands run by exec actions, so
+// payload-derived data can't easily be used to escalate
+type execSandbox struct {
+	// Dir - the command's working directory (defaults to the responder's)
+	Dir string
+	// User - run the command as this user (name or uid)
+	User string
+	// Group - run the command with this group (name or gid - defaults to
+	// the user's primary group, and is required for uids with no passwd
+	// entry)
+	Group string
+	// Timeout - kill the command if it runs longer than this
+	Timeout time.Duration
+	// Cgroup - a cgroup v2 directory the responder can write to, in which
+	// a cgroup is created for each command (Linux only). Required for
+	// MemoryMB and CPUs.
+	Cgroup string
+	// MemoryMB - the command's memory limit, in megabytes
+	MemoryMB int64
+	// CPUs - the number of CPUs the command may use (e.g. 0.5)
+	CPUs float64
+}
+
+var sandbox execSandbox
+
+func addSandboxFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&sandbox.Dir, "exec-dir", "", "Run the action in this working directory")
+	cmd.Flags().StringVar(&sandbox.User, "exec-user", "", "Run the action as this user (name or uid - the responder must be allowed to switch users)")
+	cmd.Flags().StringVar(&sandbox.Group, "exec-group", "", "Run the action with this group (name or gid - defaults to --exec-user's primary group, and is required when --exec-user is a uid with no passwd entry)")
+	cmd.Flags().DurationVar(&sandbox.Timeout, "exec-timeout", 0, "Kill the action if it runs longer than this (e.g. 5m). 0 for no limit.")
+	cmd.Flags().StringVar(&sandbox.Cgroup, "exec-cgroup", "", "Run each action in its own cgroup under this delegated cgroup v2 directory (Linux only), e.g. /sys/fs/cgroup/github-responder")
+	cmd.Flags().Int64Var(&sandbox.MemoryMB, "exec-memory-mb", 0, "With --exec-cgroup, limit the action's memory to this many megabytes (0 for no limit)")
+	cmd.Flags().Float64Var(&sandbox.CPUs, "exec-cpus", 0, "With --exec-cgroup, limit the action to this many CPUs, e.g. 0.5 (0 for no limit)")
+}
+
+// validate checks the sandbox can be applied, before any action runs
+func (s execSandbox) validate() error {
+	if (s.MemoryMB > 0 || s.CPUs > 0) && s.Cgroup == "" {
+		return errors.New("--exec-memory-mb and --exec-cpus require --exec-cgroup")
+	}
+	if s.MemoryMB < 0 || s.CPUs < 0 {
+		return errors.New("--exec-memory-mb and --exec-cpus can't be negative")
+	}
+	if s.User != "" || s.Group != "" {
+		_, _, err := s.credential()
+		if err != nil {
+			return err
+		}
+	}
+	return checkSandboxSupport(s)
+}
+
+// credential resolves the user and group to a uid and gid
+func (s execSandbox) credential() (uint32, uint32, error) {
+	var uid, gid uint64
+	if s.User != "" {
+		u, err := lookupUser(s.User)
+		if err != nil {
+			return 0, 0, err
+		}
+		uid, err = strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "user %s has non-numeric uid %s", s.User, u.Uid)
+		}
+		if u.Gid == "" && s.Group == "" {
+			// never fall back to gid 0
+			return 0, 0, errors.Errorf("uid %s has no passwd entry - --exec-group must be given too", s.User)
+		}
+		if u.Gid != "" {
+			gid, err = strconv.ParseUint(u.Gid, 10, 32)
+			if err != nil {
+				return 0, 0, errors.Wrapf(err, "user %s has non-numeric gid %s", s.User, u.Gid)
+			}
+		}
+	}
+	if s.Group != "" {
+		g, err := lookupGroup(s.Group)
+		if err != nil {
+			return 0, 0, err
+		}
+		gid, err = strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "group %s has non-numeric gid %s", s.Group, g.Gid)
+		}
+	}
+	return uint32(uid), uint32(gid), nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		u, err := user.LookupId(name)
+		if err == nil {
+			return u, nil
+		}
+		// a uid without a passwd entry has no primary group
+		return &user.User{Uid: name}, nil
+	}
+	u, err := user.Lookup(name)
+	return u, errors.Wrapf(err, "unknown user %s", name)
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return &user.Group{Gid: name}, nil
+	}
+	g, err := user.LookupGroup(name)
+	return g, errors.Wrapf(err, "unknown group %s", name)
+}
+
+// apply restricts the command, before it's started. The returned cleanup
+// func must be called once it's exited. The command is run in its own
+// process group, so processes it starts are killed along with it when it's
+// cancelled (e.g. timed out).
+func (s execSandbox) apply(c *exec.Cmd, deliveryID string) (func(), error) {
+	c.Dir = s.Dir
+	killProcessGroup(c)
+	if s.User != "" || s.Group != "" {
+		uid, gid, err := s.credential()
+		if err != nil {
+			return nil, err
+		}
+		err = setCredential(c, uid, gid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.Cgroup == "" {
+		return func() {}, nil
+	}
+	return joinCgroup(c, s.Cgroup, cgroupName(deliveryID), s.MemoryMB, s.CPUs)
+}
+
+// cgroupName - a unique cgroup name for the delivery's command
+func cgroupName(deliveryID string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '.' {
+			return '-'
+		}
+		return r
+	}, deliveryID)
+	return "delivery-" + name + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// cpuMax - the cgroup v2 cpu.max value limiting to the given number of CPUs
+func cpuMax(cpus float64) string {
+	const period = 100000
+	return strconv.FormatInt(int64(cpus*period), 10) + " " + strconv.Itoa(period)
+}
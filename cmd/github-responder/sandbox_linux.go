@@ -0,0 +1,109 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+func checkSandboxSupport(s execSandbox) error {
+	if s.Cgroup == "" {
+		return nil
+	}
+	_, err := os.Stat(filepath.Join(s.Cgroup, "cgroup.controllers"))
+	return errors.Wrapf(err, "%s isn't a cgroup v2 directory", s.Cgroup)
+}
+
+func setCredential(c *exec.Cmd, uid, gid uint32) error {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Credential = &syscall.Credential{Uid: uid, Gid: gid}
+	return nil
+}
+
+// killProcessGroup runs the command in its own process group, and kills the
+// whole group when the command's context is done
+func killProcessGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Setpgid = true
+	c.Cancel = func() error {
+		return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// joinCgroup creates a cgroup with the limits, and starts the command in it
+// (so there's no window before the limits apply). Any processes left in the
+// cgroup are killed before it's removed.
+func joinCgroup(c *exec.Cmd, parent, name string, memoryMB int64, cpus float64) (func(), error) {
+	dir := filepath.Join(parent, name)
+	err := os.Mkdir(dir, 0755)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cgroup")
+	}
+	remove := func() {
+		err := killCgroup(dir)
+		if err != nil {
+			log.Warn().Err(err).Str("cgroup", dir).Msg("failed to remove cgroup")
+		}
+	}
+	limits := map[string]string{}
+	if memoryMB > 0 {
+		limits["memory.max"] = strconv.FormatInt(memoryMB*1024*1024, 10)
+		// don't let the limit be sidestepped by swapping
+		limits["memory.swap.max"] = "0"
+	}
+	if cpus > 0 {
+		limits["cpu.max"] = cpuMax(cpus)
+	}
+	for f, v := range limits {
+		err = ioutil.WriteFile(filepath.Join(dir, f), []byte(v), 0644)
+		if err != nil && !(f == "memory.swap.max" && os.IsNotExist(err)) {
+			remove()
+			return nil, errors.Wrapf(err, "failed to set %s (is the controller enabled in %s/cgroup.subtree_control?)", f, parent)
+		}
+	}
+	fd, err := os.Open(dir)
+	if err != nil {
+		remove()
+		return nil, errors.Wrap(err, "failed to open cgroup")
+	}
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.UseCgroupFD = true
+	c.SysProcAttr.CgroupFD = int(fd.Fd())
+	return func() {
+		_ = fd.Close()
+		remove()
+	}, nil
+}
+
+// killCgroup kills any processes in the cgroup (with cgroup.kill, on kernels
+// that have it), and removes it once they're gone
+func killCgroup(dir string) error {
+	err := ioutil.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0644)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to kill cgroup")
+	}
+	// killed processes take a moment to leave
+	for i := 0; ; i++ {
+		err = os.Remove(dir)
+		if perr, ok := err.(*os.PathError); !ok || perr.Err != syscall.EBUSY || i == 50 {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+func checkSandboxSupport(s execSandbox) error {
+	if s.Cgroup != "" {
+		return errors.New("--exec-cgroup is only supported on Linux")
+	}
+	if s.User != "" || s.Group != "" {
+		return errors.New("--exec-user and --exec-group are only supported on Linux")
+	}
+	return nil
+}
+
+func setCredential(c *exec.Cmd, uid, gid uint32) error {
+	return errors.New("running actions as another user is only supported on Linux")
+}
+
+func killProcessGroup(c *exec.Cmd) {}
+
+func joinCgroup(c *exec.Cmd, parent, name string, memoryMB int64, cpus float64) (func(), error) {
+	return nil, errors.New("cgroups are only supported on Linux")
+}
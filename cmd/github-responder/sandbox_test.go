@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxValidate(t *testing.T) {
+	assert.NoError(t, execSandbox{}.validate())
+	assert.NoError(t, execSandbox{Dir: "/tmp", Timeout: time.Second}.validate())
+	assert.Error(t, execSandbox{MemoryMB: 100}.validate())
+	assert.Error(t, execSandbox{CPUs: 0.5}.validate())
+	assert.Error(t, execSandbox{Cgroup: "/tmp", MemoryMB: -1}.validate())
+	assert.Error(t, execSandbox{User: "no-such-user-here"}.validate())
+}
+
+func TestSandboxCredential(t *testing.T) {
+	// a uid with no passwd entry has no group to fall back to
+	_, _, err := execSandbox{User: "1234"}.credential()
+	assert.Error(t, err)
+
+	uid, gid, err := execSandbox{User: "1234", Group: "5678"}.credential()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1234, uid)
+	assert.EqualValues(t, 5678, gid)
+}
+
+func TestSandboxApply(t *testing.T) {
+	c := exec.Command("true")
+	cleanup, err := execSandbox{Dir: "/tmp"}.apply(c, "abc")
+	assert.NoError(t, err)
+	cleanup()
+	assert.Equal(t, "/tmp", c.Dir)
+}
+
+func TestCgroupName(t *testing.T) {
+	name := cgroupName("../a/b")
+	assert.True(t, strings.HasPrefix(name, "delivery----a-b-"), name)
+	assert.NotContains(t, name, "/")
+}
+
+func TestCPUMax(t *testing.T) {
+	assert.Equal(t, "50000 100000", cpuMax(0.5))
+	assert.Equal(t, "200000 100000", cpuMax(2))
+}
+
+func TestExecTimeout(t *testing.T) {
+	h := execArgsE(nil, execSandbox{Timeout: 50 * time.Millisecond}, "sh", "-c", "sleep 5")
+	start := time.Now()
+	err := h(context.Background(), "push", "1", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.True(t, time.Since(start) < 4*time.Second)
+}
+
+func TestExecTimeoutKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process groups are only killed on Linux")
+	}
+	dir, err := ioutil.TempDir("", "exec")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "pid")
+
+	h := execArgsE(nil, execSandbox{Timeout: 200 * time.Millisecond}, "sh", "-c", "sleep 30 & echo $! > "+pidFile+"; wait")
+	assert.Error(t, h(context.Background(), "push", "1", nil))
+
+	b, err := ioutil.ReadFile(pidFile)
+	assert.NoError(t, err)
+	stat := filepath.Join("/proc", strings.TrimSpace(string(b)), "stat")
+	alive := true
+	for i := 0; i < 100 && alive; i++ {
+		s, err := ioutil.ReadFile(stat)
+		// gone, or a zombie waiting to be reaped
+		alive = err == nil && !strings.Contains(string(s), ") Z ")
+		if alive {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	assert.False(t, alive, "the grandchild outlived the timeout")
+}
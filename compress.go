@@ -0,0 +1,151 @@
+package responder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Compression - a compression format for stored deliveries (see
+// Options.Compression). gzip is built in - register others (e.g. zstd)
+// with RegisterCompression.
+type Compression interface {
+	// Name - the format's name, as given to NewCompression
+	Name() string
+	// Magic - the bytes compressed data starts with, so it's recognized
+	// (and decompressed) when read back
+	Magic() []byte
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+// CompressionFactory - creates a Compression at a level. Level 0 means the
+// format's default level.
+type CompressionFactory func(level int) (Compression, error)
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[string]CompressionFactory{
+		"gzip": NewGzipCompression,
+	}
+)
+
+// RegisterCompression - make a compression format available to
+// NewCompression (and so the --compression flag), and recognize data it
+// compressed when reading stored deliveries
+func RegisterCompression(name string, f CompressionFactory) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions[name] = f
+}
+
+// NewCompression - the named compression format, at the level (0 for the
+// format's default)
+func NewCompression(name string, level int) (Compression, error) {
+	compressionsMu.RLock()
+	f, ok := compressions[name]
+	names := make([]string, 0, len(compressions))
+	for n := range compressions {
+		names = append(names, n)
+	}
+	compressionsMu.RUnlock()
+	if !ok {
+		sort.Strings(names)
+		return nil, errors.Errorf("unknown compression %q - must be one of %s, or registered with RegisterCompression", name, strings.Join(names, ", "))
+	}
+	return f(level)
+}
+
+// ParseCompression - the compression format described by spec, a name
+// optionally followed by a level, like "gzip" or "gzip:9"
+func ParseCompression(spec string) (Compression, error) {
+	name, level := spec, 0
+	if i := strings.Index(spec, ":"); i >= 0 {
+		var err error
+		name = spec[:i]
+		level, err = strconv.Atoi(spec[i+1:])
+		if err != nil {
+			return nil, errors.Errorf("invalid compression level in %q", spec)
+		}
+	}
+	return NewCompression(name, level)
+}
+
+// compress compresses b with c, when given
+func compress(c Compression, b []byte) ([]byte, error) {
+	if c == nil {
+		return b, nil
+	}
+	out, err := c.Compress(b)
+	return out, errors.Wrapf(err, "failed to %s-compress", c.Name())
+}
+
+// decompress decompresses b if it was compressed in a registered format,
+// and returns it as it is otherwise
+func decompress(b []byte) ([]byte, error) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	for name, f := range compressions {
+		c, err := f(0)
+		if err != nil || len(c.Magic()) == 0 || !bytes.HasPrefix(b, c.Magic()) {
+			continue
+		}
+		out, err := c.Decompress(b)
+		return out, errors.Wrapf(err, "failed to %s-decompress", name)
+	}
+	return b, nil
+}
+
+// GzipCompression - gzip compression, at a compress/gzip level
+type GzipCompression struct {
+	Level int
+}
+
+// NewGzipCompression - gzip compression at the level, from
+// gzip.HuffmanOnly (-2) to gzip.BestCompression (9). Level 0 means
+// gzip.DefaultCompression, rather than gzip.NoCompression.
+func NewGzipCompression(level int) (Compression, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, errors.Errorf("invalid gzip level %d - must be from %d to %d", level, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	return GzipCompression{level}, nil
+}
+
+// Name - "gzip"
+func (GzipCompression) Name() string { return "gzip" }
+
+// Magic - gzip's header
+func (GzipCompression) Magic() []byte { return []byte{0x1f, 0x8b} }
+
+// Compress - gzip b
+func (g GzipCompression) Compress(b []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := gzip.NewWriterLevel(buf, g.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(b); err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	return buf.Bytes(), err
+}
+
+// Decompress - gunzip b
+func (GzipCompression) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
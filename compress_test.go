@@ -0,0 +1,144 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reverseCompression - a stand-in for a registered format
+type reverseCompression struct{}
+
+func (reverseCompression) Name() string  { return "reverse" }
+func (reverseCompression) Magic() []byte { return []byte("REV:") }
+func (reverseCompression) Compress(b []byte) ([]byte, error) {
+	out := []byte("REV:")
+	for i := len(b) - 1; i >= 0; i-- {
+		out = append(out, b[i])
+	}
+	return out, nil
+}
+func (c reverseCompression) Decompress(b []byte) ([]byte, error) {
+	b = bytes.TrimPrefix(b, c.Magic())
+	out := make([]byte, 0, len(b))
+	for i := len(b) - 1; i >= 0; i-- {
+		out = append(out, b[i])
+	}
+	return out, nil
+}
+
+func TestCompression(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"action":"opened"}`), 100)
+	for _, spec := range []string{"gzip", "gzip:9", "gzip:-2"} {
+		c, err := ParseCompression(spec)
+		assert.NoError(t, err, spec)
+		b, err := compress(c, data)
+		assert.NoError(t, err)
+		assert.True(t, len(b) < len(data), spec)
+		out, err := decompress(b)
+		assert.NoError(t, err)
+		assert.Equal(t, data, out)
+	}
+
+	// uncompressed data is returned as it is
+	out, err := decompress(data)
+	assert.NoError(t, err)
+	assert.Equal(t, data, out)
+	b, err := compress(nil, data)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	for _, spec := range []string{"zstd", "gzip:10", "gzip:x"} {
+		_, err = ParseCompression(spec)
+		assert.Error(t, err, spec)
+	}
+
+	RegisterCompression("reverse", func(int) (Compression, error) { return reverseCompression{}, nil })
+	defer func() {
+		compressionsMu.Lock()
+		delete(compressions, "reverse")
+		compressionsMu.Unlock()
+	}()
+	c, err := ParseCompression("reverse")
+	assert.NoError(t, err)
+	b, err = compress(c, []byte("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "REV:cba", string(b))
+	out, err = decompress(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(out))
+}
+
+func TestRecordCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, priv := testAppKey(t)
+	w, err := NewRSAKeyWrapper(priv)
+	assert.NoError(t, err)
+	u, err := NewRSAKeyUnwrapper(priv)
+	assert.NoError(t, err)
+	gz, err := NewGzipCompression(0)
+	assert.NoError(t, err)
+
+	payload := []byte(`{"action":"opened"}`)
+	r := &Responder{compression: gz}
+	assert.NoError(t, r.RecordDeliveries(dir))
+	id, err := r.recorder.Put(&Delivery{EventType: "push", DeliveryID: "abc", Headers: http.Header{}, Payload: payload})
+	assert.NoError(t, err)
+	raw, err := ioutil.ReadFile(filepath.Join(dir, id))
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, gz.Magic()))
+	d, err := ReadDelivery(filepath.Join(dir, id))
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(payload), string(d.Payload))
+
+	assert.NoError(t, r.RecordEncryptedDeliveries(dir, w))
+	id, err = r.recorder.Put(&Delivery{EventType: "push", DeliveryID: "def", Headers: http.Header{}, Payload: payload})
+	assert.NoError(t, err)
+	d, err = OpenDelivery(filepath.Join(dir, id), u)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(payload), string(d.Payload))
+}
+
+func TestQueueSnapshotCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "queue.json")
+
+	gz, err := NewGzipCompression(0)
+	assert.NoError(t, err)
+	runs := []scheduledRun{{Handler: "h", Delivery: &Delivery{EventType: "push", Payload: []byte(`{}`)}}}
+	assert.NoError(t, writeQueueSnapshot(path, gz, runs))
+	raw, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, gz.Magic()))
+	b, err := decompress(raw)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"handler":"h"`)
+}
+
+func TestWriteAheadLogCompressed(t *testing.T) {
+	gz, err := NewGzipCompression(0)
+	assert.NoError(t, err)
+	s := NewMemoryStateStore()
+	r := &Responder{wal: s, compression: gz}
+	assert.NoError(t, r.putWAL("k", walEntry{Delivery: &Delivery{EventType: "push", Payload: []byte(`{}`)}, Attempts: 1}))
+	raw, err := s.Get(walNamespace, "k")
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, gz.Magic()))
+
+	b, err := decompress(raw)
+	assert.NoError(t, err)
+	e := walEntry{}
+	assert.NoError(t, json.Unmarshal(b, &e))
+	assert.Equal(t, "push", e.Delivery.EventType)
+}
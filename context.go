@@ -0,0 +1,48 @@
+package responder
+
+import (
+	"context"
+
+	"github.com/google/go-github/v24/github"
+)
+
+type ctxKey int
+
+const (
+	clientKey ctxKey = iota
+	deliveryInfoKey
+	trackedDeliveryKey
+	shadowKey
+	handlerKey
+	annotationsKey
+	stateKey
+	scheduleKey
+	priorChecksKey
+	attemptKey
+	failuresKey
+	routeAnnotationsKey
+)
+
+func withGitHubClient(ctx context.Context, client *github.Client) context.Context {
+	return context.WithValue(ctx, clientKey, client)
+}
+
+// GitHubClient - returns the GitHub API client the Responder was configured
+// with, for use by handlers. When authenticating as a GitHub App, the client
+// is scoped to the installation the event came from. Returns nil if the
+// context didn't come from a Responder.
+func GitHubClient(ctx context.Context) *github.Client {
+	client, _ := ctx.Value(clientKey).(*github.Client)
+	return client
+}
+
+func withDeliveryInfo(ctx context.Context, info *DeliveryInfo) context.Context {
+	return context.WithValue(ctx, deliveryInfoKey, info)
+}
+
+// GetDeliveryInfo - returns metadata about the delivery being handled. Returns
+// nil if the context didn't come from a Responder.
+func GetDeliveryInfo(ctx context.Context) *DeliveryInfo {
+	info, _ := ctx.Value(deliveryInfoKey).(*DeliveryInfo)
+	return info
+}
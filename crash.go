@@ -0,0 +1,248 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// crashCleanupTimeout - how long hooks are given to be deactivated before a
+// crash
+const crashCleanupTimeout = 10 * time.Second
+
+// CrashReport - written (as JSON) to the crash report directory when a
+// handler panics, for post-mortem analysis. See Options.CrashReportDir.
+type CrashReport struct {
+	Time       time.Time `json:"time"`
+	Panic      string    `json:"panic"`
+	Handler    string    `json:"handler"`
+	EventType  string    `json:"event_type"`
+	DeliveryID string    `json:"delivery_id"`
+	// Active - the deliveries whose handlers were running
+	Active []ActiveDelivery `json:"active"`
+	// Queue - what was waiting in the scheduler
+	Queue QueueSummary `json:"queue"`
+	// Stacks - the stack traces of every goroutine
+	Stacks string `json:"stacks"`
+}
+
+// ActiveDelivery - a delivery whose handlers are running
+type ActiveDelivery struct {
+	DeliveryID string    `json:"delivery_id"`
+	EventType  string    `json:"event_type"`
+	Started    time.Time `json:"started"`
+}
+
+// QueueSummary - counts of scheduled runs waiting to be run
+type QueueSummary struct {
+	FollowUps int            `json:"follow_ups"`
+	Deferred  int            `json:"deferred"`
+	ByHandler map[string]int `json:"by_handler,omitempty"`
+}
+
+// crashReporter - keeps what a crash report needs
+type crashReporter struct {
+	dir string
+
+	mu       sync.Mutex
+	active   map[string]ActiveDelivery
+	cleanups []func() error
+}
+
+func newCrashReporter(dir string) *crashReporter {
+	if dir == "" {
+		return nil
+	}
+	return &crashReporter{dir: dir, active: map[string]ActiveDelivery{}}
+}
+
+// onCrash - remember how to deactivate hooks if a panic is about to crash
+// the process
+func (c *crashReporter) onCrash(cleanup func() error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanups = append(c.cleanups, cleanup)
+}
+
+// reportPanics - wrap the handler so that panics are reported before being
+// passed on, whether or not anything recovers them
+func (r *Responder) reportPanics(name string, h HookHandler) HookHandler {
+	if r.crash == nil {
+		return h
+	}
+	return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		defer func() {
+			if p := recover(); p != nil {
+				path, err := r.writeCrashReport(fmt.Sprint(p), name, eventType, deliveryID)
+				if err != nil {
+					zerolog.Ctx(ctx).Error().Err(err).Msg("failed to write crash report")
+				} else {
+					zerolog.Ctx(ctx).Error().Str("crashReport", path).Msgf("handler panicked: %v", p)
+				}
+				panic(p)
+			}
+		}()
+		h(ctx, eventType, deliveryID, payload)
+	}
+}
+
+// guarded - wrap the routes' handlers so the delivery is listed as active
+// while they run, and so that a panic nothing else recovers deactivates the
+// hooks before the process crashes, so GitHub doesn't keep delivering to it
+func (r *Responder) guarded(routes []Route, eventType, deliveryID string) []Route {
+	c := r.crash
+	if c == nil {
+		return routes
+	}
+	c.mu.Lock()
+	c.active[deliveryID] = ActiveDelivery{DeliveryID: deliveryID, EventType: eventType, Started: r.clock()}
+	c.mu.Unlock()
+
+	var (
+		mu        sync.Mutex
+		remaining int
+	)
+	for _, rt := range routes {
+		if rt.wants(eventType) {
+			remaining++
+		}
+	}
+	guarded := make([]Route, len(routes))
+	for i, rt := range routes {
+		h := rt.Handler
+		guarded[i] = rt
+		guarded[i].Handler = func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			defer func() {
+				mu.Lock()
+				remaining--
+				if remaining == 0 {
+					c.mu.Lock()
+					delete(c.active, deliveryID)
+					c.mu.Unlock()
+				}
+				mu.Unlock()
+				if p := recover(); p != nil {
+					c.deactivateHooks()
+					panic(p)
+				}
+			}()
+			h(ctx, eventType, deliveryID, payload)
+		}
+	}
+	return guarded
+}
+
+// deactivateHooks - best-effort removal of the registered hooks
+func (c *crashReporter) deactivateHooks() {
+	c.mu.Lock()
+	cleanups := c.cleanups
+	c.cleanups = nil
+	c.mu.Unlock()
+	if len(cleanups) == 0 {
+		return
+	}
+	log.Error().Msg("handler panic is crashing the process - deactivating hooks")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, f := range cleanups {
+			if err := f(); err != nil {
+				log.Error().Err(err).Msg("failed to deactivate hooks")
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(crashCleanupTimeout):
+		log.Error().Msg("timed out deactivating hooks")
+	}
+}
+
+func (r *Responder) writeCrashReport(p, handler, eventType, deliveryID string) (string, error) {
+	now := r.clock().UTC()
+	report := CrashReport{
+		Time:       now,
+		Panic:      p,
+		Handler:    handler,
+		EventType:  eventType,
+		DeliveryID: deliveryID,
+		Queue:      r.queueSummary(),
+		Stacks:     allStacks(),
+	}
+	r.crash.mu.Lock()
+	for _, a := range r.crash.active {
+		report.Active = append(report.Active, a)
+	}
+	r.crash.mu.Unlock()
+	sort.Slice(report.Active, func(i, j int) bool {
+		return report.Active[i].Started.Before(report.Active[j].Started)
+	})
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode crash report")
+	}
+	err = os.MkdirAll(r.crash.dir, 0700)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create crash report directory")
+	}
+	path := filepath.Join(r.crash.dir, fmt.Sprintf("crash-%s-%s.json", now.Format("20060102T150405.000000000"), deliveryID))
+	err = ioutil.WriteFile(path, b, 0600)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write crash report")
+	}
+	return path, nil
+}
+
+// queueSummary - counts the scheduled runs, as best it can
+func (r *Responder) queueSummary() QueueSummary {
+	q := QueueSummary{ByHandler: map[string]int{}}
+	s := r.scheduleStore()
+	keys, err := s.Keys(scheduleNamespace)
+	if err != nil {
+		return q
+	}
+	for _, k := range keys {
+		b, err := s.Get(scheduleNamespace, k)
+		if err != nil || b == nil {
+			continue
+		}
+		run := scheduledRun{}
+		if json.Unmarshal(b, &run) != nil {
+			continue
+		}
+		if run.Deferred {
+			q.Deferred++
+		} else {
+			q.FollowUps++
+		}
+		q.ByHandler[run.Handler]++
+	}
+	return q
+}
+
+// allStacks - the stack traces of every goroutine
+func allStacks() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 16<<20 {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,64 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrashReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crash")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r := &Responder{crash: newCrashReporter(dir)}
+	err = r.schedule(scheduledRun{Handler: "0.0", Delivery: &Delivery{}, Deferred: true})
+	assert.NoError(t, err)
+	cleaned := false
+	r.crash.onCrash(func() error {
+		cleaned = true
+		return nil
+	})
+
+	h := r.reportPanics("0.0", func(context.Context, string, string, []byte) {
+		panic("boom")
+	})
+	routes := r.guarded([]Route{{Handler: h}}, "push", "abc")
+	assert.Panics(t, func() {
+		routes[0].Handler(context.Background(), "push", "abc", nil)
+	})
+	assert.True(t, cleaned)
+	assert.Empty(t, r.crash.active)
+
+	files, err := filepath.Glob(filepath.Join(dir, "crash-*-abc.json"))
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 1) {
+		return
+	}
+	b, err := ioutil.ReadFile(files[0])
+	assert.NoError(t, err)
+	report := CrashReport{}
+	assert.NoError(t, json.Unmarshal(b, &report))
+	assert.Equal(t, "boom", report.Panic)
+	assert.Equal(t, "0.0", report.Handler)
+	assert.Equal(t, "abc", report.DeliveryID)
+	if !assert.Len(t, report.Active, 1) {
+		return
+	}
+	assert.Equal(t, "push", report.Active[0].EventType)
+	assert.Equal(t, 1, report.Queue.Deferred)
+	assert.Equal(t, map[string]int{"0.0": 1}, report.Queue.ByHandler)
+	assert.Contains(t, report.Stacks, "goroutine")
+}
+
+func TestCrashReportDisabled(t *testing.T) {
+	r := &Responder{}
+	routes := []Route{{Handler: func(context.Context, string, string, []byte) {}}}
+	assert.Len(t, r.guarded(routes, "push", "abc"), 1)
+	r.crash.onCrash(func() error { return nil })
+}
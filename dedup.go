@@ -0,0 +1,88 @@
+package responder
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// dedupNamespace - the state store namespace seen delivery IDs are kept in
+const dedupNamespace = "responder.dedup"
+
+var duplicates = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "github",
+	Subsystem: "webhook",
+	Name:      "duplicate_deliveries_total",
+	Help:      "Count of deliveries suppressed because their delivery ID was already handled, by event type.",
+}, []string{"event"})
+
+// dedupStore - the state store, so seen deliveries survive restarts when
+// it's persistent, or an in-memory store when there isn't one
+func (r *Responder) dedupStore() StateStore {
+	if r.state != nil {
+		return r.state
+	}
+	if r.dedupMem == nil {
+		r.dedupMem = NewMemoryStateStore()
+	}
+	return r.dedupMem
+}
+
+// duplicate - whether the delivery was already seen within the dedup TTL.
+// If not, it's remembered as seen. Always false when deduplication is off.
+func (r *Responder) duplicate(eventType, deliveryID string) bool {
+	if r.dedupTTL <= 0 || deliveryID == "" {
+		return false
+	}
+	r.dedupMu.Lock()
+	defer r.dedupMu.Unlock()
+	s := r.dedupStore()
+	now := r.clock()
+	if now.Sub(r.dedupPruned) > r.dedupTTL {
+		r.dedupPruned = now
+		pruneSeen(s, now.Add(-r.dedupTTL))
+	}
+
+	b, err := s.Get(dedupNamespace, deliveryID)
+	if err != nil {
+		log.Warn().Err(err).Str("deliveryID", deliveryID).Msg("failed to check for a duplicate delivery")
+	} else if seen, err := time.Parse(time.RFC3339Nano, string(b)); err == nil && now.Sub(seen) < r.dedupTTL {
+		duplicates.WithLabelValues(eventType).Inc()
+		return true
+	}
+	err = s.Put(dedupNamespace, deliveryID, []byte(now.Format(time.RFC3339Nano)))
+	if err != nil {
+		log.Warn().Err(err).Str("deliveryID", deliveryID).Msg("failed to remember delivery")
+	}
+	return false
+}
+
+// forgetDelivery - let the delivery be handled again, e.g. when it's
+// redelivered after failing
+func (r *Responder) forgetDelivery(deliveryID string) {
+	if r.dedupTTL <= 0 || deliveryID == "" {
+		return
+	}
+	r.dedupMu.Lock()
+	defer r.dedupMu.Unlock()
+	_ = r.dedupStore().Delete(dedupNamespace, deliveryID)
+}
+
+// pruneSeen removes the delivery IDs seen before the cutoff
+func pruneSeen(s StateStore, cutoff time.Time) {
+	keys, err := s.Keys(dedupNamespace)
+	if err != nil {
+		return
+	}
+	for _, k := range keys {
+		b, err := s.Get(dedupNamespace, k)
+		if err != nil {
+			continue
+		}
+		seen, err := time.Parse(time.RFC3339Nano, string(b))
+		if err != nil || seen.Before(cutoff) {
+			_ = s.Delete(dedupNamespace, k)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package responder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicate(t *testing.T) {
+	r := &Responder{}
+	assert.False(t, r.duplicate("push", "abc"))
+	assert.False(t, r.duplicate("push", "abc"))
+
+	r.dedupTTL = time.Hour
+	assert.False(t, r.duplicate("push", "abc"))
+	assert.True(t, r.duplicate("push", "abc"))
+	assert.False(t, r.duplicate("push", ""))
+	assert.False(t, r.duplicate("push", ""))
+
+	r.forgetDelivery("abc")
+	assert.False(t, r.duplicate("push", "abc"))
+
+	// persisted in the state store, when there is one
+	s := NewMemoryStateStore()
+	r.SetStateStore(s)
+	assert.False(t, r.duplicate("push", "def"))
+	keys, err := s.Keys(dedupNamespace)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"def"}, keys)
+
+	// expired IDs are pruned, and handled again
+	_ = s.Put(dedupNamespace, "def", []byte(time.Now().Add(-2*time.Hour).Format(time.RFC3339Nano)))
+	r.dedupPruned = time.Time{}
+	assert.False(t, r.duplicate("push", "ghi"))
+	keys, _ = s.Keys(dedupNamespace)
+	assert.Equal(t, []string{"ghi"}, keys)
+	assert.False(t, r.duplicate("push", "def"))
+}
@@ -0,0 +1,94 @@
+package responder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+)
+
+// Delivery - a recorded webhook delivery, as stored in delivery files
+type Delivery struct {
+	EventType  string          `json:"event_type"`
+	DeliveryID string          `json:"delivery_id"`
+	Headers    http.Header     `json:"headers,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+	// ReceivedAt - when the delivery was received, if known
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// ReadDelivery - read a delivery file
+func ReadDelivery(path string) (*Delivery, error) {
+	return OpenDelivery(path, nil)
+}
+
+// OpenDelivery - read a delivery file, decrypting it with k if it was
+// recorded encrypted (see RecordEncryptedDeliveries)
+func OpenDelivery(path string, k KeyUnwrapper) (*Delivery, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read delivery %s", path)
+	}
+	s := &sealedDelivery{}
+	if json.Unmarshal(b, s) == nil && len(s.Ciphertext) > 0 {
+		if k == nil {
+			return nil, errors.Errorf("delivery %s is encrypted - a decryption key is needed", path)
+		}
+		b, err = s.open(k)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt delivery %s", path)
+		}
+	}
+	b, err = decompress(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read delivery %s", path)
+	}
+	d := &Delivery{}
+	err = json.Unmarshal(b, d)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse delivery %s", path)
+	}
+	if d.EventType == "" {
+		d.EventType = d.Headers.Get("X-GitHub-Event")
+	}
+	if d.DeliveryID == "" {
+		d.DeliveryID = d.Headers.Get("X-GitHub-Delivery")
+	}
+	if d.EventType == "" || len(d.Payload) == 0 {
+		return nil, errors.Errorf("invalid delivery %s - event_type and payload are required", path)
+	}
+	return d, nil
+}
+
+// FindDelivery - find the delivery file for the given delivery ID in dir. When
+// several match (the same delivery redelivered), the last is returned.
+func FindDelivery(dir, deliveryID string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+deliveryID+"*.json"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to search for delivery")
+	}
+	if len(matches) == 0 {
+		return "", errors.Wrapf(os.ErrNotExist, "no delivery %s in %s", deliveryID, dir)
+	}
+	sort.Strings(matches)
+	return matches[len(matches)-1], nil
+}
+
+// validate checks the delivery's recorded signature against the secret
+func (d *Delivery) validate(secret []byte) error {
+	sig := d.Headers.Get("X-Hub-Signature")
+	if sig == "" {
+		return newCauseError(ErrSignatureInvalid, "missing signature")
+	}
+	err := github.ValidateSignature(sig, d.Payload, secret)
+	if err != nil {
+		return signatureError(err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package responder
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// DeliveryInfo - metadata about a delivery, from its headers. Available to
+// handlers with GetDeliveryInfo.
+type DeliveryInfo struct {
+	EventType  string
+	DeliveryID string
+	// HookID - the ID of the webhook that sent the delivery
+	HookID int64
+	// TargetType - the type of resource the hook is installed on:
+	// "repository", "organization", or "integration" (for GitHub Apps)
+	TargetType string
+	// TargetID - the ID of the resource the hook is installed on
+	TargetID int64
+	// InstallationID - the GitHub App installation the event is for, from the
+	// payload. 0 when not delivered to a GitHub App.
+	InstallationID int64
+	// FollowUp - whether this is a follow-up run, scheduled by the handler
+	// with ScheduleFollowUp
+	FollowUp bool
+	// Truncated - whether GitHub left data out of the payload because it was
+	// too big (e.g. the commits of a large push), and it wasn't hydrated
+	// (see Options.HydrateTruncatedPayloads)
+	Truncated bool
+}
+
+func parseDeliveryInfo(header http.Header) *DeliveryInfo {
+	return &DeliveryInfo{
+		EventType:  header.Get("X-GitHub-Event"),
+		DeliveryID: header.Get("X-GitHub-Delivery"),
+		HookID:     headerInt(header, "X-GitHub-Hook-ID"),
+		TargetType: header.Get("X-GitHub-Hook-Installation-Target-Type"),
+		TargetID:   headerInt(header, "X-GitHub-Hook-Installation-Target-ID"),
+	}
+}
+
+// headerInt - the header's value as an integer, or 0 when missing or invalid
+func headerInt(header http.Header, key string) int64 {
+	i, err := strconv.ParseInt(header.Get(key), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
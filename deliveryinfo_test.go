@@ -0,0 +1,36 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeliveryInfo(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-GitHub-Delivery", "abc")
+	header.Set("X-GitHub-Hook-ID", "42")
+	header.Set("X-GitHub-Hook-Installation-Target-Type", "integration")
+	header.Set("X-GitHub-Hook-Installation-Target-ID", "123")
+	assert.Equal(t, &DeliveryInfo{
+		EventType:  "push",
+		DeliveryID: "abc",
+		HookID:     42,
+		TargetType: "integration",
+		TargetID:   123,
+	}, parseDeliveryInfo(header))
+
+	assert.Equal(t, &DeliveryInfo{}, parseDeliveryInfo(http.Header{"X-Github-Hook-Id": []string{"bogus"}}))
+	assert.Equal(t, &DeliveryInfo{}, parseDeliveryInfo(nil))
+}
+
+func TestGetDeliveryInfo(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, GetDeliveryInfo(ctx))
+
+	info := &DeliveryInfo{HookID: 1}
+	assert.Equal(t, info, GetDeliveryInfo(withDeliveryInfo(ctx, info)))
+}
@@ -0,0 +1,120 @@
+package responder
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// dnsPreflightTimeout - how long the DNS pre-flight check may take
+const dnsPreflightTimeout = 5 * time.Second
+
+// dnsPreflight checks, before hooks are registered, that the domain points
+// at this host (or the expected load balancer) - a hook for a domain that
+// points elsewhere is registered fine, but nothing ever arrives
+type dnsPreflight struct {
+	domain   string
+	expected []string
+	strict   bool
+	lookup   func(ctx context.Context, host string) ([]string, error)
+	local    func() ([]string, error)
+}
+
+func newDNSPreflight(opts Options) *dnsPreflight {
+	return &dnsPreflight{
+		domain:   opts.Domain,
+		expected: opts.ExpectedAddresses,
+		strict:   opts.StrictDNS,
+		lookup:   net.DefaultResolver.LookupHost,
+		local:    localAddrs,
+	}
+}
+
+// check - warn (or, when strict, fail with ErrDNSMismatch) when the domain
+// doesn't resolve to an expected address
+func (p *dnsPreflight) check(ctx context.Context) error {
+	if p == nil || p.domain == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, dnsPreflightTimeout)
+	defer cancel()
+
+	host := p.domain
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addrs, err := p.resolve(ctx, host)
+	if err != nil {
+		return p.problem("domain %s doesn't resolve: %v", host, err)
+	}
+
+	var expected []string
+	var what string
+	if len(p.expected) > 0 {
+		what = "the expected addresses"
+		for _, e := range p.expected {
+			a, err := p.resolve(ctx, e)
+			if err != nil {
+				return p.problem("expected address %s doesn't resolve: %v", e, err)
+			}
+			expected = append(expected, a...)
+		}
+	} else {
+		what = "this host's addresses (set the expected addresses if it's behind a load balancer or NAT)"
+		expected, err = p.local()
+		if err != nil {
+			return p.problem("can't list this host's addresses: %v", err)
+		}
+	}
+
+	for _, a := range addrs {
+		for _, e := range expected {
+			if sameIP(a, e) {
+				log.Debug().Str("domain", host).Str("address", a).Msg("DNS pre-flight check passed")
+				return nil
+			}
+		}
+	}
+	return p.problem("domain %s resolves to %s, not to %s %s - GitHub's deliveries won't arrive",
+		host, strings.Join(addrs, ", "), what, strings.Join(expected, ", "))
+}
+
+// resolve - the host's addresses, or the host itself if it's an IP address
+func (p *dnsPreflight) resolve(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	return p.lookup(ctx, host)
+}
+
+func (p *dnsPreflight) problem(format string, args ...interface{}) error {
+	err := newCauseError(ErrDNSMismatch, format, args...)
+	if p.strict {
+		return err
+	}
+	log.Warn().Msg(err.Error())
+	return nil
+}
+
+func sameIP(a, b string) bool {
+	ia, ib := net.ParseIP(a), net.ParseIP(b)
+	return ia != nil && ia.Equal(ib)
+}
+
+// localAddrs - the IP addresses of this host's interfaces
+func localAddrs() ([]string, error) {
+	ifAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, a := range ifAddrs {
+		if n, ok := a.(*net.IPNet); ok {
+			addrs = append(addrs, n.IP.String())
+		}
+	}
+	return addrs, nil
+}
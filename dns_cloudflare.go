@@ -0,0 +1,100 @@
+package responder
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/xenolf/lego/challenge/dns01"
+)
+
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// cloudflareDNS - solves DNS-01 challenges with Cloudflare's API
+type cloudflareDNS struct {
+	dnsAPI
+	token string
+}
+
+// NewCloudflareDNSProvider - a DNSProvider creating challenge records
+// through Cloudflare's API, with an API token that has the Zone:Read and
+// DNS:Edit permissions for the zones of the served domains
+func NewCloudflareDNSProvider(apiToken string) DNSProvider {
+	return &cloudflareDNS{dnsAPI: newDNSAPI(cloudflareAPI, nil), token: apiToken}
+}
+
+// cloudflareResponse - a Cloudflare API response listing zones or records.
+// Errors are reported with non-2xx statuses.
+type cloudflareResponse struct {
+	Result []struct {
+		ID string `json:"id"`
+	} `json:"result"`
+}
+
+type cloudflareRecord struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+func (p *cloudflareDNS) Present(domain, token, keyAuth string) error {
+	fqdn, value, zone, err := p.challengeRecord(domain, keyAuth)
+	if err != nil {
+		return err
+	}
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return err
+	}
+	record := cloudflareRecord{Type: "TXT", Name: dns01.UnFqdn(fqdn), Content: value, TTL: dnsRecordTTL}
+	// the result of a single-record request is an object, not the usual list
+	return p.do(http.MethodPost, "/zones/"+zoneID+"/dns_records", record, nil)
+}
+
+func (p *cloudflareDNS) CleanUp(domain, token, keyAuth string) error {
+	fqdn, value, zone, err := p.challengeRecord(domain, keyAuth)
+	if err != nil {
+		return err
+	}
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return err
+	}
+	q := url.Values{"type": {"TXT"}, "name": {dns01.UnFqdn(fqdn)}, "content": {value}}
+	var records cloudflareResponse
+	err = p.do(http.MethodGet, "/zones/"+zoneID+"/dns_records?"+q.Encode(), nil, &records)
+	if err != nil {
+		return err
+	}
+	for _, r := range records.Result {
+		err = p.do(http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID, nil, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zoneID - the ID of the zone with the name
+func (p *cloudflareDNS) zoneID(zone string) (string, error) {
+	var zones cloudflareResponse
+	err := p.do(http.MethodGet, "/zones?name="+url.QueryEscape(dns01.UnFqdn(zone)), nil, &zones)
+	if err != nil {
+		return "", err
+	}
+	if len(zones.Result) == 0 {
+		return "", errors.Errorf("Cloudflare zone %s not found - does the API token have Zone:Read permission?", dns01.UnFqdn(zone))
+	}
+	return zones.Result[0].ID, nil
+}
+
+// do - a Cloudflare API request
+func (p *cloudflareDNS) do(method, path string, body, out interface{}) error {
+	req, err := http.NewRequest(method, p.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	return p.doJSON(req, body, out)
+}
@@ -0,0 +1,54 @@
+package responder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xenolf/lego/challenge/dns01"
+)
+
+func TestCloudflareDNSProvider(t *testing.T) {
+	fqdn, value := dns01.GetRecord("hooks.example.com", "key-auth")
+	var created cloudflareRecord
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`))
+			return
+		}
+		switch req.Method + " " + req.URL.Path {
+		case "GET /zones":
+			assert.Equal(t, "example.com", req.URL.Query().Get("name"))
+			_, _ = w.Write([]byte(`{"success":true,"result":[{"id":"z1"}]}`))
+		case "POST /zones/z1/dns_records":
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&created))
+			_, _ = w.Write([]byte(`{"success":true,"result":{"id":"r1"}}`))
+		case "GET /zones/z1/dns_records":
+			assert.Equal(t, value, req.URL.Query().Get("content"))
+			_, _ = w.Write([]byte(`{"success":true,"result":[{"id":"r1"}]}`))
+		case "DELETE /zones/z1/dns_records/r1":
+			deleted = append(deleted, "r1")
+			_, _ = w.Write([]byte(`{"success":true,"result":{"id":"r1"}}`))
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewCloudflareDNSProvider("t0ken").(*cloudflareDNS)
+	p.baseURL = srv.URL
+	p.findZone = func(string) (string, error) { return "example.com.", nil }
+
+	assert.NoError(t, p.Present("hooks.example.com", "tok", "key-auth"))
+	assert.Equal(t, cloudflareRecord{Type: "TXT", Name: dns01.UnFqdn(fqdn), Content: value, TTL: dnsRecordTTL}, created)
+	assert.NoError(t, p.CleanUp("hooks.example.com", "tok", "key-auth"))
+	assert.Equal(t, []string{"r1"}, deleted)
+
+	p.token = "wrong"
+	assert.Contains(t, p.Present("hooks.example.com", "tok", "key-auth").Error(), "Invalid access token")
+}
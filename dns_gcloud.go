@@ -0,0 +1,207 @@
+package responder
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+const (
+	googleCloudDNSAPI   = "https://dns.googleapis.com/dns/v1"
+	googleCloudDNSScope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+	googleTokenURL      = "https://oauth2.googleapis.com/token"
+)
+
+// googleCloudDNS - solves DNS-01 challenges with the Google Cloud DNS API
+type googleCloudDNS struct {
+	dnsAPI
+	project string
+}
+
+// googleServiceAccount - the fields used from a service account key file
+type googleServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// NewGoogleCloudDNSProvider - a DNSProvider creating challenge records
+// through the Google Cloud DNS API, authenticating as the service account
+// with the JSON key, which needs the DNS Administrator role in its project.
+func NewGoogleCloudDNSProvider(serviceAccountKey []byte) (DNSProvider, error) {
+	var sa googleServiceAccount
+	err := json.Unmarshal(serviceAccountKey, &sa)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Google service account key")
+	}
+	if sa.ProjectID == "" || sa.ClientEmail == "" {
+		return nil, errors.New("invalid Google service account key - project_id or client_email missing")
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = googleTokenURL
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid Google service account key - private_key isn't PEM")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Google service account private key")
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid Google service account private key - must be RSA")
+	}
+
+	ts := &serviceAccountTokenSource{sa: sa, key: key, client: http.DefaultClient, now: time.Now}
+	client := oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, ts))
+	client.Timeout = 30 * time.Second
+	return &googleCloudDNS{dnsAPI: newDNSAPI(googleCloudDNSAPI, client), project: sa.ProjectID}, nil
+}
+
+type googleRRSet struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl"`
+	RRDatas []string `json:"rrdatas"`
+}
+
+func (p *googleCloudDNS) Present(domain, token, keyAuth string) error {
+	fqdn, value, zone, err := p.challengeRecord(domain, keyAuth)
+	if err != nil {
+		return err
+	}
+	zoneName, err := p.zoneName(zone)
+	if err != nil {
+		return err
+	}
+	// an existing record set (e.g. left by an interrupted run) must be
+	// replaced, as additions can't overwrite it
+	existing, err := p.rrsets(zoneName, fqdn)
+	if err != nil {
+		return err
+	}
+	change := map[string][]googleRRSet{
+		"additions": {{Name: fqdn, Type: "TXT", TTL: dnsRecordTTL, RRDatas: []string{quoteTXT(value)}}},
+	}
+	if len(existing) > 0 {
+		change["deletions"] = existing
+	}
+	return p.changes(zoneName, change)
+}
+
+func (p *googleCloudDNS) CleanUp(domain, token, keyAuth string) error {
+	fqdn, _, zone, err := p.challengeRecord(domain, keyAuth)
+	if err != nil {
+		return err
+	}
+	zoneName, err := p.zoneName(zone)
+	if err != nil {
+		return err
+	}
+	existing, err := p.rrsets(zoneName, fqdn)
+	if err != nil || len(existing) == 0 {
+		return err
+	}
+	return p.changes(zoneName, map[string][]googleRRSet{"deletions": existing})
+}
+
+// zoneName - the name of the project's managed zone for the DNS name
+func (p *googleCloudDNS) zoneName(zone string) (string, error) {
+	var zones struct {
+		ManagedZones []struct {
+			Name string `json:"name"`
+		} `json:"managedZones"`
+	}
+	err := p.do(http.MethodGet, "/managedZones?dnsName="+url.QueryEscape(zone), nil, &zones)
+	if err != nil {
+		return "", err
+	}
+	if len(zones.ManagedZones) == 0 {
+		return "", errors.Errorf("Google Cloud DNS zone %s not found in project %s", zone, p.project)
+	}
+	return zones.ManagedZones[0].Name, nil
+}
+
+// rrsets - the zone's TXT record sets with the name
+func (p *googleCloudDNS) rrsets(zoneName, fqdn string) ([]googleRRSet, error) {
+	var sets struct {
+		RRSets []googleRRSet `json:"rrsets"`
+	}
+	q := url.Values{"name": {fqdn}, "type": {"TXT"}}
+	err := p.do(http.MethodGet, "/managedZones/"+zoneName+"/rrsets?"+q.Encode(), nil, &sets)
+	return sets.RRSets, err
+}
+
+func (p *googleCloudDNS) changes(zoneName string, change map[string][]googleRRSet) error {
+	return p.do(http.MethodPost, "/managedZones/"+zoneName+"/changes", change, nil)
+}
+
+// do - a request to the project's Cloud DNS API
+func (p *googleCloudDNS) do(method, path string, body, out interface{}) error {
+	req, err := http.NewRequest(method, p.baseURL+"/projects/"+p.project+path, nil)
+	if err != nil {
+		return err
+	}
+	return p.doJSON(req, body, out)
+}
+
+// serviceAccountTokenSource - gets access tokens for a Google service
+// account, with a JWT signed by its key (see
+// https://developers.google.com/identity/protocols/oauth2/service-account)
+type serviceAccountTokenSource struct {
+	sa     googleServiceAccount
+	key    *rsa.PrivateKey
+	client *http.Client
+	now    func() time.Time
+}
+
+// Token - see oauth2.TokenSource
+func (s *serviceAccountTokenSource) Token() (*oauth2.Token, error) {
+	now := s.now()
+	assertion, err := signJWT(s.key, map[string]interface{}{
+		"iss":   s.sa.ClientEmail,
+		"scope": googleCloudDNSScope,
+		"aud":   s.sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign service account JWT")
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	resp, err := s.client.Post(s.sa.TokenURI, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get a Google access token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, apiError(resp.Request, resp)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&tok)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Google access token response")
+	}
+	return &oauth2.Token{
+		AccessToken: tok.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      now.Add(time.Duration(tok.ExpiresIn) * time.Second),
+	}, nil
+}
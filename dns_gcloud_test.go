@@ -0,0 +1,77 @@
+package responder
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xenolf/lego/challenge/dns01"
+)
+
+func TestGoogleCloudDNSProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	fqdn, value := dns01.GetRecord("hooks.example.com", "key-auth")
+	var changes []map[string][]googleRRSet
+	var existing []googleRRSet
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			assert.NoError(t, req.ParseForm())
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", req.Form.Get("grant_type"))
+			_, _ = w.Write([]byte(`{"access_token":"acc3ss","expires_in":3600,"token_type":"Bearer"}`))
+			return
+		}
+		assert.Equal(t, "Bearer acc3ss", req.Header.Get("Authorization"))
+		switch req.Method + " " + req.URL.Path {
+		case "GET /projects/proj/managedZones":
+			assert.Equal(t, "example.com.", req.URL.Query().Get("dnsName"))
+			_, _ = w.Write([]byte(`{"managedZones":[{"name":"example-com"}]}`))
+		case "GET /projects/proj/managedZones/example-com/rrsets":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"rrsets": existing})
+		case "POST /projects/proj/managedZones/example-com/changes":
+			var c map[string][]googleRRSet
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&c))
+			changes = append(changes, c)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	sa, _ := json.Marshal(googleServiceAccount{
+		ProjectID:   "proj",
+		ClientEmail: "dns@proj.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    srv.URL + "/token",
+	})
+	provider, err := NewGoogleCloudDNSProvider(sa)
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := provider.(*googleCloudDNS)
+	p.baseURL = srv.URL
+	p.findZone = func(string) (string, error) { return "example.com.", nil }
+
+	assert.NoError(t, p.Present("hooks.example.com", "tok", "key-auth"))
+	record := googleRRSet{Name: fqdn, Type: "TXT", TTL: dnsRecordTTL, RRDatas: []string{`"` + value + `"`}}
+	existing = []googleRRSet{record}
+	assert.NoError(t, p.CleanUp("hooks.example.com", "tok", "key-auth"))
+	assert.Equal(t, []map[string][]googleRRSet{
+		{"additions": {record}},
+		{"deletions": {record}},
+	}, changes)
+
+	_, err = NewGoogleCloudDNSProvider([]byte(`{"project_id":"proj"}`))
+	assert.Error(t, err)
+}
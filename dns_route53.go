@@ -0,0 +1,203 @@
+package responder
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const route53API = "https://route53.amazonaws.com/2013-04-01"
+
+// route53DNS - solves DNS-01 challenges with the AWS Route 53 API
+type route53DNS struct {
+	dnsAPI
+	awsCredentials
+	now func() time.Time
+}
+
+// awsCredentials - an AWS access key, and the session token of temporary
+// credentials
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// NewRoute53DNSProvider - a DNSProvider creating challenge records through
+// the AWS Route 53 API, with the credentials of an IAM user or role allowed
+// route53:ListHostedZonesByName and route53:ChangeResourceRecordSets. The
+// session token is only needed for temporary credentials.
+func NewRoute53DNSProvider(accessKeyID, secretAccessKey, sessionToken string) DNSProvider {
+	return &route53DNS{
+		dnsAPI:         newDNSAPI(route53API, nil),
+		awsCredentials: awsCredentials{accessKeyID, secretAccessKey, sessionToken},
+		now:            time.Now,
+	}
+}
+
+func (p *route53DNS) Present(domain, token, keyAuth string) error {
+	return p.change("UPSERT", domain, keyAuth)
+}
+
+func (p *route53DNS) CleanUp(domain, token, keyAuth string) error {
+	return p.change("DELETE", domain, keyAuth)
+}
+
+type route53Change struct {
+	XMLName xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Action  string   `xml:"ChangeBatch>Changes>Change>Action"`
+	Name    string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Name"`
+	Type    string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>Type"`
+	TTL     int      `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>TTL"`
+	Value   string   `xml:"ChangeBatch>Changes>Change>ResourceRecordSet>ResourceRecords>ResourceRecord>Value"`
+}
+
+// change - create (or update) or delete the challenge record
+func (p *route53DNS) change(action, domain, keyAuth string) error {
+	fqdn, value, zone, err := p.challengeRecord(domain, keyAuth)
+	if err != nil {
+		return err
+	}
+	zoneID, err := p.zoneID(zone)
+	if err != nil {
+		return err
+	}
+	body, err := xml.Marshal(route53Change{
+		Action: action,
+		Name:   fqdn,
+		Type:   "TXT",
+		TTL:    dnsRecordTTL,
+		Value:  quoteTXT(value),
+	})
+	if err != nil {
+		return err
+	}
+	return p.do(http.MethodPost, "/hostedzone/"+zoneID+"/rrset", nil, body, nil)
+}
+
+// zoneID - the ID of the hosted zone with the name
+func (p *route53DNS) zoneID(zone string) (string, error) {
+	var zones struct {
+		HostedZones []struct {
+			ID   string `xml:"Id"`
+			Name string `xml:"Name"`
+		} `xml:"HostedZones>HostedZone"`
+	}
+	q := url.Values{"dnsname": {zone}, "maxitems": {"1"}}
+	err := p.do(http.MethodGet, "/hostedzonesbyname", q, nil, &zones)
+	if err != nil {
+		return "", err
+	}
+	// zones are listed from the given name onwards, so the first may be
+	// another
+	if len(zones.HostedZones) == 0 || zones.HostedZones[0].Name != zone {
+		return "", errors.Errorf("Route 53 hosted zone %s not found", zone)
+	}
+	return strings.TrimPrefix(zones.HostedZones[0].ID, "/hostedzone/"), nil
+}
+
+// do - a signed Route 53 API request, decoding the XML response into out
+// (if given)
+func (p *route53DNS) do(method, path string, q url.Values, body []byte, out interface{}) error {
+	u := p.baseURL + path
+	if len(q) > 0 {
+		u += "?" + canonicalQuery(q)
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	// Route 53 is a global service, signed for us-east-1
+	p.sign(req, body, p.now(), "us-east-1", "route53")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s %s failed", method, req.URL.Path)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return apiError(req, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(xml.Unmarshal(b, out), "invalid response to %s %s", method, req.URL.Path)
+}
+
+// sign - sign the request to the service with AWS Signature Version 4
+func (c awsCredentials) sign(req *http.Request, body []byte, now time.Time, region, service string) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(req.Header.Get(k))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + c.secretAccessKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery - the query string in AWS's canonical form: sorted by key,
+// with spaces escaped as %20
+func canonicalQuery(q url.Values) string {
+	return strings.Replace(q.Encode(), "+", "%20", -1)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
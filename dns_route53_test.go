@@ -0,0 +1,70 @@
+package responder
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xenolf/lego/challenge/dns01"
+)
+
+func TestAWSSignV4(t *testing.T) {
+	// the get-vanilla case of AWS's Signature Version 4 test suite
+	c := awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	c.sign(req, nil, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC), "us-east-1", "service")
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+
+	c.sessionToken = "session"
+	req, _ = http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	c.sign(req, nil, time.Now(), "us-east-1", "service")
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,")
+}
+
+func TestRoute53DNSProvider(t *testing.T) {
+	fqdn, value := dns01.GetRecord("hooks.example.com", "key-auth")
+	var changes []route53Change
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		switch req.Method + " " + req.URL.Path {
+		case "GET /hostedzonesbyname":
+			// zones are listed from the name on
+			_, _ = w.Write([]byte(`<ListHostedZonesByNameResponse><HostedZones><HostedZone>` +
+				`<Id>/hostedzone/Z1</Id><Name>example.com.</Name></HostedZone></HostedZones></ListHostedZonesByNameResponse>`))
+		case "POST /hostedzone/Z1/rrset":
+			b, _ := ioutil.ReadAll(req.Body)
+			var c route53Change
+			assert.NoError(t, xml.Unmarshal(b, &c))
+			changes = append(changes, c)
+			_, _ = w.Write([]byte(`<ChangeResourceRecordSetsResponse/>`))
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewRoute53DNSProvider("AKID", "secret", "").(*route53DNS)
+	p.baseURL = srv.URL
+	p.findZone = func(string) (string, error) { return "example.com.", nil }
+
+	assert.NoError(t, p.Present("hooks.example.com", "tok", "key-auth"))
+	assert.NoError(t, p.CleanUp("hooks.example.com", "tok", "key-auth"))
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, "UPSERT", changes[0].Action)
+		assert.Equal(t, fqdn, changes[0].Name)
+		assert.Equal(t, `"`+value+`"`, changes[0].Value)
+		assert.Equal(t, "DELETE", changes[1].Action)
+	}
+
+	p.findZone = func(string) (string, error) { return "other.com.", nil }
+	assert.Contains(t, p.Present("hooks.other.com", "tok", "key-auth").Error(), "not found")
+}
@@ -0,0 +1,45 @@
+package responder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSPreflight(t *testing.T) {
+	hosts := map[string][]string{
+		"hooks.example.com": {"203.0.113.7"},
+		"lb.example.com":    {"203.0.113.7", "203.0.113.8"},
+	}
+	p := &dnsPreflight{
+		domain: "hooks.example.com:8443",
+		strict: true,
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			if a, ok := hosts[host]; ok {
+				return a, nil
+			}
+			return nil, errors.New("no such host")
+		},
+		local: func() ([]string, error) { return []string{"127.0.0.1", "10.0.0.2"}, nil },
+	}
+
+	err := p.check(context.Background())
+	assert.Equal(t, ErrDNSMismatch, errors.Cause(err))
+	assert.Contains(t, err.Error(), "resolves to 203.0.113.7, not to this host's addresses")
+
+	p.expected = []string{"lb.example.com"}
+	assert.NoError(t, p.check(context.Background()))
+	p.expected = []string{"198.51.100.1"}
+	assert.Error(t, p.check(context.Background()))
+
+	p.domain = "nowhere.example.com"
+	assert.Contains(t, p.check(context.Background()).Error(), "doesn't resolve")
+
+	p.strict = false
+	assert.NoError(t, p.check(context.Background()))
+
+	p = nil
+	assert.NoError(t, p.check(context.Background()))
+}
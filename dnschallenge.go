@@ -0,0 +1,99 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xenolf/lego/challenge/dns01"
+)
+
+// DNSProvider - solves ACME DNS-01 challenges, by creating the TXT record
+// for the challenge (and removing it afterwards), so certificates can be
+// obtained without the ACME CA reaching this host on ports 80 or 443 -
+// e.g. behind NAT. Any lego DNS provider
+// (github.com/xenolf/lego/providers/dns/...) fits. See
+// NewCloudflareDNSProvider, NewRoute53DNSProvider, and
+// NewGoogleCloudDNSProvider.
+type DNSProvider interface {
+	// Present - create the TXT record for the challenge (see
+	// dns01.GetRecord)
+	Present(domain, token, keyAuth string) error
+	// CleanUp - remove the record created by Present
+	CleanUp(domain, token, keyAuth string) error
+}
+
+// dnsRecordTTL - the TTL of challenge records, in seconds
+const dnsRecordTTL = 120
+
+// dnsAPI - what the built-in DNS providers have in common: an HTTP API,
+// and finding the zone a challenge record belongs in
+type dnsAPI struct {
+	baseURL string
+	client  *http.Client
+	// findZone - the zone (with a trailing dot) the FQDN is in, found
+	// through DNS
+	findZone func(fqdn string) (string, error)
+}
+
+func newDNSAPI(baseURL string, client *http.Client) dnsAPI {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return dnsAPI{baseURL: baseURL, client: client, findZone: dns01.FindZoneByFqdn}
+}
+
+// challengeRecord - the FQDN and value of the challenge's TXT record, and
+// the zone it goes in
+func (a dnsAPI) challengeRecord(domain, keyAuth string) (fqdn, value, zone string, err error) {
+	fqdn, value = dns01.GetRecord(domain, keyAuth)
+	zone, err = a.findZone(fqdn)
+	if err != nil {
+		return "", "", "", errors.Wrapf(err, "failed to find the DNS zone of %s", domain)
+	}
+	return fqdn, value, zone, nil
+}
+
+// doJSON sends the request body (if any) as JSON, and decodes the JSON
+// response into out (if given). Non-2xx responses are errors, with the
+// response body for detail.
+func (a dnsAPI) doJSON(req *http.Request, body, out interface{}) error {
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		req.ContentLength = int64(len(b))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s %s failed", req.Method, req.URL.Path)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return apiError(req, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(out), "invalid response to %s %s", req.Method, req.URL.Path)
+}
+
+// apiError - a DNS API's error response, with (the start of) its body
+func apiError(req *http.Request, resp *http.Response) error {
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return errors.Errorf("%s %s failed: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(b))
+}
+
+// quoteTXT - a TXT record value in zone file form, as some APIs want it
+func quoteTXT(value string) string {
+	return fmt.Sprintf("%q", value)
+}
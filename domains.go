@@ -0,0 +1,43 @@
+package responder
+
+import (
+	"github.com/pkg/errors"
+)
+
+// servedDomains - the primary domain, and any others served alongside it
+// (see Options.Domains)
+func (r *Responder) servedDomains() []string {
+	return append([]string{r.domain}, r.domains...)
+}
+
+// servesDomain - whether the domain is one of the served domains
+func (r *Responder) servesDomain(domain string) bool {
+	for _, d := range r.servedDomains() {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// callbackFor - the callback URL of the endpoint's hook in the repo. It's
+// the endpoint's URL (or the repo's own, with PerRepoCallbackPaths), on the
+// domain chosen for the repo by Options.DomainFor. Deliveries are routed by
+// path, so they reach the endpoint whichever domain they arrive on.
+func (r *Responder) callbackFor(ep *endpoint, repo repository) (string, error) {
+	callback := r.repoCallbackURL(ep, repo)
+	if r.domainFor == nil {
+		return callback, nil
+	}
+	name := repo.owner + "/" + repo.name
+	d := r.domainFor(name)
+	if d == "" || d == r.domain {
+		return callback, nil
+	}
+	for _, served := range r.domains {
+		if d == served {
+			return callbackScheme() + d + getPath(callback), nil
+		}
+	}
+	return "", errors.Errorf("domain %q chosen for %s isn't one of the served domains", d, name)
+}
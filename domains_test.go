@@ -0,0 +1,42 @@
+package responder
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallbackFor(t *testing.T) {
+	r := &Responder{domain: "blue.example.com", pathPrefix: "/", hookSecret: "s"}
+	ep := r.newEndpoint(nil, nil)
+	foo, bar := repository{owner: "o", name: "foo"}, repository{owner: "o", name: "bar"}
+
+	u, err := r.callbackFor(ep, foo)
+	assert.NoError(t, err)
+	assert.Equal(t, ep.callbackURL, u)
+
+	r.domains = []string{"green.example.com"}
+	r.domainFor = func(repo string) string {
+		switch repo {
+		case "o/foo":
+			return "green.example.com"
+		case "o/bar":
+			return ""
+		}
+		return "elsewhere.example.com"
+	}
+	u, err = r.callbackFor(ep, foo)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://green.example.com"+getPath(ep.callbackURL), u)
+	assert.True(t, r.ownHook(&github.Hook{Config: map[string]interface{}{"url": u}}))
+
+	u, err = r.callbackFor(ep, bar)
+	assert.NoError(t, err)
+	assert.Equal(t, ep.callbackURL, u)
+
+	_, err = r.callbackFor(ep, repository{owner: "o", name: "baz"})
+	assert.Contains(t, err.Error(), "isn't one of the served domains")
+
+	assert.Equal(t, []string{"blue.example.com", "green.example.com"}, r.servedDomains())
+}
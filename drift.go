@@ -0,0 +1,167 @@
+package responder
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// driftMaxPayload - larger payloads aren't compared for unknown fields,
+	// as it takes a few passes over the payload
+	driftMaxPayload = 1 << 20
+	// driftSampleInterval - how often each event type's payloads are compared
+	// for unknown fields - new fields show up in every delivery, so a sample
+	// finds them
+	driftSampleInterval = time.Minute
+)
+
+// driftDetector notices when GitHub sends events or fields that the bundled
+// go-github doesn't know about, so handlers relying on typed parsing don't
+// silently miss data
+type driftDetector struct {
+	// fields already warned about, keyed by "event:field"
+	seen sync.Map
+	// sampled - when each event type's payload was last compared
+	sampled sync.Map
+	// comparing - set while a comparison is running - deliveries arriving
+	// meanwhile aren't compared
+	comparing int32
+	now       func() time.Time
+}
+
+// check logs a warning the first time an unrecognized event type is seen,
+// and counts every delivery of one. A sample of the other deliveries is
+// compared for unknown fields in the background (see compare), so it's off
+// the request path.
+func (d *driftDetector) check(log zerolog.Logger, eventType string, payload []byte) {
+	if !parsableEvent(eventType) {
+		countUnrecognized(eventType, "unknown_event")
+		if _, warned := d.seen.LoadOrStore(eventType, true); !warned {
+			log.Warn().Msg("unrecognized event type - handlers using typed parsing won't see it")
+		}
+		return
+	}
+	if !d.sample(eventType, len(payload)) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&d.comparing, 0)
+		d.compare(log, eventType, payload)
+	}()
+}
+
+// sample - whether to compare the delivery's payload: only when it's not too
+// large, no other comparison is running, and none of the event type's has
+// in the last driftSampleInterval. When it returns true, the caller must
+// reset comparing once it's done.
+func (d *driftDetector) sample(eventType string, size int) bool {
+	if size > driftMaxPayload {
+		return false
+	}
+	now := time.Now()
+	if d.now != nil {
+		now = d.now()
+	}
+	if last, ok := d.sampled.Load(eventType); ok && now.Sub(last.(time.Time)) < driftSampleInterval {
+		return false
+	}
+	if !atomic.CompareAndSwapInt32(&d.comparing, 0, 1) {
+		return false
+	}
+	d.sampled.Store(eventType, now)
+	return true
+}
+
+// compare logs a warning the first time a field unknown to the bundled
+// go-github is seen in the event type's payloads, and counts the payload
+// when it contains any
+func (d *driftDetector) compare(log zerolog.Logger, eventType string, payload []byte) {
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		return
+	}
+
+	fields, err := unknownFields(event, payload)
+	if err != nil || len(fields) == 0 {
+		return
+	}
+	countUnrecognized(eventType, "unknown_fields")
+
+	fresh := []string{}
+	for _, f := range fields {
+		if _, warned := d.seen.LoadOrStore(eventType+":"+f, true); !warned {
+			fresh = append(fresh, f)
+		}
+	}
+	if len(fresh) > 0 {
+		log.Warn().Strs("fields", fresh).Msg("payload has fields unknown to the bundled go-github")
+	}
+}
+
+// unknownFields compares the payload to the parsed event (re-encoded), and
+// returns the paths of fields that didn't survive the round-trip
+func unknownFields(event interface{}, payload []byte) ([]string, error) {
+	var orig interface{}
+	err := json.Unmarshal(payload, &orig)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	err = json.Unmarshal(b, &parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	diffKeys("", orig, parsed, &fields)
+	sort.Strings(fields)
+	return fields, nil
+}
+
+func diffKeys(prefix string, orig, parsed interface{}, fields *[]string) {
+	switch o := orig.(type) {
+	case map[string]interface{}:
+		p, _ := parsed.(map[string]interface{})
+		for k, v := range o {
+			if isEmptyJSON(v) {
+				// empty values are dropped by omitempty, so can't be compared
+				continue
+			}
+			pv, ok := p[k]
+			if !ok {
+				*fields = append(*fields, prefix+k)
+				continue
+			}
+			diffKeys(prefix+k+".", v, pv, fields)
+		}
+	case []interface{}:
+		p, _ := parsed.([]interface{})
+		// only the first element is compared, to avoid reporting the same
+		// field for every element
+		if len(o) > 0 && len(p) > 0 {
+			diffKeys(prefix+"[].", o[0], p[0], fields)
+		}
+	}
+}
+
+func isEmptyJSON(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
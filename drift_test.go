@@ -0,0 +1,76 @@
+package responder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownFields(t *testing.T) {
+	payload := []byte(`{
+		"action": "started",
+		"brand_new": true,
+		"nothing": null,
+		"repository": {"name": "foo", "shiny": "yes", "topics": []},
+		"sender": {"login": "octocat"}
+	}`)
+	event, err := github.ParseWebHook("watch", payload)
+	assert.NoError(t, err)
+	fields, err := unknownFields(event, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"brand_new", "repository.shiny"}, fields)
+
+	payload = []byte(`{"action": "started", "sender": {"login": "octocat"}}`)
+	event, err = github.ParseWebHook("watch", payload)
+	assert.NoError(t, err)
+	fields, err = unknownFields(event, payload)
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+}
+
+func TestDriftDetectorCheck(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := zerolog.New(out)
+	d := &driftDetector{}
+
+	d.check(log, "some_future_event", []byte(`{}`))
+	assert.Contains(t, out.String(), "unrecognized event type")
+
+	out.Reset()
+	d.check(log, "some_future_event", []byte(`{}`))
+	assert.Empty(t, out.String(), "should only warn once")
+
+	d.compare(log, "watch", []byte(`{"action": "started", "brand_new": 1}`))
+	assert.Contains(t, out.String(), `"fields":["brand_new"]`)
+}
+
+func TestDriftDetectorSample(t *testing.T) {
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	d := &driftDetector{now: func() time.Time { return now }}
+
+	assert.False(t, d.sample("watch", driftMaxPayload+1))
+	assert.True(t, d.sample("watch", 10))
+	// one comparison at a time
+	assert.False(t, d.sample("push", 10))
+	d.comparing = 0
+	assert.True(t, d.sample("push", 10))
+	d.comparing = 0
+
+	// each event type at most once an interval
+	assert.False(t, d.sample("watch", 10))
+	now = now.Add(driftSampleInterval)
+	assert.True(t, d.sample("watch", 10))
+	d.comparing = 0
+
+	// large payloads aren't compared at all
+	out := &bytes.Buffer{}
+	now = now.Add(driftSampleInterval)
+	d.check(zerolog.New(out), "watch", []byte(`{"brand_new": "`+strings.Repeat("a", driftMaxPayload)+`"}`))
+	assert.Equal(t, int32(0), d.comparing)
+	assert.Empty(t, out.String())
+}
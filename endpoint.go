@@ -0,0 +1,161 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// endpoint - a callback path, with its own secret, repos, and actions
+type endpoint struct {
+	r           *Responder
+	callbackURL string
+	secret      string
+	repos       []repository
+	routes      []Route
+	// stream - the handler of a streaming endpoint (see
+	// AddStreamingEndpoint), which has no routes
+	stream StreamHandler
+}
+
+// Route - an action, and the events it's interested in
+type Route struct {
+	// Events to route to the handler - empty (or "*") for all events
+	Events []string
+	// Topics - only route events from repos with at least one of these
+	// topics (e.g. "service"). Empty for events from any repo. Topics are
+	// fetched with the handlers' GitHub client, and cached.
+	Topics []string
+	// Sampling - only route a percentage of the matching events. Nil to
+	// route them all.
+	Sampling *Sampling
+	// Filters - custom routing rules, which must all match for the event to
+	// be routed to the handler
+	Filters []Filter
+	// Name - identifies the handler, e.g. to disable it at runtime (see
+	// DisableHandler), in logs and metrics, and in per-handler options like
+	// API call budgets. Must be unique. Defaults to the route's position
+	// (see namedRoutes).
+	Name    string
+	Handler HookHandler
+
+	// name - set by namedRoutes
+	name string
+	// annotations - why the delivery was routed here, set by the routing
+	// stages (see GetRouteAnnotations)
+	annotations RouteAnnotations
+}
+
+// wants - whether the route is interested in the event type
+func (rt Route) wants(eventType string) bool {
+	if len(rt.Events) == 0 {
+		return true
+	}
+	for _, e := range rt.Events {
+		if e == "*" || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// namedRoutes - the endpoint's routes, with handlers that know their name
+// (see handlerName), for per-handler features like API call budgets.
+// Handlers without a Name are named by their endpoint and route positions,
+// so the first action of the primary endpoint is "0.0". Handlers are wrapped
+// in the Responder's middleware (see Use).
+func (e *endpoint) namedRoutes() []Route {
+	ei := e.r.endpointIndex(e)
+	named := make([]Route, len(e.routes))
+	for i, rt := range e.routes {
+		name := rt.Name
+		if name == "" {
+			name = fmt.Sprintf("%d.%d", ei, i)
+		}
+		h := e.r.chain(e.r.reportPanics(name, rt.Handler))
+		named[i] = rt
+		named[i].name = name
+		named[i].Handler = func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			h(context.WithValue(ctx, handlerKey, name), eventType, deliveryID, payload)
+		}
+	}
+	return named
+}
+
+// handlerName - the name of the handler the context was given to, or "" if
+// it didn't come from a Responder
+func handlerName(ctx context.Context) string {
+	name, _ := ctx.Value(handlerKey).(string)
+	return name
+}
+
+// endpointIndex - the endpoint's position, for naming its handlers
+func (r *Responder) endpointIndex(e *endpoint) int {
+	for i, ep := range r.endpoints {
+		if ep == e {
+			return i
+		}
+	}
+	return -1
+}
+
+// subscription - the union of events wanted by the endpoint's routes, or
+// fallback if any route wants all events
+func (e *endpoint) subscription(fallback []string) []string {
+	var events []string
+	seen := map[string]bool{}
+	for _, rt := range e.routes {
+		if len(rt.Events) == 0 {
+			return fallback
+		}
+		for _, ev := range rt.Events {
+			if ev == "*" {
+				return []string{"*"}
+			}
+			if !seen[ev] {
+				seen[ev] = true
+				events = append(events, ev)
+			}
+		}
+	}
+	if len(events) == 0 {
+		return fallback
+	}
+	return events
+}
+
+func (r *Responder) newEndpoint(repos []repository, actions []HookHandler) *endpoint {
+	routes := make([]Route, len(actions))
+	for i, a := range actions {
+		routes[i] = Route{Handler: a}
+	}
+	i := len(r.endpoints)
+	return &endpoint{
+		r:           r,
+		callbackURL: r.endpointURL(i),
+		secret:      r.endpointSecret(i),
+		repos:       repos,
+		routes:      routes,
+	}
+}
+
+// AddEndpoint - serve another callback endpoint alongside the primary one,
+// with its own path, secret, repos, and actions. Deliveries for these repos
+// are only handled by these actions. Must be called before Register and
+// Listen.
+func (r *Responder) AddEndpoint(repos []string, actions ...HookHandler) error {
+	if len(repos) == 0 {
+		return errors.New("must provide at least one repo")
+	}
+	var repositories []repository
+	for _, s := range repos {
+		repo, err := parseRepo(s)
+		if err != nil {
+			return err
+		}
+		repositories = append(repositories, repo)
+	}
+	r.endpoints = append(r.endpoints, r.newEndpoint(repositories, actions))
+	return nil
+}
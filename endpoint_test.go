@@ -0,0 +1,100 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddEndpoint(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.endpoints = []*endpoint{r.newEndpoint([]repository{{owner: "foo", name: "bar"}}, nil)}
+
+	err := r.AddEndpoint(nil)
+	assert.Error(t, err)
+	err = r.AddEndpoint([]string{"bogus"})
+	assert.Equal(t, ErrInvalidRepo, errors.Cause(err))
+
+	err = r.AddEndpoint([]string{"https://github.com/baz/qux"})
+	assert.NoError(t, err)
+	assert.Len(t, r.endpoints, 2)
+
+	primary, other := r.endpoints[0], r.endpoints[1]
+	assert.Equal(t, []repository{{owner: "baz", name: "qux"}}, other.repos)
+	assert.NotEqual(t, primary.callbackURL, other.callbackURL)
+	assert.NotEqual(t, primary.secret, other.secret)
+	assert.Contains(t, other.callbackURL, "://example.com/gh-callback/")
+}
+
+func TestRouteWants(t *testing.T) {
+	assert.True(t, Route{}.wants("push"))
+	assert.True(t, Route{Events: []string{"*"}}.wants("push"))
+	assert.True(t, Route{Events: []string{"issues", "push"}}.wants("push"))
+	assert.False(t, Route{Events: []string{"issues"}}.wants("push"))
+}
+
+func TestSubscription(t *testing.T) {
+	fallback := []string{"fallback"}
+	e := &endpoint{}
+	assert.Equal(t, fallback, e.subscription(fallback))
+
+	e.routes = []Route{{Events: []string{"push", "issues"}}, {Events: []string{"issues", "release"}}}
+	assert.Equal(t, []string{"push", "issues", "release"}, e.subscription(fallback))
+
+	e.routes = append(e.routes, Route{Events: []string{"*"}})
+	assert.Equal(t, []string{"*"}, e.subscription(fallback))
+
+	e.routes = []Route{{Events: []string{"push"}}, {}}
+	assert.Equal(t, fallback, e.subscription(fallback))
+}
+
+func TestServeHTTPPing(t *testing.T) {
+	payload := []byte(`{"zen":"Keep it logically awesome.","hook_id":1}`)
+	called := make(chan string, 1)
+	action := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		called <- eventType
+	}
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{action})}
+	ep := r.endpoints[0]
+
+	newReq := func() *http.Request {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "ping")
+		req.Header.Set("X-GitHub-Delivery", "abc")
+		req.Header.Set("X-Hub-Signature", sign(payload, []byte(ep.secret)))
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	ep.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Keep it logically awesome.", rec.Body.String())
+	select {
+	case <-called:
+		t.Error("ping shouldn't be dispatched by default")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	r.dispatchPings = true
+	rec = httptest.NewRecorder()
+	ep.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Keep it logically awesome.", rec.Body.String())
+	assert.Equal(t, "ping", <-called)
+
+	r.EnableStatusTracking(10)
+	rec = httptest.NewRecorder()
+	ep.ServeHTTP(rec, newReq())
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "/deliveries/abc", rec.Header().Get("Location"))
+	assert.Equal(t, "Keep it logically awesome.", rec.Body.String())
+	assert.Equal(t, "ping", <-called)
+}
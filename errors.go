@@ -0,0 +1,213 @@
+package responder
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors, for programmatic handling of failures. Returned errors may
+// carry extra detail, so compare with errors.Cause (github.com/pkg/errors):
+//
+//	if errors.Cause(err) == responder.ErrMissingToken {
+var (
+	// ErrMissingToken - no GitHub API token was provided
+	ErrMissingToken = errors.New("GitHub API token missing")
+	// ErrInvalidRepo - a repo couldn't be parsed
+	ErrInvalidRepo = errors.New("invalid repo")
+	// ErrHookExists - GitHub refused to create a hook because an identical
+	// one already exists
+	ErrHookExists = errors.New("hook already exists")
+	// ErrHookNotFound - the hook doesn't exist (or isn't visible with the
+	// token's permissions)
+	ErrHookNotFound = errors.New("hook not found")
+	// ErrRepoNotFound - listing or creating a repo's hooks failed as the repo
+	// doesn't exist, or the token can't see it or manage its hooks (GitHub
+	// hides private repos with a 404)
+	ErrRepoNotFound = errors.New("repo not found, or no permission to manage its hooks")
+	// ErrSignatureInvalid - a delivery's signature is missing, malformed, or
+	// doesn't match the secret
+	ErrSignatureInvalid = errors.New("invalid signature")
+	// ErrStatusTrackingDisabled - statistics were requested, but there's no
+	// delivery history to compute them from (see EnableStatusTracking)
+	ErrStatusTrackingDisabled = errors.New("status tracking is disabled")
+	// ErrAPIBudgetExceeded - a handler's GitHub API call was refused, because
+	// it would exceed an API call budget
+	ErrAPIBudgetExceeded = errors.New("API call budget exceeded")
+	// ErrMissingPermission - the token (or GitHub App installation) can't
+	// create and remove webhooks
+	ErrMissingPermission = errors.New("missing permission to manage webhooks")
+	// ErrObserving - the Responder doesn't manage hooks, in observe mode (see
+	// Options.Observe)
+	ErrObserving = errors.New("hooks aren't managed in observe mode")
+	// ErrDeliveryNotFound - no hook has a delivery with the ID
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	// ErrUnknownHandler - no handler has the name (see Handlers)
+	ErrUnknownHandler = errors.New("unknown handler")
+	// ErrDNSMismatch - the domain doesn't resolve to this host, or to the
+	// expected addresses (see Options.StrictDNS)
+	ErrDNSMismatch = errors.New("domain doesn't resolve to this host")
+)
+
+// causeError is an error with its own message, but with a sentinel error as
+// its cause
+type causeError struct {
+	cause error
+	msg   string
+}
+
+func newCauseError(cause error, format string, args ...interface{}) error {
+	return &causeError{cause, fmt.Sprintf(format, args...)}
+}
+
+func (e *causeError) Error() string {
+	return e.msg
+}
+
+// Cause - for github.com/pkg/errors.Cause
+func (e *causeError) Cause() error {
+	return e.cause
+}
+
+// hookError translates the error from an API call on one hook (getting,
+// editing, or deleting it, or its deliveries) into a sentinel-caused error
+// where possible, otherwise the error is returned as-is
+func hookError(resp *github.Response, err error) error {
+	return sentinelError(resp, err, ErrHookNotFound)
+}
+
+// repoHooksError - like hookError, for API calls on a repo's hooks as a whole
+// (listing or creating them), where a 404 is about the repo
+func repoHooksError(resp *github.Response, err error) error {
+	return sentinelError(resp, err, ErrRepoNotFound)
+}
+
+// sentinelError translates a hook API error, with notFound as the cause of 404s
+func sentinelError(resp *github.Response, err error, notFound error) error {
+	if err == nil {
+		return nil
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return newCauseError(notFound, err.Error())
+	}
+	if e, ok := err.(*github.ErrorResponse); ok {
+		for _, detail := range e.Errors {
+			if strings.Contains(detail.Message, "already exists") {
+				return newCauseError(ErrHookExists, err.Error())
+			}
+		}
+	}
+	return err
+}
+
+// signatureError marks payload validation errors caused by the signature
+func signatureError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "signature") || strings.Contains(msg, "hash type") {
+		return newCauseError(ErrSignatureInvalid, msg)
+	}
+	return err
+}
+
+// RegistrationError - returned by Register when hooks couldn't be created for
+// some repos
+type RegistrationError struct {
+	// Failed - the errors, keyed by repo ('owner/repo')
+	Failed map[string]error
+	// Succeeded - the number of hooks created (and since removed)
+	Succeeded int
+	// Skipped - the number of repos not attempted, after the first failure
+	Skipped int
+}
+
+func (e *RegistrationError) Error() string {
+	repos := e.failedRepos()
+	if len(repos) == 1 {
+		return fmt.Sprintf("failed to create hook for %s: %v", repos[0], e.Failed[repos[0]])
+	}
+	msgs := make([]string, len(repos))
+	for i, repo := range repos {
+		msgs[i] = fmt.Sprintf("%s: %v", repo, e.Failed[repo])
+	}
+	return fmt.Sprintf("failed to create hooks for %d repos: %s", len(repos), strings.Join(msgs, "; "))
+}
+
+// Cause - the first failure (by repo name), for github.com/pkg/errors.Cause
+func (e *RegistrationError) Cause() error {
+	repos := e.failedRepos()
+	if len(repos) == 0 {
+		return nil
+	}
+	return e.Failed[repos[0]]
+}
+
+func (e *RegistrationError) failedRepos() []string {
+	return sortedRepos(e.Failed)
+}
+
+// CleanupError - returned by RegisterE's cleanup function when hooks
+// couldn't be removed from some repos
+type CleanupError struct {
+	// Failed - the errors, keyed by repo ('owner/repo')
+	Failed map[string]error
+	// Succeeded - the number of hooks removed
+	Succeeded int
+}
+
+func (e *CleanupError) Error() string {
+	repos := sortedRepos(e.Failed)
+	msgs := make([]string, len(repos))
+	for i, repo := range repos {
+		msgs[i] = fmt.Sprintf("%s: %v", repo, e.Failed[repo])
+	}
+	return fmt.Sprintf("failed to remove hooks from %d repos: %s", len(repos), strings.Join(msgs, "; "))
+}
+
+// Cause - the first failure (by repo name), for github.com/pkg/errors.Cause
+func (e *CleanupError) Cause() error {
+	repos := sortedRepos(e.Failed)
+	if len(repos) == 0 {
+		return nil
+	}
+	return e.Failed[repos[0]]
+}
+
+// RedeliveryError - returned by Backfill and RedeliverSince when some
+// repos' deliveries couldn't be redelivered
+type RedeliveryError struct {
+	// Failed - the errors, keyed by repo ('owner/repo')
+	Failed map[string]error
+	// Redelivered - the number of deliveries redelivered
+	Redelivered int
+}
+
+func (e *RedeliveryError) Error() string {
+	repos := sortedRepos(e.Failed)
+	msgs := make([]string, len(repos))
+	for i, repo := range repos {
+		msgs[i] = fmt.Sprintf("%s: %v", repo, e.Failed[repo])
+	}
+	return fmt.Sprintf("failed to redeliver to %d repos: %s", len(repos), strings.Join(msgs, "; "))
+}
+
+// Cause - the first failure (by repo name), for github.com/pkg/errors.Cause
+func (e *RedeliveryError) Cause() error {
+	repos := sortedRepos(e.Failed)
+	if len(repos) == 0 {
+		return nil
+	}
+	return e.Failed[repos[0]]
+}
+
+func sortedRepos(errs map[string]error) []string {
+	repos := make([]string, 0, len(errs))
+	for repo := range errs {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	return repos
+}
@@ -0,0 +1,47 @@
+package responder
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCauseError(t *testing.T) {
+	err := newCauseError(ErrInvalidRepo, "invalid repo %s", "foo")
+	assert.EqualError(t, err, "invalid repo foo")
+	assert.Equal(t, ErrInvalidRepo, errors.Cause(err))
+	assert.Equal(t, ErrInvalidRepo, errors.Cause(errors.Wrap(err, "wrapped")))
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New([]string{"foo"}, "example.com")
+	assert.Equal(t, ErrInvalidRepo, errors.Cause(err))
+}
+
+func TestHookError(t *testing.T) {
+	assert.NoError(t, hookError(nil, nil))
+
+	err := errors.New("other")
+	assert.Equal(t, err, hookError(ghResp(500), err))
+
+	assert.Equal(t, ErrHookNotFound, errors.Cause(hookError(ghResp(404), err)))
+	assert.Equal(t, ErrRepoNotFound, errors.Cause(repoHooksError(ghResp(404), err)))
+	assert.Equal(t, err, repoHooksError(ghResp(500), err))
+
+	err = &github.ErrorResponse{
+		Response: &http.Response{StatusCode: 422, Request: &http.Request{}},
+		Errors:   []github.Error{{Message: "Hook already exists on this repository"}},
+	}
+	assert.Equal(t, ErrHookExists, errors.Cause(hookError(ghResp(422), err)))
+}
+
+func TestSignatureError(t *testing.T) {
+	err := errors.New("payload signature check failed")
+	assert.Equal(t, ErrSignatureInvalid, errors.Cause(signatureError(err)))
+
+	err = errors.New("Webhook request has unsupported Content-Type")
+	assert.Equal(t, err, signatureError(err))
+}
@@ -0,0 +1,52 @@
+package responder
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+)
+
+// eventInfo holds the fields common to most event payloads
+type eventInfo struct {
+	Action string `json:"action"`
+	Ref    string `json:"ref"`
+	Repo   struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+}
+
+// parseEventInfo extracts the common fields from the payload - missing fields
+// are left empty
+func parseEventInfo(payload []byte) eventInfo {
+	info := eventInfo{}
+	// errors are ignored, as not all payloads have these fields
+	_ = json.Unmarshal(payload, &info)
+	return info
+}
+
+// withFields adds the fields that are present to the logger context, so
+// log queries can filter on them without parsing payloads
+func (i eventInfo) withFields(c zerolog.Context) zerolog.Context {
+	if i.Repo.FullName != "" {
+		c = c.Str("repo", i.Repo.FullName)
+	}
+	if i.Sender.Login != "" {
+		c = c.Str("sender", i.Sender.Login)
+	}
+	if i.Action != "" {
+		c = c.Str("action", i.Action)
+	}
+	if i.Ref != "" {
+		c = c.Str("ref", i.Ref)
+	}
+	if i.Installation.ID != 0 {
+		c = c.Int64("installation", i.Installation.ID)
+	}
+	return c
+}
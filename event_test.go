@@ -0,0 +1,31 @@
+package responder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEventInfo(t *testing.T) {
+	info := parseEventInfo([]byte(`{
+		"action": "opened",
+		"repository": {"full_name": "foo/bar"},
+		"sender": {"login": "octocat"}
+	}`))
+	assert.Equal(t, "opened", info.Action)
+	assert.Equal(t, "foo/bar", info.Repo.FullName)
+	assert.Equal(t, "octocat", info.Sender.Login)
+	assert.Equal(t, "", info.Ref)
+
+	assert.Equal(t, eventInfo{}, parseEventInfo([]byte(`not json`)))
+}
+
+func TestEventInfoWithFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	info := parseEventInfo([]byte(`{"ref": "refs/heads/master", "repository": {"full_name": "foo/bar"}}`))
+	l := info.withFields(zerolog.New(out).With()).Logger()
+	l.Info().Msg("")
+	assert.Equal(t, `{"level":"info","repo":"foo/bar","ref":"refs/heads/master"}`+"\n", out.String())
+}
@@ -0,0 +1,186 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Explanation - how the current routing configuration would handle a
+// delivery (see Explain)
+type Explanation struct {
+	EventType  string `json:"eventType"`
+	DeliveryID string `json:"deliveryID"`
+	Repo       string `json:"repo,omitempty"`
+	// Endpoint - the index of the endpoint watching the repo (0, the
+	// primary endpoint, when none does)
+	Endpoint int `json:"endpoint"`
+	// Notes - what would happen to the delivery as a whole, e.g. being
+	// deferred for a maintenance window
+	Notes []string `json:"notes,omitempty"`
+	// Handlers - every handler of the endpoint, in order
+	Handlers []HandlerExplanation `json:"handlers"`
+}
+
+// HandlerExplanation - whether a handler would fire for a delivery, and why
+type HandlerExplanation struct {
+	Name  string `json:"name"`
+	Fires bool   `json:"fires"`
+	// Rules - the routing rules the delivery matched, as in
+	// RouteAnnotations.Rules
+	Rules []string `json:"rules,omitempty"`
+	// Fields - values extracted while routing, as in RouteAnnotations.Fields
+	Fields map[string]string `json:"fields,omitempty"`
+	// Reason - why the handler was skipped, or a caveat about it firing
+	Reason string `json:"reason,omitempty"`
+}
+
+// Explain - run a (usually recorded) delivery through the current routing
+// configuration, reporting which rules matched, which handlers would fire,
+// and why the others would be skipped - without running any handlers. Route
+// Filters are called, so must be free of side effects. Repo topics are
+// fetched (or read from the cache) when routes need them.
+func (r *Responder) Explain(ctx context.Context, d *Delivery) *Explanation {
+	eventType, payload := r.normalize(zerolog.Nop(), d.EventType, d.Payload)
+	info := parseEventInfo(payload)
+	ei := r.endpointForRepo(info.Repo.FullName)
+	x := &Explanation{
+		EventType:  eventType,
+		DeliveryID: d.DeliveryID,
+		Repo:       info.Repo.FullName,
+		Endpoint:   ei,
+	}
+
+	routes := r.endpoints[ei].namedRoutes()
+	explained := make([]HandlerExplanation, len(routes))
+	for i, rt := range routes {
+		explained[i].Name = rt.name
+	}
+	if eventType == "ping" && !r.dispatchPings {
+		x.Notes = append(x.Notes, "pings are answered, but not dispatched to handlers")
+		for i := range explained {
+			explained[i].Reason = "pings aren't dispatched"
+		}
+		x.Handlers = explained
+		return x
+	}
+	if eventType != "ping" && r.InMaintenance() {
+		x.Notes = append(x.Notes, "in maintenance - handlers would run once the maintenance window closes")
+	}
+
+	ctx = withGitHubClient(ctx, r.clientFor(info.Installation.ID))
+	var topics []string
+	var topicsErr error
+	topicsFetched := false
+
+	// the stages of ServeHTTP's routing, one route at a time, so the stage
+	// that drops each is known
+	routed := make([]Route, len(routes))
+	matched := make([]bool, len(routes))
+	for i, rt := range routes {
+		reason := ""
+		switch {
+		case len(r.enabledRoutes([]Route{rt})) == 0:
+			reason = "disabled"
+		case !rt.wants(eventType):
+			reason = fmt.Sprintf("not subscribed to %s events (only %s)", eventType, strings.Join(rt.Events, ", "))
+		}
+		if reason == "" && len(rt.Events) > 0 {
+			rt = rt.annotate("event:" + eventType)
+		}
+		for _, f := range rt.Filters {
+			if reason != "" {
+				break
+			}
+			ok, fields := f.Match(ctx, eventType, payload)
+			if !ok {
+				reason = fmt.Sprintf("filter %q didn't match", f.Name)
+				break
+			}
+			rt = rt.annotate("filter:"+f.Name, flatten(fields)...)
+		}
+		if reason == "" && len(rt.Topics) > 0 {
+			if !topicsFetched && info.Repo.FullName != "" {
+				topics, topicsErr = r.topics.get(ctx, GitHubClient(ctx), info.Repo.FullName)
+				topicsFetched = true
+			}
+			topic, ok := rt.matchedTopic(topics)
+			switch {
+			case topicsErr != nil:
+				reason = "couldn't fetch the repo's topics: " + topicsErr.Error()
+			case !ok:
+				reason = fmt.Sprintf("the repo has none of the topics %s", strings.Join(rt.Topics, ", "))
+			default:
+				rt = rt.annotate("topic:"+topic, "topic", topic)
+			}
+		}
+		explained[i].Reason = reason
+		routed[i], matched[i] = rt, reason == ""
+	}
+
+	if recs := r.rerequestedChecks(eventType, payload); len(recs) > 0 {
+		producers := map[string]bool{}
+		for _, rec := range recs {
+			producers[rec.Handler] = true
+		}
+		anyProducer := false
+		for i, rt := range routed {
+			anyProducer = anyProducer || (matched[i] && producers[rt.name])
+		}
+		for i, rt := range routed {
+			switch {
+			case !anyProducer || !matched[i]:
+			case producers[rt.name]:
+				routed[i] = rt.annotate("rerequested")
+			default:
+				matched[i] = false
+				explained[i].Reason = "the re-run check was produced by another handler"
+			}
+		}
+	}
+
+	for i, rt := range routed {
+		if !matched[i] {
+			continue
+		}
+		s := rt.Sampling
+		switch {
+		case s == nil || s.Percent >= 100:
+		case s.Percent <= 0:
+			matched[i] = false
+			explained[i].Reason = "sampled at 0%"
+		case s.Key == nil:
+			explained[i].Reason = fmt.Sprintf("randomly sampled at %s%% - fires for some deliveries only", formatPercent(s.Percent))
+			rt = rt.annotate("sampled", "samplePercent", formatPercent(s.Percent))
+		case !s.sampled(eventType, d.DeliveryID, payload):
+			matched[i] = false
+			explained[i].Reason = fmt.Sprintf("not in the %s%% sample", formatPercent(s.Percent))
+		default:
+			rt = rt.annotate("sampled", "samplePercent", formatPercent(s.Percent))
+		}
+		explained[i].Fires = matched[i]
+		if matched[i] {
+			explained[i].Rules = rt.annotations.Rules
+			if len(rt.annotations.Fields) > 0 {
+				explained[i].Fields = rt.annotations.Fields
+			}
+		}
+	}
+	x.Handlers = explained
+	return x
+}
+
+// endpointForRepo - the index of the endpoint watching the repo, or 0 (the
+// primary endpoint) when none does
+func (r *Responder) endpointForRepo(fullName string) int {
+	for i, ep := range r.endpoints {
+		for _, repo := range ep.repos {
+			if repo.owner != "" && strings.EqualFold(repo.owner+"/"+repo.name, fullName) {
+				return i
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package responder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	ran := false
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) { ran = true }
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	ep := r.newEndpoint(nil, []HookHandler{h})
+	r.endpoints = []*endpoint{ep}
+	master := Filter{Name: "master", Match: func(ctx context.Context, eventType string, payload []byte) (bool, map[string]string) {
+		return string(payload) != "" && eventType == "push", map[string]string{"branch": "master"}
+	}}
+	never := Filter{Name: "never", Match: func(ctx context.Context, eventType string, payload []byte) (bool, map[string]string) {
+		return false, nil
+	}}
+	ep.routes = append(ep.routes,
+		Route{Name: "deploy", Events: []string{"push"}, Filters: []Filter{master}, Handler: h},
+		Route{Name: "issues", Events: []string{"issues", "issue_comment"}, Handler: h},
+		Route{Name: "never", Filters: []Filter{never}, Handler: h},
+		Route{Name: "services", Topics: []string{"service"}, Handler: h},
+		Route{Name: "libraries", Topics: []string{"library"}, Handler: h},
+		Route{Name: "sampled", Sampling: &Sampling{Percent: 50}, Handler: h},
+		Route{Name: "off", Handler: h},
+	)
+	assert.NoError(t, r.DisableHandler("off"))
+	r.topics.set("foo/bar", []string{"service"})
+
+	d := &Delivery{EventType: "push", DeliveryID: "d1", Payload: []byte(`{"repository": {"full_name": "foo/bar"}}`)}
+	x := r.Explain(context.Background(), d)
+	assert.False(t, ran)
+	assert.Equal(t, "foo/bar", x.Repo)
+	assert.Empty(t, x.Notes)
+	assert.Equal(t, []HandlerExplanation{
+		{Name: "0.0", Fires: true},
+		{Name: "deploy", Fires: true, Rules: []string{"event:push", "filter:master"}, Fields: map[string]string{"branch": "master"}},
+		{Name: "issues", Reason: "not subscribed to push events (only issues, issue_comment)"},
+		{Name: "never", Reason: `filter "never" didn't match`},
+		{Name: "services", Fires: true, Rules: []string{"topic:service"}, Fields: map[string]string{"topic": "service"}},
+		{Name: "libraries", Reason: "the repo has none of the topics library"},
+		{Name: "sampled", Fires: true, Rules: []string{"sampled"}, Fields: map[string]string{"samplePercent": "50"},
+			Reason: "randomly sampled at 50% - fires for some deliveries only"},
+		{Name: "off", Reason: "disabled"},
+	}, x.Handlers)
+
+	x = r.Explain(context.Background(), &Delivery{EventType: "ping", DeliveryID: "d2", Payload: []byte(`{}`)})
+	assert.Equal(t, []string{"pings are answered, but not dispatched to handlers"}, x.Notes)
+	for _, h := range x.Handlers {
+		assert.False(t, h.Fires)
+	}
+
+	r.SetMaintenance(true)
+	x = r.Explain(context.Background(), d)
+	assert.Equal(t, []string{"in maintenance - handlers would run once the maintenance window closes"}, x.Notes)
+	assert.False(t, ran)
+}
@@ -0,0 +1,88 @@
+package responder
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HookDelivery - a delivery in the shape GitHub's hook deliveries API
+// returns (see https://docs.github.com/en/rest/webhooks/repo-deliveries), for
+// tooling built against that format. The response, status, and duration
+// fields aren't recorded, so are omitted.
+type HookDelivery struct {
+	ID             int64               `json:"id"`
+	GUID           string              `json:"guid"`
+	DeliveredAt    *time.Time          `json:"delivered_at,omitempty"`
+	Redelivery     bool                `json:"redelivery"`
+	Event          string              `json:"event"`
+	Action         string              `json:"action,omitempty"`
+	InstallationID int64               `json:"installation_id,omitempty"`
+	RepositoryID   int64               `json:"repository_id,omitempty"`
+	Request        HookDeliveryRequest `json:"request"`
+}
+
+// HookDeliveryRequest - the request GitHub sent, as in HookDelivery
+type HookDeliveryRequest struct {
+	Headers map[string]string `json:"headers"`
+	Payload json.RawMessage   `json:"payload"`
+}
+
+// ExportDelivery - the delivery in GitHub's hook deliveries API format. GitHub
+// identifies deliveries with numeric IDs too, which aren't recorded, so the
+// caller chooses one.
+func ExportDelivery(d *Delivery, id int64) HookDelivery {
+	var p struct {
+		Action       string `json:"action"`
+		Installation struct {
+			ID int64 `json:"id"`
+		} `json:"installation"`
+		Repo struct {
+			ID int64 `json:"id"`
+		} `json:"repository"`
+	}
+	// errors are ignored, as not all payloads have these fields
+	_ = json.Unmarshal(d.Payload, &p)
+
+	headers := make(map[string]string, len(d.Headers))
+	for k := range d.Headers {
+		headers[k] = d.Headers.Get(k)
+	}
+	hd := HookDelivery{
+		ID:             id,
+		GUID:           d.DeliveryID,
+		Event:          d.EventType,
+		Action:         p.Action,
+		InstallationID: p.Installation.ID,
+		RepositoryID:   p.Repo.ID,
+		Request:        HookDeliveryRequest{Headers: headers, Payload: d.Payload},
+	}
+	if !d.ReceivedAt.IsZero() {
+		t := d.ReceivedAt.UTC()
+		hd.DeliveredAt = &t
+	}
+	return hd
+}
+
+// ExportDeliveries - write the stored deliveries to w as a JSON array in
+// GitHub's hook deliveries API format, numbered in the order stored
+func ExportDeliveries(w io.Writer, s DeliveryStore) error {
+	ids, err := s.List()
+	if err != nil {
+		return err
+	}
+	out := make([]HookDelivery, len(ids))
+	for i, id := range ids {
+		d, err := s.Get(id)
+		if err != nil {
+			return err
+		}
+		out[i] = ExportDelivery(d, int64(i+1))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(out), "failed to write deliveries")
+}
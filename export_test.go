@@ -0,0 +1,50 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportDelivery(t *testing.T) {
+	at := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	d := &Delivery{
+		EventType:  "issues",
+		DeliveryID: "abc",
+		Headers:    http.Header{"X-Github-Event": {"issues"}},
+		Payload:    []byte(`{"action":"opened","installation":{"id":5},"repository":{"id":7}}`),
+		ReceivedAt: at,
+	}
+	hd := ExportDelivery(d, 3)
+	assert.Equal(t, int64(3), hd.ID)
+	assert.Equal(t, "abc", hd.GUID)
+	assert.Equal(t, "issues", hd.Event)
+	assert.Equal(t, "opened", hd.Action)
+	assert.Equal(t, int64(5), hd.InstallationID)
+	assert.Equal(t, int64(7), hd.RepositoryID)
+	assert.Equal(t, &at, hd.DeliveredAt)
+	assert.Equal(t, map[string]string{"X-Github-Event": "issues"}, hd.Request.Headers)
+
+	hd = ExportDelivery(&Delivery{EventType: "ping", Payload: []byte(`{}`)}, 1)
+	assert.Nil(t, hd.DeliveredAt)
+}
+
+func TestExportDeliveries(t *testing.T) {
+	s := NewMemoryDeliveryStore()
+	_, _ = s.Put(&Delivery{EventType: "push", DeliveryID: "a", Payload: []byte(`{"ref":"x"}`)})
+	_, _ = s.Put(&Delivery{EventType: "issues", DeliveryID: "b", Payload: []byte(`{"action":"closed"}`)})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ExportDeliveries(buf, s))
+	var out []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Len(t, out, 2)
+	assert.Equal(t, "a", out[0]["guid"])
+	assert.Equal(t, float64(2), out[1]["id"])
+	assert.Equal(t, "closed", out[1]["action"])
+	assert.Equal(t, map[string]interface{}{"ref": "x"}, out[0]["request"].(map[string]interface{})["payload"])
+}
@@ -0,0 +1,60 @@
+package responder
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultTrustedProxies - the proxies whose X-Forwarded-For and
+// X-Forwarded-Proto headers are trusted behind a proxy, when none are
+// given: loopback and private addresses
+var DefaultTrustedProxies = []string{
+	"127.0.0.0/8", "::1", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+}
+
+// forwarded - middleware that, for requests from trusted proxies, takes the
+// client's address from the X-Forwarded-For header (so logging and IP
+// filtering see the client rather than the proxy), and logs the scheme the
+// client used, from X-Forwarded-Proto
+func (r *Responder) forwarded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil || !r.trustedProxies.Allowed(net.ParseIP(host)) {
+			next.ServeHTTP(resp, req)
+			return
+		}
+		if client := r.forwardedClient(req.Header.Get("X-Forwarded-For")); client != "" {
+			req.RemoteAddr = net.JoinHostPort(client, "0")
+		}
+		if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" {
+			req.URL.Scheme = proto
+			zerolog.Ctx(req.Context()).UpdateContext(func(c zerolog.Context) zerolog.Context {
+				return c.Str("proto", proto)
+			})
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
+
+// forwardedClient - the client address from an X-Forwarded-For header: the
+// last address that isn't a trusted proxy, as earlier ones can be forged by
+// the client. Empty when there's none.
+func (r *Responder) forwardedClient(xff string) string {
+	if xff == "" {
+		return ""
+	}
+	hops := strings.Split(xff, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if i == 0 || !r.trustedProxies.Allowed(ip) {
+			return ip.String()
+		}
+	}
+	return ""
+}
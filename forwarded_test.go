@@ -0,0 +1,50 @@
+package responder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardedClient(t *testing.T) {
+	r := &Responder{trustedProxies: mustIPFilter(DefaultTrustedProxies, nil)}
+	assert.Equal(t, "", r.forwardedClient(""))
+	assert.Equal(t, "192.30.252.1", r.forwardedClient("192.30.252.1"))
+	assert.Equal(t, "192.30.252.1", r.forwardedClient("192.30.252.1, 10.0.0.2"))
+	// earlier hops may be forged
+	assert.Equal(t, "192.30.252.1", r.forwardedClient("1.2.3.4, 192.30.252.1, 10.0.0.2"))
+	assert.Equal(t, "10.0.0.3", r.forwardedClient("10.0.0.3, 10.0.0.2"))
+	assert.Equal(t, "", r.forwardedClient("bogus"))
+}
+
+func TestForwarded(t *testing.T) {
+	r := &Responder{trustedProxies: mustIPFilter([]string{"10.0.0.0/8"}, nil)}
+	var remote, scheme string
+	h := r.forwarded(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		remote, scheme = req.RemoteAddr, req.URL.Scheme
+	}))
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "192.30.252.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "192.30.252.1:0", remote)
+	assert.Equal(t, "https", scheme)
+
+	// untrusted proxies' headers are ignored
+	req = httptest.NewRequest("POST", "/", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	req.Header.Set("X-Forwarded-For", "192.30.252.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "8.8.8.8:1234", remote)
+
+	o := Options{Domain: "example.com", Repos: []string{"a/b"}, TrustedProxies: []string{"10.0.0.0/8"}}
+	assert.Contains(t, o.Validate().Error(), "behind a proxy")
+	o.BehindProxy = true
+	assert.NoError(t, o.Validate())
+	o.TrustedProxies = []string{"nope"}
+	assert.Error(t, o.Validate())
+}
@@ -0,0 +1,128 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// HookHandlerE - like HookHandler, but returns an error when the event could
+// not be handled successfully.
+type HookHandlerE func(ctx context.Context, eventType, deliveryID string, payload []byte) error
+
+// statuses can only be 140 characters long
+const maxStatusDescription = 140
+
+type gateTarget struct {
+	owner string
+	repo  string
+	sha   string
+}
+
+// Gate - wraps handler so that it can be used as a required check. For
+// pull_request events that change the head commit (opened, reopened and
+// synchronize), a pending commit status named statusContext is created before
+// handler runs, and is concluded as success or failure depending on handler's
+// result - as failure when handler panics, before the panic carries on. All
+// other events are passed to handler unchanged.
+func Gate(statusContext string, handler HookHandlerE) HookHandler {
+	return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		log := log.Ctx(ctx)
+		target, ok := parseGateTarget(eventType, payload)
+		client := GitHubClient(ctx)
+		if !ok || client == nil {
+			err := handler(ctx, eventType, deliveryID, payload)
+			if err != nil {
+				log.Error().Err(err).Msg("handler failed")
+			}
+			return
+		}
+
+		l := log.With().
+			Str("status_context", statusContext).
+			Str("sha", target.sha).
+			Logger()
+		log = &l
+
+		err := setStatus(ctx, client, target, statusContext, "pending", "in progress")
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create pending status")
+		}
+		// the panic's recovered further up, which would leave the status
+		// pending, blocking the pull request
+		defer func() {
+			if p := recover(); p != nil {
+				err := setStatus(ctx, client, target, statusContext, "failure", "handler panicked")
+				if err != nil {
+					log.Error().Err(err).Msg("failed to conclude status")
+				}
+				panic(p)
+			}
+		}()
+
+		state, desc := "success", "succeeded"
+		herr := handler(ctx, eventType, deliveryID, payload)
+		if herr != nil {
+			log.Error().Err(herr).Msg("handler failed")
+			state, desc = "failure", herr.Error()
+		}
+
+		err = setStatus(ctx, client, target, statusContext, state, desc)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to conclude status")
+			return
+		}
+		log.Info().Str("state", state).Msg("Concluded status")
+	}
+}
+
+func setStatus(ctx context.Context, client *github.Client, target gateTarget, statusContext, state, desc string) error {
+	status := &github.RepoStatus{
+		State:       github.String(state),
+		Context:     github.String(statusContext),
+		Description: github.String(truncateDescription(desc)),
+	}
+	_, _, err := client.Repositories.CreateStatus(ctx, target.owner, target.repo, target.sha, status)
+	return errors.Wrapf(err, "failed to set %s status on %s/%s@%s", state, target.owner, target.repo, target.sha)
+}
+
+// truncateDescription shortens the description to fit a status, counting
+// characters rather than bytes, so it's not cut mid-character
+func truncateDescription(desc string) string {
+	if utf8.RuneCountInString(desc) <= maxStatusDescription {
+		return desc
+	}
+	return string([]rune(desc)[:maxStatusDescription-3]) + "..."
+}
+
+// parseGateTarget returns the commit to report on, and whether the event is
+// one that should be gated at all
+func parseGateTarget(eventType string, payload []byte) (gateTarget, bool) {
+	if eventType != "pull_request" {
+		return gateTarget{}, false
+	}
+	event := &github.PullRequestEvent{}
+	err := json.Unmarshal(payload, event)
+	if err != nil {
+		return gateTarget{}, false
+	}
+	switch event.GetAction() {
+	case "opened", "reopened", "synchronize":
+	default:
+		return gateTarget{}, false
+	}
+
+	target := gateTarget{
+		owner: event.GetRepo().GetOwner().GetLogin(),
+		repo:  event.GetRepo().GetName(),
+		sha:   event.GetPullRequest().GetHead().GetSHA(),
+	}
+	if target.owner == "" || target.repo == "" || target.sha == "" {
+		return gateTarget{}, false
+	}
+	return target, true
+}
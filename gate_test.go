@@ -0,0 +1,118 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGateTarget(t *testing.T) {
+	payload := []byte(`{
+		"action": "synchronize",
+		"pull_request": {"head": {"sha": "abc123"}},
+		"repository": {"name": "bar", "owner": {"login": "foo"}}
+	}`)
+	target, ok := parseGateTarget("pull_request", payload)
+	assert.True(t, ok)
+	assert.Equal(t, gateTarget{"foo", "bar", "abc123"}, target)
+
+	_, ok = parseGateTarget("push", payload)
+	assert.False(t, ok)
+
+	_, ok = parseGateTarget("pull_request", []byte(`{"action": "closed"}`))
+	assert.False(t, ok)
+
+	_, ok = parseGateTarget("pull_request", []byte(`{"action": "opened"}`))
+	assert.False(t, ok)
+
+	_, ok = parseGateTarget("pull_request", []byte(`not json`))
+	assert.False(t, ok)
+}
+
+func TestTruncateDescription(t *testing.T) {
+	assert.Equal(t, "short", truncateDescription("short"))
+
+	long := strings.Repeat("é", 200)
+	desc := truncateDescription(long)
+	assert.True(t, utf8.ValidString(desc))
+	assert.Equal(t, maxStatusDescription, utf8.RuneCountInString(desc))
+	assert.True(t, strings.HasSuffix(desc, "é..."))
+}
+
+func TestGate(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []github.RepoStatus
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/repos/foo/bar/statuses/abc123", req.URL.Path)
+		s := github.RepoStatus{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&s))
+		mu.Lock()
+		statuses = append(statuses, s)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	ctx := withGitHubClient(context.Background(), client)
+
+	payload := []byte(`{
+		"action": "opened",
+		"pull_request": {"head": {"sha": "abc123"}},
+		"repository": {"name": "bar", "owner": {"login": "foo"}}
+	}`)
+	states := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		out := []string{}
+		for _, s := range statuses {
+			assert.Equal(t, "ci/responder", s.GetContext())
+			out = append(out, s.GetState()+": "+s.GetDescription())
+		}
+		statuses = nil
+		return out
+	}
+
+	var ran bool
+	Gate("ci/responder", func(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+		ran = true
+		// pending while the handler runs
+		assert.Equal(t, []string{"pending: in progress"}, states())
+		return nil
+	})(ctx, "pull_request", "1", payload)
+	assert.True(t, ran)
+	assert.Equal(t, []string{"success: succeeded"}, states())
+
+	Gate("ci/responder", func(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+		return errors.New("tests failed")
+	})(ctx, "pull_request", "2", payload)
+	assert.Equal(t, []string{"pending: in progress", "failure: tests failed"}, states())
+
+	// a panicking handler fails the status, and still panics
+	assert.PanicsWithValue(t, "boom", func() {
+		Gate("ci/responder", func(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+			panic("boom")
+		})(ctx, "pull_request", "4", payload)
+	})
+	assert.Equal(t, []string{"pending: in progress", "failure: handler panicked"}, states())
+
+	// other events aren't gated
+	ran = false
+	Gate("ci/responder", func(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+		ran = true
+		return nil
+	})(ctx, "push", "3", payload)
+	assert.True(t, ran)
+	assert.Empty(t, states())
+}
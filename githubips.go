@@ -0,0 +1,175 @@
+package responder
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/hlog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultGitHubHookIPsRefresh - how often GitHub's hook addresses are
+	// refreshed, when Options.GitHubHookIPsRefresh isn't given
+	DefaultGitHubHookIPsRefresh = time.Hour
+	// hookIPsFetchTimeout bounds fetching GitHub's hook addresses
+	hookIPsFetchTimeout = 10 * time.Second
+	// hookIPsRetry - how long after a failed refresh it's retried, doubling
+	// with each further failure, up to the refresh interval
+	hookIPsRetry = time.Minute
+)
+
+var hookIPRanges = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "github",
+	Subsystem: "webhook",
+	Name:      "hook_ip_ranges",
+	Help:      "The number of address ranges GitHub delivers webhooks from, as last fetched from the /meta API.",
+})
+
+// hookIPWatcher - the addresses GitHub delivers webhooks from, fetched from
+// the /meta API and refreshed periodically
+type hookIPWatcher struct {
+	refresh time.Duration
+	now     func() time.Time
+	// fetch returns GitHub's hook CIDRs
+	fetch func(ctx context.Context) ([]string, error)
+
+	mu     sync.Mutex
+	filter *IPFilter
+	// next - when the addresses are next due a refresh
+	next       time.Time
+	failures   int
+	refreshing bool
+}
+
+// githubHookCIDRs - the CIDRs in the /meta API's hooks list
+func githubHookCIDRs(ctx context.Context, r *Responder) ([]string, error) {
+	meta, _, err := r.ghclient.APIMeta(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch GitHub's hook addresses")
+	}
+	if len(meta.Hooks) == 0 {
+		return nil, errors.New("GitHub's /meta API listed no hook addresses")
+	}
+	return meta.Hooks, nil
+}
+
+// run refreshes the addresses every refresh interval, until the context is
+// cancelled
+func (w *hookIPWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(w.refresh)
+	defer ticker.Stop()
+	for {
+		if w.begin() {
+			w.update(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// begin - whether the caller should refresh the addresses, as no other
+// refresh is running. The caller must call update.
+func (w *hookIPWatcher) begin() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.refreshing {
+		return false
+	}
+	w.refreshing = true
+	return true
+}
+
+// update fetches the addresses. The last ones fetched are kept when it
+// fails, and it's retried with a backoff, so an outage doesn't set every
+// callback fetching them.
+func (w *hookIPWatcher) update(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, hookIPsFetchTimeout)
+	defer cancel()
+	cidrs, err := w.fetch(ctx)
+	var f *IPFilter
+	if err == nil {
+		f, err = NewIPFilter(cidrs, nil)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.refreshing = false
+	if err != nil {
+		retry := hookIPsRetry << uint(w.failures)
+		if retry > w.refresh || retry <= 0 {
+			retry = w.refresh
+		} else {
+			w.failures++
+		}
+		w.next = w.now().Add(retry)
+		log.Warn().Err(err).Bool("stale", w.filter != nil).Dur("retry", retry).Msg("failed to refresh GitHub's hook addresses")
+		return
+	}
+	if w.filter == nil || !sameRanges(w.filter.Allow, f.Allow) {
+		log.Info().Strs("ranges", cidrs).Msg("Accepting callbacks from GitHub's hook addresses")
+	}
+	w.filter = f
+	w.failures = 0
+	w.next = w.now().Add(w.refresh)
+	hookIPRanges.Set(float64(len(f.Allow)))
+}
+
+// current - the addresses, and whether they're due a refresh
+func (w *hookIPWatcher) current() (*IPFilter, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.filter, !w.now().Before(w.next)
+}
+
+// Middleware - reject requests from addresses GitHub doesn't deliver from
+// with a 404, like IPFilter. Until the addresses have been fetched, they're
+// fetched before the request is let through, and requests are rejected with
+// a 503 if that fails (and until it's retried). Stale addresses are
+// refreshed in the background, so the addresses stay fresh when served with
+// Handler rather than Listen.
+func (w *hookIPWatcher) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		f, stale := w.current()
+		switch {
+		case f == nil:
+			if stale && w.begin() {
+				w.update(req.Context())
+			}
+			if f, _ = w.current(); f == nil {
+				http.Error(resp, "GitHub's hook addresses are unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		case stale && w.begin():
+			go w.update(context.Background())
+		}
+
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err == nil && f.Allowed(net.ParseIP(host)) {
+			next.ServeHTTP(resp, req)
+			return
+		}
+		hlog.FromRequest(req).Warn().Str("remoteAddr", req.RemoteAddr).Msg("not one of GitHub's hook addresses - rejecting")
+		resp.WriteHeader(http.StatusNotFound)
+	})
+}
+
+func sameRanges(a, b []*net.IPNet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// hookIPsFixture - the addresses a test's watcher fetches. It's guarded, as
+// the watcher may fetch them in the background.
+type hookIPsFixture struct {
+	mu       sync.Mutex
+	now      time.Time
+	cidrs    []string
+	fetchErr error
+	fetches  int
+}
+
+func (f *hookIPsFixture) clock() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *hookIPsFixture) fetch(ctx context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches++
+	return f.cidrs, f.fetchErr
+}
+
+func (f *hookIPsFixture) set(advance time.Duration, cidrs []string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(advance)
+	f.cidrs = cidrs
+	f.fetchErr = err
+}
+
+func (f *hookIPsFixture) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+func TestHookIPWatcher(t *testing.T) {
+	fx := &hookIPsFixture{
+		now:   time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC),
+		cidrs: []string{"192.30.252.0/22"},
+	}
+	w := &hookIPWatcher{refresh: time.Hour, now: fx.clock, fetch: fx.fetch}
+	h := w.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(addr string) int {
+		req := httptest.NewRequest("POST", "/gh-callback", nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// waitIdle waits for a background refresh to finish
+	waitIdle := func() {
+		for i := 0; i < 500 && !w.begin(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.mu.Lock()
+		w.refreshing = false
+		w.mu.Unlock()
+	}
+
+	// fetched on the first request
+	assert.Equal(t, http.StatusOK, serve("192.30.252.1:1234"))
+	assert.Equal(t, http.StatusNotFound, serve("8.8.8.8:1234"))
+	assert.Equal(t, 1, fx.count())
+
+	// refreshed once stale, keeping the old addresses when that fails
+	fx.set(2*time.Hour, []string{"192.30.252.0/22"}, errors.New("boom"))
+	w.begin()
+	w.update(context.Background())
+	assert.Equal(t, 2, fx.count())
+	assert.Equal(t, http.StatusOK, serve("192.30.252.1:1234"))
+
+	// failed refreshes aren't retried by every callback
+	for i := 0; i < 10; i++ {
+		serve("192.30.252.1:1234")
+	}
+	waitIdle()
+	assert.Equal(t, 2, fx.count())
+
+	// but in the background, once the backoff's passed
+	fx.set(hookIPsRetry, []string{"140.82.112.0/20"}, nil)
+	assert.Equal(t, http.StatusOK, serve("192.30.252.1:1234"))
+	waitIdle()
+	assert.Equal(t, 3, fx.count())
+	assert.Equal(t, http.StatusNotFound, serve("192.30.252.1:1234"))
+	assert.Equal(t, http.StatusOK, serve("140.82.112.5:1234"))
+	f, stale := w.current()
+	assert.NotNil(t, f)
+	assert.False(t, stale)
+}
+
+func TestHookIPWatcherBackoff(t *testing.T) {
+	fx := &hookIPsFixture{now: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), fetchErr: errors.New("boom")}
+	w := &hookIPWatcher{refresh: 5 * time.Minute, now: fx.clock, fetch: fx.fetch}
+	for _, retry := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		w.begin()
+		w.update(context.Background())
+		assert.Equal(t, fx.now.Add(retry), w.next)
+	}
+
+	fx.set(0, []string{"192.30.252.0/22"}, nil)
+	w.begin()
+	w.update(context.Background())
+	assert.Equal(t, 0, w.failures)
+	assert.Equal(t, fx.now.Add(5*time.Minute), w.next)
+}
+
+func TestHookIPWatcherUnavailable(t *testing.T) {
+	fx := &hookIPsFixture{now: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), fetchErr: errors.New("boom")}
+	w := &hookIPWatcher{refresh: time.Hour, now: fx.clock, fetch: fx.fetch}
+	h := w.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func() int {
+		req := httptest.NewRequest("POST", "/gh-callback", nil)
+		req.RemoteAddr = "192.30.252.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, serve())
+	// not retried until the backoff's passed
+	assert.Equal(t, http.StatusServiceUnavailable, serve())
+	assert.Equal(t, 1, fx.count())
+
+	fx.set(hookIPsRetry, []string{"192.30.252.0/22"}, nil)
+	assert.Equal(t, http.StatusOK, serve())
+	assert.Equal(t, 2, fx.count())
+}
+
+func TestGitHubHookCIDRs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/meta", r.URL.Path)
+		_, _ = w.Write([]byte(`{"hooks": ["192.30.252.0/22", "185.199.108.0/22"]}`))
+	}))
+	defer srv.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	r := &Responder{ghclient: client}
+	cidrs, err := githubHookCIDRs(context.Background(), r)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.30.252.0/22", "185.199.108.0/22"}, cidrs)
+
+	err = Options{Repos: []string{"keyolk/github-responder"}, Domain: "hooks.example.com", GitHubHookIPsRefresh: -time.Minute}.Validate()
+	assert.Error(t, err)
+}
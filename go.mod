@@ -1,7 +1,7 @@
 module github.com/hairyhenderson/github-responder
 
 require (
-	github.com/cenkalti/backoff v2.1.1+incompatible // indirect
+	github.com/cenkalti/backoff v2.1.1+incompatible
 	github.com/coreos/go-iptables v0.4.0
 	github.com/golang/protobuf v1.3.0 // indirect
 	github.com/google/go-github/v24 v24.0.1
@@ -20,7 +20,7 @@ require (
 	github.com/spf13/cobra v0.0.3
 	github.com/spf13/pflag v1.0.3 // indirect
 	github.com/stretchr/testify v1.3.0
-	github.com/xenolf/lego v2.2.0+incompatible // indirect
+	github.com/xenolf/lego v2.2.0+incompatible
 	github.com/zenazn/goji v0.9.0 // indirect
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	golang.org/x/net v0.0.0-20190310074541-c10a0554eabf // indirect
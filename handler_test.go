@@ -0,0 +1,55 @@
+package responder
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	r := testResponder(t, nil, "a/b")
+	path := r.CallbackPath()
+	assert.True(t, strings.HasPrefix(path, "/gh-callback/"), path)
+
+	// mounted on someone else's mux
+	mux := http.NewServeMux()
+	mux.Handle(path, r.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {})
+
+	// deliveries are validated
+	req := httptest.NewRequest("POST", path, strings.NewReader(`{}`))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature", "sha1=bogus")
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// other paths aren't served
+	resp = httptest.NewRecorder()
+	r.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/elsewhere", nil))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestAccessLog(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	buf := &bytes.Buffer{}
+	r := &Responder{}
+	r.SetAccessLog(buf)
+	h := r.accessLogging().ThenFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, buf.String(), `"level":"info"`)
+	assert.Contains(t, buf.String(), `"status":204`)
+}
@@ -0,0 +1,165 @@
+package responder
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// HandlerInfo - a handler, and whether it's enabled (see Handlers)
+type HandlerInfo struct {
+	// Name - the route's Name, or its position, like "0.1" for the second
+	// action of the primary endpoint
+	Name string `json:"name"`
+	// Endpoint - the position of the handler's endpoint, 0 for the primary
+	Endpoint int `json:"endpoint"`
+	// Events the handler is routed - empty for all events
+	Events  []string `json:"events,omitempty"`
+	Enabled bool     `json:"enabled"`
+}
+
+// Handlers - the Responder's handlers, in the order they're run
+func (r *Responder) Handlers() []HandlerInfo {
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+	var handlers []HandlerInfo
+	for i, ep := range r.endpoints {
+		for _, rt := range ep.namedRoutes() {
+			handlers = append(handlers, HandlerInfo{
+				Name:     rt.name,
+				Endpoint: i,
+				Events:   rt.Events,
+				Enabled:  !r.disabledHandlers[rt.name],
+			})
+		}
+	}
+	return handlers
+}
+
+// DisableHandler - stop routing deliveries to the named handler (see
+// Handlers), until it's enabled again - including deliveries retried from
+// the write-ahead log, and its follow-ups and queued runs, which are
+// dropped when due. Runs already started aren't affected. Useful when one
+// automation misbehaves, as the rest carry on.
+func (r *Responder) DisableHandler(name string) error {
+	return r.setHandlerEnabled(name, false)
+}
+
+// EnableHandler - route deliveries to the named handler again, after
+// DisableHandler. Deliveries received while it was disabled aren't
+// replayed.
+func (r *Responder) EnableHandler(name string) error {
+	return r.setHandlerEnabled(name, true)
+}
+
+func (r *Responder) setHandlerEnabled(name string, enabled bool) error {
+	if !r.hasHandler(name) {
+		return newCauseError(ErrUnknownHandler, "unknown handler %q", name)
+	}
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+	if r.disabledHandlers == nil {
+		r.disabledHandlers = map[string]bool{}
+	}
+	if r.disabledHandlers[name] == !enabled {
+		return nil
+	}
+	if enabled {
+		delete(r.disabledHandlers, name)
+	} else {
+		r.disabledHandlers[name] = true
+	}
+	log.Info().Str("handler", name).Bool("enabled", enabled).Msg("handler switched")
+	return nil
+}
+
+func (r *Responder) hasHandler(name string) bool {
+	_, ok := r.routeNamed(name)
+	return ok
+}
+
+// enabledRoutes drops the routes of disabled handlers
+func (r *Responder) enabledRoutes(routes []Route) []Route {
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+	if len(r.disabledHandlers) == 0 {
+		return routes
+	}
+	enabled := make([]Route, 0, len(routes))
+	for _, rt := range routes {
+		if !r.disabledHandlers[rt.name] {
+			enabled = append(enabled, rt)
+		}
+	}
+	return enabled
+}
+
+// validateRouteNames checks the routes' names are usable in the admin
+// endpoint's URLs, and unique among the Responder's handlers
+func (r *Responder) validateRouteNames(routes []Route) error {
+	names := map[string]bool{}
+	for _, ep := range r.endpoints {
+		for _, rt := range ep.namedRoutes() {
+			names[rt.name] = true
+		}
+	}
+	for _, rt := range routes {
+		switch {
+		case rt.Name == "":
+			continue
+		case strings.ContainsAny(rt.Name, "/?# "):
+			return errors.Errorf("invalid handler name %q - must not contain '/', '?', '#', or spaces", rt.Name)
+		case names[rt.Name]:
+			return errors.Errorf("duplicate handler name %q", rt.Name)
+		}
+		names[rt.Name] = true
+	}
+	return nil
+}
+
+// EnableHandlerAdmin - serve the handler admin endpoint at <prefix>handlers,
+// to addresses allowed by the metrics IP filter. GET lists the handlers as
+// JSON (see Handlers), and a POST to <prefix>handlers/<name>/disable or
+// <prefix>handlers/<name>/enable switches a handler off or on.
+func (r *Responder) EnableHandlerAdmin() {
+	r.handlerAdmin = true
+}
+
+// handlerAdminHandler serves the handler admin endpoint under path
+func (r *Responder) handlerAdminHandler(path string) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == path {
+			if req.Method != http.MethodGet {
+				http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			resp.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(resp).Encode(r.Handlers())
+			return
+		}
+		rest := strings.TrimPrefix(req.URL.Path, path+"/")
+		i := strings.LastIndex(rest, "/")
+		if i < 0 {
+			http.NotFound(resp, req)
+			return
+		}
+		name, action := rest[:i], rest[i+1:]
+		if action != "enable" && action != "disable" {
+			http.NotFound(resp, req)
+			return
+		}
+		if req.Method != http.MethodPost {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		err := r.setHandlerEnabled(name, action == "enable")
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusNotFound)
+			return
+		}
+		resp.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// AutoMergeConfig - configures the AutoMerge handler
+type AutoMergeConfig struct {
+	// Label that must be present on a pull request for it to be merged
+	Label string
+	// Method is the merge method - one of "merge" (the default), "squash", or
+	// "rebase"
+	Method string
+	// Policy - when set, only merge pull requests whose label was last
+	// applied by an actor the policy authorizes
+	Policy Policy
+	// EnableAutoMerge - when set, GitHub's auto-merge is enabled on labelled
+	// pull requests that branch protection doesn't yet allow merging, so
+	// GitHub merges them once its requirements are met. The repository must
+	// allow auto-merge.
+	EnableAutoMerge bool
+}
+
+// mergeabilityRetry - how long to wait before re-checking pull requests
+// whose mergeability GitHub was still computing
+const mergeabilityRetry = 30 * time.Second
+
+type automerge struct {
+	cfg AutoMergeConfig
+
+	mu sync.Mutex
+	// enabled - the pull request heads auto-merge has been enabled for, by
+	// node ID and SHA, so it's only enabled once per push
+	enabled map[string]bool
+}
+
+// AutoMerge - returns a handler that merges labelled pull requests once all
+// their statuses and check runs have passed, and branch protection allows it.
+//
+// Pull requests are evaluated whenever they're labelled or updated, when a
+// review is submitted, and when a status, check run, or check suite on their
+// head commit completes. Pull requests whose mergeability GitHub hasn't yet
+// computed are evaluated again in a follow-up (see
+// responder.ScheduleFollowUp).
+func AutoMerge(cfg AutoMergeConfig) (responder.HookHandler, error) {
+	if cfg.Label == "" {
+		return nil, errors.New("must provide a label")
+	}
+	switch cfg.Method {
+	case "":
+		cfg.Method = "merge"
+	case "merge", "squash", "rebase":
+	default:
+		return nil, errors.Errorf("invalid merge method %s - must be merge, squash, or rebase", cfg.Method)
+	}
+	a := &automerge{cfg: cfg, enabled: map[string]bool{}}
+	return a.handle, nil
+}
+
+func (a *automerge) handle(ctx context.Context, eventType, deliveryID string, payload []byte) {
+	log := log.Ctx(ctx)
+	owner, repo, numbers, sha, err := mergeCandidates(eventType, payload)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse event")
+		return
+	}
+	if len(numbers) == 0 && sha == "" {
+		return
+	}
+
+	client, err := client(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		return
+	}
+
+	if sha != "" {
+		numbers, err = pullsForSHA(ctx, client, owner, repo, sha)
+		if err != nil {
+			log.Error().Err(err).Str("sha", sha).Msg("failed to find pull requests")
+			return
+		}
+	}
+
+	retry := false
+	for _, n := range numbers {
+		pending, err := a.tryMerge(ctx, client, owner, repo, n)
+		if err != nil {
+			log.Error().Err(err).Int("number", n).Msg("failed to merge")
+		}
+		retry = retry || pending
+	}
+
+	// GitHub computes mergeability in the background once it's asked for, so
+	// it's usually known by the time the follow-up runs - only one is
+	// scheduled, so a pull request that's never computed isn't retried forever
+	info := responder.GetDeliveryInfo(ctx)
+	if retry && (info == nil || !info.FollowUp) {
+		err = responder.ScheduleFollowUp(ctx, mergeabilityRetry)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to schedule mergeability re-check")
+		}
+	}
+}
+
+// mergeCandidates finds the pull requests an event may affect - for status
+// events only the head SHA is known, so the pull requests must be looked up
+func mergeCandidates(eventType string, payload []byte) (owner, repo string, numbers []int, sha string, err error) {
+	var r *github.Repository
+	switch eventType {
+	case "pull_request":
+		event := &github.PullRequestEvent{}
+		err = json.Unmarshal(payload, event)
+		switch event.GetAction() {
+		case "opened", "reopened", "synchronize", "labeled", "ready_for_review":
+			numbers = []int{event.GetPullRequest().GetNumber()}
+		}
+		r = event.GetRepo()
+	case "pull_request_review":
+		event := &github.PullRequestReviewEvent{}
+		err = json.Unmarshal(payload, event)
+		numbers = []int{event.GetPullRequest().GetNumber()}
+		r = event.GetRepo()
+	case "status":
+		event := &github.StatusEvent{}
+		err = json.Unmarshal(payload, event)
+		if event.GetState() == "success" {
+			sha = event.GetSHA()
+		}
+		r = event.GetRepo()
+	case "check_run":
+		event := &github.CheckRunEvent{}
+		err = json.Unmarshal(payload, event)
+		if event.GetAction() == "completed" {
+			for _, pr := range event.GetCheckRun().PullRequests {
+				numbers = append(numbers, pr.GetNumber())
+			}
+		}
+		r = event.GetRepo()
+	case "check_suite":
+		event := &github.CheckSuiteEvent{}
+		err = json.Unmarshal(payload, event)
+		if event.GetAction() == "completed" {
+			for _, pr := range event.GetCheckSuite().PullRequests {
+				numbers = append(numbers, pr.GetNumber())
+			}
+		}
+		r = event.GetRepo()
+	}
+	return r.GetOwner().GetLogin(), r.GetName(), numbers, sha, err
+}
+
+func pullsForSHA(ctx context.Context, client *github.Client, owner, repo, sha string) ([]int, error) {
+	opt := &github.PullRequestListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	numbers := []int{}
+	for {
+		pulls, resp, err := client.PullRequests.List(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, pr := range pulls {
+			if pr.GetHead().GetSHA() == sha {
+				numbers = append(numbers, pr.GetNumber())
+			}
+		}
+		if resp.NextPage == 0 {
+			return numbers, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// tryMerge merges the pull request if it can be, returning whether its
+// mergeability was still being computed, so it should be tried again
+func (a *automerge) tryMerge(ctx context.Context, client *github.Client, owner, repo string, number int) (bool, error) {
+	log := log.Ctx(ctx).With().Int("number", number).Logger()
+
+	// the payload may be stale, and only a fetched PR has mergeability info
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get pull request")
+	}
+	if !hasLabel(pr.Labels, a.cfg.Label) {
+		return false, nil
+	}
+	if a.cfg.Policy != nil {
+		labeler, err := lastLabeler(ctx, client, owner, repo, number, a.cfg.Label)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to find who applied the label")
+		}
+		err = a.cfg.Policy.Authorize(ctx, client, Actor{
+			Login:       labeler,
+			Owner:       owner,
+			Repo:        repo,
+			Number:      number,
+			PullRequest: true,
+		})
+		if d, denied := err.(*Denial); denied {
+			log.Debug().Str("labeler", labeler).Str("reason", d.Reason).Msg("Not merging - labeler isn't authorized")
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if pr.Mergeable == nil || pr.GetMergeableState() == "unknown" {
+		log.Debug().Msg("Not merging yet - mergeability not yet computed")
+		return true, nil
+	}
+	if a.cfg.EnableAutoMerge && waitsForProtection(pr) {
+		return false, a.enableAutoMerge(ctx, client, pr)
+	}
+
+	sha := pr.GetHead().GetSHA()
+	combined, _, err := client.Repositories.GetCombinedStatus(ctx, owner, repo, sha, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get combined status")
+	}
+	runs, err := listCheckRuns(ctx, client, owner, repo, sha)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list check runs")
+	}
+
+	ok, reason := mergeable(pr, combined, runs)
+	if !ok {
+		log.Debug().Str("reason", reason).Msg("Not merging")
+		return false, nil
+	}
+
+	result, _, err := client.PullRequests.Merge(ctx, owner, repo, number, "", &github.PullRequestOptions{
+		SHA:         sha,
+		MergeMethod: a.cfg.Method,
+	})
+	if err != nil {
+		return false, err
+	}
+	log.Info().
+		Str("method", a.cfg.Method).
+		Str("sha", result.GetSHA()).
+		Msg("Merged pull request")
+	return false, nil
+}
+
+// listCheckRuns lists all the check runs for the ref
+func listCheckRuns(ctx context.Context, client *github.Client, owner, repo, ref string) ([]*github.CheckRun, error) {
+	opt := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	runs := []*github.CheckRun{}
+	for {
+		page, resp, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, ref, opt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, page.CheckRuns...)
+		if resp.NextPage == 0 {
+			return runs, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// waitsForProtection - whether the pull request is open, and can't be merged
+// only because branch protection's requirements (checks or reviews) aren't
+// yet met - which GitHub's auto-merge waits for
+func waitsForProtection(pr *github.PullRequest) bool {
+	if pr.GetState() != "open" || pr.GetDraft() {
+		return false
+	}
+	switch pr.GetMergeableState() {
+	case "blocked", "unstable", "behind":
+		return true
+	}
+	return false
+}
+
+// enableAutoMergeMutation - the GraphQL mutation enabling auto-merge, which
+// has no REST equivalent
+const enableAutoMergeMutation = `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) {
+    clientMutationId
+  }
+}`
+
+// enableAutoMerge enables GitHub's auto-merge on the pull request, once for
+// each head commit
+func (a *automerge) enableAutoMerge(ctx context.Context, client *github.Client, pr *github.PullRequest) error {
+	key := pr.GetNodeID() + "@" + pr.GetHead().GetSHA()
+	a.mu.Lock()
+	done := a.enabled[key]
+	a.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	req, err := client.NewRequest("POST", graphQLPath(client), map[string]interface{}{
+		"query": enableAutoMergeMutation,
+		"variables": map[string]string{
+			"id":     pr.GetNodeID(),
+			"method": strings.ToUpper(a.cfg.Method),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	out := struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	_, err = client.Do(ctx, req, &out)
+	if err != nil {
+		return errors.Wrap(err, "failed to enable auto-merge")
+	}
+	if len(out.Errors) > 0 {
+		return errors.Errorf("failed to enable auto-merge: %s", out.Errors[0].Message)
+	}
+
+	a.mu.Lock()
+	a.enabled[key] = true
+	a.mu.Unlock()
+	log.Ctx(ctx).Info().
+		Int("number", pr.GetNumber()).
+		Str("method", a.cfg.Method).
+		Msg("Enabled auto-merge")
+	return nil
+}
+
+// graphQLPath - the GraphQL endpoint, relative to the client's REST API base
+// URL - GitHub Enterprise serves the REST API under /api/v3/, and GraphQL at
+// /api/graphql
+func graphQLPath(client *github.Client) string {
+	if strings.HasSuffix(client.BaseURL.Path, "/v3/") {
+		return "../graphql"
+	}
+	return "graphql"
+}
+
+// lastLabeler finds who last applied the label to the issue or pull request
+func lastLabeler(ctx context.Context, client *github.Client, owner, repo string, number int, label string) (string, error) {
+	opt := &github.ListOptions{PerPage: 100}
+	labeler := ""
+	for {
+		events, resp, err := client.Issues.ListIssueEvents(ctx, owner, repo, number, opt)
+		if err != nil {
+			return "", err
+		}
+		for _, e := range events {
+			if e.GetEvent() == "labeled" && e.GetLabel().GetName() == label {
+				labeler = e.GetActor().GetLogin()
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	if labeler == "" {
+		return "", errors.Errorf("no labeled event for %s", label)
+	}
+	return labeler, nil
+}
+
+func hasLabel(labels []*github.Label, name string) bool {
+	for _, l := range labels {
+		if l.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeable decides whether a pull request can be merged, given the state of
+// its head commit's statuses and check runs. When it can't, a reason is given.
+func mergeable(pr *github.PullRequest, combined *github.CombinedStatus, runs []*github.CheckRun) (bool, string) {
+	if pr.GetState() != "open" {
+		return false, "not open"
+	}
+	if pr.GetDraft() {
+		return false, "draft"
+	}
+	if pr.Mergeable == nil {
+		return false, "mergeability not yet computed"
+	}
+
+	// mergeable_state reflects branch protection - required checks, required
+	// reviews, and whether the branch must be up to date
+	switch pr.GetMergeableState() {
+	case "clean":
+	case "blocked":
+		return false, "blocked by branch protection"
+	case "behind":
+		return false, "head branch is behind the base branch"
+	case "dirty":
+		return false, "merge conflicts"
+	default:
+		return false, "mergeable state is " + pr.GetMergeableState()
+	}
+
+	if combined.GetTotalCount() > 0 && combined.GetState() != "success" {
+		return false, "combined status is " + combined.GetState()
+	}
+	for _, run := range runs {
+		if run.GetStatus() != "completed" {
+			return false, "check run " + run.GetName() + " is " + run.GetStatus()
+		}
+		switch run.GetConclusion() {
+		case "success", "neutral", "skipped":
+		default:
+			return false, "check run " + run.GetName() + " concluded " + run.GetConclusion()
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoMerge(t *testing.T) {
+	_, err := AutoMerge(AutoMergeConfig{})
+	assert.Error(t, err)
+
+	_, err = AutoMerge(AutoMergeConfig{Label: "automerge", Method: "octopus"})
+	assert.Error(t, err)
+
+	_, err = AutoMerge(AutoMergeConfig{Label: "automerge"})
+	assert.NoError(t, err)
+}
+
+func TestMergeCandidates(t *testing.T) {
+	owner, repo, numbers, sha, err := mergeCandidates("check_suite", []byte(`{
+		"action": "completed",
+		"check_suite": {"pull_requests": [{"number": 1}, {"number": 2}]},
+		"repository": {"name": "bar", "owner": {"login": "foo"}}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", owner)
+	assert.Equal(t, "bar", repo)
+	assert.Equal(t, []int{1, 2}, numbers)
+	assert.Equal(t, "", sha)
+
+	_, _, numbers, _, err = mergeCandidates("check_run", []byte(`{
+		"action": "completed",
+		"check_run": {"pull_requests": [{"number": 3}]}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, numbers)
+
+	_, _, numbers, _, err = mergeCandidates("check_run", []byte(`{"action": "created", "check_run": {"pull_requests": [{"number": 3}]}}`))
+	assert.NoError(t, err)
+	assert.Empty(t, numbers)
+
+	_, _, numbers, sha, err = mergeCandidates("status", []byte(`{"state": "success", "sha": "abc"}`))
+	assert.NoError(t, err)
+	assert.Empty(t, numbers)
+	assert.Equal(t, "abc", sha)
+
+	_, _, numbers, sha, err = mergeCandidates("status", []byte(`{"state": "pending", "sha": "abc"}`))
+	assert.NoError(t, err)
+	assert.Empty(t, numbers)
+	assert.Equal(t, "", sha)
+
+	_, _, numbers, _, err = mergeCandidates("pull_request", []byte(`{"action": "closed", "pull_request": {"number": 1}}`))
+	assert.NoError(t, err)
+	assert.Empty(t, numbers)
+
+	_, _, numbers, _, err = mergeCandidates("watch", []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Empty(t, numbers)
+}
+
+func TestMergeable(t *testing.T) {
+	pr := &github.PullRequest{
+		State:          github.String("open"),
+		Mergeable:      github.Bool(true),
+		MergeableState: github.String("clean"),
+	}
+	success := &github.CombinedStatus{State: github.String("success"), TotalCount: github.Int(1)}
+	run := func(status, conclusion string) *github.CheckRun {
+		return &github.CheckRun{Name: github.String("ci"), Status: &status, Conclusion: &conclusion}
+	}
+
+	ok, _ := mergeable(pr, success, []*github.CheckRun{run("completed", "success")})
+	assert.True(t, ok)
+
+	ok, _ = mergeable(pr, &github.CombinedStatus{State: github.String("pending")}, nil)
+	assert.True(t, ok, "no statuses at all shouldn't block")
+
+	ok, reason := mergeable(pr, &github.CombinedStatus{State: github.String("failure"), TotalCount: github.Int(1)}, nil)
+	assert.False(t, ok)
+	assert.Equal(t, "combined status is failure", reason)
+
+	ok, _ = mergeable(pr, success, []*github.CheckRun{run("in_progress", "")})
+	assert.False(t, ok)
+
+	ok, _ = mergeable(pr, success, []*github.CheckRun{run("completed", "failure")})
+	assert.False(t, ok)
+
+	pr.MergeableState = github.String("blocked")
+	ok, reason = mergeable(pr, success, nil)
+	assert.False(t, ok)
+	assert.Equal(t, "blocked by branch protection", reason)
+
+	pr.Mergeable = nil
+	ok, _ = mergeable(pr, success, nil)
+	assert.False(t, ok)
+}
+
+// mergeFixture - a GitHub API serving one labelled pull request, whose check
+// runs are listed over two pages
+type mergeFixture struct {
+	pr      string
+	runs    [2]string
+	merged  bool
+	graphQL map[string]interface{}
+}
+
+func (f *mergeFixture) client(t *testing.T) (*github.Client, func()) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	mux.HandleFunc("/repos/foo/bar/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, f.pr)
+	})
+	mux.HandleFunc("/repos/foo/bar/commits/abc/status", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"state": "success", "total_count": 1}`)
+	})
+	mux.HandleFunc("/repos/foo/bar/commits/abc/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, f.runs[1])
+			return
+		}
+		w.Header().Set("Link", `<`+srv.URL+`/repos/foo/bar/commits/abc/check-runs?page=2>; rel="next"`)
+		fmt.Fprint(w, f.runs[0])
+	})
+	mux.HandleFunc("/repos/foo/bar/pulls/1/merge", func(w http.ResponseWriter, r *http.Request) {
+		f.merged = true
+		fmt.Fprint(w, `{"merged": true, "sha": "def"}`)
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&f.graphQL))
+		fmt.Fprint(w, `{"data": {}}`)
+	})
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client, srv.Close
+}
+
+func TestTryMerge(t *testing.T) {
+	pr := func(mergeable, state string) string {
+		return `{"number": 1, "node_id": "PR_1", "state": "open", "mergeable": ` + mergeable +
+			`, "mergeable_state": "` + state + `", "head": {"sha": "abc"}, "labels": [{"name": "automerge"}]}`
+	}
+	success := `{"total_count": 1, "check_runs": [{"name": "a", "status": "completed", "conclusion": "success"}]}`
+	failure := `{"total_count": 1, "check_runs": [{"name": "b", "status": "completed", "conclusion": "failure"}]}`
+	ctx := context.Background()
+
+	f := &mergeFixture{pr: pr("true", "clean"), runs: [2]string{success, success}}
+	client, done := f.client(t)
+	defer done()
+	a := &automerge{cfg: AutoMergeConfig{Label: "automerge", Method: "squash"}, enabled: map[string]bool{}}
+	pending, err := a.tryMerge(ctx, client, "foo", "bar", 1)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.True(t, f.merged)
+
+	// a failed run on the second page blocks the merge
+	f.merged = false
+	f.runs[1] = failure
+	_, err = a.tryMerge(ctx, client, "foo", "bar", 1)
+	assert.NoError(t, err)
+	assert.False(t, f.merged)
+
+	// mergeability's still being computed
+	f.pr = pr("null", "unknown")
+	pending, err = a.tryMerge(ctx, client, "foo", "bar", 1)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+	assert.False(t, f.merged)
+
+	// branch protection's requirements aren't met, so auto-merge is enabled,
+	// once for the head commit
+	f.pr = pr("true", "blocked")
+	pending, err = a.tryMerge(ctx, client, "foo", "bar", 1)
+	assert.NoError(t, err)
+	assert.False(t, pending)
+	assert.Nil(t, f.graphQL)
+
+	a.cfg.EnableAutoMerge = true
+	_, err = a.tryMerge(ctx, client, "foo", "bar", 1)
+	assert.NoError(t, err)
+	assert.False(t, f.merged)
+	assert.Equal(t, map[string]interface{}{"id": "PR_1", "method": "SQUASH"}, f.graphQL["variables"])
+	f.graphQL = nil
+	_, err = a.tryMerge(ctx, client, "foo", "bar", 1)
+	assert.NoError(t, err)
+	assert.Nil(t, f.graphQL)
+}
+
+func TestGraphQLPath(t *testing.T) {
+	client := github.NewClient(nil)
+	assert.Equal(t, "https://api.github.com/graphql", client.BaseURL.ResolveReference(&url.URL{Path: graphQLPath(client)}).String())
+	client, err := github.NewEnterpriseClient("https://ghe.example.com/api/v3/", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ghe.example.com/api/graphql", client.BaseURL.ResolveReference(&url.URL{Path: graphQLPath(client)}).String())
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// BackportConfig - configures the Backport handler
+type BackportConfig struct {
+	// LabelPrefix - labels named with this prefix followed by a branch name
+	// request a backport to that branch. Defaults to "backport/".
+	LabelPrefix string
+	// Command - comments on merged pull requests starting with this command
+	// followed by a branch name request a backport to that branch. Defaults
+	// to "/backport".
+	Command string
+	// Policy - authorizes commenters requesting backports with Command.
+	// Defaults to MinPermission(PermissionWrite). Labels aren't checked, as
+	// only collaborators can apply them.
+	Policy Policy
+}
+
+type backport struct {
+	cfg BackportConfig
+}
+
+type backportRequest struct {
+	owner    string
+	repo     string
+	number   int
+	branches []string
+	// commenter - who requested the backport with a comment, if anyone
+	commenter string
+}
+
+// errConflict is returned when a commit can't be cleanly cherry-picked
+var errConflict = errors.New("merge conflict")
+
+// Backport - returns a handler that cherry-picks the commits of merged pull
+// requests onto other branches, and opens pull requests for them. Backports
+// are requested with labels or comment commands (see BackportConfig).
+// Conflicts are reported back as a comment on the original pull request.
+func Backport(cfg BackportConfig) responder.HookHandler {
+	if cfg.LabelPrefix == "" {
+		cfg.LabelPrefix = "backport/"
+	}
+	if cfg.Command == "" {
+		cfg.Command = "/backport"
+	}
+	if cfg.Policy == nil {
+		cfg.Policy = MinPermission(PermissionWrite)
+	}
+	b := &backport{cfg}
+	return b.handle
+}
+
+func (b *backport) handle(ctx context.Context, eventType, deliveryID string, payload []byte) {
+	log := log.Ctx(ctx)
+	req, err := b.parseRequest(eventType, payload)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse event")
+		return
+	}
+	if len(req.branches) == 0 {
+		return
+	}
+
+	client, err := client(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		return
+	}
+
+	err = b.authorize(ctx, client, req)
+	if d, denied := err.(*Denial); denied {
+		log.Warn().Str("user", req.commenter).Str("reason", d.Reason).Msg("unauthorized backport request")
+		reply(ctx, client, req.owner, req.repo, req.number,
+			fmt.Sprintf("@%s %s to request backports.", req.commenter, d.Reason))
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check authorization")
+		return
+	}
+
+	pr, _, err := client.PullRequests.Get(ctx, req.owner, req.repo, req.number)
+	if err != nil {
+		log.Error().Err(err).Int("number", req.number).Msg("failed to get pull request")
+		return
+	}
+	if !pr.GetMerged() {
+		log.Debug().Int("number", req.number).Msg("not backporting unmerged pull request")
+		return
+	}
+
+	for _, branch := range req.branches {
+		l := log.With().Int("number", req.number).Str("branch", branch).Logger()
+		created, err := b.backport(ctx, client, req, pr, branch)
+		if err != nil {
+			l.Error().Err(err).Msg("backport failed")
+			msg := fmt.Sprintf("Backport to `%s` failed: %s", branch, err)
+			if errors.Cause(err) == errConflict {
+				msg = fmt.Sprintf("Backport to `%s` failed due to conflicts - this will need to be backported manually.", branch)
+			}
+			_, _, cerr := client.Issues.CreateComment(ctx, req.owner, req.repo, req.number, &github.IssueComment{Body: &msg})
+			if cerr != nil {
+				l.Error().Err(cerr).Msg("failed to report backport failure")
+			}
+			continue
+		}
+		l.Info().Int("backport", created.GetNumber()).Msg("Created backport")
+	}
+}
+
+func (b *backport) parseRequest(eventType string, payload []byte) (backportRequest, error) {
+	req := backportRequest{}
+	var repo *github.Repository
+	switch eventType {
+	case "pull_request":
+		event := &github.PullRequestEvent{}
+		err := json.Unmarshal(payload, event)
+		if err != nil {
+			return req, err
+		}
+		repo = event.GetRepo()
+		req.number = event.GetPullRequest().GetNumber()
+		switch event.GetAction() {
+		case "closed":
+			if !event.GetPullRequest().GetMerged() {
+				return req, nil
+			}
+			for _, l := range event.GetPullRequest().Labels {
+				if branch := strings.TrimPrefix(l.GetName(), b.cfg.LabelPrefix); branch != l.GetName() {
+					req.branches = append(req.branches, branch)
+				}
+			}
+		case "labeled":
+			// labelling an open PR is handled when it's closed
+			if !event.GetPullRequest().GetMerged() {
+				return req, nil
+			}
+			name := event.GetLabel().GetName()
+			if branch := strings.TrimPrefix(name, b.cfg.LabelPrefix); branch != name {
+				req.branches = append(req.branches, branch)
+			}
+		}
+	case "issue_comment":
+		event := &github.IssueCommentEvent{}
+		err := json.Unmarshal(payload, event)
+		if err != nil {
+			return req, err
+		}
+		if event.GetAction() != "created" || !event.GetIssue().IsPullRequest() {
+			return req, nil
+		}
+		repo = event.GetRepo()
+		req.number = event.GetIssue().GetNumber()
+		req.branches = parseBackportCommand(b.cfg.Command, event.GetComment().GetBody())
+		req.commenter = event.GetComment().GetUser().GetLogin()
+	}
+	req.owner = repo.GetOwner().GetLogin()
+	req.repo = repo.GetName()
+	return req, nil
+}
+
+// authorize checks the commenter who requested the backport, if it was
+// requested with a comment, against the policy
+func (b *backport) authorize(ctx context.Context, client *github.Client, req backportRequest) error {
+	if req.commenter == "" {
+		return nil
+	}
+	return b.cfg.Policy.Authorize(ctx, client, Actor{
+		Login:       req.commenter,
+		Owner:       req.owner,
+		Repo:        req.repo,
+		Number:      req.number,
+		PullRequest: true,
+	})
+}
+
+// parseBackportCommand finds lines like '/backport release-1.0 release-1.1'
+func parseBackportCommand(command, body string) []string {
+	branches := []string{}
+	s := bufio.NewScanner(strings.NewReader(body))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) > 1 && fields[0] == command {
+			branches = append(branches, fields[1:]...)
+		}
+	}
+	return branches
+}
+
+// backport cherry-picks each of the pull request's commits onto a new branch
+// created from the target branch, then opens a pull request. The git data API
+// has no cherry-pick operation, so each pick is done by merging the commit
+// into a temporary commit that has the target's tree and the picked commit's
+// parent, and keeping only the resulting tree.
+func (b *backport) backport(ctx context.Context, client *github.Client, req backportRequest, pr *github.PullRequest, target string) (*github.PullRequest, error) {
+	owner, repo := req.owner, req.repo
+	commits, err := listPRCommits(ctx, client, owner, repo, req.number)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list commits")
+	}
+
+	base, _, err := client.Git.GetRef(ctx, owner, repo, "heads/"+target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get branch %s", target)
+	}
+	head := base.GetObject().GetSHA()
+	tip, _, err := client.Git.GetCommit(ctx, owner, repo, head)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get commit %s", head)
+	}
+
+	branch := fmt.Sprintf("backport-%d-to-%s", req.number, target)
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(head)},
+	}
+	_, _, err = client.Git.CreateRef(ctx, owner, repo, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create branch %s", branch)
+	}
+
+	for _, c := range commits {
+		tip, err = cherryPick(ctx, client, owner, repo, ref, tip, c)
+		if err != nil {
+			_, derr := client.Git.DeleteRef(ctx, owner, repo, "heads/"+branch)
+			if derr != nil {
+				log.Ctx(ctx).Error().Err(derr).Str("branch", branch).Msg("failed to clean up branch")
+			}
+			return nil, err
+		}
+	}
+
+	body := fmt.Sprintf("Backport of #%d to `%s`.\n\n%s", req.number, target, pr.GetBody())
+	created, _, err := client.PullRequests.Create(ctx, owner, repo, &github.NewPullRequest{
+		Title: github.String(fmt.Sprintf("[%s] %s", target, pr.GetTitle())),
+		Head:  github.String(branch),
+		Base:  github.String(target),
+		Body:  &body,
+	})
+	return created, errors.Wrap(err, "failed to create pull request")
+}
+
+func cherryPick(ctx context.Context, client *github.Client, owner, repo string, ref *github.Reference, tip *github.Commit, pick *github.RepositoryCommit) (*github.Commit, error) {
+	if len(pick.Parents) != 1 {
+		return nil, errors.Errorf("can't cherry-pick merge commit %s", pick.GetSHA())
+	}
+
+	sibling, _, err := client.Git.CreateCommit(ctx, owner, repo, &github.Commit{
+		Message: github.String("temporary cherry-pick commit"),
+		Tree:    tip.Tree,
+		Parents: []github.Commit{{SHA: pick.Parents[0].SHA}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary commit")
+	}
+	err = setRef(ctx, client, owner, repo, ref, sibling.GetSHA())
+	if err != nil {
+		return nil, err
+	}
+
+	merge, resp, err := client.Repositories.Merge(ctx, owner, repo, &github.RepositoryMergeRequest{
+		Base: github.String(strings.TrimPrefix(ref.GetRef(), "refs/heads/")),
+		Head: pick.SHA,
+	})
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		return nil, errors.Wrapf(errConflict, "cherry-picking %s", pick.GetSHA())
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to merge %s", pick.GetSHA())
+	}
+
+	picked, _, err := client.Git.CreateCommit(ctx, owner, repo, &github.Commit{
+		Message: github.String(pick.GetCommit().GetMessage() + "\n\n(cherry picked from commit " + pick.GetSHA() + ")"),
+		Author:  pick.GetCommit().Author,
+		Tree:    merge.GetCommit().Tree,
+		Parents: []github.Commit{{SHA: tip.SHA}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cherry-picked commit")
+	}
+	return picked, setRef(ctx, client, owner, repo, ref, picked.GetSHA())
+}
+
+func setRef(ctx context.Context, client *github.Client, owner, repo string, ref *github.Reference, sha string) error {
+	ref.Object.SHA = github.String(sha)
+	_, _, err := client.Git.UpdateRef(ctx, owner, repo, ref, true)
+	return errors.Wrapf(err, "failed to update %s", ref.GetRef())
+}
+
+func listPRCommits(ctx context.Context, client *github.Client, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+	opt := &github.ListOptions{PerPage: 100}
+	commits := []*github.RepositoryCommit{}
+	for {
+		page, resp, err := client.PullRequests.ListCommits(ctx, owner, repo, number, opt)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, page...)
+		if resp.NextPage == 0 {
+			return commits, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBackportCommand(t *testing.T) {
+	assert.Equal(t, []string{"release-1.0", "release-1.1"},
+		parseBackportCommand("/backport", "thanks!\n/backport release-1.0 release-1.1\n"))
+	assert.Equal(t, []string{}, parseBackportCommand("/backport", "/backport"))
+	assert.Equal(t, []string{}, parseBackportCommand("/backport", "please /backport foo"))
+}
+
+func TestBackportParseRequest(t *testing.T) {
+	b := &backport{BackportConfig{LabelPrefix: "backport/", Command: "/backport"}}
+
+	req, err := b.parseRequest("pull_request", []byte(`{
+		"action": "closed",
+		"pull_request": {"number": 42, "merged": true, "labels": [{"name": "backport/v1"}, {"name": "bug"}]},
+		"repository": {"name": "bar", "owner": {"login": "foo"}}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, backportRequest{"foo", "bar", 42, []string{"v1"}, ""}, req)
+
+	req, err = b.parseRequest("pull_request", []byte(`{
+		"action": "labeled",
+		"label": {"name": "backport/v2"},
+		"pull_request": {"number": 42, "merged": false}
+	}`))
+	assert.NoError(t, err)
+	assert.Empty(t, req.branches)
+
+	req, err = b.parseRequest("issue_comment", []byte(`{
+		"action": "created",
+		"issue": {"number": 7, "pull_request": {}},
+		"comment": {"body": "/backport v3", "user": {"login": "hubot"}},
+		"repository": {"name": "bar", "owner": {"login": "foo"}}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, backportRequest{"foo", "bar", 7, []string{"v3"}, "hubot"}, req)
+
+	req, err = b.parseRequest("issue_comment", []byte(`{
+		"action": "created",
+		"issue": {"number": 7},
+		"comment": {"body": "/backport v3"}
+	}`))
+	assert.NoError(t, err)
+	assert.Empty(t, req.branches)
+}
+
+func TestBackportAuthorize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/repos/foo/bar/collaborators/hubot/permission", req.URL.Path)
+		fmt.Fprint(w, `{"permission":"read"}`)
+	}))
+	defer srv.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	ctx := context.Background()
+
+	bp := &backport{BackportConfig{Policy: MinPermission(PermissionWrite)}}
+	req := backportRequest{"foo", "bar", 7, []string{"v3"}, "hubot"}
+	assert.Equal(t, &Denial{"you need write permission"}, bp.authorize(ctx, client, req))
+
+	bp.cfg.Policy = MinPermission(PermissionRead)
+	assert.NoError(t, bp.authorize(ctx, client, req))
+
+	// label requests aren't checked
+	bp.cfg.Policy = MinPermission(PermissionAdmin)
+	req.commenter = ""
+	assert.NoError(t, bp.authorize(ctx, client, req))
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ChangelogSection - a heading in the release notes, listing pull requests
+// with any of the given labels
+type ChangelogSection struct {
+	Title  string
+	Labels []string
+}
+
+// ChangelogConfig - configures the Changelog handler
+type ChangelogConfig struct {
+	// Sections are filled in order - a pull request is listed in the first
+	// section with a matching label. Pull requests matching no section are
+	// listed under "Other Changes". Defaults to features and bug fixes
+	// sections.
+	Sections []ChangelogSection
+	// ExcludeLabels - pull requests with any of these labels are left out
+	ExcludeLabels []string
+}
+
+var defaultChangelogSections = []ChangelogSection{
+	{Title: "Features", Labels: []string{"enhancement", "feature"}},
+	{Title: "Bug Fixes", Labels: []string{"bug"}},
+}
+
+type changelog struct {
+	cfg ChangelogConfig
+
+	mu sync.Mutex
+	// created - the releases this handler created (as owner/repo@tag), whose
+	// release events it ignores
+	created map[string]bool
+}
+
+// Changelog - returns a handler that generates release notes from the pull
+// requests merged since the previous tag, when a tag is pushed or a release
+// is published. The release for the tag is created if necessary, and its
+// body is replaced with the generated notes.
+func Changelog(cfg ChangelogConfig) responder.HookHandler {
+	if len(cfg.Sections) == 0 {
+		cfg.Sections = defaultChangelogSections
+	}
+	c := &changelog{cfg: cfg, created: map[string]bool{}}
+	return c.handle
+}
+
+func (c *changelog) handle(ctx context.Context, eventType, deliveryID string, payload []byte) {
+	log := log.Ctx(ctx)
+	owner, repo, tag, err := releaseTag(eventType, payload)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse event")
+		return
+	}
+	if tag == "" {
+		return
+	}
+	if eventType == "release" && c.ownRelease(owner, repo, tag) {
+		log.Debug().Str("tag", tag).Msg("Ignoring release this handler created")
+		return
+	}
+
+	client, err := client(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		return
+	}
+
+	l := log.With().Str("tag", tag).Logger()
+	err = c.updateRelease(ctx, client, owner, repo, tag)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to generate release notes")
+		return
+	}
+	l.Info().Msg("Updated release notes")
+}
+
+// releaseTag returns the tag a release should be generated for, if any
+func releaseTag(eventType string, payload []byte) (owner, repo, tag string, err error) {
+	switch eventType {
+	case "push":
+		event := &github.PushEvent{}
+		err = json.Unmarshal(payload, event)
+		ref := event.GetRef()
+		if event.GetCreated() && strings.HasPrefix(ref, "refs/tags/") {
+			tag = strings.TrimPrefix(ref, "refs/tags/")
+		}
+		return event.GetRepo().GetOwner().GetLogin(), event.GetRepo().GetName(), tag, err
+	case "release":
+		event := &github.ReleaseEvent{}
+		err = json.Unmarshal(payload, event)
+		// editing the release ourselves triggers more events, so only the
+		// initial publication is handled
+		if event.GetAction() == "published" {
+			tag = event.GetRelease().GetTagName()
+		}
+		return event.GetRepo().GetOwner().GetLogin(), event.GetRepo().GetName(), tag, err
+	}
+	return "", "", "", nil
+}
+
+// ownRelease - whether the release for the tag was created by this handler,
+// so its publication shouldn't be handled again
+func (c *changelog) ownRelease(owner, repo, tag string) bool {
+	key := owner + "/" + repo + "@" + tag
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	own := c.created[key]
+	delete(c.created, key)
+	return own
+}
+
+// setCreated marks (or unmarks) the tag's release as created by this handler
+func (c *changelog) setCreated(owner, repo, tag string, created bool) {
+	key := owner + "/" + repo + "@" + tag
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if created {
+		c.created[key] = true
+	} else {
+		delete(c.created, key)
+	}
+}
+
+func (c *changelog) updateRelease(ctx context.Context, client *github.Client, owner, repo, tag string) error {
+	prev, since, until, err := previousTag(ctx, client, owner, repo, tag)
+	if err != nil {
+		return err
+	}
+
+	pulls, err := mergedPulls(ctx, client, owner, repo, since, until)
+	if err != nil {
+		return err
+	}
+	body := c.render(pulls, prev, tag)
+
+	release, resp, err := client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		// marked first, as the release event may arrive before the response
+		c.setCreated(owner, repo, tag, true)
+		_, _, err = client.Repositories.CreateRelease(ctx, owner, repo, &github.RepositoryRelease{
+			TagName: github.String(tag),
+			Name:    github.String(tag),
+			Body:    &body,
+		})
+		if err != nil {
+			c.setCreated(owner, repo, tag, false)
+		}
+		return errors.Wrap(err, "failed to create release")
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get release")
+	}
+	_, _, err = client.Repositories.EditRelease(ctx, owner, repo, release.GetID(), &github.RepositoryRelease{Body: &body})
+	return errors.Wrap(err, "failed to edit release")
+}
+
+// previousTag finds the nearest tag in the given tag's history (other than
+// tags on the same commit), returning it ("" if there is none) with its
+// commit's date, and the date of the given tag's commit
+func previousTag(ctx context.Context, client *github.Client, owner, repo, tag string) (prev string, since, until time.Time, err error) {
+	tags, err := listTags(ctx, client, owner, repo)
+	if err != nil {
+		return "", since, until, err
+	}
+
+	opt := &github.CommitsListOptions{SHA: tag, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		commits, resp, err := client.Repositories.ListCommits(ctx, owner, repo, opt)
+		if err != nil {
+			return "", since, until, errors.Wrapf(err, "failed to list commits for %s", tag)
+		}
+		for i, commit := range commits {
+			date := commit.GetCommit().GetCommitter().GetDate()
+			if opt.Page == 0 && i == 0 {
+				until = date
+				continue
+			}
+			if name, ok := tags[commit.GetSHA()]; ok {
+				return name, date, until, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return "", since, until, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// listTags maps the repo's tagged commits' SHAs to their tags
+func listTags(ctx context.Context, client *github.Client, owner, repo string) (map[string]string, error) {
+	opt := &github.ListOptions{PerPage: 100}
+	tags := map[string]string{}
+	for {
+		page, resp, err := client.Repositories.ListTags(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tags")
+		}
+		for _, t := range page {
+			if _, ok := tags[t.GetCommit().GetSHA()]; !ok {
+				tags[t.GetCommit().GetSHA()] = t.GetName()
+			}
+		}
+		if resp.NextPage == 0 {
+			return tags, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// mergedPulls lists pull requests merged in the (since, until] window
+func mergedPulls(ctx context.Context, client *github.Client, owner, repo string, since, until time.Time) ([]*github.PullRequest, error) {
+	opt := &github.PullRequestListOptions{
+		State:       "closed",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	pulls := []*github.PullRequest{}
+	for {
+		page, resp, err := client.PullRequests.List(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list pull requests")
+		}
+		for _, pr := range page {
+			// merging updates a PR, so older ones can't have merged in the window
+			if pr.GetUpdatedAt().Before(since) {
+				return pulls, nil
+			}
+			if mergedBetween(pr, since, until) {
+				pulls = append(pulls, pr)
+			}
+		}
+		if resp.NextPage == 0 {
+			return pulls, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func mergedBetween(pr *github.PullRequest, since, until time.Time) bool {
+	if pr.MergedAt == nil {
+		return false
+	}
+	merged := pr.GetMergedAt()
+	return merged.After(since) && !merged.After(until)
+}
+
+func (c *changelog) render(pulls []*github.PullRequest, prev, tag string) string {
+	sections := make([][]string, len(c.cfg.Sections)+1)
+PULLS:
+	for _, pr := range pulls {
+		for _, l := range c.cfg.ExcludeLabels {
+			if hasLabel(pr.Labels, l) {
+				continue PULLS
+			}
+		}
+		line := fmt.Sprintf("- %s (#%d) @%s", pr.GetTitle(), pr.GetNumber(), pr.GetUser().GetLogin())
+		i := len(c.cfg.Sections)
+	SECTIONS:
+		for j, s := range c.cfg.Sections {
+			for _, l := range s.Labels {
+				if hasLabel(pr.Labels, l) {
+					i = j
+					break SECTIONS
+				}
+			}
+		}
+		sections[i] = append(sections[i], line)
+	}
+
+	out := &strings.Builder{}
+	if prev != "" {
+		fmt.Fprintf(out, "Changes since %s:\n", prev)
+	}
+	listed := 0
+	for i, lines := range sections {
+		if len(lines) == 0 {
+			continue
+		}
+		title := "Other Changes"
+		if i < len(c.cfg.Sections) {
+			title = c.cfg.Sections[i].Title
+		}
+		fmt.Fprintf(out, "\n## %s\n\n%s\n", title, strings.Join(lines, "\n"))
+		listed += len(lines)
+	}
+	if listed == 0 {
+		fmt.Fprintf(out, "\nNo pull requests were merged for %s.\n", tag)
+	}
+	return strings.TrimSpace(out.String()) + "\n"
+}
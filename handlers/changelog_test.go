@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseTag(t *testing.T) {
+	owner, repo, tag, err := releaseTag("push", []byte(`{
+		"ref": "refs/tags/v1.0.0",
+		"created": true,
+		"repository": {"name": "bar", "owner": {"login": "foo"}}
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", owner)
+	assert.Equal(t, "bar", repo)
+	assert.Equal(t, "v1.0.0", tag)
+
+	_, _, tag, _ = releaseTag("push", []byte(`{"ref": "refs/heads/master", "created": true}`))
+	assert.Equal(t, "", tag)
+
+	_, _, tag, _ = releaseTag("push", []byte(`{"ref": "refs/tags/v1.0.0", "deleted": true}`))
+	assert.Equal(t, "", tag)
+
+	_, _, tag, _ = releaseTag("release", []byte(`{"action": "published", "release": {"tag_name": "v2"}}`))
+	assert.Equal(t, "v2", tag)
+
+	_, _, tag, _ = releaseTag("release", []byte(`{"action": "edited", "release": {"tag_name": "v2"}}`))
+	assert.Equal(t, "", tag)
+}
+
+func TestMergedBetween(t *testing.T) {
+	since := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(24 * time.Hour)
+	pr := func(merged time.Time) *github.PullRequest {
+		return &github.PullRequest{MergedAt: &merged}
+	}
+	assert.True(t, mergedBetween(pr(since.Add(time.Hour)), since, until))
+	assert.True(t, mergedBetween(pr(until), since, until))
+	assert.False(t, mergedBetween(pr(since), since, until))
+	assert.False(t, mergedBetween(pr(until.Add(time.Second)), since, until))
+	assert.False(t, mergedBetween(&github.PullRequest{}, since, until))
+}
+
+func TestChangelogRender(t *testing.T) {
+	c := &changelog{cfg: ChangelogConfig{
+		Sections:      defaultChangelogSections,
+		ExcludeLabels: []string{"skip-changelog"},
+	}}
+	pr := func(n int, title string, labels ...string) *github.PullRequest {
+		p := &github.PullRequest{
+			Number: github.Int(n),
+			Title:  github.String(title),
+			User:   &github.User{Login: github.String("someone")},
+		}
+		for _, l := range labels {
+			p.Labels = append(p.Labels, &github.Label{Name: github.String(l)})
+		}
+		return p
+	}
+
+	out := c.render([]*github.PullRequest{
+		pr(1, "Add a thing", "enhancement"),
+		pr(2, "Fix a thing", "bug"),
+		pr(3, "Update deps"),
+		pr(4, "Hidden", "bug", "skip-changelog"),
+	}, "v0.1.0", "v0.2.0")
+	assert.Equal(t, `Changes since v0.1.0:
+
+## Features
+
+- Add a thing (#1) @someone
+
+## Bug Fixes
+
+- Fix a thing (#2) @someone
+
+## Other Changes
+
+- Update deps (#3) @someone
+`, out)
+
+	assert.Equal(t, "No pull requests were merged for v0.1.0.\n", c.render(nil, "", "v0.1.0"))
+}
+
+func TestPreviousTag(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/repos/foo/bar/tags", func(w http.ResponseWriter, r *http.Request) {
+		// v1.1.0 is on another branch, and latest is on the same commit as v2.0.0
+		fmt.Fprint(w, `[
+			{"name": "v2.0.0", "commit": {"sha": "c3"}},
+			{"name": "latest", "commit": {"sha": "c3"}},
+			{"name": "v1.1.0", "commit": {"sha": "x1"}},
+			{"name": "v1.0.0", "commit": {"sha": "c1"}}
+		]`)
+	})
+	mux.HandleFunc("/repos/foo/bar/commits", func(w http.ResponseWriter, r *http.Request) {
+		commit := func(sha, date string) string {
+			return `{"sha": "` + sha + `", "commit": {"committer": {"date": "` + date + `"}}}`
+		}
+		switch r.URL.Query().Get("sha") {
+		case "v2.0.0":
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `[`+commit("c1", "2019-01-01T00:00:00Z")+`]`)
+				return
+			}
+			w.Header().Set("Link", `<`+srv.URL+`/repos/foo/bar/commits?sha=v2.0.0&page=2>; rel="next"`)
+			fmt.Fprint(w, `[`+commit("c3", "2019-03-01T00:00:00Z")+`,`+commit("c2", "2019-02-01T00:00:00Z")+`]`)
+		case "v1.0.0":
+			fmt.Fprint(w, `[`+commit("c1", "2019-01-01T00:00:00Z")+`,`+commit("c0", "2018-12-01T00:00:00Z")+`]`)
+		}
+	})
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	prev, since, until, err := previousTag(context.Background(), client, "foo", "bar", "v2.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.0.0", prev)
+	assert.Equal(t, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), since)
+	assert.Equal(t, time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), until)
+
+	prev, since, until, err = previousTag(context.Background(), client, "foo", "bar", "v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "", prev)
+	assert.True(t, since.IsZero())
+	assert.Equal(t, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), until)
+}
+
+func TestChangelogOwnRelease(t *testing.T) {
+	c := &changelog{created: map[string]bool{}}
+	assert.False(t, c.ownRelease("foo", "bar", "v1"))
+	c.setCreated("foo", "bar", "v1", true)
+	assert.False(t, c.ownRelease("foo", "baz", "v1"))
+	assert.True(t, c.ownRelease("foo", "bar", "v1"))
+	// only its first release event is ignored
+	assert.False(t, c.ownRelease("foo", "bar", "v1"))
+
+	c.setCreated("foo", "bar", "v2", true)
+	c.setCreated("foo", "bar", "v2", false)
+	assert.False(t, c.ownRelease("foo", "bar", "v2"))
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// the locations GitHub looks for CODEOWNERS, in order of precedence
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type ownersRule struct {
+	re     *regexp.Regexp
+	owners []string
+}
+
+type ownersEntry struct {
+	rules   []ownersRule
+	fetched time.Time
+}
+
+type codeowners struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[string]ownersEntry
+}
+
+// CodeOwners - returns a handler that requests reviews from the owners of the
+// changed files (as listed in the CODEOWNERS file of the base branch) when
+// pull requests are opened or updated. CODEOWNERS files are cached for ttl.
+func CodeOwners(ttl time.Duration) responder.HookHandler {
+	c := &codeowners{
+		ttl:   ttl,
+		cache: map[string]ownersEntry{},
+	}
+	return c.handle
+}
+
+func (c *codeowners) handle(ctx context.Context, eventType, deliveryID string, payload []byte) {
+	if eventType != "pull_request" {
+		return
+	}
+	log := log.Ctx(ctx)
+	event := &github.PullRequestEvent{}
+	err := json.Unmarshal(payload, event)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse pull_request event")
+		return
+	}
+	switch event.GetAction() {
+	case "opened", "reopened", "synchronize", "ready_for_review":
+	default:
+		return
+	}
+
+	client, err := client(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		return
+	}
+
+	pr := event.GetPullRequest()
+	owner := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+	rules, err := c.rules(ctx, client, owner, repo, pr.GetBase().GetRef())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get CODEOWNERS")
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	files, err := listPRFiles(ctx, client, owner, repo, pr.GetNumber())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list changed files")
+		return
+	}
+
+	req := reviewersFor(rules, files, pr.GetUser().GetLogin())
+	if len(req.Reviewers) == 0 && len(req.TeamReviewers) == 0 {
+		return
+	}
+	_, _, err = client.PullRequests.RequestReviewers(ctx, owner, repo, pr.GetNumber(), req)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to request reviews")
+		return
+	}
+	log.Info().
+		Int("number", pr.GetNumber()).
+		Strs("reviewers", req.Reviewers).
+		Strs("team_reviewers", req.TeamReviewers).
+		Msg("Requested reviews from code owners")
+}
+
+func (c *codeowners) rules(ctx context.Context, client *github.Client, owner, repo, ref string) ([]ownersRule, error) {
+	key := owner + "/" + repo + "@" + ref
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetched) < c.ttl {
+		return entry.rules, nil
+	}
+
+	rules, err := fetchCodeowners(ctx, client, owner, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cache[key] = ownersEntry{rules, time.Now()}
+	c.mu.Unlock()
+	return rules, nil
+}
+
+func fetchCodeowners(ctx context.Context, client *github.Client, owner, repo, ref string) ([]ownersRule, error) {
+	opt := &github.RepositoryContentGetOptions{Ref: ref}
+	for _, p := range codeownersPaths {
+		file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, p, opt)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %s", p)
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode %s", p)
+		}
+		return parseCodeowners(content)
+	}
+	return nil, nil
+}
+
+func listPRFiles(ctx context.Context, client *github.Client, owner, repo string, number int) ([]string, error) {
+	opt := &github.ListOptions{PerPage: 100}
+	files := []string{}
+	for {
+		page, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, number, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range page {
+			files = append(files, f.GetFilename())
+		}
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func parseCodeowners(content string) ([]ownersRule, error) {
+	rules := []ownersRule{}
+	s := bufio.NewScanner(strings.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		re, err := compileOwnersPattern(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, ownersRule{re, fields[1:]})
+	}
+	return rules, s.Err()
+}
+
+// compileOwnersPattern converts a gitignore-style CODEOWNERS pattern to a
+// regexp matching file paths
+func compileOwnersPattern(pattern string) (*regexp.Regexp, error) {
+	p := pattern
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	re := &strings.Builder{}
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case p[i] == '*':
+			re.WriteString("[^/]*")
+		case p[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	switch {
+	case dirOnly:
+		re.WriteString("/.*$")
+	case strings.HasSuffix(p, "/*"):
+		// unlike gitignore, 'dir/*' doesn't match files in subdirectories
+		re.WriteString("$")
+	default:
+		re.WriteString("(/.*)?$")
+	}
+
+	r, err := regexp.Compile(re.String())
+	return r, errors.Wrapf(err, "invalid CODEOWNERS pattern %s", pattern)
+}
+
+// ownersOf returns the owners of the given path - the last matching rule wins
+func ownersOf(rules []ownersRule, path string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].re.MatchString(path) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+func reviewersFor(rules []ownersRule, files []string, author string) github.ReviewersRequest {
+	req := github.ReviewersRequest{}
+	seen := map[string]bool{}
+	for _, f := range files {
+		for _, o := range ownersOf(rules, f) {
+			if seen[o] || !strings.HasPrefix(o, "@") {
+				// email addresses can't be requested as reviewers
+				continue
+			}
+			seen[o] = true
+			name := strings.TrimPrefix(o, "@")
+			if i := strings.Index(name, "/"); i >= 0 {
+				req.TeamReviewers = append(req.TeamReviewers, name[i+1:])
+			} else if !strings.EqualFold(name, author) {
+				req.Reviewers = append(req.Reviewers, name)
+			}
+		}
+	}
+	return req
+}
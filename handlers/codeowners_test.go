@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileOwnersPattern(t *testing.T) {
+	testdata := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*", "foo/bar.go", true},
+		{"*.go", "foo/bar.go", true},
+		{"*.go", "foo/bar.js", false},
+		{"/build/logs/", "build/logs/x.log", true},
+		{"/build/logs/", "foo/build/logs/x.log", false},
+		{"docs/*", "docs/getting-started.md", true},
+		{"docs/*", "docs/build-app/troubleshooting.md", false},
+		{"apps/", "foo/apps/x.go", true},
+		{"/docs/", "docs/a/b.md", true},
+		{"**/logs", "deeply/nested/logs/x.log", true},
+		{"**/logs", "logs/x.log", true},
+		{"/scripts/**", "scripts/a/b.sh", true},
+		{"README.md", "sub/README.md", true},
+		{"README.md", "sub/README.mdx", false},
+	}
+	for _, d := range testdata {
+		re, err := compileOwnersPattern(d.pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, d.match, re.MatchString(d.path), "%s vs %s", d.pattern, d.path)
+	}
+}
+
+func TestReviewersFor(t *testing.T) {
+	rules, err := parseCodeowners(`# comment
+*       @global-owner
+*.js    @js-owner dev@example.com
+
+/docs/  @org/docs-team @author
+`)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 3)
+
+	req := reviewersFor(rules, []string{"main.go", "app.js", "docs/x.md"}, "Author")
+	assert.Equal(t, github.ReviewersRequest{
+		Reviewers:     []string{"global-owner", "js-owner"},
+		TeamReviewers: []string{"docs-team"},
+	}, req)
+
+	assert.Equal(t, github.ReviewersRequest{}, reviewersFor(nil, []string{"main.go"}, ""))
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Command - a slash command found in an issue or pull request comment
+type Command struct {
+	// Name of the command, without the leading slash
+	Name string
+	Args []string
+	// Event is the comment event the command was found in
+	Event *github.IssueCommentEvent
+}
+
+// CommandHandler - handles a slash command. Returned errors are reported back
+// to the commenter.
+type CommandHandler func(ctx context.Context, cmd *Command) error
+
+// Permission levels a commenter may need to run a command, in increasing order
+const (
+	PermissionNone  = "none"
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+	PermissionAdmin = "admin"
+)
+
+var permissionRank = map[string]int{
+	PermissionNone:  0,
+	PermissionRead:  1,
+	PermissionWrite: 2,
+	PermissionAdmin: 3,
+}
+
+type command struct {
+	policy  Policy
+	handler CommandHandler
+}
+
+// Commands - dispatches `/command args` comments on issues and pull requests
+// to registered command handlers. Use Handle as the responder.HookHandler.
+type Commands struct {
+	mu       sync.RWMutex
+	commands map[string]command
+}
+
+// NewCommands -
+func NewCommands() *Commands {
+	return &Commands{commands: map[string]command{}}
+}
+
+// Register a handler for the named command (without the leading slash). Only
+// commenters with at least the given permission level on the repository may
+// run it.
+func (c *Commands) Register(name, permission string, handler CommandHandler) error {
+	if _, ok := permissionRank[permission]; !ok {
+		return errors.Errorf("invalid permission %s", permission)
+	}
+	return c.RegisterWithPolicy(name, MinPermission(permission), handler)
+}
+
+// RegisterWithPolicy - like Register, but only commenters authorized by the
+// policy may run the command (e.g. members of a team, or code owners)
+func (c *Commands) RegisterWithPolicy(name string, policy Policy, handler CommandHandler) error {
+	if policy == nil {
+		return errors.New("must provide a policy")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands[name] = command{policy, handler}
+	return nil
+}
+
+func (c *Commands) lookup(name string) (command, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cmd, ok := c.commands[name]
+	return cmd, ok
+}
+
+// Handle - a responder.HookHandler for issue_comment events
+func (c *Commands) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) {
+	if eventType != "issue_comment" {
+		return
+	}
+	log := log.Ctx(ctx)
+	event := &github.IssueCommentEvent{}
+	err := json.Unmarshal(payload, event)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse issue_comment event")
+		return
+	}
+	if event.GetAction() != "created" {
+		return
+	}
+
+	cmds := parseCommands(event.GetComment().GetBody())
+	if len(cmds) == 0 {
+		return
+	}
+
+	client, err := client(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		return
+	}
+
+	owner := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+	user := event.GetComment().GetUser().GetLogin()
+	number := event.GetIssue().GetNumber()
+	actor := Actor{
+		Login:       user,
+		Owner:       owner,
+		Repo:        repo,
+		Number:      number,
+		PullRequest: event.GetIssue() != nil && event.GetIssue().IsPullRequest(),
+	}
+	for _, cmd := range cmds {
+		registered, ok := c.lookup(cmd.Name)
+		if !ok {
+			continue
+		}
+		l := log.With().Str("command", cmd.Name).Str("user", user).Logger()
+
+		err = registered.policy.Authorize(ctx, client, actor)
+		if d, denied := err.(*Denial); denied {
+			l.Warn().Str("reason", d.Reason).Msg("unauthorized command")
+			reply(ctx, client, owner, repo, number,
+				fmt.Sprintf("@%s %s to run `/%s`.", user, d.Reason, cmd.Name))
+			continue
+		}
+		if err != nil {
+			l.Error().Err(err).Msg("failed to check authorization")
+			return
+		}
+
+		cmd.Event = event
+		l.Info().Strs("args", cmd.Args).Msg("Running command")
+		err = registered.handler(ctx, cmd)
+		if err != nil {
+			l.Error().Err(err).Msg("command failed")
+			reply(ctx, client, owner, repo, number,
+				fmt.Sprintf("@%s `/%s` failed: %s", user, cmd.Name, err))
+		}
+	}
+}
+
+func permissionLevel(ctx context.Context, client *github.Client, owner, repo, user string) (string, error) {
+	level, _, err := client.Repositories.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		return "", err
+	}
+	return level.GetPermission(), nil
+}
+
+func reply(ctx context.Context, client *github.Client, owner, repo string, number int, body string) {
+	_, _, err := client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: &body})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to reply")
+	}
+}
+
+// parseCommands finds lines starting with '/', ignoring quoted lines and
+// fenced code blocks
+func parseCommands(body string) []*Command {
+	cmds := []*Command{}
+	fenced := false
+	s := bufio.NewScanner(strings.NewReader(body))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced || !strings.HasPrefix(line, "/") {
+			continue
+		}
+		fields := strings.Fields(line)
+		name := strings.TrimPrefix(fields[0], "/")
+		if name == "" {
+			continue
+		}
+		cmds = append(cmds, &Command{Name: name, Args: fields[1:]})
+	}
+	return cmds
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommands(t *testing.T) {
+	cmds := parseCommands(`/retest
+some text
+  /label bug  needs-triage
+> /quoted
+` + "```" + `
+/fenced
+` + "```" + `
+/
+`)
+	assert.Len(t, cmds, 2)
+	assert.Equal(t, "retest", cmds[0].Name)
+	assert.Empty(t, cmds[0].Args)
+	assert.Equal(t, "label", cmds[1].Name)
+	assert.Equal(t, []string{"bug", "needs-triage"}, cmds[1].Args)
+
+	assert.Empty(t, parseCommands("no commands here"))
+}
+
+func TestCommandsRegister(t *testing.T) {
+	c := NewCommands()
+	h := func(ctx context.Context, cmd *Command) error { return nil }
+	assert.Error(t, c.Register("foo", "superuser", h))
+	assert.NoError(t, c.Register("foo", PermissionWrite, h))
+
+	cmd, ok := c.lookup("foo")
+	assert.True(t, ok)
+	assert.Equal(t, MinPermission(PermissionWrite), cmd.policy)
+
+	_, ok = c.lookup("bar")
+	assert.False(t, ok)
+}
@@ -0,0 +1,22 @@
+// Package handlers contains ready-made responder.HookHandlers for common
+// repository automation tasks.
+//
+// Handlers in this package make GitHub API calls with the client found in the
+// context (see responder.GitHubClient), so they must be run by a Responder.
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+)
+
+func client(ctx context.Context) (*github.Client, error) {
+	c := responder.GitHubClient(ctx)
+	if c == nil {
+		return nil, errors.New("no GitHub client in context - handler must be run by a Responder")
+	}
+	return c, nil
+}
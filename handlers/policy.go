@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+)
+
+// Actor - a user trying to trigger a privileged action on a repository, for
+// a Policy to authorize
+type Actor struct {
+	Login string
+	Owner string
+	Repo  string
+	// Number - the issue or pull request the action is on, if any
+	Number int
+	// PullRequest - whether Number is a pull request
+	PullRequest bool
+}
+
+// Policy - decides who may trigger privileged actions, like slash commands
+// (see Commands.RegisterWithPolicy), automatic merges (see
+// AutoMergeConfig.Policy) and backports (see BackportConfig.Policy).
+// Authorize returns a *Denial when the actor isn't authorized, and other
+// errors when that couldn't be determined.
+type Policy interface {
+	Authorize(ctx context.Context, client *github.Client, a Actor) error
+}
+
+// PolicyFunc - adapts a function to a Policy
+type PolicyFunc func(ctx context.Context, client *github.Client, a Actor) error
+
+// Authorize -
+func (f PolicyFunc) Authorize(ctx context.Context, client *github.Client, a Actor) error {
+	return f(ctx, client, a)
+}
+
+// Denial - returned by a Policy when the actor isn't authorized. The reason
+// is addressed to the actor (e.g. "you need write permission").
+type Denial struct {
+	Reason string
+}
+
+func (d *Denial) Error() string {
+	return "unauthorized - " + d.Reason
+}
+
+func deny(format string, args ...interface{}) error {
+	return &Denial{fmt.Sprintf(format, args...)}
+}
+
+// MinPermission - authorizes collaborators with at least the permission
+// level (e.g. PermissionWrite) on the repository
+func MinPermission(level string) Policy {
+	return permissionPolicy{level}
+}
+
+type permissionPolicy struct {
+	level string
+}
+
+func (p permissionPolicy) Authorize(ctx context.Context, client *github.Client, a Actor) error {
+	permission, err := permissionLevel(ctx, client, a.Owner, a.Repo, a.Login)
+	if err != nil {
+		return errors.Wrap(err, "failed to check permissions")
+	}
+	if permissionRank[permission] < permissionRank[p.level] {
+		return deny("you need %s permission", p.level)
+	}
+	return nil
+}
+
+// TeamMember - authorizes active members of any of the organization's teams,
+// given by slug (e.g. "release-managers")
+func TeamMember(org string, teams ...string) Policy {
+	return &teamPolicy{org: org, teams: teams}
+}
+
+type teamPolicy struct {
+	org   string
+	teams []string
+
+	mu  sync.Mutex
+	ids map[string]int64
+}
+
+func (p *teamPolicy) Authorize(ctx context.Context, client *github.Client, a Actor) error {
+	for _, slug := range p.teams {
+		ok, err := p.isMember(ctx, client, slug, a.Login)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	names := make([]string, len(p.teams))
+	for i, slug := range p.teams {
+		names[i] = "@" + p.org + "/" + slug
+	}
+	return deny("you must be a member of %s", strings.Join(names, " or "))
+}
+
+func (p *teamPolicy) isMember(ctx context.Context, client *github.Client, slug, user string) (bool, error) {
+	id, err := p.teamID(ctx, client, slug)
+	if err != nil {
+		return false, err
+	}
+	m, resp, err := client.Teams.GetTeamMembership(ctx, id, user)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check membership of %s/%s", p.org, slug)
+	}
+	return m.GetState() == "active", nil
+}
+
+// teamID looks up the team's ID by slug - team IDs don't change, so they're
+// cached
+func (p *teamPolicy) teamID(ctx context.Context, client *github.Client, slug string) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.ids[slug]; ok {
+		return id, nil
+	}
+	if p.ids == nil {
+		p.ids = map[string]int64{}
+	}
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		teams, resp, err := client.Teams.ListTeams(ctx, p.org, opt)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to list %s's teams", p.org)
+		}
+		for _, t := range teams {
+			p.ids[t.GetSlug()] = t.GetID()
+		}
+		if id, ok := p.ids[slug]; ok {
+			return id, nil
+		}
+		if resp.NextPage == 0 {
+			return 0, errors.Errorf("no team %s/%s", p.org, slug)
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// CodeOwner - authorizes owners (directly, or through a team) of every file a
+// pull request changes, according to the CODEOWNERS file of its base branch.
+// CODEOWNERS files are cached for ttl. Actions on issues are denied.
+func CodeOwner(ttl time.Duration) Policy {
+	return &codeownerPolicy{owners: &codeowners{ttl: ttl, cache: map[string]ownersEntry{}}}
+}
+
+type codeownerPolicy struct {
+	owners *codeowners
+
+	mu    sync.Mutex
+	teams map[string]*teamPolicy
+}
+
+func (p *codeownerPolicy) Authorize(ctx context.Context, client *github.Client, a Actor) error {
+	if !a.PullRequest {
+		return deny("only code owners may do this, on pull requests")
+	}
+	pr, _, err := client.PullRequests.Get(ctx, a.Owner, a.Repo, a.Number)
+	if err != nil {
+		return errors.Wrap(err, "failed to get pull request")
+	}
+	rules, err := p.owners.rules(ctx, client, a.Owner, a.Repo, pr.GetBase().GetRef())
+	if err != nil {
+		return errors.Wrap(err, "failed to get CODEOWNERS")
+	}
+	files, err := listPRFiles(ctx, client, a.Owner, a.Repo, a.Number)
+	if err != nil {
+		return errors.Wrap(err, "failed to list changed files")
+	}
+	for _, f := range files {
+		ok, err := p.owns(ctx, client, ownersOf(rules, f), a.Login)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return deny("you must be a code owner of %s", f)
+		}
+	}
+	return nil
+}
+
+// owns - whether the user is one of the owners, or in one of the owning teams
+func (p *codeownerPolicy) owns(ctx context.Context, client *github.Client, owners []string, user string) (bool, error) {
+	for _, o := range owners {
+		name := strings.TrimPrefix(o, "@")
+		i := strings.Index(name, "/")
+		if i < 0 {
+			if strings.EqualFold(name, user) {
+				return true, nil
+			}
+			continue
+		}
+		ok, err := p.team(name[:i]).isMember(ctx, client, name[i+1:], user)
+		if ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func (p *codeownerPolicy) team(org string) *teamPolicy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.teams == nil {
+		p.teams = map[string]*teamPolicy{}
+	}
+	t, ok := p.teams[org]
+	if !ok {
+		t = &teamPolicy{org: org}
+		p.teams[org] = t
+	}
+	return t
+}
+
+// AnyOf - authorizes actors authorized by any of the policies
+func AnyOf(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx context.Context, client *github.Client, a Actor) error {
+		reasons := []string{}
+		for _, p := range policies {
+			err := p.Authorize(ctx, client, a)
+			d, denied := err.(*Denial)
+			if !denied {
+				return err
+			}
+			reasons = append(reasons, d.Reason)
+		}
+		return deny("%s", strings.Join(reasons, ", or "))
+	})
+}
+
+// AllOf - authorizes actors authorized by all of the policies
+func AllOf(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx context.Context, client *github.Client, a Actor) error {
+		for _, p := range policies {
+			err := p.Authorize(ctx, client, a)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// maxCachedDecisions - how many decisions CachePolicy keeps before
+// forgetting expired ones
+const maxCachedDecisions = 1000
+
+// CachePolicy - remembers the policy's decisions for each actor for ttl, to
+// save API calls. Errors other than denials aren't cached.
+func CachePolicy(p Policy, ttl time.Duration) Policy {
+	return &cachedPolicy{p: p, ttl: ttl, decisions: map[Actor]decision{}}
+}
+
+type decision struct {
+	err     error
+	decided time.Time
+}
+
+type cachedPolicy struct {
+	p   Policy
+	ttl time.Duration
+
+	mu        sync.Mutex
+	decisions map[Actor]decision
+}
+
+func (c *cachedPolicy) Authorize(ctx context.Context, client *github.Client, a Actor) error {
+	c.mu.Lock()
+	d, ok := c.decisions[a]
+	c.mu.Unlock()
+	if ok && time.Since(d.decided) < c.ttl {
+		return d.err
+	}
+
+	err := c.p.Authorize(ctx, client, a)
+	if _, denied := err.(*Denial); err == nil || denied {
+		c.mu.Lock()
+		c.decisions[a] = decision{err, time.Now()}
+		c.expire()
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// expire forgets expired decisions, once there are enough to matter. Must be
+// called with the lock held.
+func (c *cachedPolicy) expire() {
+	if len(c.decisions) < maxCachedDecisions {
+		return
+	}
+	for a, d := range c.decisions {
+		if time.Since(d.decided) >= c.ttl {
+			delete(c.decisions, a)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinPermission(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"permission":"write"}`)
+	}))
+	defer srv.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	ctx := context.Background()
+	a := Actor{Login: "hubot", Owner: "foo", Repo: "bar"}
+	assert.NoError(t, MinPermission(PermissionWrite).Authorize(ctx, client, a))
+	err := MinPermission(PermissionAdmin).Authorize(ctx, client, a)
+	assert.Equal(t, &Denial{"you need admin permission"}, err)
+}
+
+func TestCombinedPolicies(t *testing.T) {
+	ctx := context.Background()
+	allow := PolicyFunc(func(context.Context, *github.Client, Actor) error { return nil })
+	denyAll := func(reason string) Policy {
+		return PolicyFunc(func(context.Context, *github.Client, Actor) error { return deny("%s", reason) })
+	}
+	broken := PolicyFunc(func(context.Context, *github.Client, Actor) error { return errors.New("boom") })
+
+	assert.NoError(t, AnyOf(denyAll("a"), allow).Authorize(ctx, nil, Actor{}))
+	assert.Equal(t, &Denial{"a, or b"}, AnyOf(denyAll("a"), denyAll("b")).Authorize(ctx, nil, Actor{}))
+	assert.EqualError(t, AnyOf(denyAll("a"), broken).Authorize(ctx, nil, Actor{}), "boom")
+
+	assert.NoError(t, AllOf(allow, allow).Authorize(ctx, nil, Actor{}))
+	assert.Equal(t, &Denial{"b"}, AllOf(allow, denyAll("b")).Authorize(ctx, nil, Actor{}))
+}
+
+func TestCachePolicy(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	var result error
+	p := CachePolicy(PolicyFunc(func(context.Context, *github.Client, Actor) error {
+		calls++
+		return result
+	}), time.Hour)
+
+	a := Actor{Login: "a"}
+	result = errors.New("boom")
+	assert.Error(t, p.Authorize(ctx, nil, a))
+	// errors aren't cached
+	result = deny("no")
+	assert.Error(t, p.Authorize(ctx, nil, a))
+	assert.Error(t, p.Authorize(ctx, nil, a))
+	assert.Equal(t, 2, calls)
+
+	result = nil
+	assert.NoError(t, p.Authorize(ctx, nil, Actor{Login: "b"}))
+	assert.Equal(t, 3, calls)
+}
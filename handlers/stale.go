@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// StaleConfig - configures a StaleSweeper
+type StaleConfig struct {
+	// Repo to sweep, in 'owner/repo' form
+	Repo string
+	// StaleAfter is how long an issue or PR must be inactive before it's
+	// marked stale
+	StaleAfter time.Duration
+	// CloseAfter is how long a stale issue or PR must remain inactive before
+	// it's closed. Zero means stale items are never closed.
+	CloseAfter time.Duration
+	// StaleLabel is applied to stale items - defaults to "stale"
+	StaleLabel string
+	// StaleComment is posted when an item is marked stale (optional)
+	StaleComment string
+	// CloseComment is posted when a stale item is closed (optional)
+	CloseComment string
+	// ExemptLabels - items with any of these labels are never swept
+	ExemptLabels []string
+	// SkipPullRequests - only sweep issues
+	SkipPullRequests bool
+	// DryRun - log what would be done, without changing anything
+	DryRun bool
+}
+
+type staleAction int
+
+const (
+	staleNone staleAction = iota
+	staleMark
+	staleClose
+)
+
+// StaleSweeper - labels, comments on, and closes inactive issues and pull
+// requests
+type StaleSweeper struct {
+	client *github.Client
+	cfg    StaleConfig
+	owner  string
+	repo   string
+	now    func() time.Time
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+// NewStaleSweeper -
+func NewStaleSweeper(client *github.Client, cfg StaleConfig) (*StaleSweeper, error) {
+	parts := strings.SplitN(cfg.Repo, "/", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid repo %s - need 'owner/repo' form", cfg.Repo)
+	}
+	if cfg.StaleAfter <= 0 {
+		return nil, errors.New("StaleAfter must be positive")
+	}
+	if cfg.StaleLabel == "" {
+		cfg.StaleLabel = "stale"
+	}
+	return &StaleSweeper{
+		client: client,
+		cfg:    cfg,
+		owner:  parts[0],
+		repo:   parts[1],
+		now:    time.Now,
+	}, nil
+}
+
+// Handler - a responder.HookHandler sweeping every interval, and removing
+// the stale label from items with new activity. The first delivery (like
+// the ping sent when the hook's registered) starts sweeping, and each sweep
+// schedules the next as a follow-up (see responder.ScheduleFollowUp), so
+// sweeps carry on across restarts with a persistent state store.
+func (s *StaleSweeper) Handler(interval time.Duration) responder.HookHandler {
+	return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		log := log.Ctx(ctx).With().Str("repo", s.cfg.Repo).Logger()
+		info := responder.GetDeliveryInfo(ctx)
+		followUp := info != nil && info.FollowUp
+		if !followUp {
+			err := s.unmarkActive(ctx, eventType, payload)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to remove stale label")
+			}
+		}
+		if !s.due(followUp, interval) {
+			return
+		}
+		err := s.Sweep(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("stale sweep failed")
+		}
+		err = responder.ScheduleFollowUp(ctx, interval)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to schedule next stale sweep")
+		}
+	}
+}
+
+// due - whether to sweep now: on the first delivery, and on follow-ups,
+// unless another sweep ran less than half an interval ago. Dropping those
+// leaves one chain of follow-ups, when a restart (with follow-ups kept in
+// the state store) starts another.
+func (s *StaleSweeper) due(followUp bool, interval time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if !s.swept.IsZero() && (!followUp || now.Sub(s.swept) < interval/2) {
+		return false
+	}
+	s.swept = now
+	return true
+}
+
+// Sweep - check all open issues and pull requests once. They're all listed
+// before any is changed, as changing them reorders the list.
+func (s *StaleSweeper) Sweep(ctx context.Context) error {
+	opt := &github.IssueListByRepoOptions{
+		State:       "open",
+		Sort:        "updated",
+		Direction:   "asc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	var all []*github.Issue
+	for {
+		issues, resp, err := s.client.Issues.ListByRepo(ctx, s.owner, s.repo, opt)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list issues for %s", s.cfg.Repo)
+		}
+		all = append(all, issues...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	for _, issue := range all {
+		err := s.apply(ctx, issue, s.classify(issue))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activityEvents - the events that are activity on an issue or pull request
+var activityEvents = map[string]bool{
+	"issues":                      true,
+	"issue_comment":               true,
+	"pull_request":                true,
+	"pull_request_review":         true,
+	"pull_request_review_comment": true,
+}
+
+// unmarkActive removes the stale label from the event's issue or pull
+// request, when the event is new activity on it. The sweeper's own changes
+// (labelling, commenting, closing) and bots' aren't activity.
+func (s *StaleSweeper) unmarkActive(ctx context.Context, eventType string, payload []byte) error {
+	if !activityEvents[eventType] {
+		return nil
+	}
+	event := struct {
+		Action      string               `json:"action"`
+		Sender      github.User          `json:"sender"`
+		Repo        github.Repository    `json:"repository"`
+		Issue       *github.Issue        `json:"issue"`
+		PullRequest *github.PullRequest  `json:"pull_request"`
+		Comment     *github.IssueComment `json:"comment"`
+	}{}
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %s event", eventType)
+	}
+	switch {
+	case !strings.EqualFold(event.Repo.GetFullName(), s.cfg.Repo),
+		event.Sender.GetType() == "Bot",
+		event.Action == "labeled", event.Action == "unlabeled", event.Action == "closed":
+		return nil
+	case event.Comment != nil && event.Comment.GetBody() != "" &&
+		(event.Comment.GetBody() == s.cfg.StaleComment || event.Comment.GetBody() == s.cfg.CloseComment):
+		return nil
+	}
+
+	var number int
+	var labels []string
+	switch {
+	case event.Issue != nil:
+		number = event.Issue.GetNumber()
+		for _, l := range event.Issue.Labels {
+			labels = append(labels, l.GetName())
+		}
+	case event.PullRequest != nil:
+		number = event.PullRequest.GetNumber()
+		for _, l := range event.PullRequest.Labels {
+			labels = append(labels, l.GetName())
+		}
+	}
+	stale := false
+	for _, l := range labels {
+		stale = stale || l == s.cfg.StaleLabel
+	}
+	if !stale {
+		return nil
+	}
+	log.Ctx(ctx).Info().Str("repo", s.cfg.Repo).Int("number", number).Bool("dry_run", s.cfg.DryRun).Msg("Active again - removing stale label")
+	if s.cfg.DryRun {
+		return nil
+	}
+	_, err = s.client.Issues.RemoveLabelForIssue(ctx, s.owner, s.repo, number, s.cfg.StaleLabel)
+	return errors.Wrapf(err, "failed to unlabel #%d", number)
+}
+
+func (s *StaleSweeper) classify(issue *github.Issue) staleAction {
+	if s.cfg.SkipPullRequests && issue.IsPullRequest() {
+		return staleNone
+	}
+	stale := false
+	for _, l := range issue.Labels {
+		name := l.GetName()
+		for _, exempt := range s.cfg.ExemptLabels {
+			if name == exempt {
+				return staleNone
+			}
+		}
+		if name == s.cfg.StaleLabel {
+			stale = true
+		}
+	}
+
+	// labelling an item updates it, so for stale items the update time is
+	// roughly when they were marked stale
+	inactive := s.now().Sub(issue.GetUpdatedAt())
+	switch {
+	case stale && s.cfg.CloseAfter > 0 && inactive >= s.cfg.CloseAfter:
+		return staleClose
+	case !stale && inactive >= s.cfg.StaleAfter:
+		return staleMark
+	default:
+		return staleNone
+	}
+}
+
+func (s *StaleSweeper) apply(ctx context.Context, issue *github.Issue, action staleAction) error {
+	if action == staleNone {
+		return nil
+	}
+	number := issue.GetNumber()
+	log := log.With().
+		Str("repo", s.cfg.Repo).
+		Int("number", number).
+		Bool("dry_run", s.cfg.DryRun).
+		Logger()
+
+	if action == staleMark {
+		log.Info().Msg("Marking stale")
+		if s.cfg.DryRun {
+			return nil
+		}
+		_, _, err := s.client.Issues.AddLabelsToIssue(ctx, s.owner, s.repo, number, []string{s.cfg.StaleLabel})
+		if err != nil {
+			return errors.Wrapf(err, "failed to label #%d", number)
+		}
+		return s.comment(ctx, number, s.cfg.StaleComment)
+	}
+
+	log.Info().Msg("Closing stale")
+	if s.cfg.DryRun {
+		return nil
+	}
+	err := s.comment(ctx, number, s.cfg.CloseComment)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.client.Issues.Edit(ctx, s.owner, s.repo, number, &github.IssueRequest{State: github.String("closed")})
+	return errors.Wrapf(err, "failed to close #%d", number)
+}
+
+func (s *StaleSweeper) comment(ctx context.Context, number int, body string) error {
+	if body == "" {
+		return nil
+	}
+	_, _, err := s.client.Issues.CreateComment(ctx, s.owner, s.repo, number, &github.IssueComment{Body: &body})
+	return errors.Wrapf(err, "failed to comment on #%d", number)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStaleSweeper(t *testing.T) {
+	_, err := NewStaleSweeper(nil, StaleConfig{Repo: "foo", StaleAfter: time.Hour})
+	assert.Error(t, err)
+
+	_, err = NewStaleSweeper(nil, StaleConfig{Repo: "foo/bar"})
+	assert.Error(t, err)
+
+	s, err := NewStaleSweeper(nil, StaleConfig{Repo: "foo/bar", StaleAfter: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, "stale", s.cfg.StaleLabel)
+}
+
+func TestStaleClassify(t *testing.T) {
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	s, _ := NewStaleSweeper(nil, StaleConfig{
+		Repo:         "foo/bar",
+		StaleAfter:   30 * 24 * time.Hour,
+		CloseAfter:   7 * 24 * time.Hour,
+		ExemptLabels: []string{"pinned"},
+	})
+	s.now = func() time.Time { return now }
+
+	issue := func(age time.Duration, labels ...string) *github.Issue {
+		updated := now.Add(-age)
+		i := &github.Issue{UpdatedAt: &updated}
+		for _, l := range labels {
+			i.Labels = append(i.Labels, github.Label{Name: github.String(l)})
+		}
+		return i
+	}
+	day := 24 * time.Hour
+
+	assert.Equal(t, staleNone, s.classify(issue(day)))
+	assert.Equal(t, staleMark, s.classify(issue(31*day)))
+	assert.Equal(t, staleNone, s.classify(issue(31*day, "pinned")))
+	assert.Equal(t, staleNone, s.classify(issue(day, "stale")))
+	assert.Equal(t, staleClose, s.classify(issue(8*day, "stale")))
+
+	s.cfg.CloseAfter = 0
+	assert.Equal(t, staleNone, s.classify(issue(80*day, "stale")))
+
+	s.cfg.SkipPullRequests = true
+	pr := issue(31 * day)
+	pr.PullRequestLinks = &github.PullRequestLinks{}
+	assert.Equal(t, staleNone, s.classify(pr))
+}
+
+// staleServer - serves a repo's open issues a page at a time, oldest update
+// first, like GitHub. Labelling an issue updates it.
+func staleServer(t *testing.T, updated map[int]time.Time, now time.Time) (*github.Client, *[]int, *[]int) {
+	var mu sync.Mutex
+	var labelled, unlabelled []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var number int
+		switch {
+		case req.Method == "GET" && req.URL.Path == "/repos/foo/bar/issues":
+			numbers := []int{}
+			for n := range updated {
+				numbers = append(numbers, n)
+			}
+			sort.Slice(numbers, func(i, j int) bool { return updated[numbers[i]].Before(updated[numbers[j]]) })
+			page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			start, end := (page-1)*2, page*2
+			if end < len(numbers) {
+				w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next"`, req.URL.Path, page+1))
+			} else {
+				end = len(numbers)
+			}
+			issues := []*github.Issue{}
+			for _, n := range numbers[start:end] {
+				u := updated[n]
+				issues = append(issues, &github.Issue{Number: github.Int(n), UpdatedAt: &u})
+			}
+			_ = json.NewEncoder(w).Encode(issues)
+		case req.Method == "POST":
+			_, _ = fmt.Sscanf(req.URL.Path, "/repos/foo/bar/issues/%d/labels", &number)
+			labelled = append(labelled, number)
+			updated[number] = now
+			fmt.Fprint(w, `[]`)
+		case req.Method == "DELETE":
+			_, _ = fmt.Sscanf(req.URL.Path, "/repos/foo/bar/issues/%d/labels/stale", &number)
+			unlabelled = append(unlabelled, number)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	return client, &labelled, &unlabelled
+}
+
+func TestStaleSweep(t *testing.T) {
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	updated := map[int]time.Time{}
+	for n := 1; n <= 5; n++ {
+		updated[n] = now.Add(-time.Duration(100-n) * 24 * time.Hour)
+	}
+	client, labelled, _ := staleServer(t, updated, now)
+	s, err := NewStaleSweeper(client, StaleConfig{Repo: "foo/bar", StaleAfter: 30 * 24 * time.Hour})
+	assert.NoError(t, err)
+	s.now = func() time.Time { return now }
+
+	// every item's marked, though marking moves it to the end of the list
+	assert.NoError(t, s.Sweep(context.Background()))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, *labelled)
+}
+
+func TestStaleUnmarkActive(t *testing.T) {
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	client, _, unlabelled := staleServer(t, map[int]time.Time{}, now)
+	s, err := NewStaleSweeper(client, StaleConfig{Repo: "foo/bar", StaleAfter: time.Hour, StaleComment: "This is stale"})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	event := func(action, sender, body string, labels ...string) []byte {
+		l := []map[string]string{}
+		for _, name := range labels {
+			l = append(l, map[string]string{"name": name})
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"action":     action,
+			"sender":     map[string]string{"login": sender, "type": "User"},
+			"repository": map[string]string{"full_name": "foo/bar"},
+			"issue":      map[string]interface{}{"number": 7, "labels": l},
+			"comment":    map[string]string{"body": body},
+		})
+		return b
+	}
+	assert.NoError(t, s.unmarkActive(ctx, "issue_comment", event("created", "hubot", "still happening", "bug")))
+	assert.NoError(t, s.unmarkActive(ctx, "issue_comment", event("created", "hubot", "This is stale", "stale")))
+	assert.NoError(t, s.unmarkActive(ctx, "issues", event("labeled", "hubot", "", "stale")))
+	assert.NoError(t, s.unmarkActive(ctx, "push", event("", "hubot", "", "stale")))
+	assert.Empty(t, *unlabelled)
+
+	assert.NoError(t, s.unmarkActive(ctx, "issue_comment", event("created", "hubot", "still happening", "stale")))
+	assert.Equal(t, []int{7}, *unlabelled)
+}
+
+func TestStaleDue(t *testing.T) {
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	s, _ := NewStaleSweeper(nil, StaleConfig{Repo: "foo/bar", StaleAfter: time.Hour})
+	s.now = func() time.Time { return now }
+
+	// the first delivery starts sweeping, later ones don't
+	assert.True(t, s.due(false, time.Hour))
+	assert.False(t, s.due(false, time.Hour))
+	now = now.Add(time.Hour)
+	assert.False(t, s.due(false, time.Hour))
+	assert.True(t, s.due(true, time.Hour))
+	// a second chain of follow-ups is dropped
+	now = now.Add(10 * time.Minute)
+	assert.False(t, s.due(true, time.Hour))
+}
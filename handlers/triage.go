@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"sync"
+	"text/template"
+
+	"github.com/google/go-github/v24/github"
+	responder "github.com/hairyhenderson/github-responder"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// LabelRule - a label to apply to issues whose title or body matches a pattern
+type LabelRule struct {
+	Label string
+	// Pattern is matched against the issue's title and body
+	Pattern string
+}
+
+// TriageConfig - configures the Triage handler
+type TriageConfig struct {
+	// Labels to apply to new issues, when their patterns match
+	Labels []LabelRule
+	// Welcome is a text/template for a comment to post on new issues. The
+	// template is executed with the *github.IssuesEvent. Leave empty to skip
+	// commenting.
+	Welcome string
+	// Triagers are the logins to assign new issues to, in round-robin order.
+	// Leave empty to skip assignment.
+	Triagers []string
+}
+
+type labelMatcher struct {
+	label string
+	re    *regexp.Regexp
+}
+
+type triage struct {
+	labels   []labelMatcher
+	welcome  *template.Template
+	triagers []string
+
+	mu   sync.Mutex
+	next int
+}
+
+// Triage - returns a handler that triages newly-opened issues: labels are
+// applied based on the title and body, a welcome comment is posted, and a
+// triager is assigned in round-robin fashion.
+func Triage(cfg TriageConfig) (responder.HookHandler, error) {
+	t := &triage{triagers: cfg.Triagers}
+	for _, l := range cfg.Labels {
+		re, err := regexp.Compile(l.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern for label %s", l.Label)
+		}
+		t.labels = append(t.labels, labelMatcher{l.Label, re})
+	}
+	if cfg.Welcome != "" {
+		tmpl, err := template.New("welcome").Parse(cfg.Welcome)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid welcome template")
+		}
+		t.welcome = tmpl
+	}
+	return t.handle, nil
+}
+
+func (t *triage) handle(ctx context.Context, eventType, deliveryID string, payload []byte) {
+	if eventType != "issues" {
+		return
+	}
+	log := log.Ctx(ctx)
+	event := &github.IssuesEvent{}
+	err := json.Unmarshal(payload, event)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to parse issues event")
+		return
+	}
+	if event.GetAction() != "opened" {
+		return
+	}
+
+	client, err := client(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+		return
+	}
+
+	owner := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+	number := event.GetIssue().GetNumber()
+
+	if labels := t.matchLabels(event.GetIssue()); len(labels) > 0 {
+		_, _, err = client.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels)
+		if err != nil {
+			log.Error().Err(err).Strs("labels", labels).Msg("failed to label issue")
+		}
+	}
+
+	if t.welcome != nil {
+		body, err := t.renderWelcome(event)
+		if err == nil {
+			_, _, err = client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: &body})
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to post welcome comment")
+		}
+	}
+
+	if triager := t.nextTriager(); triager != "" {
+		_, _, err = client.Issues.AddAssignees(ctx, owner, repo, number, []string{triager})
+		if err != nil {
+			log.Error().Err(err).Str("triager", triager).Msg("failed to assign triager")
+		}
+	}
+
+	log.Info().Int("issue", number).Msg("Triaged issue")
+}
+
+func (t *triage) matchLabels(issue *github.Issue) []string {
+	labels := []string{}
+	for _, l := range t.labels {
+		if l.re.MatchString(issue.GetTitle()) || l.re.MatchString(issue.GetBody()) {
+			labels = append(labels, l.label)
+		}
+	}
+	return labels
+}
+
+func (t *triage) renderWelcome(event *github.IssuesEvent) (string, error) {
+	out := &bytes.Buffer{}
+	err := t.welcome.Execute(out, event)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render welcome template")
+	}
+	return out.String(), nil
+}
+
+func (t *triage) nextTriager() string {
+	if len(t.triagers) == 0 {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	triager := t.triagers[t.next%len(t.triagers)]
+	t.next++
+	return triager
+}
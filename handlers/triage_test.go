@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"regexp"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriage(t *testing.T) {
+	_, err := Triage(TriageConfig{Labels: []LabelRule{{"bug", "("}}})
+	assert.Error(t, err)
+
+	_, err = Triage(TriageConfig{Welcome: "{{ .Bogus"})
+	assert.Error(t, err)
+
+	_, err = Triage(TriageConfig{})
+	assert.NoError(t, err)
+}
+
+func TestTriageMatchLabels(t *testing.T) {
+	tr := &triage{labels: []labelMatcher{
+		{"bug", regexp.MustCompile(`(?i)\bcrash`)},
+		{"docs", regexp.MustCompile(`(?i)readme`)},
+	}}
+	issue := &github.Issue{
+		Title: github.String("It crashes"),
+		Body:  github.String("see the README"),
+	}
+	assert.Equal(t, []string{"bug", "docs"}, tr.matchLabels(issue))
+	assert.Equal(t, []string{}, tr.matchLabels(&github.Issue{}))
+}
+
+func TestTriageNextTriager(t *testing.T) {
+	tr := &triage{}
+	assert.Equal(t, "", tr.nextTriager())
+
+	tr = &triage{triagers: []string{"a", "b"}}
+	assert.Equal(t, "a", tr.nextTriager())
+	assert.Equal(t, "b", tr.nextTriager())
+	assert.Equal(t, "a", tr.nextTriager())
+}
+
+func TestTriageRenderWelcome(t *testing.T) {
+	tr := &triage{
+		welcome: template.Must(template.New("welcome").Parse("Thanks @{{ .Sender.GetLogin }}!")),
+	}
+	out, err := tr.renderWelcome(&github.IssuesEvent{Sender: &github.User{Login: github.String("hairyhenderson")}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Thanks @hairyhenderson!", out)
+}
@@ -0,0 +1,71 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableHandler(t *testing.T) {
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	ep := r.newEndpoint(nil, []HookHandler{h})
+	r.endpoints = []*endpoint{ep}
+	ep.routes = append(ep.routes, Route{Name: "deploy", Events: []string{"push"}, Handler: h})
+
+	assert.Equal(t, []HandlerInfo{
+		{Name: "0.0", Enabled: true},
+		{Name: "deploy", Events: []string{"push"}, Enabled: true},
+	}, r.Handlers())
+
+	assert.Equal(t, ErrUnknownHandler, errors.Cause(r.DisableHandler("nope")))
+	assert.NoError(t, r.DisableHandler("deploy"))
+	routes := r.enabledRoutes(ep.namedRoutes())
+	if assert.Len(t, routes, 1) {
+		assert.Equal(t, "0.0", routes[0].name)
+	}
+	assert.False(t, r.Handlers()[1].Enabled)
+
+	assert.NoError(t, r.EnableHandler("deploy"))
+	assert.Len(t, r.enabledRoutes(ep.namedRoutes()), 2)
+
+	assert.Error(t, r.validateRouteNames([]Route{{Name: "deploy"}}))
+	assert.Error(t, r.validateRouteNames([]Route{{Name: "a"}, {Name: "a"}}))
+	assert.Error(t, r.validateRouteNames([]Route{{Name: "a/b"}}))
+	assert.NoError(t, r.validateRouteNames([]Route{{Name: "a"}, {}}))
+}
+
+func TestHandlerAdmin(t *testing.T) {
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	r := &Responder{domain: "example.com", pathPrefix: "/", metricsIP: DefaultMetricsIPFilter}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{h})}
+	r.EnableHandlerAdmin()
+	mux := r.Handler()
+
+	do := func(method, path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, "/handlers/0.0/disable", "127.0.0.1:1234").Code)
+	w := do(http.MethodGet, "/handlers", "127.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var handlers []HandlerInfo
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&handlers))
+	assert.Equal(t, []HandlerInfo{{Name: "0.0"}}, handlers)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "/handlers/0.0/enable", "127.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/handlers/nope/enable", "127.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/handlers/0.0/enable", "192.0.2.1:1234").Code)
+	assert.False(t, r.Handlers()[0].Enabled)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, "/handlers/0.0/enable", "127.0.0.1:1234").Code)
+	assert.True(t, r.Handlers()[0].Enabled)
+}
@@ -0,0 +1,134 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HookDefinition - a hook the Responder would register, for managing it
+// with infrastructure-as-code tooling (e.g. Terraform) instead, while the
+// Responder only serves its callback (see Options.Observe). The secret isn't
+// included - SecretRef names where the tooling gets it from.
+type HookDefinition struct {
+	// Repo - the repo, in 'owner/repo' form
+	Repo   string   `json:"repo"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// ContentType - always "json"
+	ContentType string `json:"content_type"`
+	// Config - extra hook config fields (see Options.HookConfig)
+	Config map[string]interface{} `json:"config,omitempty"`
+	// SecretRef - a reference to the hook secret, e.g. a Terraform variable
+	SecretRef string `json:"secret_ref,omitempty"`
+}
+
+// HookDefinitions - the hooks Register would create for the watched repos,
+// with their secrets given by secretRef. Callback URLs must be the same
+// across runs for hooks managed elsewhere, so Options.HookSecret is
+// required. When events is empty, each endpoint's events are used, as in
+// Register.
+func (r *Responder) HookDefinitions(ctx context.Context, events []string, secretRef string) ([]HookDefinition, error) {
+	if r.hookSecret == "" {
+		return nil, errors.New("exported hooks need stable callback URLs - set a hook secret")
+	}
+	err := r.resolveRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var defs []HookDefinition
+	for _, ep := range r.endpoints {
+		subscription := events
+		if len(subscription) == 0 {
+			subscription = ep.subscription(r.events)
+		}
+		for _, repo := range ep.repos {
+			callback, err := r.callbackFor(ep, repo)
+			if err != nil {
+				return nil, err
+			}
+			hook := r.hookFor(ep, callback, subscription)
+			config := map[string]interface{}{}
+			for k, v := range hook.Config {
+				if !reservedHookConfig[k] {
+					config[k] = v
+				}
+			}
+			defs = append(defs, HookDefinition{
+				Repo:        repo.owner + "/" + repo.name,
+				URL:         callback,
+				Events:      hook.Events,
+				ContentType: "json",
+				Config:      config,
+				SecretRef:   secretRef,
+			})
+		}
+	}
+	return defs, nil
+}
+
+// WriteHookDefinitionsJSON - write the hook definitions to w as a JSON array
+func WriteHookDefinitionsJSON(w io.Writer, defs []HookDefinition) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(defs), "failed to write hook definitions")
+}
+
+// nonIdentifier - characters not allowed in Terraform resource names
+var nonIdentifier = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// WriteHookDefinitionsTerraform - write the hook definitions to w as
+// Terraform github_repository_webhook resources. The GitHub provider's
+// owner must be the repos' owner. SecretRef is written as an expression
+// (e.g. var.webhook_secret), and config fields the resource doesn't
+// support are left out.
+func WriteHookDefinitionsTerraform(w io.Writer, defs []HookDefinition) error {
+	var b strings.Builder
+	for i, d := range defs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		parts := strings.SplitN(d.Repo, "/", 2)
+		name := nonIdentifier.ReplaceAllString(strings.Replace(d.Repo, "/", "_", 1), "_")
+		fmt.Fprintf(&b, "resource \"github_repository_webhook\" %q {\n", name)
+		fmt.Fprintf(&b, "  repository = %q\n", parts[len(parts)-1])
+		b.WriteString("  active     = true\n")
+		events := make([]string, len(d.Events))
+		for i, e := range d.Events {
+			events[i] = fmt.Sprintf("%q", e)
+		}
+		sort.Strings(events)
+		fmt.Fprintf(&b, "  events     = [%s]\n\n", strings.Join(events, ", "))
+		b.WriteString("  configuration {\n")
+		fmt.Fprintf(&b, "    url          = %q\n", d.URL)
+		fmt.Fprintf(&b, "    content_type = %q\n", d.ContentType)
+		if v, ok := d.Config["insecure_ssl"]; ok {
+			fmt.Fprintf(&b, "    insecure_ssl = %v\n", insecureSSL(v))
+		}
+		if d.SecretRef != "" {
+			fmt.Fprintf(&b, "    secret       = %s\n", d.SecretRef)
+		}
+		b.WriteString("  }\n}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return errors.Wrap(err, "failed to write hook definitions")
+}
+
+// insecureSSL - the insecure_ssl config value (GitHub takes "0" or "1") as
+// a Terraform bool
+func insecureSSL(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "1" || v == "true"
+	}
+	return false
+}
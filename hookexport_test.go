@@ -0,0 +1,50 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHookDefinitions(t *testing.T) {
+	r := testResponder(t, nil, "a/b", "a/c")
+	_, err := r.HookDefinitions(context.Background(), nil, "var.secret")
+	assert.Error(t, err)
+
+	r.hookSecret = "s3cret"
+	r.hookConfig = map[string]interface{}{"insecure_ssl": "1"}
+	r.endpoints = []*endpoint{r.newEndpoint(r.endpoints[0].repos, nil)}
+	defs, err := r.HookDefinitions(context.Background(), []string{"push", "issues"}, "var.secret")
+	if !assert.NoError(t, err) || !assert.Len(t, defs, 2) {
+		return
+	}
+	assert.Equal(t, "a/b", defs[0].Repo)
+	assert.Equal(t, r.endpoints[0].callbackURL, defs[0].URL)
+	assert.Equal(t, map[string]interface{}{"insecure_ssl": "1"}, defs[0].Config)
+
+	var b bytes.Buffer
+	assert.NoError(t, WriteHookDefinitionsJSON(&b, defs))
+	assert.NotContains(t, b.String(), "s3cret")
+	var decoded []HookDefinition
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+	assert.Equal(t, "var.secret", decoded[1].SecretRef)
+
+	b.Reset()
+	assert.NoError(t, WriteHookDefinitionsTerraform(&b, defs[:1]))
+	assert.Equal(t, `resource "github_repository_webhook" "a_b" {
+  repository = "b"
+  active     = true
+  events     = ["issues", "push"]
+
+  configuration {
+    url          = "`+defs[0].URL+`"
+    content_type = "json"
+    insecure_ssl = true
+    secret       = var.secret
+  }
+}
+`, b.String())
+}
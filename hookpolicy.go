@@ -0,0 +1,82 @@
+package responder
+
+import (
+	"context"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// HookShutdownPolicy - what the cleanup function (see Register) does with
+// the Responder's hooks
+type HookShutdownPolicy string
+
+const (
+	// DeleteHooks - delete the hooks. The default, unless AdoptHooks.
+	DeleteHooks HookShutdownPolicy = "delete"
+	// KeepHooks - leave the hooks in place, to be adopted on the next
+	// startup. The default with AdoptHooks.
+	KeepHooks HookShutdownPolicy = "keep"
+	// DeactivateHooks - leave the hooks in place, but inactive, so GitHub
+	// stops attempting deliveries during planned downtime (instead of
+	// recording them as failed), and reactivate them when they're adopted
+	// on the next startup. Implies AdoptHooks. Note that GitHub doesn't
+	// record deliveries for inactive hooks, so events during the downtime
+	// can't be redelivered or backfilled.
+	DeactivateHooks HookShutdownPolicy = "deactivate"
+)
+
+func (p HookShutdownPolicy) validate() error {
+	switch p {
+	case "", DeleteHooks, KeepHooks, DeactivateHooks:
+		return nil
+	}
+	return errors.Errorf("invalid hook shutdown policy %q - must be %q, %q, or %q", p, DeleteHooks, KeepHooks, DeactivateHooks)
+}
+
+// hookCleanup - the cleanup function for the repo's hook, according to the
+// shutdown policy
+func (r *Responder) hookCleanup(ctx context.Context, repo repository, id int64) func() error {
+	policy := r.shutdownPolicy
+	if policy == "" && r.adoptHooks {
+		policy = KeepHooks
+	}
+	switch policy {
+	case KeepHooks:
+		return keepHook
+	case DeactivateHooks:
+		return func() error {
+			return r.deactivateHook(ctx, repo, id)
+		}
+	}
+	return func() error {
+		log := log.With().Int64("hook_id", id).Logger()
+		log.Info().Msg("Cleaning up webhook")
+		resp, err := r.ghclient.Repositories.DeleteHook(ctx, repo.owner, repo.name, id)
+		err = hookError(resp, err)
+		if err != nil {
+			err = errors.Wrap(err, "failed to delete webhook")
+			log.Error().Err(err).Msg("failed to delete webhook")
+		}
+		return err
+	}
+}
+
+// deactivateHook sets the hook inactive, leaving it to be reactivated by
+// adoptHook
+func (r *Responder) deactivateHook(ctx context.Context, repo repository, id int64) error {
+	log := log.With().Int64("hook_id", id).Str("repo", repo.owner+"/"+repo.name).Logger()
+	log.Info().Msg("Deactivating webhook")
+	var resp *github.Response
+	err := withRetries(ctx, "deactivating hook", func() (_ *github.Response, err error) {
+		_, resp, err = r.ghclient.Repositories.EditHook(ctx, repo.owner, repo.name, id, &github.Hook{Active: github.Bool(false)})
+		return resp, err
+	})
+	err = hookError(resp, err)
+	if err != nil {
+		err = errors.Wrap(err, "failed to deactivate webhook")
+		log.Error().Err(err).Msg("failed to deactivate webhook")
+	}
+	return err
+}
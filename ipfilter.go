@@ -0,0 +1,107 @@
+package responder
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+)
+
+// IPFilter - allows or denies requests by remote IP address. Denied ranges
+// take precedence. With no allowed ranges, all addresses not denied are
+// allowed.
+type IPFilter struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+// DefaultMetricsIPFilter - the filter used for /metrics when none is given:
+// only loopback, link-local, and 10.0.0.0/8 addresses are allowed
+var DefaultMetricsIPFilter = mustIPFilter([]string{
+	"127.0.0.0/8", "::1", "169.254.0.0/16", "fe80::/10", "10.0.0.0/8",
+}, nil)
+
+// NewIPFilter - build an IPFilter from lists of CIDRs (e.g. "10.0.0.0/8").
+// Bare IP addresses are treated as single-address ranges.
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	a, err := parseCIDRs(allow)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseCIDRs(deny)
+	if err != nil {
+		return nil, err
+	}
+	return &IPFilter{Allow: a, Deny: d}, nil
+}
+
+func mustIPFilter(allow, deny []string) *IPFilter {
+	f, err := NewIPFilter(allow, deny)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP address %q", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid CIDR %q", c)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Allowed - whether requests from ip are allowed
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range f.Deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, n := range f.Allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware - reject requests from disallowed addresses with a 404, so the
+// endpoint's existence isn't revealed
+func (f *IPFilter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err == nil && f.Allowed(net.ParseIP(host)) {
+			next.ServeHTTP(resp, req)
+			return
+		}
+
+		hlog.FromRequest(req).Warn().Str("remoteAddr", req.RemoteAddr).Msg("bad remoteAddr - rejecting")
+		resp.WriteHeader(http.StatusNotFound)
+	})
+}
@@ -0,0 +1,53 @@
+package responder
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPFilterAllowed(t *testing.T) {
+	f := DefaultMetricsIPFilter
+	assert.True(t, f.Allowed(net.ParseIP("127.0.0.1")))
+	assert.True(t, f.Allowed(net.ParseIP("::1")))
+	assert.True(t, f.Allowed(net.ParseIP("10.1.2.3")))
+	assert.True(t, f.Allowed(net.ParseIP("fe80::1")))
+	assert.False(t, f.Allowed(net.ParseIP("192.168.0.1")))
+	assert.False(t, f.Allowed(nil))
+
+	f, err := NewIPFilter(nil, []string{"192.30.252.0/22", "1.2.3.4"})
+	assert.NoError(t, err)
+	assert.False(t, f.Allowed(net.ParseIP("192.30.252.10")))
+	assert.False(t, f.Allowed(net.ParseIP("1.2.3.4")))
+	assert.True(t, f.Allowed(net.ParseIP("1.2.3.5")))
+
+	f, err = NewIPFilter([]string{"10.0.0.0/8"}, []string{"10.0.0.0/24"})
+	assert.NoError(t, err)
+	assert.False(t, f.Allowed(net.ParseIP("10.0.0.1")))
+	assert.True(t, f.Allowed(net.ParseIP("10.1.0.1")))
+
+	_, err = NewIPFilter([]string{"bogus"}, nil)
+	assert.Error(t, err)
+	_, err = NewIPFilter(nil, []string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	h := DefaultMetricsIPFilter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req.RemoteAddr = "8.8.8.8:1234"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,59 @@
+package responder
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownHookTimeout bounds how long OnShutdown hooks may run in total
+var shutdownHookTimeout = 30 * time.Second
+
+// LifecycleHook - a function run at a point in the Responder's lifecycle. See
+// OnStart, OnReady, and OnShutdown.
+type LifecycleHook func(ctx context.Context) error
+
+type lifecycle struct {
+	start    []LifecycleHook
+	ready    []LifecycleHook
+	shutdown []LifecycleHook
+}
+
+// OnStart - run f in RegisterAndListen, before any webhooks are registered. An
+// error aborts startup.
+func (r *Responder) OnStart(f LifecycleHook) {
+	r.lifecycle.start = append(r.lifecycle.start, f)
+}
+
+// OnReady - run f in RegisterAndListen, once webhooks are registered and the
+// server is bound to its address (and with TLS, has its certificates), so can
+// serve deliveries. It's run in the background. Errors are logged.
+func (r *Responder) OnReady(f LifecycleHook) {
+	r.lifecycle.ready = append(r.lifecycle.ready, f)
+}
+
+// OnShutdown - run f in RegisterAndListen when shutting down, before webhooks
+// are cleaned up. Errors are logged. Hooks get a fresh context, since the
+// original may already be cancelled.
+func (r *Responder) OnShutdown(f LifecycleHook) {
+	r.lifecycle.shutdown = append(r.lifecycle.shutdown, f)
+}
+
+// runHooks runs the hooks in registration order. When failFast is set the
+// first error is returned, otherwise errors are logged and the rest still run.
+func runHooks(ctx context.Context, stage string, hooks []LifecycleHook, failFast bool) error {
+	for _, f := range hooks {
+		err := f(ctx)
+		if err == nil {
+			continue
+		}
+		err = errors.Wrapf(err, "%s hook failed", stage)
+		if failFast {
+			return err
+		}
+		log.Error().Err(err).Str("stage", stage).Msg("lifecycle hook failed")
+	}
+	return nil
+}
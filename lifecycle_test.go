@@ -0,0 +1,40 @@
+package responder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHooks(t *testing.T) {
+	ctx := context.Background()
+	var calls []int
+	hooks := []LifecycleHook{
+		func(context.Context) error { calls = append(calls, 1); return nil },
+		func(context.Context) error { calls = append(calls, 2); return errors.New("boom") },
+		func(context.Context) error { calls = append(calls, 3); return nil },
+	}
+
+	err := runHooks(ctx, "start", hooks, true)
+	assert.EqualError(t, err, "start hook failed: boom")
+	assert.Equal(t, []int{1, 2}, calls)
+
+	calls = nil
+	err = runHooks(ctx, "shutdown", hooks, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, calls)
+}
+
+func TestStop(t *testing.T) {
+	r := &Responder{}
+	select {
+	case <-r.stopped():
+		t.Error("shouldn't be stopped yet")
+	default:
+	}
+	r.Stop()
+	r.Stop()
+	<-r.stopped()
+}
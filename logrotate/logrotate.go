@@ -0,0 +1,162 @@
+// Package logrotate provides an io.Writer that writes to a file, rotating it
+// when it grows too large or too old.
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// File - a rotating log file. When rotated, the current file is renamed with a
+// timestamp suffix, and a new file is started. The zero value is not usable -
+// Filename must be set.
+type File struct {
+	// Filename is the file to write to
+	Filename string
+	// MaxSize is the size in bytes at which the file is rotated - 0 means no
+	// size limit
+	MaxSize int64
+	// Interval is the maximum age of the file before it's rotated - 0 means
+	// no time limit
+	Interval time.Duration
+	// MaxBackups is the number of rotated files to keep - 0 keeps them all
+	MaxBackups int
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+}
+
+// Write - fulfils io.Writer
+func (l *File) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		err := l.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if l.shouldRotate(int64(len(p))) {
+		err := l.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// Close - fulfils io.Closer
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.close()
+}
+
+// Rotate - rotate the file now
+func (l *File) Rotate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rotate()
+}
+
+func (l *File) timeNow() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+func (l *File) shouldRotate(n int64) bool {
+	if l.MaxSize > 0 && l.size > 0 && l.size+n > l.MaxSize {
+		return true
+	}
+	return l.Interval > 0 && l.timeNow().Sub(l.opened) >= l.Interval
+}
+
+func (l *File) open() error {
+	err := os.MkdirAll(filepath.Dir(l.Filename), 0755)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create directory for %s", l.Filename)
+	}
+	f, err := os.OpenFile(l.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", l.Filename)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "failed to stat %s", l.Filename)
+	}
+	l.f = f
+	l.size = fi.Size()
+	// an existing file's age is measured from when it was last written, so
+	// restarts don't postpone rotation forever
+	l.opened = l.timeNow()
+	if fi.Size() > 0 {
+		l.opened = fi.ModTime()
+	}
+	return nil
+}
+
+func (l *File) close() error {
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+func (l *File) rotate() error {
+	err := l.close()
+	if err != nil {
+		return err
+	}
+	backup := l.Filename + "." + l.timeNow().Format(backupTimeFormat)
+	err = os.Rename(l.Filename, backup)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to rotate %s", l.Filename)
+	}
+	err = l.prune()
+	if err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// prune removes the oldest backups beyond MaxBackups
+func (l *File) prune() error {
+	if l.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(l.Filename + ".*")
+	if err != nil {
+		return err
+	}
+	if len(backups) <= l.MaxBackups {
+		return nil
+	}
+	// the timestamp format sorts lexically
+	sort.Strings(backups)
+	for _, b := range backups[:len(backups)-l.MaxBackups] {
+		err = os.Remove(b)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove old log %s", b)
+		}
+	}
+	return nil
+}
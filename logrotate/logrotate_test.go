@@ -0,0 +1,69 @@
+package logrotate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileRotatesBySize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &File{
+		Filename:   filepath.Join(dir, "access.log"),
+		MaxSize:    10,
+		MaxBackups: 2,
+		now: func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		},
+	}
+	defer l.Close()
+
+	for _, s := range []string{"12345\n", "67890\n", "abcde\n", "fghij\n"} {
+		_, err = l.Write([]byte(s))
+		assert.NoError(t, err)
+	}
+
+	b, err := ioutil.ReadFile(l.Filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "fghij\n", string(b))
+
+	backups, err := filepath.Glob(l.Filename + ".*")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	b, err = ioutil.ReadFile(backups[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "abcde\n", string(b))
+}
+
+func TestFileRotatesByAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	l := &File{
+		Filename: filepath.Join(dir, "access.log"),
+		Interval: time.Hour,
+		now:      func() time.Time { return now },
+	}
+	defer l.Close()
+
+	_, err = l.Write([]byte("one\n"))
+	assert.NoError(t, err)
+	now = now.Add(2 * time.Hour)
+	_, err = l.Write([]byte("two\n"))
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(l.Filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "two\n", string(b))
+}
@@ -0,0 +1,83 @@
+package responder
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// MaintenanceWindow - a period during which deliveries are accepted, but
+// their handlers aren't run until it's over
+type MaintenanceWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// contains - whether the time is in the window. The end is exclusive.
+func (w MaintenanceWindow) contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+func (w MaintenanceWindow) validate() error {
+	if w.Start.IsZero() || w.End.IsZero() {
+		return errors.New("invalid maintenance window - start and end are required")
+	}
+	if !w.End.After(w.Start) {
+		return errors.Errorf("invalid maintenance window %s-%s - must end after it starts", w.Start, w.End)
+	}
+	return nil
+}
+
+// SetMaintenance - start or end maintenance by hand, regardless of the
+// configured windows (see Options.MaintenanceWindows). While in
+// maintenance, deliveries are answered with 202 Accepted and queued in the
+// scheduler's store (see SetStateStore), and their handlers are run by the
+// scheduler once maintenance is over. Pings are still answered as usual.
+func (r *Responder) SetMaintenance(on bool) {
+	r.maintenanceMu.Lock()
+	defer r.maintenanceMu.Unlock()
+	r.maintenanceOn = on
+}
+
+// InMaintenance - whether the Responder is in maintenance now
+func (r *Responder) InMaintenance() bool {
+	return r.inMaintenance(r.clock())
+}
+
+func (r *Responder) inMaintenance(now time.Time) bool {
+	r.maintenanceMu.Lock()
+	defer r.maintenanceMu.Unlock()
+	if r.maintenanceOn {
+		return true
+	}
+	for _, w := range r.maintenance {
+		if w.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// deferDelivery queues a run of each of the routes interested in the
+// delivery, for the scheduler to run once maintenance is over
+func (r *Responder) deferDelivery(log zerolog.Logger, routes []Route, d *Delivery) {
+	now := r.clock()
+	for _, rt := range routes {
+		if !rt.wants(d.EventType) {
+			continue
+		}
+		err := r.schedule(scheduledRun{
+			Handler:  rt.name,
+			Due:      now,
+			Delivery: d,
+			Deferred: true,
+		})
+		if err != nil {
+			log.Error().Err(err).Str("handler", rt.name).Msg("failed to queue delivery during maintenance - dropping it")
+			continue
+		}
+	}
+	deferred.WithLabelValues(d.EventType).Inc()
+	log.Info().Msg("In maintenance - queued delivery")
+}
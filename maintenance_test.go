@@ -0,0 +1,76 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	now := time.Now()
+	w := MaintenanceWindow{Start: now, End: now.Add(time.Hour)}
+	assert.NoError(t, w.validate())
+	assert.True(t, w.contains(now))
+	assert.True(t, w.contains(now.Add(time.Minute)))
+	assert.False(t, w.contains(now.Add(-time.Minute)))
+	assert.False(t, w.contains(now.Add(time.Hour)))
+
+	assert.Error(t, MaintenanceWindow{Start: now}.validate())
+	assert.Error(t, MaintenanceWindow{Start: now, End: now}.validate())
+
+	opts := Options{Repos: []string{"foo/bar"}, Domain: "example.com"}
+	opts.MaintenanceWindows = []MaintenanceWindow{{Start: now.Add(time.Hour), End: now}}
+	assert.Error(t, opts.Validate())
+}
+
+func TestInMaintenance(t *testing.T) {
+	now := time.Now()
+	r := &Responder{maintenance: []MaintenanceWindow{{Start: now, End: now.Add(time.Hour)}}}
+	assert.True(t, r.inMaintenance(now))
+	assert.False(t, r.inMaintenance(now.Add(2*time.Hour)))
+
+	r.SetMaintenance(true)
+	assert.True(t, r.inMaintenance(now.Add(2*time.Hour)))
+	r.SetMaintenance(false)
+	assert.False(t, r.inMaintenance(now.Add(2*time.Hour)))
+}
+
+func TestServeHTTPMaintenance(t *testing.T) {
+	payload := []byte(`{}`)
+	called := make(chan bool, 1)
+	action := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		called <- GetDeliveryInfo(ctx).FollowUp
+	}
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{action})}
+	ep := r.endpoints[0]
+	r.SetMaintenance(true)
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-GitHub-Delivery", "abc")
+	req.Header.Set("X-Hub-Signature", sign(payload, []byte(ep.secret)))
+	rec := httptest.NewRecorder()
+	ep.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	// still in maintenance, so not drained yet
+	r.runDue(time.Now().Add(time.Hour))
+	select {
+	case <-called:
+		t.Error("action shouldn't run during maintenance")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	r.SetMaintenance(false)
+	r.runDue(time.Now())
+	assert.False(t, <-called)
+	keys, _ := r.scheduleStore().Keys(scheduleNamespace)
+	assert.Empty(t, keys)
+}
@@ -1,15 +1,14 @@
 package responder
 
 import (
-	"net"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/justinas/alice"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/rs/zerolog/log"
 )
 
 var (
@@ -62,16 +61,94 @@ var (
 			Help:       "A summary of request sizes for requests.",
 			Objectives: sumObjectives,
 		}, httpLabels)}
+
+	deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "Count of validated webhook deliveries, by event type and (when enabled) repository.",
+	}, []string{"event", "repo"})
+
+	unrecognized = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "unrecognized_deliveries_total",
+		Help:      "Count of deliveries with an event type or fields unknown to the bundled go-github, by event type and reason. Only a sample of deliveries is checked for unknown fields.",
+	}, []string{"event", "reason"})
+
+	deferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "deferred_deliveries_total",
+		Help:      "Count of deliveries queued during maintenance, by event type.",
+	}, []string{"event"})
+
+	timeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "delivery_timeouts_total",
+		Help:      "Count of deliveries whose handlers didn't finish within the delivery budget, by event type.",
+	}, []string{"event"})
+
+	shadowResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "shadow_results_total",
+		Help:      "Count of shadow handler runs, by event type and result (succeeded or failed).",
+	}, []string{"event", "result"})
+
+	handlerDurations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "handler_duration_seconds",
+		Help:      "A histogram of handler run times, by event type and handler, when the Timing middleware is in use.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event", "handler"})
+
+	apiCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "api",
+		Name:      "handler_calls_total",
+		Help:      "Count of GitHub API calls made by handlers, when API call budgets are enabled, by handler and result (allowed or rejected).",
+	}, []string{"handler", "result"})
+
+	// event types seen so far, so evicted repos' series can be deleted
+	seenEvents sync.Map
 )
 
+// metricsOnce - metrics are registered once, though Handler may be called
+// more than once
+var metricsOnce sync.Once
+
 func initMetrics() {
+	metricsOnce.Do(registerMetrics)
+}
+
+func registerMetrics() {
 	o := []prometheus.Collector{}
 	for _, m := range observers {
 		o = append(o, m)
 	}
+	o = append(o, deliveries, unrecognized, deferred, timeouts, shadowResults, handlerDurations, queueDepth, rejected, duplicates, redeliveryChanges, hookIPRanges, rateLimited, inFlightDeliveries, apiCalls, certExpiry)
 	MetricsRegisterer.MustRegister(o...)
 }
 
+func countDelivery(eventType, repo string) {
+	seenEvents.Store(eventType, true)
+	deliveries.WithLabelValues(eventType, repo).Inc()
+}
+
+func countUnrecognized(eventType, reason string) {
+	unrecognized.WithLabelValues(eventType, reason).Inc()
+}
+
+func deleteRepoSeries(repo string) {
+	seenEvents.Range(func(k, _ interface{}) bool {
+		deliveries.DeleteLabelValues(k.(string), repo)
+		return true
+	})
+}
+
 func instrumentHTTP(handler string) alice.Chain {
 	l := prometheus.Labels{"handler": handler}
 	chain := alice.New()
@@ -94,35 +171,3 @@ func instrumentHTTP(handler string) alice.Chain {
 	}
 	return chain
 }
-
-func filterByIP(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
-		host, _, err := net.SplitHostPort(req.RemoteAddr)
-		if err == nil {
-			ip := net.ParseIP(host)
-			if isAllowed(ip) {
-				next.ServeHTTP(resp, req)
-				return
-			}
-		}
-
-		log.Warn().Str("remoteAddr", req.RemoteAddr).Msg("bad remoteAddr - rejecting")
-		resp.WriteHeader(http.StatusNotFound)
-	})
-}
-
-func isAllowed(ip net.IP) bool {
-	if ip == nil {
-		return false
-	}
-
-	if ip.IsLinkLocalUnicast() || ip.IsLoopback() {
-		return true
-	}
-
-	_, cidr, err := net.ParseCIDR("10.0.0.0/8")
-	if err != nil {
-		log.Fatal().Err(err).Msg("couldn't parse the CIDR")
-	}
-	return cidr.Contains(ip)
-}
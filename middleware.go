@@ -0,0 +1,119 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Middleware - wraps a HookHandler, to add behaviour around every handler
+type Middleware func(HookHandler) HookHandler
+
+// Use - wrap the handlers of every route (including follow-up and deferred
+// runs) in the given middleware. Middleware is applied in the order given,
+// the first being outermost, so
+//
+//	r.Use(Logging(), Retry(3, time.Second), Recover())
+//
+// logs once per delivery, and retries handlers that panic. Shadow handlers
+// aren't wrapped.
+func (r *Responder) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// chain - wrap the handler in the Responder's middleware
+func (r *Responder) chain(h HookHandler) HookHandler {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return h
+}
+
+// Recover - middleware that recovers from panics in the handler, logging the
+// stack and reporting the panic as a failure (see ReportFailure)
+func Recover() Middleware {
+	return func(next HookHandler) HookHandler {
+		return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			defer func() {
+				if p := recover(); p != nil {
+					zerolog.Ctx(ctx).Error().
+						Str("handler", handlerName(ctx)).
+						Str("stack", string(debug.Stack())).
+						Msgf("handler panicked: %v", p)
+					ReportFailure(ctx, fmt.Errorf("handler panicked: %v", p))
+				}
+			}()
+			next(ctx, eventType, deliveryID, payload)
+		}
+	}
+}
+
+// Logging - middleware that logs when the handler starts and finishes, and
+// how long it took
+func Logging() Middleware {
+	return func(next HookHandler) HookHandler {
+		return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			log := zerolog.Ctx(ctx).With().Str("handler", handlerName(ctx)).Logger()
+			log.Debug().Msg("handler starting")
+			start := time.Now()
+			next(ctx, eventType, deliveryID, payload)
+			log.Info().Dur("duration", time.Since(start)).Msg("handler finished")
+		}
+	}
+}
+
+// Timing - middleware that records handler run times in the
+// github_webhook_handler_duration_seconds histogram
+func Timing() Middleware {
+	return func(next HookHandler) HookHandler {
+		return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			start := time.Now()
+			defer func() {
+				handlerDurations.WithLabelValues(eventType, handlerName(ctx)).
+					Observe(time.Since(start).Seconds())
+			}()
+			next(ctx, eventType, deliveryID, payload)
+		}
+	}
+}
+
+// Retry - middleware that runs the handler up to attempts times, waiting
+// backoff (doubling each time) between attempts, until it doesn't report a
+// failure (see ReportFailure). Only the last attempt's failure is reported.
+// Waiting stops early if the context is cancelled, e.g. when the delivery
+// budget runs out. Put Recover inside Retry to retry panics too.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next HookHandler) HookHandler {
+		return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			var err error
+			wait := backoff
+			for i := 1; ; i++ {
+				res := &handlerResult{}
+				next(context.WithValue(ctx, attemptKey, res), eventType, deliveryID, payload)
+				if err = res.err; err == nil {
+					return
+				}
+				if i >= attempts {
+					break
+				}
+				zerolog.Ctx(ctx).Warn().Err(err).
+					Str("handler", handlerName(ctx)).
+					Int("attempt", i).
+					Dur("backoff", wait).
+					Msg("handler failed - retrying")
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					ReportFailure(ctx, errors.Wrapf(err, "gave up after %d attempts", i))
+					return
+				}
+				wait *= 2
+			}
+			ReportFailure(ctx, errors.Wrapf(err, "gave up after %d attempts", attempts))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package responder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUseOrder(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next HookHandler) HookHandler {
+			return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+				calls = append(calls, name)
+				next(ctx, eventType, deliveryID, payload)
+			}
+		}
+	}
+	r := &Responder{}
+	r.Use(mw("a"), mw("b"))
+	r.chain(func(context.Context, string, string, []byte) {
+		calls = append(calls, "handler")
+	})(context.Background(), "push", "1", nil)
+	assert.Equal(t, []string{"a", "b", "handler"}, calls)
+}
+
+func TestRetry(t *testing.T) {
+	n := 0
+	flaky := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		n++
+		if n < 3 {
+			panic("boom")
+		}
+	}
+	res := &handlerResult{}
+	ctx := context.WithValue(context.Background(), shadowKey, res)
+	Retry(3, 0)(Recover()(flaky))(ctx, "push", "1", nil)
+	assert.Equal(t, 3, n)
+	assert.NoError(t, res.err)
+
+	n = 0
+	failing := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		n++
+		ReportFailure(ctx, errors.New("nope"))
+	}
+	Retry(2, 0)(failing)(ctx, "push", "1", nil)
+	assert.Equal(t, 2, n)
+	assert.EqualError(t, res.err, "gave up after 2 attempts: nope")
+}
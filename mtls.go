@@ -0,0 +1,83 @@
+package responder
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// acmeTLSProto - the ALPN protocol ACME TLS-ALPN-01 challenges are
+// validated over. The CA has no client certificate, so these handshakes
+// never require one.
+const acmeTLSProto = "acme-tls/1"
+
+// clientCAPool - the pool of CAs in the PEM bundle, for verifying client
+// certificates
+func clientCAPool(pemCerts []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, errors.New("invalid client CAs - no PEM-encoded certificates found")
+	}
+	return pool, nil
+}
+
+// validateClientCAs - check the mutual TLS options
+func (o Options) validateClientCAs() error {
+	switch {
+	case len(o.ClientCAs) == 0:
+		if o.ClientCertsOnCallbacks {
+			return errors.New("client certificates can only be required on callbacks when client CAs are given")
+		}
+		return nil
+	case o.BehindProxy:
+		return errors.New("client certificates can't be verified behind a proxy, as the proxy terminates TLS")
+	}
+	_, err := clientCAPool(o.ClientCAs)
+	return err
+}
+
+// mutualTLS - configure the TLS config to verify client certificates
+// against the client CAs, when they're given. Certificates are only
+// required during the handshake when callbacks need them too - otherwise
+// GitHub (which has none) couldn't connect, so requireClientCert enforces
+// them per endpoint instead.
+func (r *Responder) mutualTLS(cfg *tls.Config) *tls.Config {
+	if r.clientCAs == nil {
+		return cfg
+	}
+	acme := cfg
+	cfg = cfg.Clone()
+	cfg.ClientCAs = r.clientCAs
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if r.clientCertCallbacks {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		for _, proto := range hello.SupportedProtos {
+			if proto == acmeTLSProto {
+				return acme, nil
+			}
+		}
+		return nil, nil
+	}
+	return cfg
+}
+
+// requireClientCert - middleware rejecting requests without a verified
+// client certificate with a 403, when client CAs are given. Requests that
+// didn't arrive over TLS (e.g. from Handler mounted on a plain HTTP server)
+// are rejected too.
+func (r *Responder) requireClientCert(next http.Handler) http.Handler {
+	if r.clientCAs == nil {
+		return next
+	}
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+			http.Error(resp, "client certificate required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
@@ -0,0 +1,110 @@
+package responder
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClientCert - a CA (PEM-encoded), and a client certificate it signed
+func testClientCert(t *testing.T) ([]byte, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	ca, err = x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &key.PublicKey, caKey)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	caPEM, clientCert := testClientCert(t)
+	pool, err := clientCAPool(caPEM)
+	assert.NoError(t, err)
+	_, err = clientCAPool([]byte("nope"))
+	assert.Error(t, err)
+
+	r := &Responder{domain: "example.com", pathPrefix: "/", metricsIP: &IPFilter{}, clientCAs: pool}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, nil)}
+	r.EnableTopology()
+	srv := httptest.NewUnstartedServer(r.Handler())
+	srv.TLS = r.mutualTLS(&tls.Config{})
+	srv.StartTLS()
+	defer srv.Close()
+
+	get := func(path string, certs ...tls.Certificate) int {
+		tr := srv.Client().Transport.(*http.Transport).Clone()
+		tr.TLSClientConfig.Certificates = certs
+		resp, err := (&http.Client{Transport: tr}).Post(srv.URL+path, "application/json", nil)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusForbidden, get("/topology"))
+	assert.Equal(t, http.StatusMethodNotAllowed, get("/topology", clientCert))
+	// GitHub has no client certificate - the callback is still reachable
+	assert.Equal(t, http.StatusBadRequest, get(r.CallbackPath()))
+
+	// TLS-ALPN challenges never need one
+	cfg := r.mutualTLS(&tls.Config{})
+	acme, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{acmeTLSProto}})
+	assert.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, acme.ClientAuth)
+	other, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{"h2"}})
+	assert.NoError(t, err)
+	assert.Nil(t, other)
+
+	r.clientCertCallbacks = true
+	assert.Equal(t, tls.RequireAndVerifyClientCert, r.mutualTLS(&tls.Config{}).ClientAuth)
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, r.CallbackPath(), nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestClientCAOptions(t *testing.T) {
+	caPEM, _ := testClientCert(t)
+	o := Options{Repos: []string{"foo/bar"}, Domain: "hooks.example.com", ClientCAs: caPEM}
+	assert.NoError(t, o.Validate())
+	o.ClientCertsOnCallbacks = true
+	assert.NoError(t, o.Validate())
+	o.BehindProxy = true
+	assert.Error(t, o.Validate())
+	o = Options{Repos: []string{"foo/bar"}, Domain: "hooks.example.com", ClientCertsOnCallbacks: true}
+	assert.Error(t, o.Validate())
+	o.ClientCAs = []byte("nope")
+	assert.Error(t, o.Validate())
+}
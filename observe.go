@@ -0,0 +1,23 @@
+package responder
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// observeHooks - Register in observe mode (see Options.Observe), which only
+// checks the domain resolves here, and logs the callback URLs the existing
+// hooks must deliver to. The cleanup function does nothing.
+func (r *Responder) observeHooks(ctx context.Context) (func() error, error) {
+	err := r.dns.check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range r.endpoints {
+		log.Info().
+			Str("webhook_url", ep.callbackURL).
+			Msg("Observe mode - not registering webhooks, serving the existing hook's callback")
+	}
+	return keepHook, nil
+}
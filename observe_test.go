@@ -0,0 +1,53 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveOptions(t *testing.T) {
+	o := Options{Domain: "example.com", Observe: true, HookSecret: "s3cret"}
+	assert.NoError(t, o.Validate())
+	o.CallbackPath = "/webhooks/github"
+	assert.NoError(t, o.Validate())
+	o.CallbackPath = "webhooks"
+	assert.Contains(t, o.Validate().Error(), "invalid callback path")
+
+	o = Options{Domain: "example.com", Observe: true}
+	assert.Contains(t, o.Validate().Error(), "secret")
+	o = Options{Domain: "example.com", Observe: true, HookSecret: "s", AdoptHooks: true}
+	assert.Error(t, o.Validate())
+	o = Options{Domain: "example.com", Observe: true, HookSecret: "s", HookShutdownPolicy: DeactivateHooks}
+	assert.Error(t, o.Validate())
+	o = Options{Domain: "example.com", Observe: true, HookSecret: "s", CallbackPathStrategy: RandomCallbackPaths}
+	assert.Contains(t, o.Validate().Error(), "random")
+}
+
+func TestObserve(t *testing.T) {
+	t.Setenv(ghtokName, "")
+	r, err := NewWithOptions(Options{
+		Domain:       "example.com",
+		Repos:        []string{"a/b"},
+		Observe:      true,
+		HookSecret:   "s3cret",
+		CallbackPath: "/webhooks/github",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/webhooks/github", r.CallbackPath())
+	assert.Equal(t, "s3cret", r.endpoints[0].secret)
+
+	// hooks are left alone
+	r.ghclient = testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+	}).ghclient
+	cleanup, err := r.RegisterE(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, cleanup())
+	_, err = r.PruneStaleHooks(context.Background())
+	assert.Equal(t, ErrObserving, err)
+}
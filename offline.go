@@ -0,0 +1,78 @@
+package responder
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// WatchDeliveries - replay delivery files (*.json) from dir through the actions
+// as they're created or modified, until the context is cancelled. Files already
+// in dir are replayed first. No server is started, and GitHub isn't contacted
+// (unless opts.Client is set and the actions use it), so this is useful for
+// developing handlers offline.
+//
+// The directory is polled every interval (default 1s). Invalid files and
+// failed replays are logged, and don't stop the watch.
+func WatchDeliveries(ctx context.Context, dir string, interval time.Duration, opts ReplayOptions, actions ...HookHandler) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	w := &deliveryWatcher{dir: dir, seen: map[string]time.Time{}}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		paths, err := w.changed()
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			d, err := OpenDelivery(path, opts.Key)
+			if err == nil {
+				err = Replay(ctx, d, opts, actions...)
+			}
+			if err != nil {
+				log.Error().Err(err).Str("path", path).Msg("failed to replay delivery")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type deliveryWatcher struct {
+	dir  string
+	seen map[string]time.Time
+}
+
+// changed returns the delivery files created or modified since the last call,
+// in name order
+func (w *deliveryWatcher) changed() ([]string, error) {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", w.dir)
+	}
+	var paths []string
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(w.dir, f.Name())
+		if mod, ok := w.seen[path]; ok && mod.Equal(f.ModTime()) {
+			continue
+		}
+		w.seen[path] = f.ModTime()
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
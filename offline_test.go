@@ -0,0 +1,42 @@
+package responder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryWatcherChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deliveries")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "b.json")
+	assert.NoError(t, ioutil.WriteFile(a, []byte("{}"), 0600))
+	assert.NoError(t, ioutil.WriteFile(b, []byte("{}"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("hi"), 0600))
+
+	w := &deliveryWatcher{dir: dir, seen: map[string]time.Time{}}
+	paths, err := w.changed()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{a, b}, paths)
+
+	paths, err = w.changed()
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(b, later, later))
+	paths, err = w.changed()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{b}, paths)
+
+	w.dir = filepath.Join(dir, "missing")
+	_, err = w.changed()
+	assert.Error(t, err)
+}
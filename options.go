@@ -0,0 +1,749 @@
+package responder
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// Options - configures a Responder. Use with NewWithOptions.
+type Options struct {
+	// Repos to watch. Required, unless AppSecret or Observe is set. Each may be given as 'owner/repo', a URL
+	// (https://github.com/owner/repo or git@github.com:owner/repo.git), or
+	// a numeric repository ID, which is resolved when registering.
+	Repos []string
+	// Domain to serve webhook callbacks on - a certificate will be acquired
+	// for this domain. Required.
+	Domain string
+	// Domains - more domains to serve webhook callbacks on (certificates are
+	// acquired for these too), e.g. the new domain during a blue/green DNS
+	// migration. Deliveries reach the same handlers on any domain.
+	Domains []string
+	// DomainFor - chooses the domain of each repo's hook callback URL, from
+	// Domain and Domains, given the repo's "owner/name". Returning "" (or a
+	// nil DomainFor) means Domain. With AdoptHooks, existing hooks are moved
+	// to the chosen domain.
+	DomainFor func(repo string) string
+	// BaseURL - the API URL of a GitHub Enterprise Server instance to use
+	// instead of github.com, e.g. "https://github.example.com/api/v3/" (the
+	// "/api/v3/" path is assumed if the URL has none).
+	BaseURL string
+	// UploadURL - the GitHub Enterprise Server upload URL. Defaults to the
+	// "/api/uploads/" path on BaseURL's host.
+	UploadURL string
+	// TokenSource - where the GitHub API token comes from, for embedding the
+	// Responder where tokens come from files (see NewFileTokenSource), a
+	// secrets manager, or rotating credentials. Tokens are reused until they
+	// expire. Defaults to the GITHUB_TOKEN environment variable.
+	TokenSource oauth2.TokenSource
+	// AppSecret - act as the webhook receiver for a GitHub App, validating
+	// deliveries with the App's webhook secret. Set the App's webhook URL to
+	// https://<Domain><PathPrefix>app. No per-repo hooks are created, and
+	// deliveries from every installation are handled. Can't be combined with
+	// Repos (use AddEndpoint to watch repos too). In App mode, GITHUB_TOKEN is
+	// optional.
+	AppSecret string
+	// AppID and AppPrivateKey (PEM-encoded) - authenticate as a GitHub App,
+	// so handlers get a client scoped to the installation each event came
+	// from (see GitHubClient and InstallationClient).
+	AppID         int64
+	AppPrivateKey []byte
+	// AppInstallationID - with AppID and AppPrivateKey, use this
+	// installation's token (refreshed automatically) for the Responder's own
+	// API calls, like registering hooks, instead of GITHUB_TOKEN
+	AppInstallationID int64
+	// RegisterAppWebhook - in App mode (see AppSecret), with AppID and
+	// AppPrivateKey, point the App's webhook at the Responder when
+	// registering, instead of it being set by hand. This replaces the App's
+	// existing webhook URL.
+	RegisterAppWebhook bool
+	// HookSecret - the webhook secret, instead of a random one for each run.
+	// Callback paths are derived from it too, so hooks keep working across
+	// restarts (see AdoptHooks). Endpoints added with AddEndpoint get their
+	// own secrets derived from it.
+	HookSecret string
+	// AdoptHooks - when registering, update each repo's existing hook that
+	// delivers to this Domain and PathPrefix (e.g. from a previous run),
+	// rather than creating a new one, and remove any others. Adopted and
+	// created hooks are left in place by the cleanup function, so they can
+	// be adopted on restart. Best used with HookSecret.
+	AdoptHooks bool
+	// Observe - never create, modify, or delete hooks, only serve the
+	// callback of an existing hook managed elsewhere (e.g. by Terraform),
+	// validating deliveries with HookSecret, which is required. The hook
+	// must deliver to CallbackPath on Domain. GITHUB_TOKEN is optional,
+	// unless handlers need the API.
+	Observe bool
+	// CallbackPath - the URL path hooks deliver to with
+	// FixedCallbackPaths, e.g. "/webhooks/github". In Observe mode, it's
+	// the existing hook's path. Not under PathPrefix.
+	CallbackPath string
+	// CallbackPathStrategy - how callback paths are chosen:
+	// RandomCallbackPaths, StableCallbackPaths, PerRepoCallbackPaths, or
+	// FixedCallbackPaths. Stable paths let hooks from previous runs be
+	// reused. Defaults to FixedCallbackPaths with a CallbackPath,
+	// StableCallbackPaths with a HookSecret, and RandomCallbackPaths
+	// otherwise.
+	CallbackPathStrategy CallbackPathStrategy
+	// HookShutdownPolicy - what the cleanup function does with the hooks:
+	// DeleteHooks, KeepHooks, or DeactivateHooks. Defaults to KeepHooks with
+	// AdoptHooks, and DeleteHooks otherwise.
+	HookShutdownPolicy HookShutdownPolicy
+	// Events to subscribe to - see https://developer.github.com/webhooks/#events.
+	// Defaults to all events ("*").
+	Events []string
+	// HookConfig - extra hook config fields (e.g. "insecure_ssl", or fields
+	// specific to GitHub Enterprise), merged into the hook creation request.
+	// The url, content_type, and secret fields are managed by the Responder
+	// and can't be set here.
+	HookConfig map[string]interface{}
+	// PathPrefix - serve all endpoints (the callback, /metrics) under this
+	// path, for running behind a path-routing ingress. Defaults to "/".
+	PathPrefix string
+	// CallbackIPFilter - restrict which addresses may deliver to callback
+	// endpoints. Defaults to allowing all addresses.
+	CallbackIPFilter *IPFilter
+	// GitHubHookIPs - only accept callbacks from the addresses GitHub
+	// delivers webhooks from, as listed by its /meta API, in addition to
+	// validating signatures. CallbackIPFilter's denied ranges still apply.
+	GitHubHookIPs bool
+	// GitHubHookIPsRefresh - how often GitHub's hook addresses are
+	// refreshed. Defaults to DefaultGitHubHookIPsRefresh.
+	GitHubHookIPsRefresh time.Duration
+	// MetricsIPFilter - restrict which addresses may scrape /metrics.
+	// Defaults to DefaultMetricsIPFilter.
+	MetricsIPFilter *IPFilter
+	// RegisterConcurrency - the maximum number of hooks to create at once,
+	// when registering. Defaults to 4.
+	RegisterConcurrency int
+	// DispatchPings - also pass ping events to actions (and status tracking),
+	// rather than only answering them. Useful for verifying the whole pipeline
+	// with GitHub's "Redeliver" button.
+	DispatchPings bool
+	// APICallsPerHour - the most GitHub API calls all handlers may make
+	// through the provided client (see GitHubClient) per hour, so a buggy
+	// handler can't exhaust the token's rate limit. Calls over budget fail
+	// with an error wrapping ErrAPIBudgetExceeded (in a *url.Error). Only
+	// calls made with the handler's context are counted. 0 (the default)
+	// means no limit.
+	APICallsPerHour int
+	// HandlerAPICallsPerHour - like APICallsPerHour, but for each handler
+	HandlerAPICallsPerHour int
+	// TrustedRelayToken - accept deliveries without a valid GitHub signature
+	// when they carry this token in the X-Responder-Relay-Token header, for
+	// events re-emitted by trusted internal relays. Use a long random value,
+	// and only send it over TLS. Empty (the default) disables relays.
+	TrustedRelayToken string
+	// AdminToken - a secret authorizing admin actions that change state (see
+	// EnableRedeliveryAdmin), sent as "Authorization: Bearer <token>". Not
+	// needed when client certificates are verified (see ClientCAs).
+	AdminToken string
+	// DeliveryBudget - how long a delivery's handlers may run before their
+	// context is cancelled, and the delivery counted as timed out. 0 (the
+	// default) means no limit.
+	DeliveryBudget time.Duration
+	// HydrateTruncatedPayloads - when GitHub leaves commits out of a push
+	// payload because there are too many, fetch them from the API before
+	// running handlers. See DeliveryInfo.Truncated.
+	HydrateTruncatedPayloads bool
+	// MaintenanceWindows - periods during which deliveries are queued rather
+	// than handled, and handled once the window closes. See SetMaintenance.
+	MaintenanceWindows []MaintenanceWindow
+	// ExpectedAddresses - the IP addresses or hostnames (e.g. of a load
+	// balancer) Domain should resolve to. Before hooks are registered, a
+	// warning is logged if it doesn't. Defaults to this host's addresses.
+	ExpectedAddresses []string
+	// StrictDNS - fail registration with ErrDNSMismatch, rather than warn,
+	// when Domain doesn't resolve to the expected addresses
+	StrictDNS bool
+	// Clock - the source of the current time for the Responder's timestamps,
+	// schedules and expiries, e.g. for deterministic tests. Defaults to
+	// time.Now.
+	Clock func() time.Time
+	// Random - the source of randomness for hook secrets, callback paths
+	// and IDs. Defaults to crypto/rand.
+	Random io.Reader
+	// Backfill - once listening, ask GitHub to redeliver deliveries the
+	// watched repos' hooks failed to deliver since the last run. Only useful
+	// with AdoptHooks. See Responder.Backfill.
+	Backfill bool
+	// SkipPermissionCheck - don't check, before registering hooks, that the
+	// token has the admin:repo_hook scope (or the App installation the
+	// repository webhooks permission)
+	SkipPermissionCheck bool
+	// DedupTTL - ignore deliveries whose delivery ID was already seen within
+	// this long, so handlers see each delivery once, even when GitHub
+	// redelivers it. Seen IDs are kept in the state store (see
+	// SetStateStore), so survive restarts when it's persistent. In
+	// synchronous mode, failed deliveries are forgotten, so they can be
+	// redelivered. 0 (the default) disables deduplication.
+	DedupTTL time.Duration
+	// RedeliveryDiffWindow - keep received payloads this long (in the state
+	// store, if set), and when a delivery with the same ID arrives again,
+	// log how its payload differs from the original - to diagnose payloads
+	// changed by GitHub, or tampered with. Diffs are kept too (see
+	// RedeliveryDiff). 0 (the default) disables diffing.
+	RedeliveryDiffWindow time.Duration
+	// Compression - compress deliveries kept in the write-ahead log (see
+	// EnableWriteAheadLog), the queue snapshot (see EnableQueueSnapshot), and
+	// files written by RecordDeliveries. Compressed data is recognized and
+	// decompressed when read, whatever this is set to, so it can be changed
+	// freely. Defaults to no compression.
+	Compression Compression
+	// MaxConcurrency - run at most this many handlers at once, queueing the
+	// rest. Deliveries that don't fit in the queue are rejected with 503, so
+	// they can be redelivered. 0 (the default) means no limit.
+	MaxConcurrency int
+	// MaxQueuedHandlers - with MaxConcurrency, how many handler runs may wait
+	// for a worker. Defaults to 10 per worker.
+	MaxQueuedHandlers int
+	// MaxInFlightDeliveries - accept at most this many deliveries whose
+	// handlers haven't all finished, rejecting more with 503, so a flood of
+	// events can't exhaust memory. 0 (the default) means no limit.
+	MaxInFlightDeliveries int
+	// MaxPayloadSize - reject deliveries with payloads larger than this many
+	// bytes with 413, without reading them into memory. Defaults to
+	// DefaultMaxPayloadSize. Accepted payloads are read into memory in full
+	// before any handler sees them, as their signatures must be validated
+	// (and they must be routed) first - except on streaming endpoints (see
+	// AddStreamingEndpoint), which spool them to temporary files instead.
+	MaxPayloadSize int64
+	// CallbackRateLimit - accept at most this many callback requests per
+	// second, rejecting more with 429 and a Retry-After header. 0 (the
+	// default) means no limit.
+	CallbackRateLimit float64
+	// CallbackRateLimitPerIP - like CallbackRateLimit, but for each client
+	// address (or /64, for IPv6 addresses)
+	CallbackRateLimitPerIP float64
+	// CallbackRateBurst - how many requests beyond the rate limits may be
+	// accepted at once. Defaults to a second's worth.
+	CallbackRateBurst int
+	// Synchronous - wait for a delivery's handlers before replying to GitHub,
+	// replying 500 (or 422, see Unprocessable) when any fail, so failed
+	// deliveries show as failed in the hook's delivery log, and can be
+	// redelivered from there. Handlers fail with ReportFailure (see
+	// Reporting), or by panicking. GitHub gives up on replies after 10
+	// seconds, so handlers must be quick - consider a DeliveryBudget.
+	Synchronous bool
+	// Proxy - send outbound traffic (GitHub API and ACME requests) through
+	// this http(s) or socks5 proxy URL. By default the HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY environment variables are honoured.
+	Proxy string
+	// DNSProvider - obtain certificates with ACME DNS-01 challenges, solved
+	// by creating TXT records with this provider, rather than HTTP-01 and
+	// TLS-ALPN-01 challenges, which need the ACME CA to reach ports 80 or
+	// 443. For hosts behind NAT, or with port 80 blocked - the HTTP port
+	// isn't served. See NewCloudflareDNSProvider, NewRoute53DNSProvider, and
+	// NewGoogleCloudDNSProvider.
+	DNSProvider DNSProvider
+	// CertStoragePath - keep certificates (and ACME account keys) in this
+	// directory, rather than certmagic's default in the home directory -
+	// e.g. on a volume that survives container restarts
+	CertStoragePath string
+	// CertStorage - keep certificates in this storage, so replicas can share
+	// them. See NewRedisCertStorage, NewDynamoDBCertStorage, and
+	// NewKubernetesCertStorage.
+	CertStorage certmagic.Storage
+	// ClientCAs - PEM-encoded CA certificates to verify client certificates
+	// against (mutual TLS). The metrics, stats, delivery status, and admin
+	// endpoints then require a client certificate signed by one of them.
+	// GitHub has no client certificate, so callbacks don't require one,
+	// unless ClientCertsOnCallbacks is set. Can't be used BehindProxy.
+	ClientCAs []byte
+	// ClientCertsOnCallbacks - with ClientCAs, require client certificates
+	// on callbacks too, e.g. when deliveries are relayed (see
+	// TrustedRelayToken) rather than sent by GitHub
+	ClientCertsOnCallbacks bool
+	// ACMECA - the ACME CA to obtain certificates from: LetsEncryptCA (the
+	// default), LetsEncryptStagingCA to test without burning production rate
+	// limits, ZeroSSLCA, or another CA's https:// directory URL
+	ACMECA string
+	// ACMEEmail - the ACME account's email address, which the CA may send
+	// expiry and policy notices to
+	ACMEEmail string
+	// ACMEEABKeyID and ACMEEABHMACKey - External Account Binding keys (the
+	// HMAC key base64url-encoded), for CAs like ZeroSSL that only issue to
+	// accounts linked to an account of theirs. The ACME account is
+	// registered with them, agreeing to the CA's terms, before the first
+	// certificate is obtained.
+	ACMEEABKeyID   string
+	ACMEEABHMACKey string
+	// CrashReportDir - when set, a handler panic writes a CrashReport here,
+	// and a panic that would crash the process first removes the registered
+	// hooks, as best it can
+	CrashReportDir string
+	// CertExpiryWarning - warn (and run OnCertExpiring hooks) when the serving
+	// certificate expires within this long. Defaults to 14 days.
+	CertExpiryWarning time.Duration
+	// ShutdownSignals - signals that make RegisterAndListen shut down
+	// gracefully. Defaults to SIGINT and SIGTERM.
+	ShutdownSignals []os.Signal
+	// DrainTimeout - on shutdown, how long to wait for in-flight requests
+	// and handlers to finish, before giving up on them. Defaults to 30
+	// seconds.
+	DrainTimeout time.Duration
+	// HTTPPort to listen on for HTTP traffic (ACME challenges, or callbacks
+	// when TLS is disabled). Defaults to 80.
+	HTTPPort int
+	// HTTPSPort to listen on for HTTPS traffic. Defaults to 443.
+	HTTPSPort int
+	// Addr - the address to serve callbacks on, in host:port form (e.g.
+	// "127.0.0.1:8443", to only accept connections from a local proxy),
+	// instead of all interfaces on HTTPSPort (or HTTPPort when TLS is
+	// disabled). ACME challenges are still answered on HTTPPort.
+	Addr string
+	// BehindProxy - run behind a load balancer or ingress that terminates
+	// TLS: callbacks are served over plain HTTP (on Addr, or HTTPPort), but
+	// hooks still deliver to https://<Domain>. The client's address is taken
+	// from X-Forwarded-For for logging and IP filtering, and the scheme it
+	// used from X-Forwarded-Proto, when the request comes from one of the
+	// TrustedProxies.
+	BehindProxy bool
+	// TrustedProxies - the CIDRs of the proxies whose forwarding headers are
+	// trusted, with BehindProxy. Defaults to DefaultTrustedProxies.
+	TrustedProxies []string
+	// Listener - serve callbacks on this listener (e.g. a socket passed by
+	// systemd socket activation) instead of listening on Addr. With TLS,
+	// connections are wrapped in TLS by the Responder. Closed on shutdown.
+	Listener net.Listener
+}
+
+// DefaultShutdownSignals - the signals RegisterAndListen shuts down on, when
+// none are given
+var DefaultShutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// DefaultEvents - the events subscribed to when none are given
+var DefaultEvents = []string{"*"}
+
+const (
+	defaultHTTPPort  = 80
+	defaultHTTPSPort = 443
+
+	defaultRegisterConcurrency = 4
+
+	defaultCertExpiryWarning = 14 * 24 * time.Hour
+
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// reservedHookConfig - hook config fields set by the Responder itself
+var reservedHookConfig = map[string]bool{"url": true, "content_type": true, "secret": true}
+
+var hostLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// withDefaults returns a copy of the options, with defaults filled in
+func (o Options) withDefaults() Options {
+	if len(o.Events) == 0 {
+		o.Events = DefaultEvents
+	}
+	if o.PathPrefix == "" {
+		o.PathPrefix = "/"
+	}
+	// normalize to a leading and trailing slash
+	o.PathPrefix = "/" + strings.Trim(o.PathPrefix, "/") + "/"
+	if o.PathPrefix == "//" {
+		o.PathPrefix = "/"
+	}
+	if o.BaseURL != "" {
+		if u, err := url.Parse(o.BaseURL); err == nil {
+			if strings.Trim(u.Path, "/") == "" {
+				u.Path = "/api/v3/"
+				o.BaseURL = u.String()
+			}
+			if o.UploadURL == "" {
+				u.Path = "/api/uploads/"
+				o.UploadURL = u.String()
+			}
+		}
+	}
+	if o.MetricsIPFilter == nil {
+		o.MetricsIPFilter = DefaultMetricsIPFilter
+	}
+	if o.MaxPayloadSize == 0 {
+		o.MaxPayloadSize = DefaultMaxPayloadSize
+	}
+	if o.GitHubHookIPsRefresh == 0 {
+		o.GitHubHookIPsRefresh = DefaultGitHubHookIPsRefresh
+	}
+	if len(o.ShutdownSignals) == 0 {
+		o.ShutdownSignals = DefaultShutdownSignals
+	}
+	if o.RegisterConcurrency == 0 {
+		o.RegisterConcurrency = defaultRegisterConcurrency
+	}
+	if o.CertExpiryWarning == 0 {
+		o.CertExpiryWarning = defaultCertExpiryWarning
+	}
+	if o.HTTPPort == 0 {
+		o.HTTPPort = defaultHTTPPort
+	}
+	if o.HookShutdownPolicy == DeactivateHooks {
+		o.AdoptHooks = true
+	}
+	if o.HookShutdownPolicy == "" {
+		o.HookShutdownPolicy = DeleteHooks
+		if o.AdoptHooks {
+			o.HookShutdownPolicy = KeepHooks
+		}
+	}
+	if o.CallbackPathStrategy == "" {
+		switch {
+		case o.CallbackPath != "":
+			o.CallbackPathStrategy = FixedCallbackPaths
+		case o.HookSecret != "":
+			o.CallbackPathStrategy = StableCallbackPaths
+		default:
+			o.CallbackPathStrategy = RandomCallbackPaths
+		}
+	}
+	if o.BehindProxy && len(o.TrustedProxies) == 0 {
+		o.TrustedProxies = DefaultTrustedProxies
+	}
+	if o.DrainTimeout == 0 {
+		o.DrainTimeout = defaultDrainTimeout
+	}
+	if o.HTTPSPort == 0 {
+		o.HTTPSPort = defaultHTTPSPort
+	}
+	return o
+}
+
+// Validate - check the options (with defaults applied) for errors. The first
+// problem found is returned.
+func (o Options) Validate() error {
+	o = o.withDefaults()
+
+	switch {
+	case o.AppSecret != "" && len(o.Repos) > 0:
+		return errors.New("repos can't be watched in GitHub App mode - GitHub sends deliveries for all of the App's installations")
+	case o.AppSecret == "" && !o.Observe && len(o.Repos) == 0:
+		return errors.New("must provide at least one repo")
+	case o.CertStorage != nil && o.CertStoragePath != "":
+		return errors.New("certificate storage and a certificate storage path can't both be given")
+	}
+
+	if err := o.validateObserve(); err != nil {
+		return err
+	}
+	if err := o.validateCallbackPath(); err != nil {
+		return err
+	}
+	if err := o.validateACME(); err != nil {
+		return err
+	}
+	if err := o.validateClientCAs(); err != nil {
+		return err
+	}
+
+	for _, r := range o.Repos {
+		_, err := parseRepo(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := validateDomain(o.Domain)
+	for _, d := range o.Domains {
+		if err == nil {
+			err = validateDomain(d)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range o.Events {
+		err = validateEvent(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(o.TrustedProxies) > 0 {
+		if !o.BehindProxy {
+			return errors.New("trusted proxies can only be given behind a proxy")
+		}
+		if _, err := parseCIDRs(o.TrustedProxies); err != nil {
+			return err
+		}
+	}
+
+	if o.Addr != "" {
+		if o.Listener != nil {
+			return errors.New("only one of a listen address and a listener can be given")
+		}
+		if _, _, err := net.SplitHostPort(o.Addr); err != nil {
+			return errors.Wrapf(err, "invalid listen address %q", o.Addr)
+		}
+	}
+
+	err = o.HookShutdownPolicy.validate()
+	if err != nil {
+		return err
+	}
+
+	if o.Proxy != "" {
+		if _, err := parseProxy(o.Proxy); err != nil {
+			return err
+		}
+	}
+
+	if o.UploadURL != "" && o.BaseURL == "" {
+		return errors.New("a GitHub Enterprise upload URL needs a base URL too")
+	}
+	for _, u := range []struct{ name, url string }{{"base", o.BaseURL}, {"upload", o.UploadURL}} {
+		if u.url == "" {
+			continue
+		}
+		parsed, err := url.Parse(u.url)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return errors.Errorf("invalid GitHub Enterprise %s URL %q - must be an absolute http(s) URL", u.name, u.url)
+		}
+	}
+
+	if u, err := url.Parse(o.PathPrefix); err != nil || u.EscapedPath() != o.PathPrefix || strings.Contains(o.PathPrefix, "//") {
+		return errors.Errorf("invalid path prefix %q - must be a plain URL path like '/hooks/'", o.PathPrefix)
+	}
+
+	for k := range o.HookConfig {
+		if reservedHookConfig[k] {
+			return errors.Errorf("hook config field %q can't be overridden - it's managed by the responder", k)
+		}
+	}
+
+	if (o.AppID == 0) != (len(o.AppPrivateKey) == 0) {
+		return errors.New("GitHub App authentication needs both an App ID and a private key")
+	}
+	if o.AppInstallationID != 0 && o.AppID == 0 {
+		return errors.New("a GitHub App installation ID needs an App ID and private key")
+	}
+	if o.AppInstallationID != 0 && o.TokenSource != nil {
+		return errors.New("a token source can't be combined with a GitHub App installation ID - the installation's token is used")
+	}
+	if o.RegisterAppWebhook && (o.AppID == 0 || o.AppSecret == "") {
+		return errors.New("registering the GitHub App's webhook needs an App ID, private key, and webhook secret")
+	}
+	if len(o.AppPrivateKey) > 0 {
+		if _, err := parsePrivateKey(o.AppPrivateKey); err != nil {
+			return err
+		}
+	}
+
+	if o.DeliveryBudget < 0 {
+		return errors.Errorf("invalid delivery budget %s - must be positive", o.DeliveryBudget)
+	}
+	if o.DrainTimeout < 0 {
+		return errors.Errorf("invalid drain timeout %s - must be positive", o.DrainTimeout)
+	}
+	if o.DedupTTL < 0 {
+		return errors.Errorf("invalid dedup TTL %s - must be positive", o.DedupTTL)
+	}
+	if o.GitHubHookIPsRefresh < 0 {
+		return errors.Errorf("invalid GitHub hook address refresh interval %s - must be positive", o.GitHubHookIPsRefresh)
+	}
+	if o.RedeliveryDiffWindow < 0 {
+		return errors.Errorf("invalid redelivery diff window %s - must be positive", o.RedeliveryDiffWindow)
+	}
+	if o.MaxConcurrency < 0 || o.MaxQueuedHandlers < 0 {
+		return errors.New("invalid handler concurrency - must be positive")
+	}
+	if o.MaxPayloadSize < 0 {
+		return errors.Errorf("invalid maximum payload size %d - must be positive", o.MaxPayloadSize)
+	}
+	if o.MaxInFlightDeliveries < 0 {
+		return errors.New("invalid maximum in-flight deliveries - must be positive")
+	}
+	if o.CallbackRateLimit < 0 || o.CallbackRateLimitPerIP < 0 || o.CallbackRateBurst < 0 {
+		return errors.New("invalid callback rate limit - must be positive")
+	}
+	if o.APICallsPerHour < 0 || o.HandlerAPICallsPerHour < 0 {
+		return errors.New("invalid API call budget - must be positive")
+	}
+	for _, w := range o.MaintenanceWindows {
+		err = w.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if o.CertExpiryWarning < 0 {
+		return errors.Errorf("invalid certificate expiry warning %s - must be positive", o.CertExpiryWarning)
+	}
+	if o.RegisterConcurrency < 0 {
+		return errors.Errorf("invalid register concurrency %d - must be positive", o.RegisterConcurrency)
+	}
+
+	err = validatePort("HTTP", o.HTTPPort)
+	if err != nil {
+		return err
+	}
+	err = validatePort("HTTPS", o.HTTPSPort)
+	if err != nil {
+		return err
+	}
+	if o.HTTPPort == o.HTTPSPort {
+		return errors.Errorf("HTTP and HTTPS ports must differ (both are %d)", o.HTTPPort)
+	}
+	return nil
+}
+
+func validatePort(name string, port int) error {
+	if port < 1 || port > 65535 {
+		return errors.Errorf("invalid %s port %d - must be between 1 and 65535", name, port)
+	}
+	return nil
+}
+
+// parseRepo normalizes the supported repo identifier forms
+func parseRepo(r string) (repository, error) {
+	invalid := newCauseError(ErrInvalidRepo, "invalid repo %s - need 'owner/repo' form, a repo URL, or a numeric ID", r)
+
+	if id, err := strconv.ParseInt(r, 10, 64); err == nil {
+		if id <= 0 {
+			return repository{}, invalid
+		}
+		return repository{id: id}, nil
+	}
+
+	s := r
+	switch {
+	case strings.HasPrefix(s, "git@"):
+		// scp-like syntax - git@github.com:owner/repo.git
+		i := strings.Index(s, ":")
+		if i < 0 {
+			return repository{}, invalid
+		}
+		s = s[i+1:]
+	case strings.Contains(s, "://"):
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return repository{}, invalid
+		}
+		s = u.Path
+	}
+	s = strings.TrimSuffix(strings.Trim(s, "/"), ".git")
+
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return repository{}, invalid
+	}
+	return repository{owner: parts[0], name: parts[1]}, nil
+}
+
+// validateDomain checks that the domain is a valid hostname or IP address,
+// optionally with a port
+func validateDomain(domain string) error {
+	if domain == "" {
+		return errors.New("must provide a domain")
+	}
+	host := domain
+	if h, port, err := net.SplitHostPort(domain); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			return errors.Errorf("invalid domain %s - bad port %s", domain, port)
+		}
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return errors.Errorf("invalid domain %s - too long", domain)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if !hostLabel.MatchString(label) {
+			return errors.Errorf("invalid domain %s - must be a hostname like 'hooks.example.com'", domain)
+		}
+	}
+	return nil
+}
+
+// newerEvents - events GitHub delivers that the vendored go-github doesn't
+// know about (deliveries of these reach handlers, but can't be parsed with
+// github.ParseWebHook)
+var newerEvents = map[string]bool{
+	"branch_protection_configuration": true,
+	"branch_protection_rule":          true,
+	"code_scanning_alert":             true,
+	"custom_property":                 true,
+	"custom_property_values":          true,
+	"dependabot_alert":                true,
+	"deploy_key":                      true,
+	"deployment_protection_rule":      true,
+	"deployment_review":               true,
+	"discussion":                      true,
+	"discussion_comment":              true,
+	"github_app_authorization":        true,
+	"installation_target":             true,
+	"merge_group":                     true,
+	"meta":                            true,
+	"package":                         true,
+	"personal_access_token_request":   true,
+	"projects_v2":                     true,
+	"projects_v2_item":                true,
+	"pull_request_review_thread":      true,
+	"registry_package":                true,
+	"repository_advisory":             true,
+	"repository_dispatch":             true,
+	"repository_import":               true,
+	"repository_ruleset":              true,
+	"secret_scanning_alert":           true,
+	"secret_scanning_alert_location":  true,
+	"security_advisory":               true,
+	"security_and_analysis":           true,
+	"sponsorship":                     true,
+	"star":                            true,
+	"workflow_dispatch":               true,
+	"workflow_job":                    true,
+	"workflow_run":                    true,
+}
+
+// validateEvent checks that an event is given. Whether it's one GitHub
+// delivers is left to GitHub - see knownEvent.
+func validateEvent(event string) error {
+	if strings.TrimSpace(event) == "" {
+		return errors.New("empty event name - see https://docs.github.com/webhooks/webhook-events-and-payloads for valid events")
+	}
+	return nil
+}
+
+// knownEvent - whether the event is "*", or one known to go-github or listed
+// in newerEvents. Unknown events are only warned about, as GitHub adds new
+// ones faster than this list is updated.
+func knownEvent(event string) bool {
+	return event == "*" || newerEvents[event] || parsableEvent(event)
+}
+
+// parsableEvent - whether go-github can parse the event's payloads
+func parsableEvent(event string) bool {
+	_, err := github.ParseWebHook(event, []byte("{}"))
+	return err == nil
+}
+
+// validateObserve checks Observe mode's options
+func (o Options) validateObserve() error {
+	if !o.Observe {
+		return nil
+	}
+	switch {
+	case o.HookSecret == "":
+		return errors.New("observe mode needs the existing hook's secret")
+	case o.AppSecret != "" || o.RegisterAppWebhook:
+		return errors.New("observe mode can't be combined with GitHub App mode")
+	case o.AdoptHooks:
+		return errors.New("hooks can't be adopted or deactivated in observe mode")
+	case o.CallbackPathStrategy == RandomCallbackPaths:
+		return errors.New("observe mode needs the existing hook's callback path - random paths can't be observed")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package responder
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	valid := Options{Repos: []string{"foo/bar"}, Domain: "hooks.example.com"}
+	assert.NoError(t, valid.Validate())
+
+	o := valid.withDefaults()
+	assert.Equal(t, []string{"*"}, o.Events)
+	assert.Equal(t, 80, o.HTTPPort)
+	assert.Equal(t, 443, o.HTTPSPort)
+	assert.Equal(t, "/", o.PathPrefix)
+
+	for in, expected := range map[string]string{"hooks": "/hooks/", "/hooks": "/hooks/", "/a/b/": "/a/b/", "/": "/"} {
+		o = valid
+		o.PathPrefix = in
+		assert.Equal(t, expected, o.withDefaults().PathPrefix)
+		assert.NoError(t, o.Validate())
+	}
+	for _, in := range []string{"a//b", "hooks?x=1", "a b"} {
+		o = valid
+		o.PathPrefix = in
+		assert.Error(t, o.Validate(), in)
+	}
+
+	o = valid
+	o.Repos = nil
+	assert.EqualError(t, o.Validate(), "must provide at least one repo")
+
+	o.AppSecret = "secret"
+	assert.NoError(t, o.Validate())
+
+	o = valid
+	o.AppSecret = "secret"
+	assert.Contains(t, o.Validate().Error(), "GitHub App mode")
+
+	o = valid
+	o.Repos = []string{"foo/"}
+	assert.Equal(t, ErrInvalidRepo, errors.Cause(o.Validate()))
+
+	o = valid
+	o.Events = []string{"push", "pull_request", "*"}
+	assert.NoError(t, o.Validate())
+	// events newer than go-github's are accepted, unknown ones only warned
+	// about
+	o.Events = []string{"workflow_run", "pullrequest"}
+	assert.NoError(t, o.Validate())
+	o.Events = []string{""}
+	assert.Error(t, o.Validate())
+	assert.True(t, knownEvent("workflow_run"))
+	assert.True(t, knownEvent("push"))
+	assert.False(t, knownEvent("pullrequest"))
+
+	o = valid
+	o.HookConfig = map[string]interface{}{"insecure_ssl": "1"}
+	assert.NoError(t, o.Validate())
+	o.HookConfig["secret"] = "foo"
+	assert.Contains(t, o.Validate().Error(), `hook config field "secret"`)
+
+	o = valid
+	o.AppInstallationID = 7
+	assert.Contains(t, o.Validate().Error(), "installation ID needs an App ID")
+	o = valid
+	o.Repos = nil
+	o.AppSecret = "secret"
+	o.RegisterAppWebhook = true
+	assert.Contains(t, o.Validate().Error(), "registering the GitHub App's webhook")
+
+	o = valid
+	o.BaseURL = "https://github.example.com"
+	assert.NoError(t, o.Validate())
+	assert.Equal(t, "https://github.example.com/api/v3/", o.withDefaults().BaseURL)
+	assert.Equal(t, "https://github.example.com/api/uploads/", o.withDefaults().UploadURL)
+	o.BaseURL = "https://github.example.com/custom/"
+	o.UploadURL = "https://uploads.example.com/"
+	assert.Equal(t, "https://github.example.com/custom/", o.withDefaults().BaseURL)
+	assert.Equal(t, "https://uploads.example.com/", o.withDefaults().UploadURL)
+	o.BaseURL = "github.example.com"
+	assert.Contains(t, o.Validate().Error(), "invalid GitHub Enterprise base URL")
+	o.BaseURL = ""
+	assert.Contains(t, o.Validate().Error(), "needs a base URL")
+
+	o = valid
+	o.Domains = []string{"green.example.com"}
+	assert.NoError(t, o.Validate())
+	o.Domains = []string{"not a domain"}
+	assert.Error(t, o.Validate())
+
+	o = valid
+	o.Proxy = "socks5://proxy.example.com:1080"
+	assert.NoError(t, o.Validate())
+	o.Proxy = "proxy.example.com:3128"
+	assert.Contains(t, o.Validate().Error(), "invalid proxy URL")
+
+	o = valid
+	o.HTTPSPort = 70000
+	assert.EqualError(t, o.Validate(), "invalid HTTPS port 70000 - must be between 1 and 65535")
+	o.HTTPSPort = 80
+	assert.Contains(t, o.Validate().Error(), "must differ")
+
+	o = valid
+	o.Addr = "127.0.0.1:8443"
+	assert.NoError(t, o.Validate())
+	o.Addr = "8443"
+	assert.Contains(t, o.Validate().Error(), "invalid listen address")
+	o.Addr = ":8443"
+	o.Listener = &net.TCPListener{}
+	assert.Contains(t, o.Validate().Error(), "only one of")
+}
+
+func TestValidateDomain(t *testing.T) {
+	for _, d := range []string{"example.com", "example.com.", "localhost", "localhost:8080", "10.0.0.1", "[::1]:443", "a-b.c"} {
+		assert.NoError(t, validateDomain(d), d)
+	}
+	for _, d := range []string{"", "https://example.com", "example.com/path", "-bad.com", "bad-.com", "a..b", "example.com:0", "example.com:http", "under_score.com"} {
+		assert.Error(t, validateDomain(d), d)
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	testdata := map[string]repository{
+		"foo/bar":                          {owner: "foo", name: "bar"},
+		"https://github.com/foo/bar":       {owner: "foo", name: "bar"},
+		"https://github.com/foo/bar/":      {owner: "foo", name: "bar"},
+		"https://github.com/foo/bar.git":   {owner: "foo", name: "bar"},
+		"http://ghe.example.com/foo/bar":   {owner: "foo", name: "bar"},
+		"ssh://git@github.com/foo/bar.git": {owner: "foo", name: "bar"},
+		"git@github.com:foo/bar.git":       {owner: "foo", name: "bar"},
+		"git@github.com:foo/bar":           {owner: "foo", name: "bar"},
+		"12345":                            {id: 12345},
+	}
+	for in, expected := range testdata {
+		actual, err := parseRepo(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, expected, actual, in)
+	}
+
+	for _, in := range []string{"foo", "foo/bar/baz", "/bar", "https://github.com/foo", "git@github.com", "-1", "0"} {
+		_, err := parseRepo(in)
+		assert.Equal(t, ErrInvalidRepo, errors.Cause(err), in)
+	}
+}
+
+func TestNewWithOptionsEnterprise(t *testing.T) {
+	t.Setenv(ghtokName, "foo")
+	r, err := NewWithOptions(Options{
+		Repos:   []string{"foo/bar"},
+		Domain:  "hooks.example.com",
+		BaseURL: "https://github.example.com",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.example.com/api/v3/", r.ghclient.BaseURL.String())
+	assert.Equal(t, "https://github.example.com/api/uploads/", r.ghclient.UploadURL.String())
+}
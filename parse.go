@@ -0,0 +1,63 @@
+package responder
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+)
+
+// ParsedDelivery - a validated and parsed webhook delivery
+type ParsedDelivery struct {
+	// EventType - from the X-GitHub-Event header
+	EventType string
+	// DeliveryID - from the X-GitHub-Delivery header
+	DeliveryID string
+	// SignatureAlgorithm - the hash algorithm of the validated signature
+	// (e.g. "sha1")
+	SignatureAlgorithm string
+	// Event - the parsed event, e.g. *github.PushEvent. Nil for event types
+	// unknown to go-github.
+	Event interface{}
+	// Payload - the raw JSON payload
+	Payload []byte
+}
+
+// ValidateAndParse - validate a webhook request's signature against the secret,
+// and parse its payload. This is the validation the Responder does for each
+// delivery, for use in servers that don't embed a Responder. The body isn't
+// limited in size - wrap it with http.MaxBytesReader first to limit it.
+//
+// Signature failures are caused by ErrSignatureInvalid (see errors.Cause).
+// Event types unknown to go-github aren't an error - Event is left nil.
+func ValidateAndParse(req *http.Request, secret []byte) (*ParsedDelivery, error) {
+	payload, err := readPayload(req, secret, true)
+	if err != nil {
+		return nil, signatureError(err)
+	}
+
+	d := &ParsedDelivery{
+		EventType:          github.WebHookType(req),
+		DeliveryID:         github.DeliveryID(req),
+		SignatureAlgorithm: signatureAlgorithm(req.Header.Get("X-Hub-Signature")),
+		Payload:            payload,
+	}
+	if !parsableEvent(d.EventType) {
+		return d, nil
+	}
+	d.Event, err = github.ParseWebHook(d.EventType, payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s payload", d.EventType)
+	}
+	return d, nil
+}
+
+// signatureAlgorithm - the algorithm prefix of a signature like "sha1=..."
+func signatureAlgorithm(sig string) string {
+	i := strings.Index(sig, "=")
+	if i < 0 {
+		return ""
+	}
+	return sig[:i]
+}
@@ -0,0 +1,61 @@
+package responder
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAndParse(t *testing.T) {
+	secret := []byte("secret")
+	payload := []byte(`{"ref":"refs/heads/master"}`)
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-GitHub-Delivery", "abc")
+	req.Header.Set("X-Hub-Signature", sign(payload, secret))
+
+	d, err := ValidateAndParse(req, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "push", d.EventType)
+	assert.Equal(t, "abc", d.DeliveryID)
+	assert.Equal(t, "sha1", d.SignatureAlgorithm)
+	assert.Equal(t, payload, d.Payload)
+	assert.Equal(t, "refs/heads/master", d.Event.(*github.PushEvent).GetRef())
+
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "some_future_event")
+	req.Header.Set("X-Hub-Signature", sign(payload, secret))
+	d, err = ValidateAndParse(req, secret)
+	assert.NoError(t, err)
+	assert.Nil(t, d.Event)
+	assert.Equal(t, payload, d.Payload)
+
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature", sign(payload, []byte("wrong")))
+	_, err = ValidateAndParse(req, secret)
+	assert.Equal(t, ErrSignatureInvalid, errors.Cause(err))
+
+	// form-encoded payloads are signed as sent
+	form := []byte("payload=" + url.QueryEscape(string(payload)))
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature", sign(form, secret))
+	d, err = ValidateAndParse(req, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, d.Payload)
+
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	_, err = ValidateAndParse(req, secret)
+	assert.Equal(t, ErrSignatureInvalid, errors.Cause(err))
+}
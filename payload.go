@@ -0,0 +1,113 @@
+package responder
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" // nolint: gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxPayloadSize - the largest payload accepted when
+	// Options.MaxPayloadSize isn't given: 25MB, GitHub's own cap
+	DefaultMaxPayloadSize = 25 << 20
+	// signatureHeader - the header GitHub sends the payload's HMAC in
+	signatureHeader = "X-Hub-Signature"
+)
+
+// errPayloadTooLarge - the payload is larger than the limit
+var errPayloadTooLarge = errors.New("payload too large")
+
+// limitedBody - a request body limited with http.MaxBytesReader, noting
+// whether the limit was exceeded
+type limitedBody struct {
+	io.ReadCloser
+	n, max   int64
+	declared int64
+	tooLarge bool
+}
+
+// limitPayload - limit the request's body to max bytes (when max is
+// positive), so larger payloads aren't read into memory. Bodies declared
+// larger by their Content-Length header fail without being read.
+func limitPayload(resp http.ResponseWriter, req *http.Request, max int64) *limitedBody {
+	b := &limitedBody{ReadCloser: req.Body, max: max, declared: req.ContentLength}
+	if max > 0 {
+		b.ReadCloser = http.MaxBytesReader(resp, req.Body, max)
+	}
+	req.Body = b
+	return b
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.max > 0 && b.declared > b.max {
+		b.tooLarge = true
+		return 0, errPayloadTooLarge
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF && b.max > 0 && b.n >= b.max {
+		b.tooLarge = true
+	}
+	return n, err
+}
+
+// exceeded - whether reading the payload failed, as it's larger than the
+// limit
+func (b *limitedBody) exceeded() bool {
+	return b.tooLarge
+}
+
+// readBody reads the request's body in one pass, into a buffer sized by
+// the Content-Length header (when it's plausible), writing it to w as it's
+// read, when given
+func readBody(req *http.Request, w io.Writer) ([]byte, error) {
+	size := int64(bytes.MinRead)
+	if req.ContentLength > 0 && req.ContentLength <= DefaultMaxPayloadSize {
+		size += req.ContentLength
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	var r io.Reader = req.Body
+	if w != nil {
+		r = io.TeeReader(r, w)
+	}
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
+
+// signatureMAC - the HMAC in the signature header, and a hash to compute
+// the payload's with, as it's read. Errors are worded as go-github's
+// ValidateSignature's are.
+func signatureMAC(signature string, secret []byte) ([]byte, hash.Hash, error) {
+	if signature == "" {
+		return nil, nil, errors.New("missing signature")
+	}
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 {
+		return nil, nil, errors.Errorf("error parsing signature %q", signature)
+	}
+	var h func() hash.Hash
+	switch parts[0] {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha512":
+		h = sha512.New
+	default:
+		return nil, nil, errors.Errorf("unknown hash type prefix: %q", parts[0])
+	}
+	want, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, errors.Errorf("error decoding signature %q: %v", signature, err)
+	}
+	return want, hmac.New(h, secret), nil
+}
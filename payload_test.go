@@ -0,0 +1,54 @@
+package responder
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxPayloadSize(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/", maxPayload: 64}
+	ep := r.newEndpoint(nil, nil)
+	serve := func(payload []byte, contentLength int64) int {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+		req.ContentLength = contentLength
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "ping")
+		req.Header.Set("X-Hub-Signature", sign(payload, []byte(ep.secret)))
+		rec := httptest.NewRecorder()
+		ep.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	small := []byte(`{"zen":"hi","hook_id":1}`)
+	assert.Equal(t, http.StatusOK, serve(small, int64(len(small))))
+
+	large := []byte(`{"zen":"` + strings.Repeat("a", 100) + `","hook_id":1}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(large, int64(len(large))))
+	// without a Content-Length, the limit's hit while reading
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(large, -1))
+}
+
+func TestValidatePayloadSignatures(t *testing.T) {
+	payload := []byte(`{"zen":"hi"}`)
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	ep := r.newEndpoint(nil, nil)
+	validate := func(ct, sig string) error {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", ct)
+		req.Header.Set("X-Hub-Signature", sig)
+		_, _, err := ep.validatePayload(req)
+		return err
+	}
+
+	assert.NoError(t, validate("application/json", sign(payload, []byte(ep.secret))))
+	assert.EqualError(t, validate("application/json", sign(payload, []byte("wrong"))), "payload signature check failed")
+	assert.EqualError(t, validate("application/json", ""), "missing signature")
+	assert.EqualError(t, validate("application/json", "md5=abc"), `unknown hash type prefix: "md5"`)
+	assert.Error(t, validate("application/json", "sha1=zz"))
+	assert.Error(t, validate("text/plain", sign(payload, []byte(ep.secret))))
+}
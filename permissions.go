@@ -0,0 +1,86 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// hookScopes - the OAuth scopes that allow creating and removing repo hooks
+var hookScopes = map[string]bool{"admin:repo_hook": true, "repo": true}
+
+// checkPermissions - fail with ErrMissingPermission when the token (or App
+// installation) registering hooks can't create and remove them, rather than
+// with a 404 from GitHub for each repo. Tokens that don't report their
+// scopes (e.g. fine-grained tokens) aren't checked.
+func (r *Responder) checkPermissions(ctx context.Context) error {
+	if !r.permCheck || !r.watchesRepos() {
+		return nil
+	}
+	if r.app != nil && r.installationID != 0 {
+		return r.app.checkInstallationPermissions(ctx, r.installationID)
+	}
+
+	var resp *github.Response
+	err := withRetries(ctx, "checking the token's scopes", func() (_ *github.Response, err error) {
+		_, resp, err = r.ghclient.Users.Get(ctx, "")
+		return resp, err
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to check the GitHub token's scopes")
+	}
+	if _, ok := resp.Header["X-Oauth-Scopes"]; !ok {
+		log.Debug().Msg("the GitHub token doesn't report its scopes - not checking them")
+		return nil
+	}
+	header := resp.Header.Get("X-OAuth-Scopes")
+	for _, s := range strings.Split(header, ",") {
+		if hookScopes[strings.TrimSpace(s)] {
+			return nil
+		}
+	}
+	if header == "" {
+		header = "none"
+	}
+	return newCauseError(ErrMissingPermission,
+		"the GitHub token's scopes (%s) don't include admin:repo_hook (or repo), needed to create and remove webhooks", header)
+}
+
+// watchesRepos - whether any endpoint has repos to create hooks in
+func (r *Responder) watchesRepos() bool {
+	for _, ep := range r.endpoints {
+		if len(ep.repos) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInstallationPermissions - fail with ErrMissingPermission when the
+// installation can't manage repo hooks. go-github doesn't know this
+// permission, so the installation is fetched directly.
+func (a *appAuth) checkInstallationPermissions(ctx context.Context, id int64) error {
+	var inst struct {
+		Permissions map[string]string `json:"permissions"`
+	}
+	err := withRetries(ctx, "checking the installation's permissions", func() (*github.Response, error) {
+		req, err := a.appClient.NewRequest("GET", fmt.Sprintf("app/installations/%d", id), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+		return a.appClient.Do(ctx, req, &inst)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to check the permissions of GitHub App installation %d", id)
+	}
+	if inst.Permissions["repository_hooks"] != "write" {
+		return newCauseError(ErrMissingPermission,
+			"GitHub App installation %d lacks the 'Repository webhooks: Read & write' permission, needed to create and remove webhooks", id)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	scopes := "repo, read:org"
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/user", req.URL.Path)
+		if scopes != "-" {
+			w.Header().Set("X-OAuth-Scopes", scopes)
+		}
+		fmt.Fprint(w, `{"login":"bot"}`)
+	}, "a/b")
+	ctx := context.Background()
+
+	// only checked when enabled
+	scopes = ""
+	assert.NoError(t, r.checkPermissions(ctx))
+	r.permCheck = true
+	err := r.checkPermissions(ctx)
+	assert.Equal(t, ErrMissingPermission, errors.Cause(err))
+	assert.Contains(t, err.Error(), "scopes (none)")
+
+	for _, s := range []string{"repo, read:org", "admin:repo_hook", "-"} {
+		scopes = s
+		assert.NoError(t, r.checkPermissions(ctx), s)
+	}
+	scopes = "write:repo_hook, gist"
+	assert.Contains(t, r.checkPermissions(ctx).Error(), "(write:repo_hook, gist)")
+}
+
+func TestCheckInstallationPermissions(t *testing.T) {
+	perms := `{"repository_hooks":"write"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/app/installations/7", req.URL.Path)
+		fmt.Fprintf(w, `{"id":7,"permissions":%s}`, perms)
+	}))
+	defer srv.Close()
+
+	base := github.NewClient(nil)
+	base.BaseURL, _ = url.Parse(srv.URL + "/")
+	_, p := testAppKey(t)
+	a, err := newAppAuth(42, p, base, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, a.checkInstallationPermissions(context.Background(), 7))
+	perms = `{"repository_hooks":"read","contents":"write"}`
+	err = a.checkInstallationPermissions(context.Background(), 7)
+	assert.Equal(t, ErrMissingPermission, errors.Cause(err))
+}
@@ -0,0 +1,168 @@
+package responder
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultQueuePerWorker - how many handler runs may wait per worker, when
+// the queue size isn't given
+const defaultQueuePerWorker = 10
+
+var (
+	queueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "handler_queue_depth",
+		Help:      "The number of handler runs waiting for a worker, when concurrency is limited.",
+	})
+
+	rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "rejected_deliveries_total",
+		Help:      "Count of deliveries turned away because the handler queue was full, or too many deliveries were in flight, by event type.",
+	}, []string{"event"})
+)
+
+// workerPool runs handlers on a fixed number of goroutines, with a bounded
+// queue. A nil pool runs each handler on its own goroutine.
+type workerPool struct {
+	jobs chan poolJob
+
+	mu     sync.Mutex
+	queued int
+	max    int
+}
+
+func newWorkerPool(workers, queue int) *workerPool {
+	if workers <= 0 {
+		return nil
+	}
+	if queue <= 0 {
+		queue = defaultQueuePerWorker * workers
+	}
+	p := &workerPool{jobs: make(chan poolJob, queue), max: queue}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// poolJob - a handler run, waiting in the queue
+type poolJob struct {
+	run func()
+	// skip - called instead of run when the job is taken out of the queue
+	// (see takeQueued)
+	skip func()
+	// handler and delivery - what's run, so it can be run again later (see
+	// EnableQueueSnapshot)
+	handler  string
+	delivery *Delivery
+}
+
+func (p *workerPool) work() {
+	for j := range p.jobs {
+		p.dequeued()
+		j.run()
+	}
+}
+
+func (p *workerPool) dequeued() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queued--
+	queueDepth.Set(float64(p.queued))
+}
+
+// takeQueued - take the jobs still waiting for a worker out of the queue,
+// skipping them
+func (p *workerPool) takeQueued() []poolJob {
+	if p == nil {
+		return nil
+	}
+	var taken []poolJob
+	for {
+		select {
+		case j := <-p.jobs:
+			p.dequeued()
+			if j.skip != nil {
+				j.skip()
+			}
+			taken = append(taken, j)
+		default:
+			return taken
+		}
+	}
+}
+
+// reserve - make room in the queue for n handler runs, all or nothing, so a
+// delivery is either handled fully or rejected. Each reservation must be
+// followed by a run.
+func (p *workerPool) reserve(n int) bool {
+	if p == nil {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queued+n > p.max {
+		return false
+	}
+	p.queued += n
+	queueDepth.Set(float64(p.queued))
+	return true
+}
+
+// run - run f on a worker, in a place reserved for it
+func (p *workerPool) run(f func()) {
+	p.enqueue(poolJob{run: f})
+}
+
+// enqueue - queue the job for a worker, in a place reserved for it
+func (p *workerPool) enqueue(j poolJob) {
+	if p == nil {
+		go j.run()
+		return
+	}
+	p.jobs <- j
+}
+
+// wanting - how many of the routes want the event
+func wanting(routes []Route, eventType string) int {
+	n := 0
+	for _, rt := range routes {
+		if rt.wants(eventType) {
+			n++
+		}
+	}
+	return n
+}
+
+// dispatchTo - like dispatch, but runs the handlers on the pool, which must
+// have room reserved for them
+func dispatchTo(ctx context.Context, p *workerPool, routes []Route, eventType, deliveryID string, payload []byte) *sync.WaitGroup {
+	var d *Delivery
+	if sc, ok := ctx.Value(scheduleKey).(*scheduleContext); ok {
+		d = sc.d
+	}
+	wg := &sync.WaitGroup{}
+	for _, rt := range routes {
+		if !rt.wants(eventType) {
+			continue
+		}
+		wg.Add(1)
+		h := rt.Handler
+		p.enqueue(poolJob{
+			run: func() {
+				defer wg.Done()
+				h(ctx, eventType, deliveryID, payload)
+			},
+			skip:     wg.Done,
+			handler:  rt.name,
+			delivery: d,
+		})
+	}
+	return wg
+}
@@ -0,0 +1,42 @@
+package responder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerPool(t *testing.T) {
+	assert.Nil(t, newWorkerPool(0, 5))
+	var none *workerPool
+	assert.True(t, none.reserve(100))
+
+	p := newWorkerPool(1, 2)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	assert.True(t, p.reserve(1))
+	p.run(func() {
+		close(started)
+		<-block
+	})
+	<-started
+
+	// the worker is busy, so runs queue until the queue is full
+	assert.True(t, p.reserve(2))
+	assert.False(t, p.reserve(1))
+
+	ran := make(chan string, 2)
+	routes := []Route{
+		{Handler: func(ctx context.Context, eventType, deliveryID string, payload []byte) { ran <- deliveryID }},
+		{Events: []string{"issues"}},
+		{Events: []string{"push"}, Handler: func(ctx context.Context, eventType, deliveryID string, payload []byte) { ran <- deliveryID }},
+	}
+	assert.Equal(t, 2, wanting(routes, "push"))
+	wg := dispatchTo(context.Background(), p, routes, "push", "abc", nil)
+	close(block)
+	wg.Wait()
+	assert.Equal(t, "abc", <-ran)
+	assert.Equal(t, "abc", <-ran)
+	assert.True(t, p.reserve(2))
+}
@@ -0,0 +1,50 @@
+package responder
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// proxySchemes - the proxy URL schemes net/http can dial through
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// parseProxy - parse and check a proxy URL
+func parseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil || !proxySchemes[u.Scheme] || u.Host == "" {
+		return nil, errors.Errorf("invalid proxy URL %q - must be an absolute http(s) or socks5 URL", proxy)
+	}
+	return u, nil
+}
+
+// proxyTransport - the transport for outbound requests. Without an explicit
+// proxy it's http.DefaultTransport, which honours the HTTPS_PROXY, HTTP_PROXY
+// and NO_PROXY environment variables.
+func proxyTransport(proxy string) (http.RoundTripper, error) {
+	if proxy == "" {
+		return http.DefaultTransport, nil
+	}
+	u, err := parseProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(u)
+	return t, nil
+}
+
+// proxyACME - send ACME (certificate) traffic through the proxy. certmagic
+// doesn't take an HTTP client, and its ACME client only reads the proxy from
+// the environment, so the environment is set - before anything reads it, as
+// net/http only reads it once.
+func proxyACME(proxy string) {
+	if proxy == "" {
+		return
+	}
+	for _, k := range []string{"HTTPS_PROXY", "HTTP_PROXY"} {
+		_ = os.Setenv(k, proxy)
+	}
+}
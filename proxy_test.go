@@ -0,0 +1,24 @@
+package responder
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyTransport(t *testing.T) {
+	rt, err := proxyTransport("")
+	assert.NoError(t, err)
+	assert.Equal(t, http.DefaultTransport, rt)
+
+	rt, err = proxyTransport("http://proxy.example.com:3128")
+	assert.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/", nil)
+	u, err := rt.(*http.Transport).Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", u.String())
+
+	_, err = proxyTransport("ftp://proxy.example.com")
+	assert.Error(t, err)
+}
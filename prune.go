@@ -0,0 +1,132 @@
+package responder
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// probeTimeout - how long a stale hook's callback URL has to respond
+const probeTimeout = 5 * time.Second
+
+// PruneStaleHooks - delete the watched repos' hooks left behind by previous
+// runs (for example after a crash) that are no longer reachable. Only hooks
+// on the given domains are pruned, which must be ones this Responder serves
+// - opt in only domains no other instance's hooks are reachable through
+// that could be mistaken for gone. A hook is from a previous run when it
+// delivers to one of the domains and this Responder's path prefix, but not
+// to one of its current callback URLs. It's unreachable when its callback
+// URL can't be connected to (the host can't be resolved, or refuses the
+// connection). Any response, even a 404 from a load balancer, or a timeout
+// keeps the hook, as another instance may be behind it. Call before
+// Register, or before Listen when using Options.AdoptHooks.
+//
+// Returns the number of hooks deleted. Failures don't stop the pruning of
+// other repos, and are returned as a *CleanupError.
+func (r *Responder) PruneStaleHooks(ctx context.Context, domains ...string) (int, error) {
+	if r.observe {
+		return 0, ErrObserving
+	}
+	if len(domains) == 0 {
+		return 0, errors.New("no domains to prune stale hooks on")
+	}
+	for _, d := range domains {
+		if !r.servesDomain(d) {
+			return 0, errors.Errorf("can't prune stale hooks on %s - it isn't one of the served domains", d)
+		}
+	}
+	err := r.resolveRepos(ctx)
+	if err != nil {
+		return 0, err
+	}
+	current := map[string]bool{}
+	seen := map[repository]bool{}
+	var repos []repository
+	for _, ep := range r.endpoints {
+		current[ep.callbackURL] = true
+		for _, repo := range ep.repos {
+			if callback, err := r.callbackFor(ep, repo); err == nil {
+				current[callback] = true
+			}
+			if !seen[repo] {
+				seen[repo] = true
+				repos = append(repos, repo)
+			}
+		}
+	}
+
+	p := &pacer{}
+	cerr := &CleanupError{Failed: map[string]error{}}
+	for _, repo := range repos {
+		name := repo.owner + "/" + repo.name
+		hooks, err := r.ownHooks(ctx, p, repo)
+		if err != nil {
+			cerr.Failed[name] = err
+			continue
+		}
+		for _, h := range hooks {
+			u, _ := h.Config["url"].(string)
+			if current[u] || !r.onDomains(u, domains) || reachable(ctx, r.transport, u) {
+				continue
+			}
+			log := log.With().Str("repo", name).Int64("hook_id", h.GetID()).Str("callback", u).Logger()
+			resp, err := r.ghclient.Repositories.DeleteHook(ctx, repo.owner, repo.name, h.GetID())
+			err = hookError(resp, err)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to delete stale webhook")
+				cerr.Failed[name] = err
+				continue
+			}
+			log.Info().Msg("Deleted stale webhook")
+			cerr.Succeeded++
+		}
+	}
+	if len(cerr.Failed) > 0 {
+		return cerr.Succeeded, cerr
+	}
+	return cerr.Succeeded, nil
+}
+
+// onDomains - whether the callback URL is on one of the domains
+func (r *Responder) onDomains(u string, domains []string) bool {
+	for _, d := range domains {
+		if r.onDomain(u, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// reachable - whether the callback URL can be connected to. Only failing to
+// connect at all counts as unreachable - whatever answers may be routing
+// for another instance.
+func reachable(ctx context.Context, transport http.RoundTripper, u string) bool {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return true
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req.WithContext(ctx))
+	if err != nil {
+		return !connectFailed(err)
+	}
+	resp.Body.Close()
+	return true
+}
+
+// connectFailed - whether the request failed as its host couldn't be
+// resolved or connected to. Timeouts don't count, as they may be a slow
+// instance.
+func connectFailed(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	operr, ok := err.(*net.OpError)
+	return ok && operr.Op == "dial" && !operr.Timeout()
+}
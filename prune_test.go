@@ -0,0 +1,69 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneStaleHooks(t *testing.T) {
+	t.Setenv("TLS_DISABLE", "true")
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// like a load balancer with no instance left serving the path
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer live.Close()
+	domain := strings.TrimPrefix(live.URL, "http://")
+	gone, other := closedAddr(t), closedAddr(t)
+
+	var mu sync.Mutex
+	var deleted []string
+	var r *Responder
+	r = testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method {
+		case "GET":
+			fmt.Fprintf(w, `[
+				{"id":1,"config":{"url":%q}},
+				{"id":2,"config":{"url":"http://%s/gh-callback/crashed"}},
+				{"id":3,"config":{"url":"http://%s/gh-callback/crashed"}},
+				{"id":4,"config":{"url":"http://%s/gh-callback/crashed"}},
+				{"id":5,"config":{"url":"https://elsewhere.example.com/gh-callback/x"}}
+			]`, r.endpoints[0].callbackURL, domain, gone, other)
+		case "DELETE":
+			deleted = append(deleted, req.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	r.domain = domain
+	r.domains = []string{gone, other}
+	r.endpoints = []*endpoint{r.newEndpoint([]repository{{owner: "a", name: "1"}}, nil)}
+
+	_, err := r.PruneStaleHooks(context.Background())
+	assert.Error(t, err)
+	_, err = r.PruneStaleHooks(context.Background(), "elsewhere.example.com")
+	assert.Error(t, err)
+
+	// a 404 isn't enough, and other's not opted in
+	n, err := r.PruneStaleHooks(context.Background(), domain, gone)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"/repos/a/1/hooks/3"}, deleted)
+}
+
+// closedAddr - the address of a port nothing listens on
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
@@ -0,0 +1,107 @@
+package responder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// EnableQueueSnapshot - on shutdown, save the handler runs still waiting
+// for a worker (see Options.MaxConcurrency) to the file, rather than
+// running them before exiting (or losing them when the drain timeout runs
+// out), and run them on the next start, once RunScheduler starts (as RegisterAndListen does). This covers
+// routine restarts at little cost, without a write-ahead log (see
+// EnableWriteAheadLog) - but runs are still lost if the process crashes,
+// and runs already started must finish within the drain timeout.
+//
+// The file holds the deliveries' payloads, so is only readable by the
+// owner.
+func (r *Responder) EnableQueueSnapshot(path string) {
+	r.queueSnapshot = path
+}
+
+// snapshotQueue - take the runs waiting for a worker out of the queue, and
+// save them to the snapshot file
+func (r *Responder) snapshotQueue() {
+	if r.queueSnapshot == "" {
+		return
+	}
+	var runs []scheduledRun
+	for _, j := range r.pool.takeQueued() {
+		if j.handler == "" || j.delivery == nil {
+			continue
+		}
+		runs = append(runs, scheduledRun{Handler: j.handler, Delivery: j.delivery, Deferred: true})
+	}
+	if len(runs) == 0 {
+		return
+	}
+	l := log.With().Str("path", r.queueSnapshot).Int("runs", len(runs)).Logger()
+	err := writeQueueSnapshot(r.queueSnapshot, r.compression, runs)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to save queued handler runs - they're lost")
+		return
+	}
+	l.Info().Msg("saved queued handler runs, to run on restart")
+}
+
+func writeQueueSnapshot(path string, c Compression, runs []scheduledRun) error {
+	b, err := json.Marshal(runs)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode queue snapshot")
+	}
+	b, err = compress(c, b)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, b, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write queue snapshot")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "failed to write queue snapshot")
+}
+
+// restoreQueue - schedule the runs saved by snapshotQueue to run now, and
+// remove the snapshot
+func (r *Responder) restoreQueue() {
+	if r.queueSnapshot == "" {
+		return
+	}
+	l := log.With().Str("path", r.queueSnapshot).Logger()
+	b, err := ioutil.ReadFile(r.queueSnapshot)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		l.Error().Err(err).Msg("failed to read queue snapshot")
+		return
+	}
+	var runs []scheduledRun
+	b, err = decompress(b)
+	if err == nil {
+		err = json.Unmarshal(b, &runs)
+	}
+	if err != nil {
+		l.Error().Err(err).Msg("dropping invalid queue snapshot")
+	}
+	now := r.clock()
+	restored := 0
+	for _, run := range runs {
+		run.Due = now
+		err = r.schedule(run)
+		if err != nil {
+			l.Error().Err(err).Str("handler", run.Handler).Msg("failed to restore queued handler run - dropping it")
+			continue
+		}
+		restored++
+	}
+	err = os.Remove(r.queueSnapshot)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to remove queue snapshot - its runs may be run again")
+	}
+	l.Info().Int("runs", restored).Msg("restored queued handler runs")
+}
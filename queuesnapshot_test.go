@@ -0,0 +1,56 @@
+package responder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	ran := make(chan string, 1)
+	block := make(chan struct{})
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) { ran <- deliveryID }
+	newResponder := func() *Responder {
+		r := &Responder{domain: "example.com", pathPrefix: "/", pool: newWorkerPool(1, 5), now: time.Now}
+		r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{h})}
+		r.EnableQueueSnapshot(path)
+		return r
+	}
+
+	r := newResponder()
+	// keep the worker busy, so the delivery's run waits in the queue
+	assert.True(t, r.pool.reserve(2))
+	r.pool.run(func() { <-block })
+	d := &Delivery{EventType: "push", DeliveryID: "abc", Payload: []byte(`{}`)}
+	ctx := context.WithValue(context.Background(), scheduleKey, &scheduleContext{r, d})
+	wg := dispatchTo(ctx, r.pool, r.endpoints[0].namedRoutes(), "push", "abc", d.Payload)
+
+	r.snapshotQueue()
+	// the skipped run no longer holds up the delivery
+	wg.Wait()
+	close(block)
+	assert.FileExists(t, path)
+
+	r2 := newResponder()
+	r2.restoreQueue()
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	r2.runDue(time.Now())
+	select {
+	case id := <-ran:
+		assert.Equal(t, "abc", id)
+	case <-time.After(time.Second):
+		t.Error("restored run didn't run")
+	}
+
+	// nothing to save or restore
+	r2.snapshotQueue()
+	r2.restoreQueue()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
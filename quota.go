@@ -0,0 +1,121 @@
+package responder
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// apiQuota enforces the API call budgets (see Options.APICallsPerHour and
+// Options.HandlerAPICallsPerHour). Only calls made by handlers, with their
+// context, are counted - handlers are named by their position (see
+// endpoint.namedRoutes).
+type apiQuota struct {
+	global     *apiBudget
+	perHandler int
+	now        func() time.Time
+
+	mu       sync.Mutex
+	handlers map[string]*apiBudget
+}
+
+// newAPIQuota returns nil when there are no budgets to enforce
+func newAPIQuota(global, perHandler int) *apiQuota {
+	if global <= 0 && perHandler <= 0 {
+		return nil
+	}
+	q := &apiQuota{perHandler: perHandler, now: time.Now, handlers: map[string]*apiBudget{}}
+	if global > 0 {
+		q.global = &apiBudget{limit: global, q: q}
+	}
+	return q
+}
+
+// apiBudget - a number of calls allowed per hour
+type apiBudget struct {
+	q     *apiQuota
+	mu    sync.Mutex
+	limit int
+	start time.Time
+	used  int
+}
+
+// take uses one call from the budget, if any remain in the current hour
+func (b *apiBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.q.now()
+	if now.Sub(b.start) >= time.Hour {
+		b.start = now
+		b.used = 0
+	}
+	if b.used >= b.limit {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// refund returns a call taken from the budget
+func (b *apiBudget) refund() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used > 0 {
+		b.used--
+	}
+}
+
+func (q *apiQuota) handler(name string) *apiBudget {
+	if q.perHandler <= 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	b, ok := q.handlers[name]
+	if !ok {
+		b = &apiBudget{limit: q.perHandler, q: q}
+		q.handlers[name] = b
+	}
+	return b
+}
+
+// transport wraps base to enforce the budgets. Safe to call on a nil quota,
+// in which case base is returned.
+func (q *apiQuota) transport(base http.RoundTripper) http.RoundTripper {
+	if q == nil {
+		return base
+	}
+	return &quotaTransport{q, base}
+}
+
+type quotaTransport struct {
+	q    *apiQuota
+	base http.RoundTripper
+}
+
+func (t *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := handlerName(req.Context())
+	if name == "" {
+		return t.base.RoundTrip(req)
+	}
+	hb := t.q.handler(name)
+	if !hb.take() {
+		apiCalls.WithLabelValues(name, "rejected").Inc()
+		return nil, errors.Wrapf(ErrAPIBudgetExceeded, "handler %s exceeded its budget of %d calls/hour", name, t.q.perHandler)
+	}
+	if !t.q.global.take() {
+		hb.refund()
+		apiCalls.WithLabelValues(name, "rejected").Inc()
+		return nil, errors.Wrapf(ErrAPIBudgetExceeded, "handlers exceeded the global budget of %d calls/hour", t.q.global.limit)
+	}
+	apiCalls.WithLabelValues(name, "allowed").Inc()
+	return t.base.RoundTrip(req)
+}
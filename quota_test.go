@@ -0,0 +1,66 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIBudget(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := newAPIQuota(2, 0)
+	q.now = func() time.Time { return now }
+	b := q.global
+
+	assert.True(t, b.take())
+	assert.True(t, b.take())
+	assert.False(t, b.take())
+	b.refund()
+	assert.True(t, b.take())
+
+	now = now.Add(time.Hour)
+	assert.True(t, b.take())
+
+	var nilBudget *apiBudget
+	assert.True(t, nilBudget.take())
+	assert.Nil(t, newAPIQuota(0, 0))
+}
+
+func TestQuotaTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	q := newAPIQuota(3, 2)
+	c := &http.Client{Transport: q.transport(http.DefaultTransport)}
+	call := func(ctx context.Context) error {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		resp, err := c.Do(req.WithContext(ctx))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	ctxs := map[string]context.Context{
+		"a": context.WithValue(context.Background(), handlerKey, "0.0"),
+		"b": context.WithValue(context.Background(), handlerKey, "0.1"),
+	}
+
+	assert.NoError(t, call(ctxs["a"]))
+	assert.NoError(t, call(ctxs["a"]))
+	err := call(ctxs["a"])
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "handler 0.0 exceeded its budget")
+
+	assert.NoError(t, call(ctxs["b"]))
+	err = call(ctxs["b"])
+	assert.Contains(t, err.Error(), ErrAPIBudgetExceeded.Error())
+	assert.Contains(t, err.Error(), "global budget")
+
+	// calls not made by handlers aren't counted
+	assert.NoError(t, call(context.Background()))
+}
@@ -0,0 +1,208 @@
+package responder
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/hlog"
+)
+
+// maxTrackedIPs - how many addresses' rate limits are kept - the least
+// recently seen are forgotten beyond this
+const maxTrackedIPs = 10000
+
+var (
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "rate_limited_requests_total",
+		Help:      "Count of callback requests turned away with 429 by a rate limit, by limit (global or ip).",
+	}, []string{"limit"})
+
+	inFlightDeliveries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "github",
+		Subsystem: "webhook",
+		Name:      "in_flight_deliveries",
+		Help:      "The number of accepted deliveries whose handlers haven't all finished.",
+	})
+)
+
+// tokenBucket - a rate limit, allowing bursts
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take takes a token, refilled at rate per second up to burst, returning
+// how long until one is available when there isn't one
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) (bool, time.Duration) {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// rateLimiter - global and per-address rate limits on callback requests
+// (see Options.CallbackRateLimit)
+type rateLimiter struct {
+	rate, perIP       float64
+	burst, perIPBurst int
+	now               func() time.Time
+	mu                sync.Mutex
+	global            tokenBucket
+	// ips - the addresses' buckets, as elements of seen
+	ips map[string]*list.Element
+	// seen - the addresses' ipBuckets, most recently seen first
+	seen *list.List
+}
+
+// ipBucket - an address's rate limit
+type ipBucket struct {
+	ip string
+	tokenBucket
+}
+
+// newRateLimiter returns nil when there are no limits
+func newRateLimiter(rate, perIP float64, burst int, now func() time.Time) *rateLimiter {
+	if rate <= 0 && perIP <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:       rate,
+		perIP:      perIP,
+		burst:      defaultBurst(rate, burst),
+		perIPBurst: defaultBurst(perIP, burst),
+		now:        now,
+		ips:        map[string]*list.Element{},
+		seen:       list.New(),
+	}
+}
+
+// defaultBurst - the burst, or a second's worth of requests (at least one)
+// when it's not given
+func defaultBurst(rate float64, burst int) int {
+	if burst > 0 {
+		return burst
+	}
+	return int(math.Max(1, math.Ceil(rate)))
+}
+
+// allow - whether a request from the address is within the limits, and if
+// not, which limit it exceeded and how long until it wouldn't
+func (l *rateLimiter) allow(ip string) (bool, string, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var b *tokenBucket
+	if l.perIP > 0 {
+		b = l.bucket(ipKey(ip))
+		if ok, wait := b.take(now, l.perIP, l.perIPBurst); !ok {
+			return false, "ip", wait
+		}
+	}
+	if l.rate > 0 {
+		if ok, wait := l.global.take(now, l.rate, l.burst); !ok {
+			if b != nil {
+				// not this address's fault - give its token back
+				b.tokens++
+			}
+			return false, "global", wait
+		}
+	}
+	return true, "", 0
+}
+
+// bucket - the address's bucket, marked as the most recently seen. The
+// least recently seen address is forgotten when too many are tracked. Must
+// be called with the lock held.
+func (l *rateLimiter) bucket(ip string) *tokenBucket {
+	if e, ok := l.ips[ip]; ok {
+		l.seen.MoveToFront(e)
+		return &e.Value.(*ipBucket).tokenBucket
+	}
+	if l.seen.Len() >= maxTrackedIPs {
+		oldest := l.seen.Back()
+		l.seen.Remove(oldest)
+		delete(l.ips, oldest.Value.(*ipBucket).ip)
+	}
+	b := &ipBucket{ip: ip}
+	l.ips[ip] = l.seen.PushFront(b)
+	return &b.tokenBucket
+}
+
+// ipKey - the key an address is rate limited by: IPv6 addresses are limited
+// by their /64, as hosts are usually given a /64 to pick addresses from
+func ipKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return ip
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// Middleware - reject requests exceeding the limits with 429, and a
+// Retry-After header saying when to try again
+func (l *rateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+		ok, limit, wait := l.allow(host)
+		if ok {
+			next.ServeHTTP(resp, req)
+			return
+		}
+		rateLimited.WithLabelValues(limit).Inc()
+		hlog.FromRequest(req).Warn().
+			Str("remoteAddr", req.RemoteAddr).
+			Str("limit", limit).
+			Msg("rate limit exceeded - rejecting")
+		resp.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(resp, "rate limit exceeded", http.StatusTooManyRequests)
+	})
+}
+
+// inFlightLimit - counts the deliveries whose handlers haven't all
+// finished, limiting them when max is set (see
+// Options.MaxInFlightDeliveries). A nil limit doesn't count.
+type inFlightLimit struct {
+	max   int64
+	count int64
+}
+
+// acquire - count a delivery in, unless the limit's been reached
+func (l *inFlightLimit) acquire() bool {
+	if l == nil {
+		return true
+	}
+	n := atomic.AddInt64(&l.count, 1)
+	if l.max > 0 && n > l.max {
+		atomic.AddInt64(&l.count, -1)
+		return false
+	}
+	inFlightDeliveries.Set(float64(n))
+	return true
+}
+
+// release - count a delivery out
+func (l *inFlightLimit) release() {
+	if l == nil {
+		return
+	}
+	inFlightDeliveries.Set(float64(atomic.AddInt64(&l.count, -1)))
+}
@@ -0,0 +1,118 @@
+package responder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0, 0, 0, time.Now))
+
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(10, 1, 0, func() time.Time { return now })
+	assert.Equal(t, 10, l.burst)
+	assert.Equal(t, 1, l.perIPBurst)
+
+	ok, _, _ := l.allow("192.0.2.1")
+	assert.True(t, ok)
+	ok, limit, wait := l.allow("192.0.2.1")
+	assert.False(t, ok)
+	assert.Equal(t, "ip", limit)
+	assert.Equal(t, time.Second, wait)
+
+	// other addresses use up the global limit
+	for i := 2; i <= 10; i++ {
+		ok, _, _ = l.allow("192.0.2." + strconv.Itoa(i))
+		assert.True(t, ok, i)
+	}
+	ok, limit, wait = l.allow("192.0.2.99")
+	assert.False(t, ok)
+	assert.Equal(t, "global", limit)
+	assert.Equal(t, 100*time.Millisecond, wait)
+
+	// which refills over time, and the address wasn't charged
+	now = now.Add(100 * time.Millisecond)
+	ok, _, _ = l.allow("192.0.2.99")
+	assert.True(t, ok)
+	now = now.Add(time.Second)
+	ok, _, _ = l.allow("192.0.2.1")
+	assert.True(t, ok)
+}
+
+func TestRateLimiterIPv6(t *testing.T) {
+	assert.Equal(t, "192.0.2.1", ipKey("192.0.2.1"))
+	assert.Equal(t, "2001:db8:1:2::/64", ipKey("2001:db8:1:2:3:4:5:6"))
+	assert.Equal(t, "::ffff:192.0.2.1", ipKey("::ffff:192.0.2.1"))
+
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(0, 1, 0, func() time.Time { return now })
+	ok, _, _ := l.allow("2001:db8:1:2::1")
+	assert.True(t, ok)
+	// the same /64
+	ok, _, _ = l.allow("2001:db8:1:2::ffff")
+	assert.False(t, ok)
+	ok, _, _ = l.allow("2001:db8:1:3::1")
+	assert.True(t, ok)
+}
+
+func TestRateLimiterForgets(t *testing.T) {
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(0, 1, 0, func() time.Time { return now })
+	ok, _, _ := l.allow("192.0.2.1")
+	assert.True(t, ok)
+	for i := 0; i < maxTrackedIPs; i++ {
+		ok, _, _ = l.allow("10." + strconv.Itoa(i/65536) + "." + strconv.Itoa(i/256%256) + "." + strconv.Itoa(i%256))
+		assert.True(t, ok)
+		if i == 0 {
+			// seen again, so it's not the least recently seen
+			ok, _, _ = l.allow("192.0.2.1")
+			assert.False(t, ok)
+		}
+	}
+	assert.Len(t, l.ips, maxTrackedIPs)
+	assert.Equal(t, maxTrackedIPs, l.seen.Len())
+	// 10.0.0.0 was the least recently seen
+	_, tracked := l.ips["10.0.0.0"]
+	assert.False(t, tracked)
+	_, tracked = l.ips["192.0.2.1"]
+	assert.True(t, tracked)
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	now := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(0, 0.5, 0, func() time.Time { return now })
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/gh-callback", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve().Code)
+	rec := serve()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+}
+
+func TestInFlightLimit(t *testing.T) {
+	var none *inFlightLimit
+	assert.True(t, none.acquire())
+	none.release()
+
+	l := &inFlightLimit{max: 2}
+	assert.True(t, l.acquire())
+	assert.True(t, l.acquire())
+	assert.False(t, l.acquire())
+	l.release()
+	assert.True(t, l.acquire())
+	assert.Equal(t, int64(2), l.count)
+}
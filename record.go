@@ -0,0 +1,193 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// recordTimeFormat - delivery file names start with the time received, so
+// they sort in order
+const recordTimeFormat = "20060102T150405.000"
+
+// DeliveryStore - storage for recorded deliveries, so recording, replay, and
+// users' own tools can share a backend. DirDeliveryStore and
+// MemoryDeliveryStore are bundled - implement this to use another (e.g. an
+// object store or a database).
+type DeliveryStore interface {
+	// Put - store the delivery, returning its ID in the store
+	Put(d *Delivery) (string, error)
+	// Get - the delivery with the ID (as returned by Put or List)
+	Get(id string) (*Delivery, error)
+	// List - the IDs of the stored deliveries, in the order stored
+	List() ([]string, error)
+}
+
+// RecordTo - store every validated delivery in s
+func (r *Responder) RecordTo(s DeliveryStore) {
+	r.recorder = s
+}
+
+// RecordDeliveries - write every validated delivery to a timestamped file in
+// dir, for use with Replay, WatchDeliveries, or in tests. The payload is
+// re-indented for readability, so recorded signatures may not validate.
+func (r *Responder) RecordDeliveries(dir string) error {
+	s, err := NewDirDeliveryStore(dir)
+	if err != nil {
+		return err
+	}
+	s.Compression = r.compression
+	r.RecordTo(s)
+	return nil
+}
+
+// RecordEncryptedDeliveries - like RecordDeliveries, but envelope-encrypt
+// each file, so stored payloads are protected at rest. Each file is
+// encrypted with its own random key, which is stored wrapped by w. Read the
+// files back with OpenDelivery.
+func (r *Responder) RecordEncryptedDeliveries(dir string, w KeyWrapper) error {
+	s, err := NewDirDeliveryStore(dir)
+	if err != nil {
+		return err
+	}
+	s.Wrapper = w
+	s.Compression = r.compression
+	r.RecordTo(s)
+	return nil
+}
+
+// DirDeliveryStore - a DeliveryStore keeping each delivery in a timestamped
+// JSON file in a directory - the format read by ReadDelivery and
+// WatchDeliveries. IDs are the file names.
+type DirDeliveryStore struct {
+	Dir string
+	// Wrapper - when set, envelope-encrypt stored deliveries
+	Wrapper KeyWrapper
+	// Unwrapper - decrypts encrypted deliveries for Get
+	Unwrapper KeyUnwrapper
+	// Compression - when set, compress stored deliveries (before encrypting
+	// them). Files keep their .json name, so they're still found by List
+	// and FindDelivery, and are decompressed when read.
+	Compression Compression
+
+	now func() time.Time
+}
+
+// NewDirDeliveryStore - a DirDeliveryStore in dir, which is created if needed
+func NewDirDeliveryStore(dir string) (*DirDeliveryStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s", dir)
+	}
+	return &DirDeliveryStore{Dir: dir, now: time.Now}, nil
+}
+
+// Put - write the delivery to a new file
+func (s *DirDeliveryStore) Put(d *Delivery) (string, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(d)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode delivery")
+	}
+	b, err := compress(s.Compression, buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	if s.Wrapper != nil {
+		b, err = seal(s.Wrapper, b)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to encrypt delivery")
+		}
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	name := now().UTC().Format(recordTimeFormat) + "-" + filepath.Base(d.DeliveryID) + ".json"
+	err = ioutil.WriteFile(filepath.Join(s.Dir, name), b, 0600)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to write delivery")
+	}
+	return name, nil
+}
+
+// Get - read the delivery file. Files recorded without a ReceivedAt time
+// get it from their name.
+func (s *DirDeliveryStore) Get(id string) (*Delivery, error) {
+	name := filepath.Base(id)
+	d, err := OpenDelivery(filepath.Join(s.Dir, name), s.Unwrapper)
+	if err != nil {
+		return nil, err
+	}
+	if d.ReceivedAt.IsZero() && len(name) > len(recordTimeFormat) {
+		d.ReceivedAt, _ = time.Parse(recordTimeFormat, name[:len(recordTimeFormat)])
+	}
+	return d, nil
+}
+
+// List - the delivery files' names
+func (s *DirDeliveryStore) List() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list deliveries")
+	}
+	ids := make([]string, len(paths))
+	for i, p := range paths {
+		ids[i] = filepath.Base(p)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// MemoryDeliveryStore - a DeliveryStore that doesn't persist across restarts,
+// for tests
+type MemoryDeliveryStore struct {
+	mu         sync.Mutex
+	ids        []string
+	deliveries map[string]Delivery
+}
+
+// NewMemoryDeliveryStore -
+func NewMemoryDeliveryStore() *MemoryDeliveryStore {
+	return &MemoryDeliveryStore{deliveries: map[string]Delivery{}}
+}
+
+// Put -
+func (m *MemoryDeliveryStore) Put(d *Delivery) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := fmt.Sprintf("%08d-%s", len(m.ids), d.DeliveryID)
+	m.ids = append(m.ids, id)
+	m.deliveries[id] = *d
+	return id, nil
+}
+
+// Get -
+func (m *MemoryDeliveryStore) Get(id string) (*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deliveries[id]
+	if !ok {
+		return nil, errors.Wrapf(os.ErrNotExist, "no delivery %s", id)
+	}
+	return &d, nil
+}
+
+// List -
+func (m *MemoryDeliveryStore) List() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string{}, m.ids...), nil
+}
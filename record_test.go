@@ -0,0 +1,82 @@
+package responder
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rec, err := NewDirDeliveryStore(dir)
+	assert.NoError(t, err)
+	rec.now = func() time.Time {
+		return time.Date(2019, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	}
+	header := http.Header{"X-Github-Event": []string{"issues"}}
+	payload := []byte(`{"action":"opened","body":"<b>&</b>"}`)
+	id, err := rec.Put(&Delivery{EventType: "issues", DeliveryID: "abc-123", Headers: header, Payload: payload})
+	assert.NoError(t, err)
+	assert.Equal(t, "20190102T030405.006-abc-123.json", id)
+	path := filepath.Join(dir, id)
+
+	// recordings can be found and replayed
+	found, err := FindDelivery(dir, "abc-123")
+	assert.NoError(t, err)
+	assert.Equal(t, path, found)
+	d, err := ReadDelivery(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "issues", d.EventType)
+	assert.Equal(t, "abc-123", d.DeliveryID)
+	assert.Equal(t, header, d.Headers)
+	assert.JSONEq(t, string(payload), string(d.Payload))
+
+	d, err = rec.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc-123", d.DeliveryID)
+	assert.Equal(t, time.Date(2019, 1, 2, 3, 4, 5, 6000000, time.UTC), d.ReceivedAt)
+
+	// delivery IDs can't escape the directory
+	id, err = rec.Put(&Delivery{EventType: "issues", DeliveryID: "../../evil", Headers: header, Payload: payload})
+	assert.NoError(t, err)
+	assert.Equal(t, "20190102T030405.006-evil.json", id)
+	ids, err := rec.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"20190102T030405.006-abc-123.json", id}, ids)
+
+	_, err = rec.Put(&Delivery{EventType: "issues", DeliveryID: "x", Headers: header, Payload: []byte("not json")})
+	assert.Error(t, err)
+}
+
+func TestMemoryDeliveryStore(t *testing.T) {
+	s := NewMemoryDeliveryStore()
+	ids, err := s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	a, err := s.Put(&Delivery{EventType: "push", DeliveryID: "a", Payload: []byte(`{}`)})
+	assert.NoError(t, err)
+	b, err := s.Put(&Delivery{EventType: "push", DeliveryID: "a", Payload: []byte(`{}`)})
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+
+	ids, err = s.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{a, b}, ids)
+
+	d, err := s.Get(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", d.DeliveryID)
+
+	_, err = s.Get("nope")
+	assert.True(t, os.IsNotExist(errors.Cause(err)))
+}
@@ -0,0 +1,348 @@
+package responder
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+	"github.com/rs/zerolog/log"
+)
+
+// hookDelivery - a delivery, as listed by the hook deliveries API (which
+// go-github doesn't support yet)
+type hookDelivery struct {
+	ID          int64     `json:"id"`
+	GUID        string    `json:"guid"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	StatusCode  int       `json:"status_code"`
+	Event       string    `json:"event"`
+}
+
+func (d hookDelivery) succeeded() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// Redeliver - ask GitHub to redeliver a past delivery (from the last 3
+// days) to one of the watched repos' hooks, by its delivery ID (the
+// X-GitHub-Delivery header, see DeliveryInfo), e.g. to reprocess it after
+// fixing a broken handler. The delivery is forgotten by deduplication (see
+// Options.DedupTTL), so it's handled again. Only hooks that outlive the
+// Responder have past deliveries, so this is for use with
+// Options.AdoptHooks. Returns an error caused by ErrDeliveryNotFound when no
+// hook has the delivery.
+func (r *Responder) Redeliver(ctx context.Context, deliveryID string) error {
+	found := false
+	rerr := r.eachOwnHook(ctx, func(repo repository, hookID int64) (int, error) {
+		if found {
+			return 0, nil
+		}
+		var target hookDelivery
+		err := r.eachHookDelivery(ctx, repo, hookID, func(d hookDelivery) bool {
+			if d.GUID == deliveryID {
+				target = d
+				return false
+			}
+			return true
+		})
+		if err != nil || target.ID == 0 {
+			return 0, err
+		}
+		found = true
+		return 1, r.redeliverHookDelivery(ctx, repo, hookID, target)
+	})
+	if len(rerr.Failed) > 0 {
+		return rerr
+	}
+	if !found {
+		return newCauseError(ErrDeliveryNotFound, "delivery %s not found in the last 3 days' deliveries", deliveryID)
+	}
+	return nil
+}
+
+// RedeliverSince - ask GitHub to redeliver every delivery to the watched
+// repos' hooks since the given time (at most 3 days ago), whether or not it
+// succeeded, e.g. to reprocess events after fixing a broken handler. See
+// Redeliver.
+//
+// Returns the number of deliveries redelivered. Failures don't stop the
+// redelivery to other repos, and are returned as a *RedeliveryError.
+func (r *Responder) RedeliverSince(ctx context.Context, since time.Time) (int, error) {
+	rerr := r.eachOwnHook(ctx, func(repo repository, hookID int64) (int, error) {
+		seen := map[string]bool{}
+		var deliveries []hookDelivery
+		err := r.eachHookDelivery(ctx, repo, hookID, func(d hookDelivery) bool {
+			if d.DeliveredAt.Before(since) {
+				return false
+			}
+			// only the latest attempt of each delivery
+			if !seen[d.GUID] {
+				seen[d.GUID] = true
+				deliveries = append(deliveries, d)
+			}
+			return true
+		})
+		if err != nil {
+			return 0, err
+		}
+		// oldest first, so they're handled in order
+		for i := len(deliveries) - 1; i >= 0; i-- {
+			err = r.redeliverHookDelivery(ctx, repo, hookID, deliveries[i])
+			if err != nil {
+				return len(deliveries) - 1 - i, err
+			}
+		}
+		return len(deliveries), nil
+	})
+	log.Info().
+		Int("redelivered", rerr.Redelivered).
+		Int("failed", len(rerr.Failed)).
+		Time("since", since).
+		Msg("Redelivered past deliveries")
+	if len(rerr.Failed) > 0 {
+		return rerr.Redelivered, rerr
+	}
+	return rerr.Redelivered, nil
+}
+
+// eachOwnHook calls f for each of the Responder's hooks in the watched
+// repos, collecting the number of deliveries it redelivered, and its errors
+func (r *Responder) eachOwnHook(ctx context.Context, f func(repo repository, hookID int64) (int, error)) *RedeliveryError {
+	rerr := &RedeliveryError{Failed: map[string]error{}}
+	err := r.resolveRepos(ctx)
+	if err != nil {
+		rerr.Failed["*"] = err
+		return rerr
+	}
+	p := &pacer{}
+	seen := map[repository]bool{}
+	for _, ep := range r.endpoints {
+		for _, repo := range ep.repos {
+			if seen[repo] {
+				continue
+			}
+			seen[repo] = true
+			name := repo.owner + "/" + repo.name
+			hooks, err := r.ownHooks(ctx, p, repo)
+			if err != nil {
+				rerr.Failed[name] = err
+				continue
+			}
+			for _, h := range hooks {
+				n, err := f(repo, h.GetID())
+				rerr.Redelivered += n
+				if err != nil {
+					rerr.Failed[name] = err
+				}
+			}
+		}
+	}
+	return rerr
+}
+
+// eachHookDelivery calls f with the hook's deliveries, newest first, until
+// it returns false or there are no more
+func (r *Responder) eachHookDelivery(ctx context.Context, repo repository, hookID int64, f func(hookDelivery) bool) error {
+	u := fmt.Sprintf("repos/%s/%s/hooks/%d/deliveries?per_page=100", repo.owner, repo.name, hookID)
+	for u != "" {
+		var page []hookDelivery
+		var resp *github.Response
+		err := withRetries(ctx, "listing hook deliveries", func() (*github.Response, error) {
+			req, err := r.ghclient.NewRequest("GET", u, nil)
+			if err != nil {
+				return nil, err
+			}
+			resp, err = r.ghclient.Do(ctx, req, &page)
+			return resp, err
+		})
+		if err != nil {
+			return hookError(resp, err)
+		}
+		for _, d := range page {
+			if !f(d) {
+				return nil
+			}
+		}
+		u = nextLink(resp)
+	}
+	return nil
+}
+
+// redeliverHookDelivery asks GitHub to redeliver the delivery, forgetting
+// it was seen so it isn't ignored as a duplicate
+func (r *Responder) redeliverHookDelivery(ctx context.Context, repo repository, hookID int64, d hookDelivery) error {
+	r.forgetDelivery(d.GUID)
+	var resp *github.Response
+	err := withRetries(ctx, "redelivering hook delivery", func() (*github.Response, error) {
+		u := fmt.Sprintf("repos/%s/%s/hooks/%d/deliveries/%d/attempts", repo.owner, repo.name, hookID, d.ID)
+		req, err := r.ghclient.NewRequest("POST", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = r.ghclient.Do(ctx, req, nil)
+		if _, ok := err.(*github.AcceptedError); ok {
+			// GitHub replies 202 Accepted, as the redelivery is queued
+			err = nil
+		}
+		return resp, err
+	})
+	if err != nil {
+		return hookError(resp, err)
+	}
+	log.Info().
+		Str("repo", repo.owner+"/"+repo.name).
+		Str("deliveryID", d.GUID).
+		Str("eventType", d.Event).
+		Str("hookDelivery", strconv.FormatInt(d.ID, 10)).
+		Msg("requested redelivery")
+	return nil
+}
+
+// nextLink - the URL of the next page of a cursor-paginated listing, which
+// go-github doesn't parse, or "" if it's the last page
+func nextLink(resp *github.Response) string {
+	if resp == nil {
+		return ""
+	}
+	for _, link := range strings.Split(resp.Header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) != `rel="next"` {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+	}
+	return ""
+}
+
+// PastDelivery - a delivery to one of the Responder's hooks, as GitHub
+// recorded it (see PastDeliveries)
+type PastDelivery struct {
+	DeliveryID  string    `json:"deliveryID"`
+	Repo        string    `json:"repo"`
+	EventType   string    `json:"eventType"`
+	DeliveredAt time.Time `json:"deliveredAt"`
+	// StatusCode - the status the Responder answered the latest attempt
+	// with
+	StatusCode int `json:"statusCode"`
+}
+
+// PastDeliveries - the deliveries to the watched repos' hooks since the
+// given time (at most 3 days ago), newest first, with only the latest
+// attempt of each - the ones Redeliver can redeliver
+func (r *Responder) PastDeliveries(ctx context.Context, since time.Time) ([]PastDelivery, error) {
+	deliveries := []PastDelivery{}
+	rerr := r.eachOwnHook(ctx, func(repo repository, hookID int64) (int, error) {
+		seen := map[string]bool{}
+		return 0, r.eachHookDelivery(ctx, repo, hookID, func(d hookDelivery) bool {
+			if d.DeliveredAt.Before(since) {
+				return false
+			}
+			if !seen[d.GUID] {
+				seen[d.GUID] = true
+				deliveries = append(deliveries, PastDelivery{
+					DeliveryID:  d.GUID,
+					Repo:        repo.owner + "/" + repo.name,
+					EventType:   d.Event,
+					DeliveredAt: d.DeliveredAt,
+					StatusCode:  d.StatusCode,
+				})
+			}
+			return true
+		})
+	})
+	if len(rerr.Failed) > 0 {
+		return nil, rerr
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].DeliveredAt.After(deliveries[j].DeliveredAt)
+	})
+	return deliveries, nil
+}
+
+// EnableRedeliveryAdmin - serve <prefix>admin/deliveries to addresses
+// allowed by the metrics IP filter, listing the past deliveries (see
+// PastDeliveries) on GET - limited with a since parameter, like ?since=6h -
+// and asking GitHub to redeliver one on POST to
+// admin/deliveries/<delivery ID>/redeliver. Unlike a local replay, the
+// redelivery is an authentic, GitHub-signed request. See Redeliver.
+//
+// As the metrics IP filter admits whole private ranges by default, POSTs
+// must also authenticate: with a client certificate (see Options.ClientCAs),
+// or with Options.AdminToken in an "Authorization: Bearer" header. Without
+// either configured, redeliveries are refused with 403.
+func (r *Responder) EnableRedeliveryAdmin() {
+	r.redeliveryAdmin = true
+}
+
+// redeliveryAdminHandler serves the redelivery admin endpoint under path
+func (r *Responder) redeliveryAdminHandler(path string) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == path {
+			if req.Method != http.MethodGet {
+				http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			var since time.Time
+			if s := req.URL.Query().Get("since"); s != "" {
+				d, err := time.ParseDuration(s)
+				if err != nil || d < 0 {
+					http.Error(resp, "invalid since - must be a duration, like 6h", http.StatusBadRequest)
+					return
+				}
+				since = r.clock().Add(-d)
+			}
+			deliveries, err := r.PastDeliveries(req.Context(), since)
+			if err != nil {
+				http.Error(resp, err.Error(), http.StatusBadGateway)
+				return
+			}
+			resp.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(resp).Encode(deliveries)
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, path+"/"), "/redeliver")
+		if id == "" || strings.Contains(id, "/") || !strings.HasSuffix(req.URL.Path, "/redeliver") {
+			http.NotFound(resp, req)
+			return
+		}
+		if req.Method != http.MethodPost {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !r.adminAuthorized(req) {
+			hlog.FromRequest(req).Warn().Str("remoteAddr", req.RemoteAddr).Msg("unauthorized redelivery request - rejecting")
+			http.Error(resp, "redelivery needs a client certificate or an admin token", http.StatusForbidden)
+			return
+		}
+		err := r.Redeliver(req.Context(), id)
+		switch {
+		case errors.Cause(err) == ErrDeliveryNotFound:
+			http.Error(resp, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(resp, err.Error(), http.StatusBadGateway)
+		default:
+			resp.WriteHeader(http.StatusAccepted)
+		}
+	})
+}
+
+// adminAuthorized - whether the request may take admin actions: it came
+// with a verified client certificate, or the admin token
+func (r *Responder) adminAuthorized(req *http.Request) bool {
+	if r.clientCAs != nil && req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	if r.adminToken == "" {
+		return false
+	}
+	got := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(r.adminToken)) == 1
+}
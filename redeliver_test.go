@@ -0,0 +1,154 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func redeliveryServer(t *testing.T, redelivered *[]string) func(w http.ResponseWriter, req *http.Request) {
+	var mu sync.Mutex
+	recent := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	older := time.Now().Add(-3 * time.Hour).Format(time.RFC3339)
+	return func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method + " " + req.URL.Path {
+		case "GET /repos/a/b/hooks":
+			fmt.Fprint(w, `[{"id":1,"config":{"url":"https://example.com/gh-callback/x"}}]`)
+		case "GET /repos/a/b/hooks/1/deliveries":
+			fmt.Fprintf(w, `[
+				{"id":14,"guid":"g3","delivered_at":%q,"status_code":200},
+				{"id":13,"guid":"g2","delivered_at":%q,"status_code":200},
+				{"id":12,"guid":"g2","delivered_at":%q,"status_code":502},
+				{"id":11,"guid":"g1","delivered_at":%q,"status_code":200}
+			]`, recent, recent, recent, older)
+		default:
+			if req.Method == "POST" {
+				*redelivered = append(*redelivered, req.URL.Path)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	var redelivered []string
+	r := testResponder(t, redeliveryServer(t, &redelivered), "a/b")
+
+	err := r.Redeliver(context.Background(), "g2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/repos/a/b/hooks/1/deliveries/13/attempts"}, redelivered)
+
+	err = r.Redeliver(context.Background(), "nope")
+	assert.Equal(t, ErrDeliveryNotFound, errors.Cause(err))
+}
+
+func TestRedeliverSince(t *testing.T) {
+	var redelivered []string
+	r := testResponder(t, redeliveryServer(t, &redelivered), "a/b")
+
+	n, err := r.RedeliverSince(context.Background(), time.Now().Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{
+		"/repos/a/b/hooks/1/deliveries/13/attempts",
+		"/repos/a/b/hooks/1/deliveries/14/attempts",
+	}, redelivered)
+}
+
+func TestRedeliverForgetsDuplicates(t *testing.T) {
+	var redelivered []string
+	r := testResponder(t, redeliveryServer(t, &redelivered), "a/b")
+	r.dedupTTL = time.Hour
+	assert.False(t, r.duplicate("push", "g3"))
+	assert.True(t, r.duplicate("push", "g3"))
+
+	err := r.Redeliver(context.Background(), "g3")
+	assert.NoError(t, err)
+	assert.False(t, r.duplicate("push", "g3"))
+}
+
+func TestPastDeliveries(t *testing.T) {
+	var redelivered []string
+	r := testResponder(t, redeliveryServer(t, &redelivered), "a/b")
+
+	deliveries, err := r.PastDeliveries(context.Background(), time.Time{})
+	assert.NoError(t, err)
+	ids := []string{}
+	for _, d := range deliveries {
+		assert.Equal(t, "a/b", d.Repo)
+		ids = append(ids, d.DeliveryID)
+	}
+	assert.Equal(t, []string{"g3", "g2", "g1"}, ids)
+	assert.Equal(t, 200, deliveries[1].StatusCode)
+
+	deliveries, err = r.PastDeliveries(context.Background(), time.Now().Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Empty(t, redelivered)
+}
+
+func TestRedeliveryAdmin(t *testing.T) {
+	var redelivered []string
+	r := testResponder(t, redeliveryServer(t, &redelivered), "a/b")
+	r.metricsIP = DefaultMetricsIPFilter
+	r.adminToken = "s3cret"
+	r.EnableRedeliveryAdmin()
+	mux := r.Handler()
+
+	token := "s3cret"
+	do := func(method, path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remote
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/admin/deliveries?since=2h", "127.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var deliveries []PastDelivery
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&deliveries))
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/deliveries?since=yesterday", "127.0.0.1:1234").Code)
+
+	assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/admin/deliveries/g2/redeliver", "127.0.0.1:1234").Code)
+	assert.Equal(t, []string{"/repos/a/b/hooks/1/deliveries/13/attempts"}, redelivered)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/admin/deliveries/nope/redeliver", "127.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "/admin/deliveries/g2/redeliver", "127.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/admin/deliveries/g2", "127.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/admin/deliveries/g2/redeliver", "192.0.2.1:1234").Code)
+
+	// listing needs only an allowed address, redelivering the token too
+	token = "wrong"
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/admin/deliveries/g2/redeliver", "127.0.0.1:1234").Code)
+	token = ""
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/admin/deliveries/g2/redeliver", "127.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/admin/deliveries", "127.0.0.1:1234").Code)
+	r.adminToken = ""
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/admin/deliveries/g2/redeliver", "127.0.0.1:1234").Code)
+	assert.Len(t, redelivered, 1)
+}
+
+func TestRedeliveryAdminWithStatusTracking(t *testing.T) {
+	r := testResponder(t, nil, "a/b")
+	r.metricsIP = DefaultMetricsIPFilter
+	r.EnableStatusTracking(10)
+	r.EnableRedeliveryAdmin()
+	assert.NotPanics(t, func() { r.Handler() })
+}
@@ -0,0 +1,259 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// payloadNamespace - the state store namespace received payloads are
+	// kept in, to diff redeliveries against
+	payloadNamespace = "responder.payloads"
+	// payloadDiffNamespace - the state store namespace redelivery diffs
+	// are kept in
+	payloadDiffNamespace = "responder.payload_diffs"
+	// maxLoggedChanges bounds how many changes are logged for a redelivery
+	maxLoggedChanges = 20
+)
+
+var redeliveryChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "github",
+	Subsystem: "webhook",
+	Name:      "redelivery_payload_changes_total",
+	Help:      "Count of redeliveries whose payload differed from the originally received one, by event type.",
+}, []string{"event"})
+
+// PayloadChange - a difference between a redelivery's payload and the
+// originally received one
+type PayloadChange struct {
+	// Path - where in the payload, like "pull_request.head.sha" or
+	// "commits[0].id"
+	Path string `json:"path"`
+	// Kind - "added", "removed", or "changed"
+	Kind string      `json:"kind"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// RedeliveryDiff - how a redelivered payload differed from the original
+type RedeliveryDiff struct {
+	DeliveryID string          `json:"deliveryID"`
+	EventType  string          `json:"eventType"`
+	Original   time.Time       `json:"original"`
+	Redelivery time.Time       `json:"redelivery"`
+	Changes    []PayloadChange `json:"changes"`
+}
+
+// receivedPayload - a payload, as kept for diffing
+type receivedPayload struct {
+	Received time.Time       `json:"received"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// payloadStore - the state store, or an in-memory store when there isn't
+// one (see dedupStore)
+func (r *Responder) payloadStore() StateStore {
+	if r.state != nil {
+		return r.state
+	}
+	if r.payloadMem == nil {
+		r.payloadMem = NewMemoryStateStore()
+	}
+	return r.payloadMem
+}
+
+// diffRedelivery - keep the payload, or if a delivery with the same ID was
+// received within the diff window, diff the two, logging and storing any
+// changes. Does nothing when diffing is off.
+func (r *Responder) diffRedelivery(log zerolog.Logger, eventType, deliveryID string, payload []byte) {
+	if r.diffWindow <= 0 || deliveryID == "" {
+		return
+	}
+	r.payloadMu.Lock()
+	defer r.payloadMu.Unlock()
+	s := r.payloadStore()
+	now := r.clock()
+	if now.Sub(r.payloadsPruned) > r.diffWindow {
+		r.payloadsPruned = now
+		prunePayloads(s, now.Add(-r.diffWindow))
+	}
+
+	var orig receivedPayload
+	b, err := s.Get(payloadNamespace, deliveryID)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to look up the original payload")
+		return
+	}
+	if b == nil || json.Unmarshal(b, &orig) != nil || now.Sub(orig.Received) >= r.diffWindow {
+		b, err = json.Marshal(receivedPayload{Received: now, Payload: payload})
+		if err == nil {
+			err = s.Put(payloadNamespace, deliveryID, b)
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to keep payload for diffing redeliveries")
+		}
+		return
+	}
+
+	changes, err := diffPayloads(orig.Payload, payload)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to diff redelivered payload")
+		return
+	}
+	if len(changes) == 0 {
+		log.Debug().Time("original", orig.Received).Msg("redelivered payload is unchanged")
+		return
+	}
+	redeliveryChanges.WithLabelValues(eventType).Inc()
+	logged := changes
+	if len(logged) > maxLoggedChanges {
+		logged = logged[:maxLoggedChanges]
+	}
+	paths := make([]string, len(logged))
+	for i, c := range logged {
+		paths[i] = c.Kind + " " + c.Path
+	}
+	log.Warn().
+		Time("original", orig.Received).
+		Int("changes", len(changes)).
+		Strs("changed", paths).
+		Msg("redelivered payload differs from the original")
+
+	b, err = json.Marshal(RedeliveryDiff{
+		DeliveryID: deliveryID,
+		EventType:  eventType,
+		Original:   orig.Received,
+		Redelivery: now,
+		Changes:    changes,
+	})
+	if err == nil {
+		err = s.Put(payloadDiffNamespace, deliveryID, b)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to store redelivery diff")
+	}
+}
+
+// RedeliveryDiff - how the last redelivery of the delivery differed from
+// the originally received payload (see Options.RedeliveryDiffWindow), or
+// nil if no redelivery has differed
+func (r *Responder) RedeliveryDiff(deliveryID string) (*RedeliveryDiff, error) {
+	r.payloadMu.Lock()
+	defer r.payloadMu.Unlock()
+	b, err := r.payloadStore().Get(payloadDiffNamespace, deliveryID)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	d := &RedeliveryDiff{}
+	err = json.Unmarshal(b, d)
+	return d, err
+}
+
+// prunePayloads removes the payloads received before the cutoff, and their
+// diffs
+func prunePayloads(s StateStore, cutoff time.Time) {
+	keys, err := s.Keys(payloadNamespace)
+	if err != nil {
+		return
+	}
+	for _, k := range keys {
+		b, err := s.Get(payloadNamespace, k)
+		if err != nil {
+			continue
+		}
+		var p receivedPayload
+		if json.Unmarshal(b, &p) != nil || p.Received.Before(cutoff) {
+			_ = s.Delete(payloadNamespace, k)
+			_ = s.Delete(payloadDiffNamespace, k)
+		}
+	}
+}
+
+// diffPayloads - the structural differences between two JSON payloads
+func diffPayloads(a, b []byte) ([]PayloadChange, error) {
+	va, err := decodeJSON(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	changes := []PayloadChange{}
+	diffValues("", va, vb, &changes)
+	return changes, nil
+}
+
+// decodeJSON decodes a payload, keeping numbers as written
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// diffValues appends the differences between a and b, found at path, to
+// changes. Objects are compared key by key, and arrays element by element.
+func diffValues(path string, a, b interface{}, changes *[]PayloadChange) {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				va, inA := a[k]
+				vb, inB := b[k]
+				switch {
+				case !inA:
+					*changes = append(*changes, PayloadChange{Path: p, Kind: "added", New: vb})
+				case !inB:
+					*changes = append(*changes, PayloadChange{Path: p, Kind: "removed", Old: va})
+				default:
+					diffValues(p, va, vb, changes)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			for i := 0; i < len(a) || i < len(b); i++ {
+				p := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(a):
+					*changes = append(*changes, PayloadChange{Path: p, Kind: "added", New: b[i]})
+				case i >= len(b):
+					*changes = append(*changes, PayloadChange{Path: p, Kind: "removed", Old: a[i]})
+				default:
+					diffValues(p, a[i], b[i], changes)
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		if path == "" {
+			path = "."
+		}
+		*changes = append(*changes, PayloadChange{Path: path, Kind: "changed", Old: a, New: b})
+	}
+}
@@ -0,0 +1,77 @@
+package responder
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPayloads(t *testing.T) {
+	changes, err := diffPayloads(
+		[]byte(`{"action": "opened", "number": 12345678901234567890, "labels": ["a", "b"], "head": {"sha": "abc"}, "gone": true}`),
+		[]byte(`{"action": "opened", "number": 12345678901234567890, "labels": ["a"], "head": {"sha": "def"}, "new": null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []PayloadChange{
+		{Path: "gone", Kind: "removed", Old: true},
+		{Path: "head.sha", Kind: "changed", Old: "abc", New: "def"},
+		{Path: "labels[1]", Kind: "removed", Old: "b"},
+		{Path: "new", Kind: "added"},
+	}, changes)
+
+	changes, err = diffPayloads([]byte(`{"a": [1, {"b": 2}]}`), []byte(` {"a":[1,{"b":2}]}`))
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = diffPayloads([]byte(`{"a": 1}`), []byte(`[1]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []PayloadChange{{Path: ".", Kind: "changed", Old: map[string]interface{}{"a": json.Number("1")}, New: []interface{}{json.Number("1")}}}, changes)
+
+	_, err = diffPayloads([]byte(`{}`), []byte(`nope`))
+	assert.Error(t, err)
+}
+
+func TestDiffRedelivery(t *testing.T) {
+	now := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := &Responder{diffWindow: time.Hour, now: func() time.Time { return now }}
+	log := zerolog.Nop()
+
+	r.diffRedelivery(log, "push", "d1", []byte(`{"ref": "master"}`))
+	d, err := r.RedeliveryDiff("d1")
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+
+	// unchanged
+	now = now.Add(time.Minute)
+	r.diffRedelivery(log, "push", "d1", []byte(`{"ref": "master"}`))
+	d, err = r.RedeliveryDiff("d1")
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+
+	now = now.Add(time.Minute)
+	r.diffRedelivery(log, "push", "d1", []byte(`{"ref": "develop"}`))
+	d, err = r.RedeliveryDiff("d1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, d) {
+		assert.Equal(t, "push", d.EventType)
+		assert.Equal(t, now.Add(-2*time.Minute), d.Original)
+		assert.Equal(t, now, d.Redelivery)
+		assert.Equal(t, []PayloadChange{{Path: "ref", Kind: "changed", Old: "master", New: "develop"}}, d.Changes)
+	}
+
+	// outside the window, payloads are pruned, with their diffs
+	now = now.Add(2 * time.Hour)
+	r.diffRedelivery(log, "push", "d2", []byte(`{}`))
+	d, err = r.RedeliveryDiff("d1")
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+	keys, _ := r.payloadStore().Keys(payloadNamespace)
+	assert.Equal(t, []string{"d2"}, keys)
+
+	// off
+	r = &Responder{}
+	r.diffRedelivery(log, "push", "d1", []byte(`{}`))
+	assert.Nil(t, r.payloadMem)
+}
@@ -0,0 +1,156 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResponder(t *testing.T, handler http.HandlerFunc, repos ...string) *Responder {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	r := &Responder{
+		ghclient:            client,
+		domain:              "example.com",
+		pathPrefix:          "/",
+		events:              []string{"*"},
+		registerConcurrency: 2,
+	}
+	var repositories []repository
+	for _, s := range repos {
+		repo, err := parseRepo(s)
+		assert.NoError(t, err)
+		repositories = append(repositories, repo)
+	}
+	r.endpoints = []*endpoint{r.newEndpoint(repositories, nil)}
+	return r
+}
+
+func TestRegister(t *testing.T) {
+	var mu sync.Mutex
+	created := map[string]bool{}
+	var inFlight, maxInFlight int32
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		repo := strings.Join(strings.Split(req.URL.Path, "/")[2:4], "/")
+		switch req.Method {
+		case "POST":
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&maxInFlight)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if strings.HasPrefix(repo, "bad/") {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"message":"Validation Failed","errors":[{"message":"Hook already exists on this repository"}]}`)
+				return
+			}
+			mu.Lock()
+			created[repo] = true
+			mu.Unlock()
+			fmt.Fprint(w, `{"id":1}`)
+		case "DELETE":
+			mu.Lock()
+			delete(created, repo)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}, "a/1", "a/2", "a/3", "a/4", "a/5")
+
+	ctx := context.Background()
+	cleanup, err := r.Register(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, created, 5)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+	cleanup()
+	assert.Empty(t, created)
+
+	r.endpoints[0].repos = append(r.endpoints[0].repos, repository{owner: "bad", name: "1"})
+	_, err = r.Register(ctx, nil)
+	assert.Error(t, err)
+	rerr, ok := err.(*RegistrationError)
+	assert.True(t, ok)
+	assert.Contains(t, rerr.Failed, "bad/1")
+	assert.Equal(t, 5, rerr.Succeeded+rerr.Skipped)
+	assert.Equal(t, ErrHookExists, errors.Cause(err))
+	assert.Empty(t, created, "hooks should be rolled back")
+}
+
+func TestRegisterECleanupError(t *testing.T) {
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == "POST":
+			fmt.Fprint(w, `{"id":1}`)
+		case strings.HasPrefix(req.URL.Path, "/repos/gone/"):
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"Must have admin rights to Repository."}`)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}, "a/1", "gone/1", "a/2")
+
+	cleanup, err := r.RegisterE(context.Background(), nil)
+	assert.NoError(t, err)
+	err = cleanup()
+	cerr, ok := err.(*CleanupError)
+	assert.True(t, ok)
+	assert.Equal(t, 2, cerr.Succeeded)
+	assert.Len(t, cerr.Failed, 1)
+	assert.Contains(t, cerr.Failed, "gone/1")
+	assert.Contains(t, err.Error(), "failed to remove hooks from 1 repos: gone/1: ")
+}
+
+func TestRegistrationError(t *testing.T) {
+	err := &RegistrationError{Failed: map[string]error{"foo/bar": errors.New("boom")}}
+	assert.EqualError(t, err, "failed to create hook for foo/bar: boom")
+
+	err.Failed["baz/qux"] = ErrHookExists
+	assert.EqualError(t, err, "failed to create hooks for 2 repos: baz/qux: hook already exists; foo/bar: boom")
+	assert.Equal(t, ErrHookExists, errors.Cause(err))
+}
+
+func TestPacer(t *testing.T) {
+	ctx := context.Background()
+	var p *pacer
+	p.hold(time.Hour)
+	assert.NoError(t, p.wait(ctx))
+
+	p = &pacer{}
+	assert.NoError(t, p.wait(ctx))
+	p.hold(20 * time.Millisecond)
+	start := time.Now()
+	assert.NoError(t, p.wait(ctx))
+	assert.True(t, time.Since(start) >= 15*time.Millisecond)
+
+	p.hold(time.Hour)
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.wait(ctx))
+}
+
+func TestRetryAfter(t *testing.T) {
+	d := 5 * time.Second
+	assert.Equal(t, d, retryAfter(&github.AbuseRateLimitError{RetryAfter: &d}))
+	assert.Equal(t, time.Duration(0), retryAfter(&github.AbuseRateLimitError{}))
+	assert.Equal(t, time.Duration(0), retryAfter(errors.New("other")))
+
+	reset := github.Timestamp{Time: time.Now().Add(time.Minute)}
+	assert.InDelta(t, float64(time.Minute), float64(retryAfter(&github.RateLimitError{Rate: github.Rate{Reset: reset}})), float64(time.Second))
+}
@@ -0,0 +1,73 @@
+package responder
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"hash"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// RelayTokenHeader - the header internal relays send the shared token in (see
+// Options.TrustedRelayToken)
+const RelayTokenHeader = "X-Responder-Relay-Token"
+
+// trustedRelay reports whether the request carries the trusted relay token
+func trustedRelay(req *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	got := req.Header.Get(RelayTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// validatePayload reads the payload, validating the GitHub signature unless
+// the request came from a trusted relay. The signature is checked before
+// the body is read, and the body's HMAC computed as it's read, so the body
+// is only read once - and not at all when the signature's missing.
+func (e *endpoint) validatePayload(req *http.Request) (payload []byte, relayed bool, err error) {
+	trusted := trustedRelay(req, e.r.relayToken)
+	// the token mustn't end up in recorded or scheduled deliveries
+	req.Header.Del(RelayTokenHeader)
+	payload, err = readPayload(req, []byte(e.secret), !trusted)
+	return payload, trusted && err == nil, err
+}
+
+// readPayload reads the request's payload (from JSON or form-encoded
+// bodies), validating its signature against the secret when verify is set
+func readPayload(req *http.Request, secret []byte, verify bool) ([]byte, error) {
+	ct, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Content-Type")
+	}
+	if ct != "application/json" && ct != "application/x-www-form-urlencoded" {
+		return nil, errors.Errorf("Webhook request has unsupported Content-Type %q", ct)
+	}
+	var want []byte
+	var mac hash.Hash
+	if verify {
+		want, mac, err = signatureMAC(req.Header.Get(signatureHeader), secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := readBody(req, mac)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read payload")
+	}
+	if mac != nil && !hmac.Equal(mac.Sum(nil), want) {
+		return nil, errors.New("payload signature check failed")
+	}
+	if ct == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse form payload")
+		}
+		return []byte(form.Get("payload")), nil
+	}
+	return body, nil
+}
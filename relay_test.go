@@ -0,0 +1,61 @@
+package responder
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedRelay(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", nil)
+	assert.False(t, trustedRelay(req, ""))
+	assert.False(t, trustedRelay(req, "secret"))
+
+	req.Header.Set(RelayTokenHeader, "wrong")
+	assert.False(t, trustedRelay(req, "secret"))
+	req.Header.Set(RelayTokenHeader, "secret")
+	assert.True(t, trustedRelay(req, "secret"))
+	assert.False(t, trustedRelay(req, ""))
+}
+
+func TestValidatePayloadRelayed(t *testing.T) {
+	payload := []byte(`{"zen":"hi"}`)
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	ep := r.newEndpoint(nil, nil)
+
+	// unsigned, no relay token configured
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RelayTokenHeader, "secret")
+	_, _, err := ep.validatePayload(req)
+	assert.Error(t, err)
+
+	r.relayToken = "secret"
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(RelayTokenHeader, "secret")
+	got, relayed, err := ep.validatePayload(req)
+	assert.NoError(t, err)
+	assert.True(t, relayed)
+	assert.Equal(t, payload, got)
+
+	form := url.Values{"payload": {string(payload)}}.Encode()
+	req = httptest.NewRequest("POST", "/", bytes.NewReader([]byte(form)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(RelayTokenHeader, "secret")
+	got, _, err = ep.validatePayload(req)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, got)
+
+	// signed deliveries still work without the token
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature", sign(payload, []byte(ep.secret)))
+	got, relayed, err = ep.validatePayload(req)
+	assert.NoError(t, err)
+	assert.False(t, relayed)
+	assert.Equal(t, payload, got)
+}
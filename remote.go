@@ -0,0 +1,239 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" // nolint: gosec
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// maxRemoteResponse bounds how much of a worker's response is read
+const maxRemoteResponse = 1 << 20
+
+// RemoteOptions - how a RemoteHandler calls its worker. Zero values get
+// defaults.
+type RemoteOptions struct {
+	// Client - the HTTP client to call the worker with (defaults to
+	// http.DefaultClient)
+	Client *http.Client
+	// Secret - when set, requests are signed like GitHub's deliveries (an
+	// X-Hub-Signature header), so workers can validate them the same way
+	Secret string
+	// Timeout - how long to wait for each attempt (default 30s)
+	Timeout time.Duration
+	// Attempts - how many times to call the worker before giving up, for
+	// transient failures (default 3)
+	Attempts int
+	// Backoff - how long to wait before the first retry, doubling each time
+	// (default 1s)
+	Backoff time.Duration
+	// BreakerThreshold - how many consecutive failed calls open the circuit
+	// breaker, so that calls fail fast instead of piling up on a struggling
+	// worker (default 5)
+	BreakerThreshold int
+	// BreakerCooldown - how long the breaker stays open, before a single
+	// trial call is let through (default 30s)
+	BreakerCooldown time.Duration
+}
+
+func (o RemoteOptions) withDefaults() RemoteOptions {
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.Attempts <= 0 {
+		o.Attempts = 3
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.BreakerThreshold <= 0 {
+		o.BreakerThreshold = 5
+	}
+	if o.BreakerCooldown <= 0 {
+		o.BreakerCooldown = 30 * time.Second
+	}
+	return o
+}
+
+// RemoteResult - the structured response a worker returns. A 2xx response
+// with an empty body is a success.
+type RemoteResult struct {
+	// Status - "success" or "failure"
+	Status string `json:"status"`
+	// Message - details of the result, logged (and reported, for failures)
+	Message string `json:"message,omitempty"`
+	// Retry - whether a failure is transient, and the call worth retrying
+	Retry bool `json:"retry,omitempty"`
+}
+
+// RemoteHandler - a handler that invokes an external worker, so handler
+// logic can be deployed and scaled separately from the receiver. Each
+// delivery is POSTed to url as JSON, with the X-GitHub-Event and
+// X-GitHub-Delivery headers GitHub sends, and the worker responds with a
+// RemoteResult. Network errors, timeouts, 429s, 5xx responses, and results
+// with Retry set are retried with backoff; other failures are reported
+// (see ReportFailure) straight away.
+func RemoteHandler(url string, opts RemoteOptions) HookHandler {
+	handler := RemoteHandlerE(url, opts)
+	return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		err := handler(ctx, eventType, deliveryID, payload)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Str("worker", url).Msg("remote handler failed")
+			ReportFailure(ctx, err)
+		}
+	}
+}
+
+// RemoteHandlerE - like RemoteHandler, but returns the failure instead of
+// reporting it, e.g. for use with Gate
+func RemoteHandlerE(url string, opts RemoteOptions) HookHandlerE {
+	opts = opts.withDefaults()
+	w := &remoteWorker{url: url, opts: opts, breaker: &breaker{
+		threshold: opts.BreakerThreshold,
+		cooldown:  opts.BreakerCooldown,
+		now:       time.Now,
+	}}
+	return w.handle
+}
+
+type remoteWorker struct {
+	url     string
+	opts    RemoteOptions
+	breaker *breaker
+}
+
+func (w *remoteWorker) handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	log := zerolog.Ctx(ctx)
+	wait := w.opts.Backoff
+	for i := 1; ; i++ {
+		if !w.breaker.allow() {
+			return errors.Errorf("circuit breaker open for worker %s - not calling it", w.url)
+		}
+		res, retry, err := w.call(ctx, eventType, deliveryID, payload)
+		w.breaker.record(err == nil || !retry)
+		if err == nil {
+			log.Debug().Str("worker", w.url).Str("message", res.Message).Msg("remote handler succeeded")
+			return nil
+		}
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+		if i >= w.opts.Attempts {
+			return errors.Wrapf(err, "gave up after %d attempts", i)
+		}
+		log.Warn().Err(err).
+			Str("worker", w.url).
+			Int("attempt", i).
+			Dur("backoff", wait).
+			Msg("remote handler failed - retrying")
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return errors.Wrapf(err, "gave up after %d attempts", i)
+		}
+		wait *= 2
+	}
+}
+
+// call - make one call to the worker, returning its result, and whether a
+// failure is worth retrying
+func (w *remoteWorker) call(ctx context.Context, eventType, deliveryID string, payload []byte) (res RemoteResult, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, w.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return res, false, errors.Wrapf(err, "invalid worker URL %q", w.url)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "github-responder")
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-GitHub-Delivery", deliveryID)
+	if w.opts.Secret != "" {
+		mac := hmac.New(sha1.New, []byte(w.opts.Secret))
+		_, _ = mac.Write(payload)
+		req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return res, true, errors.Wrapf(err, "failed to call worker %s", w.url)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteResponse))
+	if err != nil {
+		return res, true, errors.Wrapf(err, "failed to read response from worker %s", w.url)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if jerr := json.Unmarshal(body, &res); jerr != nil && resp.StatusCode < 300 {
+			return res, false, errors.Wrapf(jerr, "invalid response from worker %s", w.url)
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return res, true, errors.Errorf("worker %s responded %s: %s", w.url, resp.Status, res.Message)
+	case resp.StatusCode >= 300:
+		return res, res.Retry, errors.Errorf("worker %s responded %s: %s", w.url, resp.Status, res.Message)
+	case res.Status == "" || res.Status == "success":
+		return res, false, nil
+	case res.Status == "failure":
+		return res, res.Retry, errors.Errorf("worker %s failed: %s", w.url, res.Message)
+	}
+	return res, false, errors.Errorf("worker %s responded with unknown status %q", w.url, res.Status)
+}
+
+// breaker - a circuit breaker. After threshold consecutive failures it
+// opens, refusing calls until cooldown has passed, then lets one trial call
+// through - closing again if it succeeds, or reopening if it fails.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// allow - whether a call may be made now
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record - record the outcome of an allowed call
+func (b *breaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,117 @@
+package responder
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteHandler(t *testing.T) {
+	calls := 0
+	responses := []func(w http.ResponseWriter){}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "push", req.Header.Get("X-GitHub-Event"))
+		assert.Equal(t, "d1", req.Header.Get("X-GitHub-Delivery"))
+		payload, err := github.ValidatePayload(req, []byte("s3cret"))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"ref":"master"}`, string(payload))
+		responses[calls](w)
+		calls++
+	}))
+	defer srv.Close()
+	respond := func(code int, body string) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(body))
+		}
+	}
+
+	h := RemoteHandlerE(srv.URL, RemoteOptions{Secret: "s3cret", Backoff: time.Millisecond})
+	call := func() error {
+		return h(context.Background(), "push", "d1", []byte(`{"ref":"master"}`))
+	}
+
+	responses = append(responses, respond(200, ""))
+	assert.NoError(t, call())
+
+	// transient failures are retried
+	calls = 0
+	responses = []func(w http.ResponseWriter){
+		respond(503, ""),
+		respond(200, `{"status": "failure", "message": "busy", "retry": true}`),
+		respond(200, `{"status": "success", "message": "done"}`),
+	}
+	assert.NoError(t, call())
+	assert.Equal(t, 3, calls)
+
+	// permanent ones aren't
+	calls = 0
+	responses = []func(w http.ResponseWriter){respond(200, `{"status": "failure", "message": "bad config"}`)}
+	assert.EqualError(t, call(), "worker "+srv.URL+" failed: bad config")
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	responses = []func(w http.ResponseWriter){respond(400, `{"message": "bad payload"}`)}
+	assert.EqualError(t, call(), "worker "+srv.URL+" responded 400 Bad Request: bad payload")
+
+	calls = 0
+	responses = []func(w http.ResponseWriter){respond(500, ""), respond(500, ""), respond(500, "")}
+	assert.Contains(t, call().Error(), "gave up after 3 attempts")
+	assert.Equal(t, 3, calls)
+
+	// failures are reported by RemoteHandler
+	calls = 0
+	responses = []func(w http.ResponseWriter){respond(200, `{"status": "failure", "message": "nope"}`)}
+	res := &handlerResult{}
+	ctx := context.WithValue(context.Background(), shadowKey, res)
+	RemoteHandler(srv.URL, RemoteOptions{Secret: "s3cret"})(ctx, "push", "d1", []byte(`{"ref":"master"}`))
+	assert.EqualError(t, res.err, "worker "+srv.URL+" failed: nope")
+}
+
+func TestRemoteHandlerTimeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = ioutil.ReadAll(req.Body)
+		select {
+		case <-done:
+		case <-req.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	h := RemoteHandlerE(srv.URL, RemoteOptions{Timeout: 10 * time.Millisecond, Attempts: 2, Backoff: time.Millisecond})
+	err := h(context.Background(), "push", "d1", []byte(`{}`))
+	assert.Contains(t, err.Error(), "gave up after 2 attempts")
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := &breaker{threshold: 2, cooldown: time.Minute, now: func() time.Time { return now }}
+
+	assert.True(t, b.allow())
+	b.record(false)
+	assert.True(t, b.allow())
+	b.record(false)
+	// open
+	assert.False(t, b.allow())
+
+	// half-open - one trial call is let through
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+	b.record(false)
+	assert.False(t, b.allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow())
+	b.record(true)
+	assert.True(t, b.allow())
+	assert.True(t, b.allow())
+}
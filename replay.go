@@ -0,0 +1,77 @@
+package responder
+
+import (
+	"context"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/rs/zerolog/log"
+)
+
+// ReplayOptions - options for Replay
+type ReplayOptions struct {
+	// Secret to validate the delivery's recorded signature with. When nil,
+	// the signature isn't checked.
+	Secret []byte
+	// Client - the GitHub client made available to handlers through
+	// GitHubClient. Optional.
+	Client *github.Client
+	// DispatchPings - also pass ping events to the actions
+	DispatchPings bool
+	// Key - decrypts encrypted delivery files read by WatchDeliveries
+	Key KeyUnwrapper
+	// State - the store made available to handlers through State. Optional.
+	State StateStore
+}
+
+// Replay - feed a recorded delivery through the actions, as if it had just
+// been received, and wait for them to finish. Useful for debugging handlers
+// against real events without a server or webhook.
+//
+// Like live deliveries, pings aren't passed to actions unless
+// opts.DispatchPings is set.
+func Replay(ctx context.Context, d *Delivery, opts ReplayOptions, actions ...HookHandler) error {
+	routes := make([]Route, len(actions))
+	for i, a := range actions {
+		routes[i] = Route{Handler: a}
+	}
+	return replay(ctx, d, opts, routes)
+}
+
+// Replay - feed a recorded delivery through the Responder's primary endpoint,
+// and wait for the actions to finish. See the package-level Replay.
+func (r *Responder) Replay(ctx context.Context, d *Delivery, secret []byte) error {
+	opts := ReplayOptions{Secret: secret, Client: r.ghclient, DispatchPings: r.dispatchPings, State: r.state}
+	return replay(ctx, d, opts, r.endpoints[0].routes)
+}
+
+func replay(ctx context.Context, d *Delivery, opts ReplayOptions, routes []Route) error {
+	if opts.Secret != nil {
+		err := d.validate(opts.Secret)
+		if err != nil {
+			return err
+		}
+	}
+
+	info := parseEventInfo(d.Payload)
+	l := info.withFields(log.With().
+		Str("eventType", d.EventType).
+		Str("deliveryID", d.DeliveryID).
+		Bool("replay", true)).Logger()
+	l.Info().Msg("Replaying delivery")
+
+	if d.EventType == "ping" && !opts.DispatchPings {
+		return nil
+	}
+
+	ctx = l.WithContext(ctx)
+	di := parseDeliveryInfo(d.Headers)
+	di.EventType, di.DeliveryID = d.EventType, d.DeliveryID
+	di.InstallationID = info.Installation.ID
+	ctx = withDeliveryInfo(ctx, di)
+	ctx = withStateStore(ctx, opts.State)
+	if opts.Client != nil {
+		ctx = withGitHubClient(ctx, opts.Client)
+	}
+	dispatch(ctx, routes, d.EventType, d.DeliveryID, d.Payload).Wait()
+	return nil
+}
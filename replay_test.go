@@ -0,0 +1,82 @@
+package responder
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(payload, secret []byte) string {
+	mac := hmac.New(sha1.New, secret)
+	_, _ = mac.Write(payload)
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestReadAndFindDelivery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deliveries")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "20190102T030405-abc-123.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"headers": {"X-Github-Event": ["push"], "X-Github-Delivery": ["abc-123"]},
+		"payload": {"ref": "refs/heads/master"}
+	}`), 0600)
+	assert.NoError(t, err)
+
+	found, err := FindDelivery(dir, "abc-123")
+	assert.NoError(t, err)
+	assert.Equal(t, path, found)
+
+	_, err = FindDelivery(dir, "nope")
+	assert.True(t, os.IsNotExist(errors.Cause(err)))
+
+	d, err := ReadDelivery(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "push", d.EventType)
+	assert.Equal(t, "abc-123", d.DeliveryID)
+	assert.JSONEq(t, `{"ref": "refs/heads/master"}`, string(d.Payload))
+
+	err = ioutil.WriteFile(path, []byte(`{"event_type": "push"}`), 0600)
+	assert.NoError(t, err)
+	_, err = ReadDelivery(path)
+	assert.Error(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	payload := []byte(`{"action":"opened"}`)
+	d := &Delivery{EventType: "issues", DeliveryID: "1", Payload: payload}
+
+	var calls int32
+	action := func(ctx context.Context, eventType, deliveryID string, p []byte) {
+		atomic.AddInt32(&calls, 1)
+		assert.Equal(t, "issues", eventType)
+		assert.Equal(t, payload, p)
+	}
+
+	err := Replay(ctx, d, ReplayOptions{}, action, action)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	err = Replay(ctx, d, ReplayOptions{Secret: []byte("secret")}, action)
+	assert.Equal(t, ErrSignatureInvalid, errors.Cause(err))
+
+	d.Headers = http.Header{"X-Hub-Signature": []string{sign(payload, []byte("secret"))}}
+	err = Replay(ctx, d, ReplayOptions{Secret: []byte("secret")}, action)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	err = Replay(ctx, d, ReplayOptions{Secret: []byte("wrong")}, action)
+	assert.Equal(t, ErrSignatureInvalid, errors.Cause(err))
+}
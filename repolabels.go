@@ -0,0 +1,95 @@
+package responder
+
+import (
+	"sync"
+)
+
+const (
+	// otherRepos is the repo label value for repos outside the cardinality budget
+	otherRepos = "other"
+	// repoCountDecay - every this many deliveries, all repos' counts are
+	// halved (and repos counted down to 0 forgotten), so the counts favour
+	// recent activity, and don't grow with every repo ever seen
+	repoCountDecay = 10000
+)
+
+// repoLabeler picks the value of the repo label on per-repo metrics. Only the
+// busiest repos (up to the budget) get their own label value - all others
+// share the "other" value, so watching a large org can't create an unbounded
+// number of series.
+type repoLabeler struct {
+	budget int
+	// evict is called when a repo loses its own label value, so its series
+	// can be deleted
+	evict func(repo string)
+
+	mu     sync.Mutex
+	counts map[string]int
+	top    map[string]bool
+	// total - deliveries counted since the counts last decayed
+	total int
+}
+
+func newRepoLabeler(budget int, evict func(string)) *repoLabeler {
+	return &repoLabeler{
+		budget: budget,
+		evict:  evict,
+		counts: map[string]int{},
+		top:    map[string]bool{},
+	}
+}
+
+// label counts a delivery for the repo and returns the label value to use.
+// A nil labeler (per-repo labels disabled) always returns "", as it does for
+// deliveries that aren't for a repo - those aren't counted against the budget.
+func (l *repoLabeler) label(repo string) string {
+	if l == nil || repo == "" {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total++
+	if l.total >= repoCountDecay {
+		l.decay()
+	}
+	l.counts[repo]++
+	if l.top[repo] {
+		return repo
+	}
+	if len(l.top) < l.budget {
+		l.top[repo] = true
+		return repo
+	}
+
+	// find the quietest labelled repo, and replace it if this one has become
+	// busier - twice as busy, to avoid flapping between similar repos
+	min := ""
+	for r := range l.top {
+		if min == "" || l.counts[r] < l.counts[min] {
+			min = r
+		}
+	}
+	if min == "" || l.counts[repo] < 2*l.counts[min] {
+		return otherRepos
+	}
+	delete(l.top, min)
+	l.top[repo] = true
+	if l.evict != nil {
+		l.evict(min)
+	}
+	return repo
+}
+
+// decay halves all the counts, forgetting repos counted down to 0. Must be
+// called with the lock held.
+func (l *repoLabeler) decay() {
+	l.total = 0
+	for r, n := range l.counts {
+		if n/2 == 0 {
+			delete(l.counts, r)
+			continue
+		}
+		l.counts[r] = n / 2
+	}
+}
@@ -0,0 +1,50 @@
+package responder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepoLabeler(t *testing.T) {
+	var l *repoLabeler
+	assert.Equal(t, "", l.label("foo/bar"))
+
+	evicted := []string{}
+	l = newRepoLabeler(2, func(r string) { evicted = append(evicted, r) })
+	assert.Equal(t, "a/a", l.label("a/a"))
+	assert.Equal(t, "a/b", l.label("a/b"))
+	assert.Equal(t, "other", l.label("a/c"))
+	assert.Equal(t, "a/a", l.label("a/a"))
+
+	// a/c needs twice as many deliveries as a/b before displacing it
+	assert.Equal(t, "a/c", l.label("a/c"))
+	assert.Equal(t, []string{"a/b"}, evicted)
+	assert.Equal(t, "other", l.label("a/b"))
+
+	// deliveries without a repo don't take a slot
+	l = newRepoLabeler(1, nil)
+	assert.Equal(t, "", l.label(""))
+	assert.Equal(t, "a/a", l.label("a/a"))
+	assert.NotContains(t, l.counts, "")
+
+	l = newRepoLabeler(0, nil)
+	assert.Equal(t, "other", l.label("a/a"))
+}
+
+func TestRepoLabelerDecay(t *testing.T) {
+	l := newRepoLabeler(1, nil)
+	for i := 0; i < 4; i++ {
+		l.label("a/a")
+	}
+	l.label("a/b")
+	for i := 0; i < repoCountDecay-6; i++ {
+		l.label("a/c")
+	}
+	assert.Equal(t, map[string]int{"a/a": 4, "a/b": 1, "a/c": repoCountDecay - 6}, l.counts)
+
+	// the next delivery halves the counts first, forgetting a/b
+	assert.Equal(t, "a/c", l.label("a/c"))
+	assert.Equal(t, map[string]int{"a/a": 2, "a/c": (repoCountDecay-6)/2 + 1}, l.counts)
+	assert.Equal(t, 0, l.total)
+}
@@ -2,14 +2,16 @@ package responder
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
-	"math/rand"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -22,7 +24,6 @@ import (
 	"github.com/google/go-github/v24/github"
 	"github.com/justinas/alice"
 	"github.com/mholt/certmagic"
-	uuid "github.com/satori/go.uuid"
 	"golang.org/x/oauth2"
 )
 
@@ -33,127 +34,692 @@ const (
 type repository struct {
 	owner string
 	name  string
+	// id is set when the repo was given by numeric ID - owner and name are
+	// resolved through the API when registering
+	id int64
 }
 
 // Responder -
 type Responder struct {
-	ghclient    *github.Client
-	secret      string
-	repos       []repository
-	events      []string
-	callbackURL string
-	actions     []HookHandler
-	domain      string
+	ghclient   *github.Client
+	endpoints  []*endpoint
+	events     []string
+	hookConfig map[string]interface{}
+	domain     string
+	pathPrefix string
+	httpPort   int
+	httpsPort  int
+	addr       string
+	listener   net.Listener
+	// trustedProxies - set behind a proxy (see Options.BehindProxy)
+	trustedProxies *IPFilter
+	// registerConcurrency - max hooks created at once
+	registerConcurrency int
+	callbackIP          *IPFilter
+	hookIPs             *hookIPWatcher
+	rateLimit           *rateLimiter
+	inFlight            *inFlightLimit
+	maxPayload          int64
+	metricsIP           *IPFilter
+	repoLabels          *repoLabeler
+	accessLog           *zerolog.Logger
+	drift               driftDetector
+	lifecycle           lifecycle
+	recorder            DeliveryStore
+	status              *statusTracker
+	installations       installationRegistry
+	dispatchPings       bool
+	deliveryBudget      time.Duration
+	app                 *appAuth
+	relayToken          string
+	shadows             []HookHandler
+	middleware          []Middleware
+	crash               *crashReporter
+	transport           http.RoundTripper
+	quota               *apiQuota
+	annotationRenderer  AnnotationRenderer
+	topics              topicCache
+	state               StateStore
+	scheduleMu          sync.Mutex
+	scheduleMem         StateStore
+	shims               []Shim
+	certWatch           certWatcher
+	dnsProvider         DNSProvider
+	certStorage         certmagic.Storage
+	acmeCA              string
+	acmeEmail           string
+	acmeEAB             eabKeys
+	queueSnapshot       string
+	appWebhook          bool
+	pins                map[string][]string
+	hookSecret          string
+	pathStrategy        CallbackPathStrategy
+	callbackPath        string
+	hydrate             bool
+	sync                bool
+	pool                *workerPool
+	dns                 *dnsPreflight
+	domains             []string
+	domainFor           func(repo string) string
+	dedupTTL            time.Duration
+	permCheck           bool
+	wal                 StateStore
+	backfill            bool
+	now                 func() time.Time
+	random              io.Reader
+	installationID      int64
+	dedupMu             sync.Mutex
+	dedupMem            StateStore
+	dedupPruned         time.Time
+	diffWindow          time.Duration
+	compression         Compression
+	payloadMu           sync.Mutex
+	payloadMem          StateStore
+	payloadsPruned      time.Time
+	adoptHooks          bool
+	observe             bool
+	shutdownPolicy      HookShutdownPolicy
+	maintenanceMu       sync.Mutex
+	maintenanceOn       bool
+	maintenance         []MaintenanceWindow
+	signals             []os.Signal
+	drainTimeout        time.Duration
+	srv                 serving
+	handlersMu          sync.Mutex
+	disabledHandlers    map[string]bool
+	handlerAdmin        bool
+	redeliveryAdmin     bool
+	adminToken          string
+	topologyEndpoint    bool
+	clientCAs           *x509.CertPool
+	clientCertCallbacks bool
+	stopMu              sync.Mutex
+	stop                chan struct{}
 }
 
-// New -
+// New - create a Responder watching the given repos, with default options.
+// See NewWithOptions.
 func New(repos []string, domain string, actions ...HookHandler) (*Responder, error) {
-	if len(repos) == 0 {
-		return nil, errors.New("must provide repo")
+	return NewWithOptions(Options{Repos: repos, Domain: domain}, actions...)
+}
+
+// NewWithOptions - create a Responder. The options are validated first, so
+// misconfiguration fails here rather than when registering or listening.
+func NewWithOptions(opts Options, actions ...HookHandler) (*Responder, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+	for _, e := range opts.Events {
+		if !knownEvent(e) {
+			log.Warn().Str("event", e).Msg("unknown event - GitHub may reject the hook, or never deliver it (see https://docs.github.com/webhooks/webhook-events-and-payloads)")
+		}
 	}
 
 	var repositories []repository
-	for _, r := range repos {
-		repoParts := strings.SplitN(r, "/", 2)
-		if len(repoParts) != 2 {
-			return nil, errors.Errorf("invalid repo %s - need 'owner/repo' form", r)
-		}
-		repositories = append(repositories, repository{repoParts[0], repoParts[1]})
+	for _, r := range opts.Repos {
+		repo, _ := parseRepo(r)
+		repositories = append(repositories, repo)
 	}
 
-	// init callback URL
-	callbackURL := buildCallbackURL(domain)
+	var trustedProxies *IPFilter
+	if opts.BehindProxy {
+		trustedProxies, err = NewIPFilter(opts.TrustedProxies, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// choose random secret
-	secret := fmt.Sprintf("%x", rand.Int63())
+	transport, err := proxyTransport(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	proxyACME(opts.Proxy)
+	acmeCA, _ := acmeDirectory(opts.ACMECA)
+	var clientCAs *x509.CertPool
+	if len(opts.ClientCAs) > 0 {
+		clientCAs, _ = clientCAPool(opts.ClientCAs)
+	}
 
-	token := os.Getenv(ghtokName)
-	if token == "" {
-		return nil, errors.Errorf("GitHub API token missing - must set %s", ghtokName)
+	quota := newAPIQuota(opts.APICallsPerHour, opts.HandlerAPICallsPerHour)
+	ts := opts.TokenSource
+	if token := os.Getenv(ghtokName); ts == nil && token != "" {
+		ts = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	}
+	var hc *http.Client
+	switch {
+	case ts != nil:
+		ts = oauth2.ReuseTokenSource(nil, ts)
+		hc = &http.Client{Transport: quota.transport(&oauth2.Transport{Source: ts, Base: transport})}
+	case opts.AppSecret != "" || opts.AppID != 0 || opts.Observe:
+		// Apps and observers don't need to create hooks (and handlers get
+		// installation clients), so the default client can be
+		// unauthenticated
+		hc = &http.Client{Transport: quota.transport(transport)}
+	default:
+		return nil, newCauseError(ErrMissingToken, "GitHub API token missing - must set %s or a token source", ghtokName)
 	}
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	hc := &http.Client{Transport: &oauth2.Transport{Source: ts}}
 	client := github.NewClient(hc)
+	if opts.BaseURL != "" {
+		client, err = github.NewEnterpriseClient(opts.BaseURL, opts.UploadURL, hc)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid GitHub Enterprise URL")
+		}
+	}
+
+	r := &Responder{
+		ghclient:            client,
+		domain:              opts.Domain,
+		events:              opts.Events,
+		pathPrefix:          opts.PathPrefix,
+		hookConfig:          opts.HookConfig,
+		httpPort:            opts.HTTPPort,
+		httpsPort:           opts.HTTPSPort,
+		addr:                opts.Addr,
+		trustedProxies:      trustedProxies,
+		listener:            opts.Listener,
+		registerConcurrency: opts.RegisterConcurrency,
+		callbackIP:          opts.CallbackIPFilter,
+		clientCAs:           clientCAs,
+		clientCertCallbacks: opts.ClientCertsOnCallbacks,
+		metricsIP:           opts.MetricsIPFilter,
+		dispatchPings:       opts.DispatchPings,
+		deliveryBudget:      opts.DeliveryBudget,
+		relayToken:          opts.TrustedRelayToken,
+		adminToken:          opts.AdminToken,
+		maintenance:         opts.MaintenanceWindows,
+		hookSecret:          opts.HookSecret,
+		pathStrategy:        opts.CallbackPathStrategy,
+		dnsProvider:         opts.DNSProvider,
+		certStorage:         opts.certStorage(),
+		acmeCA:              acmeCA,
+		acmeEmail:           opts.ACMEEmail,
+		acmeEAB:             eabKeys{opts.ACMEEABKeyID, opts.ACMEEABHMACKey},
+		callbackPath:        opts.CallbackPath,
+		hydrate:             opts.HydrateTruncatedPayloads,
+		sync:                opts.Synchronous,
+		pool:                newWorkerPool(opts.MaxConcurrency, opts.MaxQueuedHandlers),
+		inFlight:            &inFlightLimit{max: int64(opts.MaxInFlightDeliveries)},
+		maxPayload:          opts.MaxPayloadSize,
+		dns:                 newDNSPreflight(opts),
+		domains:             opts.Domains,
+		domainFor:           opts.DomainFor,
+		dedupTTL:            opts.DedupTTL,
+		diffWindow:          opts.RedeliveryDiffWindow,
+		compression:         opts.Compression,
+		permCheck:           !opts.SkipPermissionCheck,
+		backfill:            opts.Backfill,
+		now:                 opts.Clock,
+		random:              opts.Random,
+		crash:               newCrashReporter(opts.CrashReportDir),
+		adoptHooks:          opts.AdoptHooks,
+		observe:             opts.Observe,
+		shutdownPolicy:      opts.HookShutdownPolicy,
+		signals:             opts.ShutdownSignals,
+		drainTimeout:        opts.DrainTimeout,
+		quota:               quota,
+		transport:           transport,
+	}
+	r.topics.now = r.clock
+	r.rateLimit = newRateLimiter(opts.CallbackRateLimit, opts.CallbackRateLimitPerIP, opts.CallbackRateBurst, r.clock)
+	if quota != nil {
+		quota.now = r.clock
+	}
+	r.certWatch = certWatcher{
+		domain: opts.Domain,
+		window: opts.CertExpiryWarning,
+		now:    r.clock,
+		load:   loadCertmagicCert,
+	}
+	if opts.GitHubHookIPs {
+		r.hookIPs = &hookIPWatcher{
+			refresh: opts.GitHubHookIPsRefresh,
+			now:     r.clock,
+			fetch: func(ctx context.Context) ([]string, error) {
+				return githubHookCIDRs(ctx, r)
+			},
+		}
+	}
+	if opts.AppID != 0 {
+		r.app, err = newAppAuth(opts.AppID, opts.AppPrivateKey, client, transport)
+		if err != nil {
+			return nil, err
+		}
+		r.app.quota = quota
+		r.app.now = r.clock
+		if opts.AppInstallationID != 0 {
+			r.installationID = opts.AppInstallationID
+			r.ghclient = r.app.client(opts.AppInstallationID)
+		}
+	}
+
+	primary := r.newEndpoint(repositories, actions)
+	if opts.AppSecret != "" {
+		primary.secret = opts.AppSecret
+		primary.callbackURL = callbackScheme() + r.domain + r.pathPrefix + "app"
+		if opts.RegisterAppWebhook {
+			r.appWebhook = true
+		} else {
+			log.Info().Str("webhook_url", primary.callbackURL).Msg("GitHub App mode - set the App's webhook URL to this")
+		}
+	}
+	r.endpoints = []*endpoint{primary}
+	return r, nil
+}
 
-	return &Responder{
-		ghclient:    client,
-		secret:      secret,
-		repos:       repositories,
-		domain:      domain,
-		callbackURL: callbackURL,
-		actions:     actions,
-	}, nil
+// Client - the GitHub API client used by the Responder, for use outside of
+// handlers (handlers should use GitHubClient)
+func (r *Responder) Client() *github.Client {
+	return r.ghclient
 }
 
-func buildCallbackURL(domain string) string {
-	u := uuid.NewV4()
-	var scheme string
+// EnableRepoMetrics - label delivery metrics with the repository's full name,
+// for at most budget of the busiest repositories. Deliveries from all other
+// repositories are labelled "other", keeping the number of series bounded.
+func (r *Responder) EnableRepoMetrics(budget int) {
+	r.repoLabels = newRepoLabeler(budget, deleteRepoSeries)
+}
+
+// SetAccessLog - write HTTP access logs to w (for example a *logrotate.File)
+// instead of the application log. Requests are logged at info level, or warn
+// when they fail.
+func (r *Responder) SetAccessLog(w io.Writer) {
+	l := zerolog.New(w).With().Timestamp().Logger()
+	r.accessLog = &l
+}
+
+func buildCallbackURL(domain, prefix, id string) string {
+	return callbackScheme() + domain + prefix + "gh-callback/" + id
+}
+
+func callbackScheme() string {
 	if tlsDisabled() {
-		scheme = "http://"
-	} else {
-		scheme = "https://"
+		return "http://"
 	}
-	return scheme + domain + "/gh-callback/" + u.String()
+	return "https://"
 }
 
 // Register a new webhook with the watched repositories for the listed events. A
 // cleanup function is returned when the hook is successfully registered - this
 // function must be called (usually deferred), otherwise invalid webhooks will be
 // left behind.
+//
+// Transient failures are retried with backoff until the context's deadline (or
+// a couple of minutes, if there's none). Permanent failures, such as bad
+// credentials or missing repos, fail immediately. Hooks are created
+// concurrently (see Options.RegisterConcurrency), pausing for rate limits. If
+// any fail, a *RegistrationError is returned, and all created hooks are
+// removed.
+//
+// When events is empty, each endpoint subscribes to the events its routes
+// want (see RegisterRouted), or to the events from the Responder's Options.
+//
+// In observe mode (see Options.Observe), no hooks are registered.
+//
+// Cleanup failures are logged - use RegisterE to handle them.
 func (r *Responder) Register(ctx context.Context, events []string) (func(), error) {
-	inHook := &github.Hook{
-		Events: events,
-		Config: map[string]interface{}{
-			"url":          r.callbackURL,
-			"content_type": "json",
-			"secret":       r.secret,
-		},
-	}
-
-	var unregFuncs []func()
-	for _, repo := range r.repos {
-		owner := repo.owner
-		repoName := repo.name
-		hook, resp, err := r.ghclient.Repositories.CreateHook(ctx, owner, repoName, inHook)
+	cleanup, err := r.RegisterE(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = cleanup() }, nil
+}
+
+// RegisterE - like Register, but the cleanup function removes every hook it
+// can, and returns a *CleanupError naming the repos whose hooks couldn't be
+// removed.
+func (r *Responder) RegisterE(ctx context.Context, events []string) (func() error, error) {
+	if r.observe {
+		return r.observeHooks(ctx)
+	}
+	err := r.resolveRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = r.checkPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = r.dns.check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.appWebhook {
+		primary := r.endpoints[0]
+		err = r.app.configureWebhook(ctx, primary.callbackURL, primary.secret)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to create hook")
+			return nil, err
 		}
-		if resp.StatusCode > 299 {
-			return nil, errors.Errorf("request failed with %s", resp.Status)
+		log.Info().Str("webhook_url", primary.callbackURL).Msg("configured the GitHub App's webhook")
+	}
+	var targets []hookTarget
+	for _, ep := range r.endpoints {
+		subscription := events
+		if len(subscription) == 0 {
+			subscription = ep.subscription(r.events)
+		}
+		for _, repo := range ep.repos {
+			callback, err := r.callbackFor(ep, repo)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, hookTarget{ep, repo, r.hookFor(ep, callback, subscription)})
 		}
+	}
 
-		id := hook.GetID()
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		unregFuncs = map[string][]func() error{}
+		created    int
+		failed     = map[string]error{}
+		skipped    int
+	)
+	unregister := func() error {
+		cerr := &CleanupError{Failed: map[string]error{}}
+		for repo, fs := range unregFuncs {
+			for _, f := range fs {
+				if err := f(); err != nil {
+					cerr.Failed[repo] = err
+					continue
+				}
+				cerr.Succeeded++
+			}
+		}
 		log.Info().
-			Str("hook_url", hook.GetURL()).
-			Int64("hook_id", id).
-			Str("callback", r.callbackURL).
-			Msg("Registered WebHook")
-
-		unregFuncs = append(unregFuncs, func() {
-			log := log.With().Int64("hook_id", id).Logger()
-			log.Info().Msg("Cleaning up webhook")
-			_, err := r.ghclient.Repositories.DeleteHook(ctx, owner, repoName, id)
+			Int("succeeded", cerr.Succeeded).
+			Int("failed", len(cerr.Failed)).
+			Msg("Cleaned up WebHooks")
+		if len(cerr.Failed) > 0 {
+			return cerr
+		}
+		return nil
+	}
+	// rate limits are shared, so when one worker hits a limit, all pause
+	p := &pacer{}
+	sem := make(chan struct{}, r.registerConcurrency)
+	for _, t := range targets {
+		mu.Lock()
+		stop := len(failed) > 0
+		mu.Unlock()
+		if stop {
+			// everything will be rolled back anyway
+			skipped++
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(t hookTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			create := r.createHook
+			if r.adoptHooks {
+				create = r.adoptHook
+			}
+			unreg, err := create(ctx, p, t)
+			repo := t.repo.owner + "/" + t.repo.name
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
-				err = errors.Wrap(err, "failed to delete webhook")
-				log.Error().Err(err).Msg("failed to delete webhook")
+				failed[repo] = err
+				return
 			}
-		})
+			unregFuncs[repo] = append(unregFuncs[repo], unreg)
+			created++
+		}(t)
 	}
+	wg.Wait()
+
+	log.Info().
+		Int("succeeded", created).
+		Int("failed", len(failed)).
+		Int("skipped", skipped).
+		Msg("Registered WebHooks")
 
-	unregister := func() {
-		for _, f := range unregFuncs {
-			f()
+	if len(failed) > 0 {
+		// don't leave hooks behind in the repos that succeeded
+		_ = unregister()
+		return nil, &RegistrationError{
+			Failed:    failed,
+			Succeeded: created,
+			Skipped:   skipped,
 		}
 	}
+
+	r.crash.onCrash(unregister)
 	return unregister, nil
 }
 
-// Listen for webhooks
+type hookTarget struct {
+	ep   *endpoint
+	repo repository
+	hook *github.Hook
+}
+
+// createHook creates a hook, returning its cleanup function (see
+// hookCleanup)
+func (r *Responder) createHook(ctx context.Context, p *pacer, t hookTarget) (func() error, error) {
+	owner := t.repo.owner
+	repoName := t.repo.name
+	var hook *github.Hook
+	var resp *github.Response
+	err := withPacedRetries(ctx, "creating hook", p, func() (_ *github.Response, err error) {
+		hook, resp, err = r.ghclient.Repositories.CreateHook(ctx, owner, repoName, t.hook)
+		return resp, err
+	})
+	err = repoHooksError(resp, err)
+	if err != nil {
+		return nil, err
+	}
+
+	id := hook.GetID()
+	log.Info().
+		Str("hook_url", hook.GetURL()).
+		Int64("hook_id", id).
+		Str("callback", t.hook.Config["url"].(string)).
+		Msg("Registered WebHook")
+
+	return r.hookCleanup(ctx, t.repo, id), nil
+}
+
+// RegisterRouted - like Register, but adds the routes to the primary endpoint
+// first. Each route's handler only receives the events it asks for, and hooks
+// subscribe to the union of all routes' events.
+func (r *Responder) RegisterRouted(ctx context.Context, routes ...Route) (func(), error) {
+	for _, rt := range routes {
+		for _, e := range rt.Events {
+			err := validateEvent(e)
+			if err != nil {
+				return nil, err
+			}
+			if !knownEvent(e) {
+				log.Warn().Str("event", e).Str("route", rt.Name).Msg("unknown event - GitHub may reject the hook, or never deliver it")
+			}
+		}
+		if rt.Sampling != nil {
+			err := rt.Sampling.validate()
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := validateFilters(rt.Filters)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := r.validateRouteNames(routes)
+	if err != nil {
+		return nil, err
+	}
+	r.endpoints[0].routes = append(r.endpoints[0].routes, routes...)
+	return r.Register(ctx, nil)
+}
+
+// hookFor builds the hook to create for an endpoint, delivering to callback
+func (r *Responder) hookFor(ep *endpoint, callback string, events []string) *github.Hook {
+	config := map[string]interface{}{}
+	for k, v := range r.hookConfig {
+		config[k] = v
+	}
+	config["url"] = callback
+	config["content_type"] = "json"
+	config["secret"] = ep.secret
+	return &github.Hook{
+		Events: events,
+		Config: config,
+	}
+}
+
+// resolveRepos looks up the owner and name of repos given by numeric ID
+func (r *Responder) resolveRepos(ctx context.Context) error {
+	for _, ep := range r.endpoints {
+		for i, repo := range ep.repos {
+			if repo.owner != "" {
+				continue
+			}
+			var ghrepo *github.Repository
+			err := withRetries(ctx, "looking up repo", func() (resp *github.Response, err error) {
+				ghrepo, resp, err = r.ghclient.Repositories.GetByID(ctx, repo.id)
+				return resp, err
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to look up repo with ID %d", repo.id)
+			}
+			ep.repos[i].owner = ghrepo.GetOwner().GetLogin()
+			ep.repos[i].name = ghrepo.GetName()
+		}
+	}
+	return nil
+}
+
+// Listen for webhooks, in the background. When the context is cancelled,
+// the servers are shut down gracefully: no new deliveries are accepted, and
+// in-flight handlers are given until Options.DrainTimeout to finish.
 func (r *Responder) Listen(ctx context.Context) {
+	certmagic.HTTPPort = r.httpPort
+	certmagic.HTTPSPort = r.httpsPort
+	if r.dnsProvider != nil {
+		certmagic.DNSProvider = r.dnsProvider
+	}
+	if r.certStorage != nil {
+		certmagic.DefaultStorage = r.certStorage
+	}
+	r.configureACME()
+
+	mux := http.NewServeMux()
+	mux.Handle(r.pathPrefix+"metrics", r.accessLogging().Append(r.metricsIP.Middleware, r.requireClientCert).
+		Then(
+			promhttp.InstrumentMetricHandler(
+				MetricsRegisterer,
+				promhttp.HandlerFor(MetricsGatherer,
+					promhttp.HandlerOpts{},
+				),
+			),
+		))
+	mux.Handle(r.pathPrefix, r.Handler())
+	if r.hookIPs != nil {
+		go r.hookIPs.run(ctx)
+	}
+
+	if tlsDisabled() || r.trustedProxies != nil {
+		// behind a proxy, the proxy terminates TLS
+		r.serveHTTP(mux)
+	} else {
+		if r.certWatch.load != nil {
+			go r.certWatch.run(ctx)
+		}
+		r.serveHTTPS(mux)
+	}
+
+	go func() {
+		<-ctx.Done()
+		err := r.drain()
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to shut down gracefully")
+		}
+	}()
+}
+
+// Handler - the Responder's webhook callbacks (and delivery status, see
+// EnableStatusTracking), with validation, logging and metrics, for serving
+// from your own HTTP server instead of with Listen. Mount it at
+// CallbackPath, or at Options.PathPrefix to also serve added endpoints and
+// delivery status - other paths get a 404. Metrics are registered with
+// MetricsRegisterer, but it's up to you to serve them.
+func (r *Responder) Handler() http.Handler {
 	initMetrics()
 
-	// now listen for events
+	c := r.accessLogging()
+	mux := http.NewServeMux()
+	cb := c.Extend(instrumentHTTP("callback"))
+	if r.callbackIP != nil {
+		cb = cb.Append(r.callbackIP.Middleware)
+	}
+	if r.hookIPs != nil {
+		cb = cb.Append(r.hookIPs.Middleware)
+	}
+	if r.rateLimit != nil {
+		cb = cb.Append(r.rateLimit.Middleware)
+	}
+	if r.clientCertCallbacks {
+		cb = cb.Append(r.requireClientCert)
+	}
+	for _, ep := range r.endpoints {
+		for _, path := range r.endpointPaths(ep) {
+			mux.Handle(path, cb.Then(ep))
+		}
+	}
+	if r.status != nil {
+		sc := c.Extend(instrumentHTTP("status"))
+		if r.callbackIP != nil {
+			sc = sc.Append(r.callbackIP.Middleware)
+		}
+		mux.Handle(r.statusPath(), sc.Append(r.requireClientCert).Then(r.status.handler(r.statusPath())))
+		mux.Handle(r.pathPrefix+"stats", c.Extend(instrumentHTTP("stats")).
+			Append(r.metricsIP.Middleware, r.requireClientCert).
+			Then(r.status.statsHandler()))
+	}
+	if r.handlerAdmin {
+		path := r.pathPrefix + "handlers"
+		h := c.Extend(instrumentHTTP("handlers")).
+			Append(r.metricsIP.Middleware, r.requireClientCert).
+			Then(r.handlerAdminHandler(path))
+		mux.Handle(path, h)
+		mux.Handle(path+"/", h)
+	}
+	if r.redeliveryAdmin {
+		path := r.pathPrefix + "admin/deliveries"
+		h := c.Extend(instrumentHTTP("admin_deliveries")).
+			Append(r.metricsIP.Middleware, r.requireClientCert).
+			Then(r.redeliveryAdminHandler(path))
+		mux.Handle(path, h)
+		mux.Handle(path+"/", h)
+	}
+	if r.topologyEndpoint {
+		mux.Handle(r.pathPrefix+"topology", c.Extend(instrumentHTTP("topology")).
+			Append(r.metricsIP.Middleware, r.requireClientCert).
+			ThenFunc(r.topologyHandler))
+	}
+	mux.Handle(r.pathPrefix, c.Extend(instrumentHTTP("default")).ThenFunc(denyHandler))
+
+	return mux
+}
+
+// CallbackPath - the URL path GitHub delivers the primary endpoint's events
+// to, for mounting Handler
+func (r *Responder) CallbackPath() string {
+	return getPath(r.endpoints[0].callbackURL)
+}
+
+// accessLogging - middleware adding a request logger to the context, and
+// logging each request to it (or to the access log, when set)
+func (r *Responder) accessLogging() alice.Chain {
 	c := alice.New(hlog.NewHandler(log.Logger))
+	if r.trustedProxies != nil {
+		c = c.Append(r.forwarded)
+	}
 	c = c.Append(
 		hlog.UserAgentHandler("user_agent"),
 		hlog.RefererHandler("referer"),
@@ -161,73 +727,103 @@ func (r *Responder) Listen(ctx context.Context) {
 		hlog.URLHandler("url"),
 		hlog.RemoteAddrHandler("remoteAddr"),
 	)
-	c = c.Append(hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
-		eventType := github.WebHookType(r)
-		deliveryID := github.DeliveryID(r)
+	c = c.Append(hlog.AccessHandler(func(req *http.Request, status, size int, duration time.Duration) {
+		eventType := github.WebHookType(req)
+		deliveryID := github.DeliveryID(req)
 		l := zerolog.DebugLevel
+		if r.accessLog != nil {
+			// the access log is for every request, whatever the log level
+			l = zerolog.InfoLevel
+		}
 		if status > 399 {
 			l = zerolog.WarnLevel
 		}
-		hlog.FromRequest(r).WithLevel(l).
-			Int("status", status).
+		e := hlog.FromRequest(req).WithLevel(l)
+		if r.accessLog != nil {
+			// the request logger's context fields aren't available here
+			e = r.accessLog.WithLevel(l).
+				Str("method", req.Method).
+				Str("url", req.URL.String()).
+				Str("remoteAddr", req.RemoteAddr).
+				Str("user_agent", req.UserAgent())
+		}
+		e.Int("status", status).
 			Int("size", size).
 			Dur("duration", duration).
 			Str("eventType", eventType).
 			Str("deliveryID", deliveryID).
-			Msgf("%s %s - %d", r.Method, r.URL, status)
+			Msgf("%s %s - %d", req.Method, req.URL, status)
 	}))
 
-	http.Handle("/metrics", c.Append(filterByIP).
-		Then(
-			promhttp.InstrumentMetricHandler(
-				MetricsRegisterer,
-				promhttp.HandlerFor(MetricsGatherer,
-					promhttp.HandlerOpts{},
-				),
-			),
-		))
-	http.Handle(getPath(r.callbackURL), c.Extend(instrumentHTTP("callback")).Then(r))
-	http.Handle("/", c.Extend(instrumentHTTP("default")).ThenFunc(denyHandler))
-
-	if tlsDisabled() {
-		go func() {
-			log.Info().Int("port", certmagic.HTTPPort).Msg("Listening for webhook callbacks")
-			port := strconv.Itoa(certmagic.HTTPPort)
-			err := http.ListenAndServe(":"+port, nil)
-			log.Error().Err(err).Msg("")
-		}()
-	}
-
-	go func() {
-		log.Info().Int("port", certmagic.HTTPSPort).Msg("Listening for webhook callbacks")
-		err := certmagic.HTTPS([]string{r.domain}, nil)
-		log.Error().Err(err).Msg("listening with certmagic")
-	}()
-
-	return
+	return c
 }
 
 // RegisterAndListen - unlike calling `Register` and `Listen` separately, this
-// will block while waiting for the context to be cancelled.
+// will block while waiting for the context to be cancelled, a shutdown signal
+// (see Options.ShutdownSignals), or Stop to be called.
 func (r *Responder) RegisterAndListen(ctx context.Context, events []string) error {
+	err := runHooks(ctx, "start", r.lifecycle.start, true)
+	if err != nil {
+		return err
+	}
+
 	cleanup, err := r.Register(ctx, events)
 	if err != nil {
 		return err
 	}
 	defer cleanup()
 
-	r.Listen(ctx)
+	lctx, stopListening := context.WithCancel(ctx)
+	defer stopListening()
+	r.Listen(lctx)
+
+	// with TLS, obtaining certificates can take a while
+	go func() {
+		select {
+		case <-r.listening():
+			_ = runHooks(lctx, "ready", r.lifecycle.ready, false)
+		case <-lctx.Done():
+		}
+	}()
+
+	if r.backfill {
+		go func(ctx context.Context) {
+			_, err := r.Backfill(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("failed to backfill some missed deliveries")
+			}
+		}(ctx)
+	}
+
+	// deferred after cleanup, so runs before it
+	defer func() {
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownHookTimeout)
+		defer cancel()
+		_ = runHooks(sctx, "shutdown", r.lifecycle.shutdown, false)
+	}()
+	// deferred last, so in-flight deliveries finish before the shutdown hooks
+	// run and the webhooks are removed
+	defer func() {
+		derr := r.drain()
+		if derr != nil {
+			log.Warn().Err(derr).Msg("failed to shut down gracefully")
+		}
+	}()
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, r.signals...)
+	defer signal.Stop(c)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	go r.RunScheduler(ctx)
 	select {
 	case s := <-c:
 		log.Debug().
 			Str("signal", s.String()).
 			Msg("shutting down gracefully...")
+	case <-r.stopped():
+		log.Debug().Msg("stopped - shutting down gracefully...")
 	case <-ctx.Done():
 		err = ctx.Err()
 		log.Error().
@@ -237,6 +833,29 @@ func (r *Responder) RegisterAndListen(ctx context.Context, events []string) erro
 	return err
 }
 
+// Stop - make RegisterAndListen return, cleaning up its webhooks, as if a
+// shutdown signal had been received. Safe to call more than once.
+func (r *Responder) Stop() {
+	ch := r.stopped()
+	r.stopMu.Lock()
+	defer r.stopMu.Unlock()
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// stopped - closed when Stop is called
+func (r *Responder) stopped() chan struct{} {
+	r.stopMu.Lock()
+	defer r.stopMu.Unlock()
+	if r.stop == nil {
+		r.stop = make(chan struct{})
+	}
+	return r.stop
+}
+
 func tlsDisabled() bool {
 	disableTLS, err := strconv.ParseBool(os.Getenv("TLS_DISABLE"))
 	if err != nil {
@@ -256,10 +875,32 @@ func getPath(u string) string {
 	return u
 }
 
+// ServeHTTP - handle deliveries to the Responder's primary callback endpoint
 func (r *Responder) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	r.endpoints[0].ServeHTTP(resp, req)
+}
+
+func (e *endpoint) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if e.stream != nil {
+		e.serveStream(resp, req)
+		return
+	}
+	r := e.r
 	log := *hlog.FromRequest(req)
-	payload, err := github.ValidatePayload(req, []byte(r.secret))
+	body := limitPayload(resp, req, r.maxPayload)
+	payload, relayed, err := e.validatePayload(req)
+	if body.exceeded() {
+		log.Error().
+			Int64("contentLength", req.ContentLength).
+			Int64("maxPayloadSize", r.maxPayload).
+			Str("eventType", github.WebHookType(req)).
+			Str("deliveryID", github.DeliveryID(req)).
+			Msg("payload too large - rejecting")
+		http.Error(resp, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
+		err = signatureError(err)
 		log.Error().Err(err).
 			Msg("invalid payload")
 		http.Error(resp, err.Error(), http.StatusBadRequest)
@@ -268,10 +909,42 @@ func (r *Responder) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 
 	eventType := github.WebHookType(req)
 	deliveryID := github.DeliveryID(req)
-	log = log.With().
+	info := parseEventInfo(payload)
+	log = info.withFields(log.With().
 		Str("eventType", eventType).
-		Str("deliveryID", deliveryID).Logger()
+		Str("deliveryID", deliveryID)).Logger()
+	if relayed {
+		log = log.With().Bool("relayed", true).Logger()
+	}
 	log.Info().Msg("Incoming request")
+
+	countDelivery(eventType, r.repoLabels.label(info.Repo.FullName))
+	if r.recorder != nil {
+		id, err := r.recorder.Put(&Delivery{
+			EventType:  eventType,
+			DeliveryID: deliveryID,
+			Headers:    req.Header,
+			Payload:    payload,
+			ReceivedAt: r.clock(),
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to record delivery")
+		} else {
+			log.Debug().Str("recordID", id).Msg("recorded delivery")
+		}
+	}
+	r.diffRedelivery(log, eventType, deliveryID, payload)
+	if eventType != "ping" && r.duplicate(eventType, deliveryID) {
+		log.Info().Msg("ignoring duplicate delivery")
+		http.Error(resp, "duplicate delivery - already handled", http.StatusOK)
+		return
+	}
+	eventType, payload = r.normalize(log, eventType, payload)
+	r.drift.check(log, eventType, payload)
+	r.installations.track(eventType, payload)
+	r.topics.track(eventType, payload)
+
+	var zen string
 	if eventType == "ping" {
 		event, err := github.ParseWebHook(eventType, payload)
 		if err != nil {
@@ -284,19 +957,153 @@ func (r *Responder) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 			http.Error(resp, fmt.Sprintf("wrong event type %T", event), http.StatusBadRequest)
 			return
 		}
-		_, err = resp.Write([]byte(*ping.Zen))
-		if err != nil {
-			log.Error().Err(err).Msg("failed to write response")
+		zen = ping.GetZen()
+		if !r.dispatchPings {
+			writeZen(log, resp, zen)
+			return
 		}
+	}
+
+	di := parseDeliveryInfo(req.Header)
+	di.InstallationID = info.Installation.ID
+	d := &Delivery{
+		EventType:  eventType,
+		DeliveryID: deliveryID,
+		Headers:    req.Header,
+		Payload:    payload,
+	}
+	ctx, routes := r.route(r.handlerContext(log, di, d), log, di, info.Repo.FullName, e.namedRoutes(), d)
+	if eventType != "ping" && r.InMaintenance() {
+		r.deferDelivery(log, routes, d)
+		resp.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if !r.srv.begin() {
+		rejected.WithLabelValues(eventType).Inc()
+		log.Warn().Msg("rejecting delivery - shutting down")
+		r.forgetDelivery(deliveryID)
+		http.Error(resp, "shutting down", http.StatusServiceUnavailable)
 		return
 	}
+	if !r.inFlight.acquire() {
+		r.srv.end()
+		rejected.WithLabelValues(eventType).Inc()
+		log.Warn().Msg("rejecting delivery - too many deliveries in flight")
+		r.forgetDelivery(deliveryID)
+		http.Error(resp, "too many deliveries in flight", http.StatusServiceUnavailable)
+		return
+	}
+	if !r.pool.reserve(wanting(routes, eventType)) {
+		r.srv.end()
+		r.inFlight.release()
+		rejected.WithLabelValues(eventType).Inc()
+		log.Warn().Msg("rejecting delivery - the handler queue is full")
+		r.forgetDelivery(deliveryID)
+		http.Error(resp, "handler queue full", http.StatusServiceUnavailable)
+		return
+	}
+	r.mirror(ctx, eventType, deliveryID, payload)
+	ctx, renderAnnotations := r.collectAnnotations(ctx)
+	var result *handlerResult
+	if r.sync && eventType != "ping" {
+		ctx, routes, result = synchronous(ctx, routes)
+	}
+	status := http.StatusNoContent
+	if r.status != nil && deliveryID != "" {
+		ctx, routes = r.status.track(ctx, eventType, deliveryID, routes)
+		r.status.describe(deliveryID, info)
+		resp.Header().Set("Location", r.statusPath()+deliveryID)
+		status = http.StatusAccepted
+	}
+	routes = r.guarded(routes, eventType, deliveryID)
+	ctx, failures := collectFailures(ctx)
+	var walKey string
+	if eventType != "ping" {
+		walKey = r.writeAhead(log, r.endpointIndex(e), d)
+	}
+	wg := r.dispatchWithBudget(ctx, routes, eventType, deliveryID, payload)
+	r.trackHandlers(wg)
+	go r.settle(log, walKey, wg, failures)
+	renderAnnotations(wg, eventType, payload)
+	if result != nil {
+		wg.Wait()
+		if result.err != nil {
+			log.Warn().Err(result.err).Msg("failing delivery - handler failed")
+			// so it's handled when redelivered
+			r.forgetDelivery(deliveryID)
+			http.Error(resp, result.err.Error(), failureStatus(result.err))
+			return
+		}
+	}
 
-	ctx := log.WithContext(req.Context())
-	for _, a := range r.actions {
-		go a(ctx, eventType, deliveryID, payload)
+	if eventType == "ping" {
+		if status == http.StatusAccepted {
+			resp.WriteHeader(status)
+		}
+		writeZen(log, resp, zen)
+		return
 	}
+	resp.WriteHeader(status)
+}
+
+// handlerContext - the context handlers get, with the things they can use.
+// Handlers outlive requests, so it can't derive from a request's context.
+func (r *Responder) handlerContext(log zerolog.Logger, di *DeliveryInfo, d *Delivery) context.Context {
+	ctx := log.WithContext(context.Background())
+	ctx = withGitHubClient(ctx, r.clientFor(di.InstallationID))
+	ctx = withDeliveryInfo(ctx, di)
+	ctx = withStateStore(ctx, r.state)
+	return context.WithValue(ctx, scheduleKey, &scheduleContext{r, d})
+}
+
+// writeZen answers a ping with its zen, so it shows in GitHub's delivery log
+func writeZen(log zerolog.Logger, resp http.ResponseWriter, zen string) {
+	_, err := resp.Write([]byte(zen))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
+
+func (r *Responder) statusPath() string {
+	return r.pathPrefix + "deliveries/"
+}
+
+// dispatchWithBudget dispatches, cancelling the handlers' context once the
+// delivery budget (if any) runs out. The returned WaitGroup waits for the
+// handlers, even if they outlive the budget.
+func (r *Responder) dispatchWithBudget(ctx context.Context, routes []Route, eventType, deliveryID string, payload []byte) *sync.WaitGroup {
+	if r.deliveryBudget <= 0 {
+		return dispatchTo(ctx, r.pool, routes, eventType, deliveryID, payload)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.deliveryBudget)
+	wg := dispatchTo(ctx, r.pool, routes, eventType, deliveryID, payload)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	go func() {
+		defer cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			timeouts.WithLabelValues(eventType).Inc()
+			if r.status != nil {
+				r.status.timedOut(deliveryID)
+			}
+			zerolog.Ctx(ctx).Warn().
+				Dur("budget", r.deliveryBudget).
+				Msg("delivery budget exceeded - handlers cancelled")
+		}
+	}()
+	return wg
+}
 
-	resp.WriteHeader(http.StatusNoContent)
+// dispatch runs each interested route's handler in its own goroutine. The
+// returned WaitGroup can be used to wait for them all to finish.
+func dispatch(ctx context.Context, routes []Route, eventType, deliveryID string, payload []byte) *sync.WaitGroup {
+	return dispatchTo(ctx, nil, routes, eventType, deliveryID, payload)
 }
 
 func denyHandler(resp http.ResponseWriter, req *http.Request) {
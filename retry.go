@@ -0,0 +1,134 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/google/go-github/v24/github"
+	"github.com/rs/zerolog/log"
+)
+
+// maxRetryTime bounds how long API calls are retried, when the context has no
+// earlier deadline
+var maxRetryTime = 2 * time.Minute
+
+// withRetries calls op until it succeeds, fails permanently, or the context is
+// done. Transient failures (network errors, rate limits, server errors) are
+// retried with exponential backoff.
+func withRetries(ctx context.Context, desc string, op func() (*github.Response, error)) error {
+	return withPacedRetries(ctx, desc, nil, op)
+}
+
+// withPacedRetries - like withRetries, but calls are paced by p (when not nil),
+// so that a rate limit hit by one caller pauses all callers sharing p.
+func withPacedRetries(ctx context.Context, desc string, p *pacer, op func() (*github.Response, error)) error {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = maxRetryTime
+
+	var err error
+	retryErr := backoff.RetryNotify(func() error {
+		if werr := p.wait(ctx); werr != nil {
+			return backoff.Permanent(werr)
+		}
+		var resp *github.Response
+		resp, err = op()
+		if err != nil && !retryable(ctx, resp, err) {
+			return backoff.Permanent(err)
+		}
+		if d := retryAfter(err); d > 0 {
+			p.hold(d)
+		}
+		return err
+	}, backoff.WithContext(b, ctx), func(err error, next time.Duration) {
+		log.Warn().Err(err).Dur("retry_in", next).Msg(desc + " failed, retrying")
+	})
+	if retryErr != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return retryErr
+}
+
+// retryable - whether a failed request is worth retrying. Client errors
+// (bad credentials, missing repos, validation failures) are permanent.
+func retryable(ctx context.Context, resp *github.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch err.(type) {
+	case *github.RateLimitError, *github.AbuseRateLimitError:
+		return true
+	}
+	if resp == nil {
+		// no response at all - most likely a network error
+		return true
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// retryAfter - how long GitHub asked us to wait before retrying, for rate
+// limit errors
+func retryAfter(err error) time.Duration {
+	switch e := err.(type) {
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			return *e.RetryAfter
+		}
+	case *github.RateLimitError:
+		return time.Until(e.Rate.Reset.Time)
+	}
+	return 0
+}
+
+// pacer - holds back calls until a shared rate limit has passed. A nil pacer
+// never waits.
+type pacer struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// hold - make callers wait for d
+func (p *pacer) hold(d time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until := time.Now().Add(d); until.After(p.until) {
+		p.until = until
+		log.Warn().Dur("wait", d).Msg("rate limited - pausing API calls")
+	}
+}
+
+// wait - block until the pacer allows calls. If the context would expire
+// first, its error is returned immediately.
+func (p *pacer) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	d := time.Until(p.until)
+	p.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now().Add(d)) {
+		return context.DeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func ghResp(status int) *github.Response {
+	return &github.Response{Response: &http.Response{StatusCode: status}}
+}
+
+func TestRetryable(t *testing.T) {
+	ctx := context.Background()
+	err := errors.New("fail")
+	assert.True(t, retryable(ctx, nil, err))
+	assert.True(t, retryable(ctx, ghResp(502), err))
+	assert.True(t, retryable(ctx, ghResp(429), err))
+	assert.True(t, retryable(ctx, ghResp(403), &github.RateLimitError{}))
+	assert.False(t, retryable(ctx, ghResp(401), err))
+	assert.False(t, retryable(ctx, ghResp(404), err))
+	assert.False(t, retryable(ctx, ghResp(422), err))
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.False(t, retryable(ctx, nil, err))
+}
+
+func TestWithRetries(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	err := withRetries(ctx, "test", func() (*github.Response, error) {
+		calls++
+		if calls < 3 {
+			return ghResp(500), errors.New("server error")
+		}
+		return ghResp(200), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = withRetries(ctx, "test", func() (*github.Response, error) {
+		calls++
+		return ghResp(404), errors.New("not found")
+	})
+	assert.EqualError(t, err, "not found")
+	assert.Equal(t, 1, calls)
+
+	// retries that can't complete before the deadline aren't attempted
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = withRetries(ctx, "test", func() (*github.Response, error) {
+		return nil, errors.New("network down")
+	})
+	assert.EqualError(t, err, "network down")
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = withRetries(ctx, "test", func() (*github.Response, error) {
+		return nil, errors.New("network down")
+	})
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,142 @@
+package responder
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Filter - a custom routing rule, for routing on more than the event type,
+// the repo's topics, or sampling
+type Filter struct {
+	// Name - the rule's name, recorded in the RouteAnnotations of the
+	// deliveries it matches
+	Name string
+	// Match - whether to route the delivery to the handler, and any fields
+	// extracted while deciding (e.g. the pushed branch), which the handler
+	// can read with GetRouteAnnotations
+	Match func(ctx context.Context, eventType string, payload []byte) (bool, map[string]string)
+}
+
+// RouteAnnotations - why a delivery was routed to a handler, attached by
+// the routing stages, so the handler doesn't need to work it out again
+type RouteAnnotations struct {
+	// Rules - the routing rules the delivery matched, in the order they
+	// were applied: "event:<type>", "filter:<name>", "topic:<topic>",
+	// "rerequested" (for check run re-runs routed to the handler that
+	// produced the check run), and "sampled"
+	Rules []string
+	// Fields - values extracted while routing. Topic routing sets "topic",
+	// sampling sets "samplePercent", and filters set their own.
+	Fields map[string]string
+}
+
+// GetRouteAnnotations - returns why the delivery being handled was routed
+// to the handler. Returns nil if the context didn't come from a Responder.
+func GetRouteAnnotations(ctx context.Context) *RouteAnnotations {
+	a, _ := ctx.Value(routeAnnotationsKey).(*RouteAnnotations)
+	return a
+}
+
+// annotate returns a copy of the route, with the matched rule and fields
+// added to its annotations. The annotations are copied, so routes sharing
+// them aren't affected.
+func (rt Route) annotate(rule string, fields ...string) Route {
+	a := RouteAnnotations{
+		Rules:  make([]string, len(rt.annotations.Rules), len(rt.annotations.Rules)+1),
+		Fields: make(map[string]string, len(rt.annotations.Fields)+len(fields)/2),
+	}
+	copy(a.Rules, rt.annotations.Rules)
+	a.Rules = append(a.Rules, rule)
+	for k, v := range rt.annotations.Fields {
+		a.Fields[k] = v
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		a.Fields[fields[i]] = fields[i+1]
+	}
+	rt.annotations = a
+	return rt
+}
+
+// validateFilters checks each filter can be applied
+func validateFilters(filters []Filter) error {
+	for _, f := range filters {
+		if f.Match == nil {
+			return errors.Errorf("filter %q has no Match func", f.Name)
+		}
+	}
+	return nil
+}
+
+// filteredRoutes drops the routes that don't want the event type, or whose
+// filters don't match the delivery. It's the first routing stage.
+func filteredRoutes(ctx context.Context, routes []Route, eventType string, payload []byte) []Route {
+	filtered := make([]Route, 0, len(routes))
+	for _, rt := range routes {
+		if !rt.wants(eventType) {
+			continue
+		}
+		if len(rt.Events) > 0 {
+			rt = rt.annotate("event:" + eventType)
+		}
+		matched := true
+		for _, f := range rt.Filters {
+			var fields map[string]string
+			matched, fields = f.Match(ctx, eventType, payload)
+			if !matched {
+				break
+			}
+			rt = rt.annotate("filter:"+f.Name, flatten(fields)...)
+		}
+		if matched {
+			filtered = append(filtered, rt)
+		}
+	}
+	return filtered
+}
+
+// route runs a delivery through the routing stages - enabled handlers, their
+// filters, topics, check re-runs, sampling, annotations, and hydration -
+// returning the routes to dispatch to, with the context to dispatch with.
+// New deliveries and those retried from the write-ahead log are routed
+// alike.
+func (r *Responder) route(ctx context.Context, log zerolog.Logger, di *DeliveryInfo, fullName string, routes []Route, d *Delivery) (context.Context, []Route) {
+	routes = filteredRoutes(ctx, r.enabledRoutes(routes), d.EventType, d.Payload)
+	routes = r.routeByTopics(ctx, fullName, routes)
+	ctx, routes = r.routeRerequested(ctx, log, d.EventType, d.Payload, routes)
+	routes = sampledRoutes(routes, d.EventType, d.DeliveryID, d.Payload)
+	routes = annotatedRoutes(routes)
+	return ctx, r.hydrating(ctx, di, d.EventType, d.Payload, routes)
+}
+
+// annotatedRoutes wraps the routes' handlers so their context carries the
+// routes' annotations (see GetRouteAnnotations), once all routing stages
+// have run
+func annotatedRoutes(routes []Route) []Route {
+	wrapped := make([]Route, len(routes))
+	for i, rt := range routes {
+		a := rt.annotations
+		h := rt.Handler
+		wrapped[i] = rt
+		wrapped[i].Handler = func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			a := a
+			h(context.WithValue(ctx, routeAnnotationsKey, &a), eventType, deliveryID, payload)
+		}
+	}
+	return wrapped
+}
+
+// flatten turns the fields into key/value pairs, for annotate
+func flatten(fields map[string]string) []string {
+	pairs := make([]string, 0, 2*len(fields))
+	for k, v := range fields {
+		pairs = append(pairs, k, v)
+	}
+	return pairs
+}
+
+func formatPercent(p float64) string {
+	return strconv.FormatFloat(p, 'g', -1, 64)
+}
@@ -0,0 +1,55 @@
+package responder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilteredRoutes(t *testing.T) {
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	branch := Filter{Name: "main", Match: func(ctx context.Context, eventType string, payload []byte) (bool, map[string]string) {
+		return string(payload) == "main", map[string]string{"branch": string(payload)}
+	}}
+	routes := []Route{
+		{Handler: h},
+		{Handler: h, Events: []string{"issues"}},
+		{Handler: h, Events: []string{"push"}, Filters: []Filter{branch}},
+	}
+
+	filtered := filteredRoutes(context.Background(), routes, "push", []byte("main"))
+	if !assert.Len(t, filtered, 2) {
+		return
+	}
+	assert.Empty(t, filtered[0].annotations.Rules)
+	assert.Equal(t, []string{"event:push", "filter:main"}, filtered[1].annotations.Rules)
+	assert.Equal(t, map[string]string{"branch": "main"}, filtered[1].annotations.Fields)
+	// the original routes aren't annotated
+	assert.Empty(t, routes[2].annotations.Rules)
+
+	assert.Len(t, filteredRoutes(context.Background(), routes, "push", []byte("dev")), 1)
+
+	assert.Error(t, validateFilters([]Filter{{Name: "x"}}))
+	assert.NoError(t, validateFilters([]Filter{branch}))
+}
+
+func TestAnnotatedRoutes(t *testing.T) {
+	var got *RouteAnnotations
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		got = GetRouteAnnotations(ctx)
+	}
+	routes := []Route{{Handler: h, Topics: []string{"service"}, Sampling: &Sampling{Percent: 100}}}
+	routes[0] = routes[0].annotate("topic:service", "topic", "service")
+	routes = sampledRoutes(routes, "push", "a", nil)
+	routes = annotatedRoutes(routes)
+	routes[0].Handler(context.Background(), "push", "a", nil)
+
+	if !assert.NotNil(t, got) {
+		return
+	}
+	assert.Equal(t, []string{"topic:service", "sampled"}, got.Rules)
+	assert.Equal(t, map[string]string{"topic": "service", "samplePercent": "100"}, got.Fields)
+
+	assert.Nil(t, GetRouteAnnotations(context.Background()))
+}
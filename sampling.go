@@ -0,0 +1,96 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/pkg/errors"
+)
+
+// Sampling - routes only a percentage of matching events, for gradual
+// rollouts of expensive handlers on busy hooks
+type Sampling struct {
+	// Percent of matching events to route, from 0 to 100
+	Percent float64
+	// Key - when set, events are sampled by a hash of the key, so events
+	// with the same key are either all routed or all dropped (see
+	// SampleByRepo and SampleByDelivery). Otherwise events are sampled at
+	// random.
+	Key func(eventType, deliveryID string, payload []byte) string
+}
+
+// SampleByRepo - a Sampling.Key that samples whole repositories
+func SampleByRepo(eventType, deliveryID string, payload []byte) string {
+	var p struct {
+		Repo struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	// errors are ignored, as not all payloads have a repository
+	_ = json.Unmarshal(payload, &p)
+	return p.Repo.FullName
+}
+
+// SampleByDelivery - a Sampling.Key that samples deliveries, so
+// redeliveries are sampled like the original
+func SampleByDelivery(eventType, deliveryID string, payload []byte) string {
+	return deliveryID
+}
+
+func (s *Sampling) validate() error {
+	if s.Percent < 0 || s.Percent > 100 {
+		return errors.Errorf("invalid sampling percentage %g - must be between 0 and 100", s.Percent)
+	}
+	return nil
+}
+
+// sampled - whether the event is in the sample. A nil Sampling samples
+// everything.
+func (s *Sampling) sampled(eventType, deliveryID string, payload []byte) bool {
+	switch {
+	case s == nil || s.Percent >= 100:
+		return true
+	case s.Percent <= 0:
+		return false
+	case s.Key == nil:
+		return rand.Float64()*100 < s.Percent
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s.Key(eventType, deliveryID, payload)))
+	return float64(h.Sum32()%10000) < s.Percent*100
+}
+
+// sampledRoutes drops the routes whose sample the event isn't in
+func sampledRoutes(routes []Route, eventType, deliveryID string, payload []byte) []Route {
+	var in []Route
+	for _, rt := range routes {
+		if !rt.Sampling.sampled(eventType, deliveryID, payload) {
+			continue
+		}
+		if rt.Sampling != nil {
+			rt = rt.annotate("sampled", "samplePercent", formatPercent(rt.Sampling.Percent))
+		}
+		in = append(in, rt)
+	}
+	return in
+}
+
+// AddSampledShadow - like AddShadow, but only mirror the sampled deliveries
+// to the shadow handlers
+func (r *Responder) AddSampledShadow(s Sampling, actions ...HookHandler) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	for _, h := range actions {
+		h := h
+		r.AddShadow(func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			if s.sampled(eventType, deliveryID, payload) {
+				h(ctx, eventType, deliveryID, payload)
+			}
+		})
+	}
+	return nil
+}
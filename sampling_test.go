@@ -0,0 +1,51 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampled(t *testing.T) {
+	var s *Sampling
+	assert.True(t, s.sampled("push", "a", nil))
+	assert.True(t, (&Sampling{Percent: 100}).sampled("push", "a", nil))
+	assert.False(t, (&Sampling{Percent: 0}).sampled("push", "a", nil))
+
+	assert.Error(t, (&Sampling{Percent: -1}).validate())
+	assert.Error(t, (&Sampling{Percent: 101}).validate())
+	assert.NoError(t, (&Sampling{Percent: 12.5}).validate())
+
+	// hash-based sampling is stable, and roughly the right size
+	s = &Sampling{Percent: 25, Key: SampleByDelivery}
+	in := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("delivery-%d", i)
+		got := s.sampled("push", id, nil)
+		assert.Equal(t, got, s.sampled("push", id, nil))
+		if got {
+			in++
+		}
+	}
+	assert.InDelta(t, 250, in, 60)
+
+	assert.Equal(t, "foo/bar", SampleByRepo("push", "a", []byte(`{"repository":{"full_name":"foo/bar"}}`)))
+	assert.Equal(t, "", SampleByRepo("ping", "a", []byte(`{}`)))
+}
+
+func TestSampledRoutes(t *testing.T) {
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	routes := []Route{
+		{Handler: h},
+		{Handler: h, Sampling: &Sampling{Percent: 0}},
+		{Handler: h, Sampling: &Sampling{Percent: 100}},
+	}
+	assert.Len(t, sampledRoutes(routes, "push", "a", nil), 2)
+
+	r := &Responder{}
+	assert.Error(t, r.AddSampledShadow(Sampling{Percent: 200}, h))
+	assert.NoError(t, r.AddSampledShadow(Sampling{Percent: 50}, h, h))
+	assert.Len(t, r.shadows, 2)
+}
@@ -0,0 +1,200 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// scheduleNamespace - the state store namespace follow-ups are kept in
+	scheduleNamespace = "responder.schedule"
+	// schedulerInterval - how often the scheduler looks for due follow-ups
+	schedulerInterval = 5 * time.Second
+)
+
+// scheduledRun - a follow-up run of a handler, as stored
+type scheduledRun struct {
+	Handler  string    `json:"handler"`
+	Due      time.Time `json:"due"`
+	Delivery *Delivery `json:"delivery"`
+	// Deferred - the delivery arrived during maintenance, and is run once
+	// maintenance is over, rather than being a follow-up
+	Deferred bool `json:"deferred,omitempty"`
+}
+
+// scheduleContext - what's needed to schedule a follow-up of the delivery
+type scheduleContext struct {
+	r *Responder
+	d *Delivery
+}
+
+// ScheduleFollowUp - run the handler again with the same delivery after the
+// delay (e.g. "re-check this PR in 30 minutes"). The follow-up's
+// GetDeliveryInfo(ctx).FollowUp is true. Follow-ups are kept in the state
+// store (see SetStateStore), so survive restarts when it's persistent, and
+// are run by RegisterAndListen (or RunScheduler).
+func ScheduleFollowUp(ctx context.Context, delay time.Duration) error {
+	sc, ok := ctx.Value(scheduleKey).(*scheduleContext)
+	name := handlerName(ctx)
+	if !ok || name == "" {
+		return errors.New("can't schedule a follow-up - the context didn't come from a Responder's handler")
+	}
+	return sc.r.schedule(scheduledRun{
+		Handler:  name,
+		Due:      sc.r.clock().Add(delay),
+		Delivery: sc.d,
+	})
+}
+
+// scheduleStore - the state store, or an in-memory store when there isn't one
+func (r *Responder) scheduleStore() StateStore {
+	r.scheduleMu.Lock()
+	defer r.scheduleMu.Unlock()
+	if r.state != nil {
+		return r.state
+	}
+	if r.scheduleMem == nil {
+		r.scheduleMem = NewMemoryStateStore()
+	}
+	return r.scheduleMem
+}
+
+func (r *Responder) schedule(run scheduledRun) error {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode follow-up")
+	}
+	return r.scheduleStore().Put(scheduleNamespace, r.newID(), b)
+}
+
+// RunScheduler - run scheduled follow-ups as they come due, and deliveries
+// deferred by maintenance once it's over, until the context is cancelled.
+// First, deliveries left in the write-ahead log are retried (see
+// EnableWriteAheadLog), and handler runs saved on shutdown are queued (see
+// EnableQueueSnapshot). RegisterAndListen runs this - only call it when
+// using Listen directly.
+func (r *Responder) RunScheduler(ctx context.Context) {
+	r.replayWAL()
+	r.restoreQueue()
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for {
+		r.runDue(r.clock())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue starts the follow-ups due by now. Each is removed from the store
+// before it's run, so runs at most once.
+func (r *Responder) runDue(now time.Time) {
+	s := r.scheduleStore()
+	keys, err := s.Keys(scheduleNamespace)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list follow-ups")
+		return
+	}
+	for _, k := range keys {
+		// left queued, for the queue snapshot, or the next start
+		if r.srv.isDraining() {
+			return
+		}
+		b, err := s.Get(scheduleNamespace, k)
+		if err != nil || b == nil {
+			continue
+		}
+		run := scheduledRun{}
+		err = json.Unmarshal(b, &run)
+		if err == nil && run.Delivery == nil {
+			err = errors.New("missing delivery")
+		}
+		if err != nil {
+			log.Error().Err(err).Str("followUp", k).Msg("dropping invalid follow-up")
+			_ = s.Delete(scheduleNamespace, k)
+			continue
+		}
+		if run.Due.After(now) || (run.Deferred && r.inMaintenance(now)) {
+			continue
+		}
+		err = s.Delete(scheduleNamespace, k)
+		if err != nil {
+			log.Error().Err(err).Str("followUp", k).Msg("failed to remove follow-up - not running it")
+			continue
+		}
+		r.runFollowUp(run)
+	}
+}
+
+func (r *Responder) runFollowUp(run scheduledRun) {
+	d := run.Delivery
+	info := parseEventInfo(d.Payload)
+	l := info.withFields(log.With().
+		Str("eventType", d.EventType).
+		Str("deliveryID", d.DeliveryID).
+		Str("handler", run.Handler).
+		Bool("followUp", !run.Deferred).
+		Bool("deferred", run.Deferred)).Logger()
+
+	rt, ok := r.routeNamed(run.Handler)
+	if !ok {
+		l.Warn().Msg("dropping scheduled run - its handler no longer exists")
+		return
+	}
+	// as with deliveries, runs due while the handler's disabled aren't
+	// replayed once it's enabled again
+	if len(r.enabledRoutes([]Route{rt})) == 0 {
+		l.Info().Msg("dropping scheduled run - its handler is disabled")
+		return
+	}
+	l.Info().Msg("Running scheduled run")
+
+	di := parseDeliveryInfo(d.Headers)
+	di.EventType, di.DeliveryID = d.EventType, d.DeliveryID
+	di.InstallationID = info.Installation.ID
+	di.FollowUp = !run.Deferred
+	ctx := r.handlerContext(l, di, d)
+	// deferred check re-runs still get their prior check runs
+	ctx, _ = r.routeRerequested(ctx, l, d.EventType, d.Payload, nil)
+	routes := r.hydrating(ctx, di, d.EventType, d.Payload, []Route{{Handler: rt.Handler, name: rt.name}})
+	if !r.srv.begin() {
+		l.Info().Msg("shutting down - running on the next start")
+		if err := r.schedule(run); err != nil {
+			l.Error().Err(err).Msg("failed to reschedule run - dropping it")
+		}
+		return
+	}
+	if !r.pool.reserve(len(routes)) {
+		r.srv.end()
+		l.Warn().Msg("handler queue full - running later")
+		run.Due = r.clock().Add(schedulerInterval)
+		if err := r.schedule(run); err != nil {
+			l.Error().Err(err).Msg("failed to reschedule run - dropping it")
+		}
+		return
+	}
+	routes = r.guarded(routes, d.EventType, d.DeliveryID)
+	wg := dispatchTo(ctx, r.pool, routes, d.EventType, d.DeliveryID, d.Payload)
+	go func() {
+		wg.Wait()
+		r.srv.end()
+	}()
+}
+
+// routeNamed finds the named route (see endpoint.namedRoutes)
+func (r *Responder) routeNamed(name string) (Route, bool) {
+	for _, ep := range r.endpoints {
+		for _, rt := range ep.namedRoutes() {
+			if rt.name == name {
+				return rt, true
+			}
+		}
+	}
+	return Route{}, false
+}
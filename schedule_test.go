@@ -0,0 +1,108 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleFollowUp(t *testing.T) {
+	assert.Error(t, ScheduleFollowUp(context.Background(), time.Minute))
+
+	type call struct {
+		deliveryID string
+		followUp   bool
+	}
+	calls := make(chan call, 2)
+	var handler HookHandler = func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		di := GetDeliveryInfo(ctx)
+		calls <- call{deliveryID, di.FollowUp}
+		if !di.FollowUp {
+			assert.NoError(t, ScheduleFollowUp(ctx, time.Minute))
+		}
+	}
+	other := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.SetStateStore(NewMemoryStateStore())
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{other, handler})}
+
+	d := &Delivery{EventType: "push", DeliveryID: "abc", Headers: http.Header{}, Payload: []byte(`{}`)}
+	ctx := r.handlerContext(zerolog.Nop(), &DeliveryInfo{}, d)
+	dispatch(ctx, r.endpoints[0].namedRoutes(), "push", "abc", d.Payload).Wait()
+	assert.Equal(t, call{"abc", false}, <-calls)
+
+	keys, _ := r.state.Keys(scheduleNamespace)
+	assert.Len(t, keys, 1)
+
+	// not due yet
+	r.runDue(time.Now())
+	assert.Empty(t, calls)
+
+	r.runDue(time.Now().Add(time.Hour))
+	assert.Equal(t, call{"abc", true}, <-calls)
+	keys, _ = r.state.Keys(scheduleNamespace)
+	assert.Empty(t, keys)
+}
+
+func TestRouteNamed(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{h})}
+	r.endpoints[0].routes[0].Events = []string{"push"}
+
+	rt, ok := r.routeNamed("0.0")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"push"}, rt.Events)
+
+	for _, name := range []string{"", "0", "0.1", "1.0", "a.b", "-1.0"} {
+		_, ok = r.routeNamed(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestRunDueInvalid(t *testing.T) {
+	r := &Responder{}
+	s := r.scheduleStore()
+	assert.NoError(t, s.Put(scheduleNamespace, "bad", []byte("not json")))
+	assert.NoError(t, s.Put(scheduleNamespace, "gone", []byte(`{"handler":"3.0","delivery":{"event_type":"push","payload":{}}}`)))
+	r.runDue(time.Now())
+	keys, _ := s.Keys(scheduleNamespace)
+	assert.Empty(t, keys)
+}
+
+func TestRunDueDisabled(t *testing.T) {
+	calls := make(chan string, 1)
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	ep := r.newEndpoint(nil, nil)
+	ep.routes = []Route{{Name: "deploy", Handler: func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		calls <- deliveryID
+	}}}
+	r.endpoints = []*endpoint{ep}
+	assert.NoError(t, r.DisableHandler("deploy"))
+
+	d := &Delivery{EventType: "push", DeliveryID: "abc", Headers: http.Header{}, Payload: []byte(`{}`)}
+	now := time.Now()
+	assert.NoError(t, r.schedule(scheduledRun{Handler: "deploy", Due: now, Delivery: d}))
+	assert.NoError(t, r.schedule(scheduledRun{Handler: "deploy", Due: now, Delivery: d, Deferred: true}))
+	r.runDue(now)
+	// dropped, not kept for when it's enabled again
+	keys, _ := r.scheduleStore().Keys(scheduleNamespace)
+	assert.Empty(t, keys)
+	assert.NoError(t, r.EnableHandler("deploy"))
+	r.runDue(now)
+	select {
+	case <-calls:
+		t.Error("shouldn't run while the handler's disabled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, r.schedule(scheduledRun{Handler: "deploy", Due: now, Delivery: d}))
+	r.runDue(now)
+	assert.Equal(t, "abc", <-calls)
+	assert.Empty(t, calls)
+}
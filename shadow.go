@@ -0,0 +1,72 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// AddShadow - mirror every delivery to shadow handlers, e.g. a canary build
+// of new handlers, to test them on live traffic. Shadow handlers get their
+// own copy of the payload, and their outcomes are logged and counted
+// (github_webhook_shadow_results_total), but never affect the delivery's
+// response or tracked status - failures (including panics) are contained.
+//
+// Shadow handlers should check IsShadow before causing side effects, like
+// commenting on issues, that the authoritative handlers already cause.
+func (r *Responder) AddShadow(actions ...HookHandler) {
+	r.shadows = append(r.shadows, actions...)
+}
+
+// IsShadow - whether the handler is running as a shadow (see AddShadow)
+func IsShadow(ctx context.Context) bool {
+	_, ok := ctx.Value(shadowKey).(*handlerResult)
+	return ok
+}
+
+// handlerResult collects failures reported by a shadow handler, or by one
+// attempt of a retried handler (see Retry)
+type handlerResult struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (s *handlerResult) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// mirror runs the shadow handlers on a copy of the delivery, recording their
+// outcomes
+func (r *Responder) mirror(ctx context.Context, eventType, deliveryID string, payload []byte) {
+	for i, h := range r.shadows {
+		p := append([]byte(nil), payload...)
+		go runShadow(ctx, i, h, eventType, deliveryID, p)
+	}
+}
+
+func runShadow(ctx context.Context, i int, h HookHandler, eventType, deliveryID string, payload []byte) {
+	res := &handlerResult{}
+	ctx = context.WithValue(ctx, shadowKey, res)
+	log := zerolog.Ctx(ctx).With().Bool("shadow", true).Int("shadowHandler", i).Logger()
+	ctx = log.WithContext(ctx)
+
+	defer func() {
+		if p := recover(); p != nil {
+			res.fail(fmt.Errorf("handler panicked: %v", p))
+		}
+		if res.err != nil {
+			shadowResults.WithLabelValues(eventType, string(StateFailed)).Inc()
+			log.Warn().Err(res.err).Msg("shadow handler failed")
+			return
+		}
+		shadowResults.WithLabelValues(eventType, string(StateSucceeded)).Inc()
+		log.Debug().Msg("shadow handler succeeded")
+	}()
+	h(ctx, eventType, deliveryID, payload)
+}
@@ -0,0 +1,49 @@
+package responder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirror(t *testing.T) {
+	results := make(chan bool, 3)
+	payloads := make(chan []byte, 3)
+	record := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		results <- IsShadow(ctx)
+		payload[0] = 'X'
+		payloads <- payload
+	}
+	failing := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		ReportFailure(ctx, errors.New("nope"))
+		record(ctx, eventType, deliveryID, payload)
+	}
+	panicky := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		record(ctx, eventType, deliveryID, payload)
+		panic("boom")
+	}
+
+	r := &Responder{}
+	r.AddShadow(record, failing)
+	r.AddShadow(panicky)
+	payload := []byte(`{}`)
+	r.mirror(context.Background(), "push", "1", payload)
+	for i := 0; i < 3; i++ {
+		assert.True(t, <-results)
+		assert.Equal(t, []byte(`X}`), <-payloads)
+	}
+	// each shadow got its own copy
+	assert.Equal(t, []byte(`{}`), payload)
+
+	assert.False(t, IsShadow(context.Background()))
+}
+
+func TestShadowResult(t *testing.T) {
+	res := &handlerResult{}
+	ctx := context.WithValue(context.Background(), shadowKey, res)
+	ReportFailure(ctx, errors.New("first"))
+	ReportFailure(ctx, errors.New("second"))
+	assert.EqualError(t, res.err, "first")
+}
@@ -0,0 +1,143 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Shim - normalizes a delivery's payload (in place) and event type, so
+// handlers written against older payload shapes keep working after GitHub
+// renames or moves things. Returns the event type to dispatch as.
+type Shim func(eventType string, payload map[string]interface{}) string
+
+// LegacyEventTypes - a Shim that renames event types GitHub has renamed,
+// like integration_installation (now installation)
+func LegacyEventTypes(eventType string, payload map[string]interface{}) string {
+	switch eventType {
+	case "integration_installation":
+		return "installation"
+	case "integration_installation_repositories":
+		return "installation_repositories"
+	}
+	return eventType
+}
+
+// CopyField - a Shim that copies the field at the dotted path from (e.g.
+// "pull_request.head.sha") to the path to, in payloads of the event type
+// that have from but not to. Both are kept, so handlers expecting either
+// shape work.
+func CopyField(eventType, from, to string) Shim {
+	return func(et string, payload map[string]interface{}) string {
+		if et != eventType {
+			return et
+		}
+		v, ok := lookupField(payload, from)
+		if !ok {
+			return et
+		}
+		if _, exists := lookupField(payload, to); !exists {
+			setField(payload, to, v)
+		}
+		return et
+	}
+}
+
+// AddShims - normalize deliveries with the shims, in order, before they're
+// dispatched. Recorded deliveries aren't normalized.
+func (r *Responder) AddShims(shims ...Shim) {
+	r.shims = append(r.shims, shims...)
+}
+
+// PinSchema - expect deliveries of the event type to have the fields (dotted
+// paths, checked after shims are applied). Deliveries missing any are logged
+// and counted (github_webhook_unrecognized_deliveries_total, with reason
+// "missing_pinned_fields"), so upstream changes are noticed.
+func (r *Responder) PinSchema(eventType string, fields ...string) {
+	if r.pins == nil {
+		r.pins = map[string][]string{}
+	}
+	r.pins[eventType] = append(r.pins[eventType], fields...)
+}
+
+// normalize applies the shims, and checks the pinned fields
+func (r *Responder) normalize(log zerolog.Logger, eventType string, payload []byte) (string, []byte) {
+	if len(r.shims) == 0 && len(r.pins[eventType]) == 0 {
+		return eventType, payload
+	}
+	var p map[string]interface{}
+	// numbers are kept as written, so large IDs survive re-encoding
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if dec.Decode(&p) != nil {
+		return eventType, payload
+	}
+
+	if len(r.shims) > 0 {
+		orig := eventType
+		for _, shim := range r.shims {
+			eventType = shim(eventType, p)
+		}
+		b, err := json.Marshal(p)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to encode normalized payload - dispatching it as received")
+			return orig, payload
+		}
+		payload = b
+		if eventType != orig {
+			log.Debug().Str("originalEventType", orig).Msg("event type normalized")
+		}
+	}
+
+	var missing []string
+	for _, f := range r.pins[eventType] {
+		if _, ok := lookupField(p, f); !ok {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		countUnrecognized(eventType, "missing_pinned_fields")
+		log.Warn().Strs("fields", missing).Msg("payload is missing pinned fields")
+	}
+	return eventType, payload
+}
+
+func lookupField(payload map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	m := payload
+	for i, part := range parts {
+		v, ok := m[part]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return v, true
+		}
+		m, ok = v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// setField sets the value at the path, creating objects as needed - unless a
+// non-object is in the way
+func setField(payload map[string]interface{}, path string, v interface{}) {
+	parts := strings.Split(path, ".")
+	m := payload
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]interface{})
+		if !ok {
+			if _, exists := m[part]; exists {
+				return
+			}
+			next = map[string]interface{}{}
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = v
+}
@@ -0,0 +1,60 @@
+package responder
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyEventTypes(t *testing.T) {
+	assert.Equal(t, "installation", LegacyEventTypes("integration_installation", nil))
+	assert.Equal(t, "installation_repositories", LegacyEventTypes("integration_installation_repositories", nil))
+	assert.Equal(t, "push", LegacyEventTypes("push", nil))
+}
+
+func TestCopyField(t *testing.T) {
+	shim := CopyField("push", "head_commit.id", "after")
+
+	p := map[string]interface{}{"head_commit": map[string]interface{}{"id": "abc"}}
+	assert.Equal(t, "push", shim("push", p))
+	assert.Equal(t, "abc", p["after"])
+
+	// existing fields aren't overwritten
+	p = map[string]interface{}{"head_commit": map[string]interface{}{"id": "abc"}, "after": "def"}
+	shim("push", p)
+	assert.Equal(t, "def", p["after"])
+
+	// other events are left alone
+	p = map[string]interface{}{"head_commit": map[string]interface{}{"id": "abc"}}
+	shim("issues", p)
+	assert.NotContains(t, p, "after")
+
+	// nested destinations are created
+	p = map[string]interface{}{"a": "x"}
+	CopyField("push", "a", "b.c")("push", p)
+	assert.Equal(t, map[string]interface{}{"c": "x"}, p["b"])
+	p = map[string]interface{}{"a": "x", "b": "in the way"}
+	CopyField("push", "a", "b.c")("push", p)
+	assert.Equal(t, "in the way", p["b"])
+}
+
+func TestNormalize(t *testing.T) {
+	r := &Responder{}
+	payload := []byte(`{"id":12345678901234567890,"installation":{"id":1}}`)
+	et, p := r.normalize(zerolog.Nop(), "integration_installation", payload)
+	assert.Equal(t, "integration_installation", et)
+	assert.Equal(t, payload, p)
+
+	r.AddShims(LegacyEventTypes, CopyField("installation", "installation.id", "installation_id"))
+	r.PinSchema("installation", "installation.id", "sender.login")
+	et, p = r.normalize(zerolog.Nop(), "integration_installation", payload)
+	assert.Equal(t, "installation", et)
+	assert.JSONEq(t, `{"id":12345678901234567890,"installation":{"id":1},"installation_id":1}`, string(p))
+	assert.Contains(t, string(p), "12345678901234567890")
+
+	// invalid payloads are passed through
+	et, p = r.normalize(zerolog.Nop(), "push", []byte(`nope`))
+	assert.Equal(t, "push", et)
+	assert.Equal(t, []byte(`nope`), p)
+}
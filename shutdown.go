@@ -0,0 +1,253 @@
+package responder
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mholt/certmagic"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// serving - the Responder's HTTP servers, and its deliveries' in-flight
+// handlers, so both can be drained on shutdown. The zero value is ready to
+// use.
+type serving struct {
+	mu       sync.Mutex
+	servers  []*http.Server
+	handlers sync.WaitGroup
+	once     sync.Once
+	err      error
+	// draining - set once drain starts waiting for handlers, after which no
+	// more may start (see begin)
+	draining bool
+	// ready - closed once callbacks can be served (see listening)
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// listening - closed once Listen's callback server is bound to its address
+// (and with TLS, has certificates), so it can serve deliveries
+func (r *Responder) listening() chan struct{} {
+	r.srv.mu.Lock()
+	defer r.srv.mu.Unlock()
+	if r.srv.ready == nil {
+		r.srv.ready = make(chan struct{})
+	}
+	return r.srv.ready
+}
+
+// markListening - signal that callbacks can be served
+func (r *Responder) markListening() {
+	ch := r.listening()
+	r.srv.readyOnce.Do(func() { close(ch) })
+}
+
+// addServer registers the server to be shut down by drain. Servers
+// registered before they're started can't start once drained.
+func (r *Responder) addServer(srv *http.Server) {
+	r.srv.mu.Lock()
+	defer r.srv.mu.Unlock()
+	r.srv.servers = append(r.srv.servers, srv)
+}
+
+// serve runs the server in the background, logging why it stopped
+func serve(name, addr string, run func() error) {
+	go func() {
+		log.Info().Str("addr", addr).Str("server", name).Msg("Listening for webhook callbacks")
+		err := run()
+		if err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Str("server", name).Msg("failed to listen")
+		}
+	}()
+}
+
+// serveHTTP serves the handler over plain HTTP, for when TLS is disabled.
+// The address is bound before it returns.
+func (r *Responder) serveHTTP(h http.Handler) {
+	srv := &http.Server{
+		Addr:    r.callbackAddr(false),
+		Handler: h,
+	}
+	r.addServer(srv)
+	ln := r.listener
+	if ln == nil {
+		var err error
+		ln, err = net.Listen("tcp", srv.Addr)
+		if err != nil {
+			log.Error().Err(err).Str("server", "http").Msg("failed to listen")
+			return
+		}
+	}
+	r.markListening()
+	serve("http", ln.Addr().String(), func() error { return srv.Serve(ln) })
+}
+
+// serveHTTPS serves the handler over HTTPS, with certificates from certmagic
+// for the served domains, and the HTTP port answering ACME challenges and
+// redirecting to HTTPS. With a DNSProvider, challenges are answered through
+// DNS, and the HTTP port isn't served. Certificates are obtained in the
+// background, as that can take a while.
+func (r *Responder) serveHTTPS(h http.Handler) {
+	httpSrv := &http.Server{
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      5 * time.Second,
+		IdleTimeout:       5 * time.Second,
+	}
+	httpsSrv := &http.Server{
+		Addr:              r.callbackAddr(true),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      2 * time.Minute,
+		IdleTimeout:       5 * time.Minute,
+		Handler:           h,
+	}
+	r.addServer(httpSrv)
+	r.addServer(httpsSrv)
+	addr := httpsSrv.Addr
+	if r.listener != nil {
+		addr = r.listener.Addr().String()
+	}
+	serve("https", addr, func() error {
+		err := r.registerACMEAccount()
+		if err != nil {
+			return err
+		}
+		cfg, err := certmagic.Manage(r.servedDomains())
+		if err != nil {
+			return errors.Wrap(err, "failed to obtain certificates")
+		}
+		if r.dnsProvider == nil {
+			httpSrv.Handler = cfg.HTTPChallengeHandler(http.HandlerFunc(redirectHTTPS))
+			httpAddr := ":" + strconv.Itoa(r.httpPort)
+			ln, err := net.Listen("tcp", httpAddr)
+			if err != nil {
+				return err
+			}
+			serve("http", httpAddr, func() error { return httpSrv.Serve(ln) })
+		}
+		httpsSrv.TLSConfig = r.mutualTLS(cfg.TLSConfig())
+		ln := r.listener
+		if ln == nil {
+			ln, err = net.Listen("tcp", httpsSrv.Addr)
+			if err != nil {
+				return err
+			}
+		}
+		r.markListening()
+		return httpsSrv.ServeTLS(ln, "", "")
+	})
+}
+
+// callbackAddr - the address to serve callbacks on: Options.Addr, or all
+// interfaces on the HTTPS (or, without TLS, HTTP) port
+func (r *Responder) callbackAddr(tls bool) string {
+	if r.addr != "" {
+		return r.addr
+	}
+	if tls {
+		return ":" + strconv.Itoa(r.httpsPort)
+	}
+	return ":" + strconv.Itoa(r.httpPort)
+}
+
+// redirectHTTPS redirects plain HTTP requests to the standard HTTPS port
+func redirectHTTPS(w http.ResponseWriter, req *http.Request) {
+	host, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		host = req.Host
+	}
+	w.Header().Set("Connection", "close")
+	http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// begin counts a delivery's (or scheduled run's) handlers in, so drain
+// waits for them - unless drain's already waiting, when it returns false,
+// and they mustn't be started. Counting in under the lock, and refusing
+// once draining, means the count can't rise from 0 while drain waits on it.
+func (s *serving) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.handlers.Add(1)
+	return true
+}
+
+// end counts handlers out, after begin
+func (s *serving) end() {
+	s.handlers.Done()
+}
+
+// isDraining - whether drain's waiting for handlers, so no more may start
+func (s *serving) isDraining() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.draining
+}
+
+// trackHandlers counts the delivery's handlers out once they've all
+// finished (they're counted in with begin), and the delivery out of the
+// in-flight limit
+func (r *Responder) trackHandlers(wg *sync.WaitGroup) {
+	go func() {
+		wg.Wait()
+		r.srv.end()
+		r.inFlight.release()
+	}()
+}
+
+// drain gracefully shuts down the Responder's HTTP servers, so no new
+// deliveries are accepted, and stops more handlers starting (scheduled runs
+// and logged deliveries are left for the next start), then waits for
+// in-flight handlers to finish -
+// giving up on both after the drain timeout (see Options.DrainTimeout).
+// Safe to call more than once - later calls wait for the first to finish,
+// and return its error.
+func (r *Responder) drain() error {
+	r.srv.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), r.drainTimeout)
+		defer cancel()
+		r.srv.err = r.srv.shutdown(ctx, r.snapshotQueue)
+	})
+	return r.srv.err
+}
+
+// shutdown stops the servers, then stops more handlers from starting and
+// calls stopped, before waiting for the handlers
+func (s *serving) shutdown(ctx context.Context, stopped func()) error {
+	s.mu.Lock()
+	servers := s.servers
+	s.mu.Unlock()
+
+	var err error
+	for _, srv := range servers {
+		serr := srv.Shutdown(ctx)
+		if serr != nil && err == nil {
+			err = errors.Wrap(serr, "failed to shut down HTTP server")
+		}
+	}
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+	stopped()
+
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Debug().Msg("all handlers finished")
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "gave up waiting for handlers to finish")
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package responder
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestDrain(t *testing.T) {
+	r := &Responder{httpPort: freePort(t), drainTimeout: 5 * time.Second}
+	r.serveHTTP(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	select {
+	case <-r.listening():
+	default:
+		t.Error("should be listening once serveHTTP returns")
+	}
+	u := "http://localhost:" + strconv.Itoa(r.httpPort) + "/"
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		resp, err = http.Get(u)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp.Body.Close()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	assert.True(t, r.srv.begin())
+	r.trackHandlers(wg)
+	drained := make(chan error)
+	go func() { drained <- r.drain() }()
+	select {
+	case <-drained:
+		t.Fatal("drained before the handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	wg.Done()
+	assert.NoError(t, <-drained)
+	// idempotent
+	assert.NoError(t, r.drain())
+
+	// no longer listening
+	_, err = http.Get(u)
+	assert.Error(t, err)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	r := &Responder{drainTimeout: 10 * time.Millisecond}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	defer wg.Done()
+	assert.True(t, r.srv.begin())
+	r.trackHandlers(wg)
+	assert.Error(t, r.drain())
+}
+
+func TestServeListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	r := &Responder{listener: ln, drainTimeout: time.Second}
+	r.serveHTTP(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+	assert.NoError(t, r.drain())
+}
+
+func TestCallbackAddr(t *testing.T) {
+	r := &Responder{httpPort: 8080, httpsPort: 8443}
+	assert.Equal(t, ":8443", r.callbackAddr(true))
+	assert.Equal(t, ":8080", r.callbackAddr(false))
+	r.addr = "127.0.0.1:9000"
+	assert.Equal(t, "127.0.0.1:9000", r.callbackAddr(true))
+}
+
+func TestServeHTTPListenFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ln.Close()
+	r := &Responder{addr: ln.Addr().String(), drainTimeout: time.Second}
+	r.serveHTTP(http.NotFoundHandler())
+	select {
+	case <-r.listening():
+		t.Error("shouldn't be listening when the address is in use")
+	default:
+	}
+	assert.NoError(t, r.drain())
+}
+
+func TestDrainRefusesNewRuns(t *testing.T) {
+	ran := make(chan string, 1)
+	r := &Responder{domain: "example.com", pathPrefix: "/", drainTimeout: time.Second}
+	ep := r.newEndpoint(nil, nil)
+	ep.routes = []Route{{Name: "deploy", Handler: func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		ran <- deliveryID
+	}}}
+	r.endpoints = []*endpoint{ep}
+	d := &Delivery{EventType: "push", DeliveryID: "abc", Headers: http.Header{}, Payload: []byte(`{}`)}
+	now := time.Now()
+	assert.NoError(t, r.schedule(scheduledRun{Handler: "deploy", Due: now, Delivery: d}))
+	r.EnableWriteAheadLog(NewMemoryStateStore())
+	r.writeAhead(log.Logger, 0, d)
+
+	assert.NoError(t, r.drain())
+	assert.False(t, r.srv.begin())
+
+	// due runs and logged deliveries are left for the next start
+	r.runDue(now)
+	r.replayWAL()
+	keys, _ := r.wal.Keys(walNamespace)
+	assert.Len(t, keys, 1)
+	keys, _ = r.scheduleStore().Keys(scheduleNamespace)
+	assert.Len(t, keys, 1)
+	assert.Empty(t, ran)
+
+	// as are runs that come due just as the drain starts
+	assert.NoError(t, r.scheduleStore().Delete(scheduleNamespace, keys[0]))
+	r.runFollowUp(scheduledRun{Handler: "deploy", Due: now, Delivery: d})
+	keys, _ = r.scheduleStore().Keys(scheduleNamespace)
+	assert.Len(t, keys, 1)
+	assert.Empty(t, ran)
+}
@@ -0,0 +1,232 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// StateStore - persistent key/value storage for handlers, partitioned into
+// namespaces. Implementations must be safe for concurrent use.
+type StateStore interface {
+	// Get returns the value, or nil if the key isn't set
+	Get(namespace, key string) ([]byte, error)
+	Put(namespace, key string, value []byte) error
+	Delete(namespace, key string) error
+	// Keys lists the namespace's keys, in no particular order
+	Keys(namespace string) ([]string, error)
+}
+
+// SetStateStore - give handlers the store, through State
+func (r *Responder) SetStateStore(s StateStore) {
+	r.state = s
+}
+
+// State - the namespace of the Responder's state store, for stateful
+// handlers (e.g. to remember the last processed SHA per branch). Returns nil
+// if the context didn't come from a Responder with a state store.
+func State(ctx context.Context, namespace string) *StateNamespace {
+	s, ok := ctx.Value(stateKey).(StateStore)
+	if !ok {
+		return nil
+	}
+	return &StateNamespace{s, namespace}
+}
+
+func withStateStore(ctx context.Context, s StateStore) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, stateKey, s)
+}
+
+// StateNamespace - a namespace of a StateStore
+type StateNamespace struct {
+	store     StateStore
+	namespace string
+}
+
+// Get - the key's value, or nil if it isn't set
+func (n *StateNamespace) Get(key string) ([]byte, error) {
+	return n.store.Get(n.namespace, key)
+}
+
+// Put - set the key's value
+func (n *StateNamespace) Put(key string, value []byte) error {
+	return n.store.Put(n.namespace, key, value)
+}
+
+// Delete - unset the key
+func (n *StateNamespace) Delete(key string) error {
+	return n.store.Delete(n.namespace, key)
+}
+
+// Keys - the namespace's keys
+func (n *StateNamespace) Keys() ([]string, error) {
+	return n.store.Keys(n.namespace)
+}
+
+// MemoryStateStore - a StateStore that doesn't persist across restarts, for
+// tests
+type MemoryStateStore struct {
+	mu   sync.Mutex
+	data map[string]map[string][]byte
+}
+
+// NewMemoryStateStore -
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{data: map[string]map[string][]byte{}}
+}
+
+// Get -
+func (m *MemoryStateStore) Get(namespace, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data[namespace][key], nil
+}
+
+// Put -
+func (m *MemoryStateStore) Put(namespace, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data[namespace] == nil {
+		m.data[namespace] = map[string][]byte{}
+	}
+	m.data[namespace][key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete -
+func (m *MemoryStateStore) Delete(namespace, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data[namespace], key)
+	return nil
+}
+
+// Keys -
+func (m *MemoryStateStore) Keys(namespace string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.data[namespace]))
+	for k := range m.data[namespace] {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// FileStateStore - a StateStore keeping each namespace in a JSON file in a
+// directory. Every write rewrites the namespace's file (atomically), so it
+// suits small amounts of state.
+type FileStateStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStateStore - a FileStateStore in dir, which is created if needed
+func NewFileStateStore(dir string) (*FileStateStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s", dir)
+	}
+	return &FileStateStore{dir: dir}, nil
+}
+
+func (f *FileStateStore) path(namespace string) string {
+	return filepath.Join(f.dir, url.PathEscape(namespace)+".json")
+}
+
+func (f *FileStateStore) load(namespace string) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	b, err := ioutil.ReadFile(f.path(namespace))
+	if os.IsNotExist(err) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read state namespace %s", namespace)
+	}
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse state namespace %s", namespace)
+	}
+	return data, nil
+}
+
+func (f *FileStateStore) save(namespace string, data map[string][]byte) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode state namespace %s", namespace)
+	}
+	tmp, err := ioutil.TempFile(f.dir, ".state-")
+	if err != nil {
+		return errors.Wrap(err, "failed to write state")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write state")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), f.path(namespace)), "failed to write state")
+}
+
+// Get -
+func (f *FileStateStore) Get(namespace, key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return data[key], nil
+}
+
+// Put -
+func (f *FileStateStore) Put(namespace, key string, value []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load(namespace)
+	if err != nil {
+		return err
+	}
+	data[key] = value
+	return f.save(namespace, data)
+}
+
+// Delete -
+func (f *FileStateStore) Delete(namespace, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load(namespace)
+	if err != nil {
+		return err
+	}
+	if _, ok := data[key]; !ok {
+		return nil
+	}
+	delete(data, key)
+	return f.save(namespace, data)
+}
+
+// Keys -
+func (f *FileStateStore) Keys(namespace string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := f.load(namespace)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
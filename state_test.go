@@ -0,0 +1,75 @@
+package responder
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStateStore(t *testing.T, s StateStore) {
+	v, err := s.Get("ns", "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, s.Put("ns", "main", []byte("abc")))
+	assert.NoError(t, s.Put("ns", "dev", []byte("def")))
+	assert.NoError(t, s.Put("other", "main", []byte("ghi")))
+
+	v, err = s.Get("ns", "main")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), v)
+	v, _ = s.Get("other", "main")
+	assert.Equal(t, []byte("ghi"), v)
+
+	keys, err := s.Keys("ns")
+	assert.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"dev", "main"}, keys)
+
+	assert.NoError(t, s.Delete("ns", "main"))
+	assert.NoError(t, s.Delete("ns", "main"))
+	v, _ = s.Get("ns", "main")
+	assert.Nil(t, v)
+}
+
+func TestMemoryStateStore(t *testing.T) {
+	testStateStore(t, NewMemoryStateStore())
+}
+
+func TestFileStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStateStore(dir)
+	assert.NoError(t, err)
+	testStateStore(t, s)
+
+	// survives restarts, and namespaces can't escape the directory
+	assert.NoError(t, s.Put("../evil", "k", []byte("v")))
+	s, err = NewFileStateStore(dir)
+	assert.NoError(t, err)
+	v, _ := s.Get("../evil", "k")
+	assert.Equal(t, []byte("v"), v)
+	v, _ = s.Get("ns", "dev")
+	assert.Equal(t, []byte("def"), v)
+	_, err = os.Stat(dir + "/..%2Fevil.json")
+	assert.NoError(t, err)
+}
+
+func TestStateContext(t *testing.T) {
+	assert.Nil(t, State(context.Background(), "ns"))
+	assert.Equal(t, context.Background(), withStateStore(context.Background(), nil))
+
+	ctx := withStateStore(context.Background(), NewMemoryStateStore())
+	n := State(ctx, "ns")
+	assert.NoError(t, n.Put("k", []byte("v")))
+	v, _ := State(ctx, "ns").Get("k")
+	assert.Equal(t, []byte("v"), v)
+	v, _ = State(ctx, "other").Get("k")
+	assert.Nil(t, v)
+}
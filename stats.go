@@ -0,0 +1,128 @@
+package responder
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// maxTopSenders - how many senders Stats reports
+const maxTopSenders = 10
+
+// Stats - aggregate statistics over the tracked deliveries received in a
+// window
+type Stats struct {
+	Since time.Time `json:"since"`
+	Total int       `json:"total"`
+	// ByEvent - counts by event type
+	ByEvent map[string]int `json:"by_event"`
+	// ByAction - counts by event type and action, keyed like "issues.opened"
+	ByAction map[string]int `json:"by_action"`
+	// ByRepo - counts by repo full name
+	ByRepo     map[string]int `json:"by_repo"`
+	TopSenders []SenderCount  `json:"top_senders"`
+	Failed     int            `json:"failed"`
+	TimedOut   int            `json:"timed_out"`
+	// FailureRate - the fraction of finished deliveries that failed or timed
+	// out
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// SenderCount - the number of deliveries triggered by a sender
+type SenderCount struct {
+	Login string `json:"login"`
+	Count int    `json:"count"`
+}
+
+// Stats - compute statistics over the deliveries received in the last window
+// (all tracked deliveries, when window is 0). Statistics are computed from the
+// status tracking history, so only cover the deliveries it retains.
+func (r *Responder) Stats(window time.Duration) (Stats, error) {
+	if r.status == nil {
+		return Stats{}, ErrStatusTrackingDisabled
+	}
+	return r.status.stats(window), nil
+}
+
+func (t *statusTracker) stats(window time.Duration) Stats {
+	var since time.Time
+	if window > 0 {
+		since = t.now().Add(-window)
+	}
+	s := Stats{
+		Since:    since,
+		ByEvent:  map[string]int{},
+		ByAction: map[string]int{},
+		ByRepo:   map[string]int{},
+	}
+	senders := map[string]int{}
+	var finished int
+
+	t.mu.Lock()
+	for _, id := range t.order {
+		d := t.statuses[id]
+		if d.Received.Before(since) {
+			continue
+		}
+		s.Total++
+		s.ByEvent[d.EventType]++
+		if d.Action != "" {
+			s.ByAction[d.EventType+"."+d.Action]++
+		}
+		if d.Repo != "" {
+			s.ByRepo[d.Repo]++
+		}
+		if d.Sender != "" {
+			senders[d.Sender]++
+		}
+		switch d.State {
+		case StateFailed:
+			s.Failed++
+		case StateTimedOut:
+			s.TimedOut++
+		}
+		if d.State != StateQueued && d.State != StateRunning {
+			finished++
+		}
+	}
+	t.mu.Unlock()
+
+	if finished > 0 {
+		s.FailureRate = float64(s.Failed+s.TimedOut) / float64(finished)
+	}
+
+	s.TopSenders = []SenderCount{}
+	for login, n := range senders {
+		s.TopSenders = append(s.TopSenders, SenderCount{login, n})
+	}
+	sort.Slice(s.TopSenders, func(i, j int) bool {
+		a, b := s.TopSenders[i], s.TopSenders[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Login < b.Login
+	})
+	if len(s.TopSenders) > maxTopSenders {
+		s.TopSenders = s.TopSenders[:maxTopSenders]
+	}
+	return s
+}
+
+// statsHandler serves statistics as JSON - the window is given with the
+// 'window' query parameter (e.g. '?window=1h')
+func (t *statusTracker) statsHandler() http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		var window time.Duration
+		if w := req.URL.Query().Get("window"); w != "" {
+			var err error
+			window, err = time.ParseDuration(w)
+			if err != nil || window < 0 {
+				http.Error(resp, "invalid window - must be a duration like '1h'", http.StatusBadRequest)
+				return
+			}
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(resp).Encode(t.stats(window))
+	})
+}
@@ -0,0 +1,77 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	r := &Responder{}
+	_, err := r.Stats(0)
+	assert.Equal(t, ErrStatusTrackingDisabled, err)
+
+	r.EnableStatusTracking(10)
+	tr := r.status
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	add := func(eventType, id, action, repo, sender string, fail bool) {
+		h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			if fail {
+				ReportFailure(ctx, errors.New("nope"))
+			}
+		}
+		ctx, routes := tr.track(context.Background(), eventType, id, []Route{{Handler: h}})
+		info := eventInfo{Action: action}
+		info.Repo.FullName = repo
+		info.Sender.Login = sender
+		tr.describe(id, info)
+		dispatch(ctx, routes, eventType, id, nil).Wait()
+	}
+
+	add("push", "1", "", "foo/bar", "cat", false)
+	now = now.Add(time.Hour)
+	add("issues", "2", "opened", "foo/bar", "octo", true)
+	add("issues", "3", "closed", "foo/baz", "octo", false)
+	add("push", "4", "", "foo/baz", "dog", false)
+
+	s, err := r.Stats(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, s.Total)
+	assert.Equal(t, map[string]int{"push": 2, "issues": 2}, s.ByEvent)
+	assert.Equal(t, map[string]int{"issues.opened": 1, "issues.closed": 1}, s.ByAction)
+	assert.Equal(t, map[string]int{"foo/bar": 2, "foo/baz": 2}, s.ByRepo)
+	assert.Equal(t, []SenderCount{{"octo", 2}, {"cat", 1}, {"dog", 1}}, s.TopSenders)
+	assert.Equal(t, 1, s.Failed)
+	assert.Equal(t, 0.25, s.FailureRate)
+
+	s, _ = r.Stats(30 * time.Minute)
+	assert.Equal(t, 3, s.Total)
+	assert.Equal(t, map[string]int{"push": 1, "issues": 2}, s.ByEvent)
+	assert.InDelta(t, 1.0/3, s.FailureRate, 0.001)
+}
+
+func TestStatsHandler(t *testing.T) {
+	tr := newStatusTracker(10)
+	_, _ = tr.track(context.Background(), "push", "abc", nil)
+	h := tr.statsHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/stats?window=1h", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	s := Stats{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+	assert.Equal(t, 1, s.Total)
+	assert.Equal(t, map[string]int{"push": 1}, s.ByEvent)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/stats?window=bogus", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,212 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeliveryState - the processing state of a delivery
+type DeliveryState string
+
+// Delivery states, reported by the status endpoint
+const (
+	StateQueued    DeliveryState = "queued"
+	StateRunning   DeliveryState = "running"
+	StateSucceeded DeliveryState = "succeeded"
+	StateFailed    DeliveryState = "failed"
+	StateTimedOut  DeliveryState = "timed_out"
+)
+
+// DeliveryStatus - the processing status of a delivery
+type DeliveryStatus struct {
+	DeliveryID string        `json:"delivery_id"`
+	EventType  string        `json:"event_type"`
+	Action     string        `json:"action,omitempty"`
+	Repo       string        `json:"repo,omitempty"`
+	Sender     string        `json:"sender,omitempty"`
+	State      DeliveryState `json:"state"`
+	Error      string        `json:"error,omitempty"`
+	Received   time.Time     `json:"received"`
+	Updated    time.Time     `json:"updated"`
+}
+
+// EnableStatusTracking - track the processing status of the last max
+// deliveries. Deliveries are then answered with 202 Accepted, and a Location
+// header pointing at the delivery's status endpoint (<prefix>deliveries/<id>),
+// which reports its status as JSON. Aggregate statistics over the tracked
+// deliveries are served at <prefix>stats (see Stats), to addresses allowed by
+// the metrics IP filter.
+//
+// Handlers fail a delivery by panicking, or by calling ReportFailure.
+func (r *Responder) EnableStatusTracking(max int) {
+	r.status = newStatusTracker(max)
+	r.status.now = r.clock
+}
+
+// ReportFailure - mark the delivery being handled as failed, when status
+// tracking is enabled, or record the failure of a shadow handler (see
+// AddShadow). Within a Retry middleware, the failure is held back until the
+// last attempt. In synchronous mode, the failure is also replied to GitHub
+// (see Options.Synchronous), and with a write-ahead log the delivery is kept
+// to be retried (see EnableWriteAheadLog). Otherwise does nothing.
+func ReportFailure(ctx context.Context, err error) {
+	if res, ok := ctx.Value(attemptKey).(*handlerResult); ok {
+		res.fail(err)
+		return
+	}
+	if res, ok := ctx.Value(shadowKey).(*handlerResult); ok {
+		res.fail(err)
+		return
+	}
+	if res, ok := ctx.Value(failuresKey).(*handlerResult); ok {
+		res.fail(err)
+	}
+	if td, ok := ctx.Value(trackedDeliveryKey).(*trackedDelivery); ok {
+		td.t.fail(td.id, err)
+	}
+}
+
+type trackedDelivery struct {
+	t  *statusTracker
+	id string
+}
+
+type statusTracker struct {
+	mu       sync.Mutex
+	max      int
+	order    []string
+	statuses map[string]*DeliveryStatus
+	now      func() time.Time
+}
+
+func newStatusTracker(max int) *statusTracker {
+	return &statusTracker{
+		max:      max,
+		statuses: map[string]*DeliveryStatus{},
+		now:      time.Now,
+	}
+}
+
+// track records a new delivery, and wraps the routes' handlers to update its
+// state
+func (t *statusTracker) track(ctx context.Context, eventType, deliveryID string, routes []Route) (context.Context, []Route) {
+	now := t.now()
+	t.mu.Lock()
+	if _, ok := t.statuses[deliveryID]; !ok {
+		t.order = append(t.order, deliveryID)
+	}
+	t.statuses[deliveryID] = &DeliveryStatus{
+		DeliveryID: deliveryID,
+		EventType:  eventType,
+		State:      StateQueued,
+		Received:   now,
+		Updated:    now,
+	}
+	for len(t.order) > t.max {
+		delete(t.statuses, t.order[0])
+		t.order = t.order[1:]
+	}
+	t.mu.Unlock()
+
+	var remaining int
+	for _, rt := range routes {
+		if rt.wants(eventType) {
+			remaining++
+		}
+	}
+	if remaining == 0 {
+		t.done(deliveryID)
+		return ctx, routes
+	}
+
+	var mu sync.Mutex
+	wrapped := make([]Route, len(routes))
+	for i, rt := range routes {
+		h := rt.Handler
+		wrapped[i] = rt
+		wrapped[i].Handler = func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			defer func() {
+				if p := recover(); p != nil {
+					ReportFailure(ctx, fmt.Errorf("handler panicked: %v", p))
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				remaining--
+				if remaining == 0 {
+					t.done(deliveryID)
+				}
+			}()
+			t.setState(deliveryID, StateRunning, "")
+			h(ctx, eventType, deliveryID, payload)
+		}
+	}
+	ctx = context.WithValue(ctx, trackedDeliveryKey, &trackedDelivery{t, deliveryID})
+	return ctx, wrapped
+}
+
+// describe records the delivery's common payload fields, for statistics
+func (t *statusTracker) describe(deliveryID string, info eventInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.statuses[deliveryID]
+	if !ok {
+		return
+	}
+	s.Action = info.Action
+	s.Repo = info.Repo.FullName
+	s.Sender = info.Sender.Login
+}
+
+// setState updates a delivery's state - failures and timeouts are final
+func (t *statusTracker) setState(deliveryID string, state DeliveryState, msg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.statuses[deliveryID]
+	if !ok || s.State == StateFailed || s.State == StateTimedOut {
+		return
+	}
+	s.State = state
+	s.Error = msg
+	s.Updated = t.now()
+}
+
+func (t *statusTracker) fail(deliveryID string, err error) {
+	t.setState(deliveryID, StateFailed, err.Error())
+}
+
+func (t *statusTracker) timedOut(deliveryID string) {
+	t.setState(deliveryID, StateTimedOut, "handlers didn't finish within the delivery budget")
+}
+
+func (t *statusTracker) done(deliveryID string) {
+	t.setState(deliveryID, StateSucceeded, "")
+}
+
+func (t *statusTracker) get(deliveryID string) (DeliveryStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.statuses[deliveryID]
+	if !ok {
+		return DeliveryStatus{}, false
+	}
+	return *s, true
+}
+
+// handler serves delivery statuses, under the given path prefix
+func (t *statusTracker) handler(prefix string) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		id := strings.TrimPrefix(req.URL.Path, prefix)
+		s, ok := t.get(id)
+		if !ok {
+			http.NotFound(resp, req)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(resp).Encode(s)
+	})
+}
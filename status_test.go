@@ -0,0 +1,104 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusTracker(t *testing.T) {
+	tr := newStatusTracker(2)
+	ctx := context.Background()
+
+	ok := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	reported := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		ReportFailure(ctx, errors.New("nope"))
+	}
+	panicky := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		panic("boom")
+	}
+
+	running := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		s, _ := tr.get(deliveryID)
+		assert.Equal(t, StateRunning, s.State)
+	}
+
+	ctx1, routes := tr.track(ctx, "push", "1", []Route{{Handler: running}, {Events: []string{"issues"}, Handler: panicky}})
+	s, _ := tr.get("1")
+	assert.Equal(t, StateQueued, s.State)
+	dispatch(ctx1, routes, "push", "1", nil).Wait()
+	s, _ = tr.get("1")
+	assert.Equal(t, StateSucceeded, s.State)
+
+	ctx2, routes := tr.track(ctx, "push", "2", []Route{{Handler: reported}, {Handler: ok}})
+	dispatch(ctx2, routes, "push", "2", nil).Wait()
+	s, _ = tr.get("2")
+	assert.Equal(t, StateFailed, s.State)
+	assert.Equal(t, "nope", s.Error)
+
+	ctx3, routes := tr.track(ctx, "push", "3", []Route{{Handler: panicky}})
+	dispatch(ctx3, routes, "push", "3", nil).Wait()
+	s, _ = tr.get("3")
+	assert.Equal(t, StateFailed, s.State)
+	assert.Equal(t, "handler panicked: boom", s.Error)
+
+	// only the last 2 are kept
+	_, found := tr.get("1")
+	assert.False(t, found)
+
+	// no interested routes
+	_, _ = tr.track(ctx, "issues", "4", []Route{{Events: []string{"push"}, Handler: ok}})
+	s, _ = tr.get("4")
+	assert.Equal(t, StateSucceeded, s.State)
+
+	// ReportFailure is harmless when not tracking
+	ReportFailure(ctx, errors.New("ignored"))
+}
+
+func TestStatusHandler(t *testing.T) {
+	tr := newStatusTracker(10)
+	_, _ = tr.track(context.Background(), "push", "abc", nil)
+	h := tr.handler("/deliveries/")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/deliveries/abc", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	s := DeliveryStatus{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+	assert.Equal(t, "abc", s.DeliveryID)
+	assert.Equal(t, StateSucceeded, s.State)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/deliveries/nope", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDispatchWithBudget(t *testing.T) {
+	r := &Responder{deliveryBudget: 20 * time.Millisecond, status: newStatusTracker(10)}
+	ctx := context.Background()
+
+	cancelled := make(chan error, 1)
+	slow := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+	}
+	ctx1, routes := r.status.track(ctx, "push", "slow", []Route{{Handler: slow}})
+	r.dispatchWithBudget(ctx1, routes, "push", "slow", nil)
+	assert.Equal(t, context.DeadlineExceeded, <-cancelled)
+	time.Sleep(10 * time.Millisecond)
+	s, _ := r.status.get("slow")
+	assert.Equal(t, StateTimedOut, s.State)
+
+	fast := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	ctx2, routes := r.status.track(ctx, "push", "fast", []Route{{Handler: fast}})
+	r.dispatchWithBudget(ctx2, routes, "push", "fast", nil)
+	time.Sleep(40 * time.Millisecond)
+	s, _ = r.status.get("fast")
+	assert.Equal(t, StateSucceeded, s.State)
+}
@@ -0,0 +1,176 @@
+package responder
+
+import (
+	"context"
+	"crypto/hmac"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+)
+
+// StreamHandler - like HookHandler, but reads the payload from an
+// io.Reader, e.g. with a json.Decoder, or to copy it on to a file or
+// another service. See AddStreamingEndpoint.
+type StreamHandler func(ctx context.Context, eventType, deliveryID string, payload io.Reader)
+
+// AddStreamingEndpoint - like AddEndpoint, but the endpoint's payloads
+// aren't read into memory: each is spooled to a temporary file while its
+// signature is validated, and once it's valid, the handler reads it from
+// the file, which is removed when the handler returns. For repos whose
+// payloads (e.g. big pushes) are too large to buffer.
+//
+// Payloads must be JSON (not form-encoded), and are still limited to
+// Options.MaxPayloadSize. As the payload isn't parsed, the endpoint has no
+// routing, middleware, write-ahead log, recording, or delivery status - but
+// duplicate deliveries are still ignored, and handlers count towards the
+// in-flight limit, and are drained on shutdown. Must be called before
+// Register and Listen.
+func (r *Responder) AddStreamingEndpoint(repos []string, h StreamHandler) error {
+	if h == nil {
+		return errors.New("must provide a handler")
+	}
+	err := r.AddEndpoint(repos)
+	if err != nil {
+		return err
+	}
+	r.endpoints[len(r.endpoints)-1].stream = h
+	return nil
+}
+
+// serveStream handles a delivery to a streaming endpoint
+func (e *endpoint) serveStream(resp http.ResponseWriter, req *http.Request) {
+	r := e.r
+	eventType := github.WebHookType(req)
+	deliveryID := github.DeliveryID(req)
+	log := hlog.FromRequest(req).With().
+		Str("eventType", eventType).
+		Str("deliveryID", deliveryID).
+		Bool("streaming", true).
+		Logger()
+
+	body := limitPayload(resp, req, r.maxPayload)
+	f, err := e.spoolPayload(req)
+	if body.exceeded() {
+		log.Error().
+			Int64("contentLength", req.ContentLength).
+			Int64("maxPayloadSize", r.maxPayload).
+			Msg("payload too large - rejecting")
+		http.Error(resp, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		err = signatureError(err)
+		log.Error().Err(err).Msg("invalid payload")
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Info().Msg("Incoming request")
+	countDelivery(eventType, "")
+
+	if eventType == "ping" && !r.dispatchPings {
+		closeSpool(log, f)
+		resp.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if eventType != "ping" && r.duplicate(eventType, deliveryID) {
+		closeSpool(log, f)
+		log.Info().Msg("ignoring duplicate delivery")
+		http.Error(resp, "duplicate delivery - already handled", http.StatusOK)
+		return
+	}
+	if !r.srv.begin() {
+		closeSpool(log, f)
+		rejected.WithLabelValues(eventType).Inc()
+		log.Warn().Msg("rejecting delivery - shutting down")
+		r.forgetDelivery(deliveryID)
+		http.Error(resp, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if !r.inFlight.acquire() {
+		r.srv.end()
+		closeSpool(log, f)
+		rejected.WithLabelValues(eventType).Inc()
+		log.Warn().Msg("rejecting delivery - too many deliveries in flight")
+		r.forgetDelivery(deliveryID)
+		http.Error(resp, "too many deliveries in flight", http.StatusServiceUnavailable)
+		return
+	}
+
+	di := parseDeliveryInfo(req.Header)
+	ctx := r.handlerContext(log, di, &Delivery{EventType: eventType, DeliveryID: deliveryID, Headers: req.Header})
+	go func() {
+		defer r.srv.end()
+		defer r.inFlight.release()
+		defer closeSpool(log, f)
+		defer func() {
+			if p := recover(); p != nil {
+				log.Error().Interface("panic", p).Msg("streaming handler panicked")
+			}
+		}()
+		e.stream(ctx, eventType, deliveryID, f)
+	}()
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// spoolPayload copies the request's body to a temporary file, validating
+// its signature (unless it's from a trusted relay) as it's copied, and
+// returns the file, ready to read from the start
+func (e *endpoint) spoolPayload(req *http.Request) (*os.File, error) {
+	ct, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Content-Type")
+	}
+	if ct != "application/json" {
+		return nil, errors.Errorf("Webhook request has unsupported Content-Type %q - streaming endpoints only accept application/json", ct)
+	}
+	verify := !trustedRelay(req, e.r.relayToken)
+	req.Header.Del(RelayTokenHeader)
+	var want []byte
+	var mac hash.Hash
+	if verify {
+		want, mac, err = signatureMAC(req.Header.Get(signatureHeader), []byte(e.secret))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := ioutil.TempFile("", "github-responder-payload-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create payload spool file")
+	}
+	var w io.Writer = f
+	if mac != nil {
+		w = io.MultiWriter(f, mac)
+	}
+	_, err = io.Copy(w, req.Body)
+	if err != nil {
+		err = errors.Wrap(err, "failed to read payload")
+	} else if mac != nil && !hmac.Equal(mac.Sum(nil), want) {
+		err = errors.New("payload signature check failed")
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		closeSpool(zerolog.Nop(), f)
+		return nil, err
+	}
+	return f, nil
+}
+
+// closeSpool closes and removes a spooled payload
+func closeSpool(log zerolog.Logger, f *os.File) {
+	_ = f.Close()
+	err := os.Remove(f.Name())
+	if err != nil {
+		log.Warn().Err(err).Str("path", f.Name()).Msg("failed to remove payload spool file")
+	}
+}
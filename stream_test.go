@@ -0,0 +1,58 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamingEndpoint(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/", maxPayload: 64, drainTimeout: time.Second}
+	assert.Error(t, r.AddStreamingEndpoint([]string{"foo/bar"}, nil))
+	assert.Error(t, r.AddStreamingEndpoint(nil, func(context.Context, string, string, io.Reader) {}))
+
+	got := make(chan string, 1)
+	paths := make(chan string, 1)
+	assert.NoError(t, r.AddStreamingEndpoint([]string{"foo/bar"}, func(ctx context.Context, eventType, deliveryID string, payload io.Reader) {
+		paths <- payload.(*os.File).Name()
+		b, err := ioutil.ReadAll(payload)
+		assert.NoError(t, err)
+		got <- eventType + " " + deliveryID + " " + string(b)
+	}))
+	ep := r.endpoints[0]
+	serve := func(ct string, payload []byte, secret string) int {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", ct)
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-GitHub-Delivery", "abc")
+		req.Header.Set("X-Hub-Signature", sign(payload, []byte(secret)))
+		rec := httptest.NewRecorder()
+		ep.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	payload := []byte(`{"ref":"refs/heads/master"}`)
+	assert.Equal(t, http.StatusNoContent, serve("application/json", payload, ep.secret))
+	assert.Equal(t, `push abc {"ref":"refs/heads/master"}`, <-got)
+	assert.NoError(t, r.drain())
+	// removed once the handler's done
+	_, err := os.Stat(<-paths)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, http.StatusBadRequest, serve("application/json", payload, "wrong"))
+	assert.Equal(t, http.StatusBadRequest, serve("application/x-www-form-urlencoded", payload, ep.secret))
+	large := []byte(`{"ref":"` + strings.Repeat("a", 100) + `"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("application/json", large, ep.secret))
+	// draining, so no more handlers may start
+	assert.Equal(t, http.StatusServiceUnavailable, serve("application/json", payload, ep.secret))
+	assert.Empty(t, got)
+}
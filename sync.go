@@ -0,0 +1,88 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Reporting - adapt a HookHandlerE to a HookHandler, reporting the errors it
+// returns with ReportFailure, so they're tracked, and fail the delivery in
+// synchronous mode (see Options.Synchronous)
+func Reporting(h HookHandlerE) HookHandler {
+	return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		err := h(ctx, eventType, deliveryID, payload)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("handler failed")
+			ReportFailure(ctx, err)
+		}
+	}
+}
+
+// unprocessableError - see Unprocessable
+type unprocessableError struct {
+	error
+}
+
+func (e unprocessableError) Cause() error {
+	return e.error
+}
+
+// Unprocessable - mark a handler's error as being the delivery's fault
+// rather than the handler's (it can't be handled, however many times it's
+// redelivered), so synchronous mode replies 422 Unprocessable Entity rather
+// than 500 Internal Server Error
+func Unprocessable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return unprocessableError{err}
+}
+
+// failureStatus - the HTTP status replied in synchronous mode when a handler
+// fails
+func failureStatus(err error) int {
+	for err != nil {
+		if _, ok := err.(unprocessableError); ok {
+			return http.StatusUnprocessableEntity
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return http.StatusInternalServerError
+}
+
+// collectFailures - a context whose handlers' failures are collected into
+// the returned result, reusing the context's collector if it has one
+func collectFailures(ctx context.Context) (context.Context, *handlerResult) {
+	if res, ok := ctx.Value(failuresKey).(*handlerResult); ok {
+		return ctx, res
+	}
+	res := &handlerResult{}
+	return context.WithValue(ctx, failuresKey, res), res
+}
+
+// synchronous - wrap the routes' handlers so that their failures (including
+// panics) are collected into the returned result, to be replied to GitHub
+func synchronous(ctx context.Context, routes []Route) (context.Context, []Route, *handlerResult) {
+	ctx, res := collectFailures(ctx)
+	wrapped := make([]Route, len(routes))
+	for i, rt := range routes {
+		h := rt.Handler
+		wrapped[i] = rt
+		wrapped[i].Handler = func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			defer func() {
+				if p := recover(); p != nil {
+					ReportFailure(ctx, fmt.Errorf("handler panicked: %v", p))
+				}
+			}()
+			h(ctx, eventType, deliveryID, payload)
+		}
+	}
+	return ctx, wrapped, res
+}
@@ -0,0 +1,55 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSynchronous(t *testing.T) {
+	payload := []byte(`{"repository":{"full_name":"foo/bar"}}`)
+	var fail error
+	action := Reporting(func(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+		return fail
+	})
+	r := &Responder{domain: "example.com", pathPrefix: "/", sync: true}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{action})}
+	ep := r.endpoints[0]
+
+	deliver := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-GitHub-Delivery", "abc")
+		req.Header.Set("X-Hub-Signature", sign(payload, []byte(ep.secret)))
+		rec := httptest.NewRecorder()
+		ep.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNoContent, deliver().Code)
+
+	fail = errors.New("boom")
+	rec := deliver()
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "boom")
+
+	fail = errors.Wrap(Unprocessable(errors.New("no such branch")), "can't deploy")
+	assert.Equal(t, http.StatusUnprocessableEntity, deliver().Code)
+
+	r.endpoints[0].routes[0].Handler = func(context.Context, string, string, []byte) {
+		panic("oops")
+	}
+	assert.Equal(t, http.StatusInternalServerError, deliver().Code)
+}
+
+func TestFailureStatus(t *testing.T) {
+	assert.Equal(t, http.StatusInternalServerError, failureStatus(errors.New("x")))
+	assert.Equal(t, http.StatusUnprocessableEntity, failureStatus(Unprocessable(errors.New("x"))))
+	assert.NoError(t, Unprocessable(nil))
+}
@@ -0,0 +1,43 @@
+package responder
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// fileTokenRefresh - how long a token read from a file is used before the
+// file is read again
+const fileTokenRefresh = time.Minute
+
+// fileTokenSource - reads the token from a file, so it can be rotated (e.g.
+// a mounted Kubernetes secret, or a file written by a Vault agent)
+type fileTokenSource struct {
+	path string
+}
+
+// NewFileTokenSource - a token source that reads the GitHub API token from
+// the file, re-reading it every minute so rotated tokens are picked up.
+// Leading and trailing whitespace is ignored. See Options.TokenSource.
+func NewFileTokenSource(path string) oauth2.TokenSource {
+	return &fileTokenSource{path}
+}
+
+// Token - see oauth2.TokenSource
+func (s *fileTokenSource) Token() (*oauth2.Token, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read GitHub API token")
+	}
+	tok := strings.TrimSpace(string(b))
+	if tok == "" {
+		return nil, newCauseError(ErrMissingToken, "GitHub API token missing - %s is empty", s.path)
+	}
+	return &oauth2.Token{
+		AccessToken: tok,
+		Expiry:      time.Now().Add(fileTokenRefresh),
+	}, nil
+}
@@ -0,0 +1,54 @@
+package responder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestFileTokenSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+
+	ts := NewFileTokenSource(path)
+	_, err = ts.Token()
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("\n"), 0600))
+	_, err = ts.Token()
+	assert.Equal(t, ErrMissingToken, errors.Cause(err))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("abc123\n"), 0600))
+	tok, err := ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", tok.AccessToken)
+	assert.True(t, tok.Valid())
+
+	// rotated
+	assert.NoError(t, ioutil.WriteFile(path, []byte("def456"), 0600))
+	tok, err = ts.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "def456", tok.AccessToken)
+}
+
+func TestNewWithOptionsTokenSource(t *testing.T) {
+	t.Setenv(ghtokName, "")
+	opts := Options{Repos: []string{"foo/bar"}, Domain: "hooks.example.com"}
+	_, err := NewWithOptions(opts)
+	assert.Equal(t, ErrMissingToken, errors.Cause(err))
+
+	opts.TokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "foo"})
+	_, err = NewWithOptions(opts)
+	assert.NoError(t, err)
+
+	_, key := testAppKey(t)
+	opts.AppID, opts.AppPrivateKey, opts.AppInstallationID = 1, key, 2
+	assert.Error(t, opts.Validate())
+}
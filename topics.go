@@ -0,0 +1,158 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// topicCacheTTL - how long fetched repo topics are trusted, in case
+	// repository events are missed
+	topicCacheTTL = time.Hour
+	// topicFetchTimeout - fetching happens while GitHub waits for a response
+	topicFetchTimeout = 5 * time.Second
+)
+
+// wantsTopics - whether the route is interested in a repo with the topics
+func (rt Route) wantsTopics(topics []string) bool {
+	_, ok := rt.matchedTopic(topics)
+	return ok
+}
+
+// matchedTopic - the first of the route's topics the repo has, and whether
+// the route is interested in the repo. Routes without topics match any
+// repo, with no topic.
+func (rt Route) matchedTopic(topics []string) (string, bool) {
+	if len(rt.Topics) == 0 {
+		return "", true
+	}
+	for _, want := range rt.Topics {
+		for _, t := range topics {
+			if strings.EqualFold(want, t) {
+				return want, true
+			}
+		}
+	}
+	return "", false
+}
+
+// topicCache caches repos' topics, for routing. Fetched topics expire after
+// topicCacheTTL, and are updated from repository events. The zero value is
+// ready to use.
+type topicCache struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]topicEntry
+}
+
+type topicEntry struct {
+	topics  []string
+	fetched time.Time
+}
+
+func (c *topicCache) clock() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
+}
+
+// get returns the repo's topics, fetching them if they're not cached
+func (c *topicCache) get(ctx context.Context, client *github.Client, fullName string) ([]string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[fullName]
+	c.mu.Unlock()
+	if ok && c.clock().Sub(e.fetched) < topicCacheTTL {
+		return e.topics, nil
+	}
+
+	parts := strings.SplitN(fullName, "/", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid repo %q", fullName)
+	}
+	ctx, cancel := context.WithTimeout(ctx, topicFetchTimeout)
+	defer cancel()
+	topics, _, err := client.Repositories.ListAllTopics(ctx, parts[0], parts[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch topics for %s", fullName)
+	}
+	c.set(fullName, topics)
+	return topics, nil
+}
+
+func (c *topicCache) set(fullName string, topics []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]topicEntry{}
+	}
+	c.entries[fullName] = topicEntry{topics: topics, fetched: c.clock()}
+}
+
+// track refreshes the cache from repository events - using the topics in
+// the payload when present, and otherwise forgetting the repo's topics so
+// they're fetched again
+func (c *topicCache) track(eventType string, payload []byte) {
+	if eventType != "repository" {
+		return
+	}
+	var p struct {
+		Action string `json:"action"`
+		Repo   struct {
+			FullName string    `json:"full_name"`
+			Topics   *[]string `json:"topics"`
+		} `json:"repository"`
+	}
+	if json.Unmarshal(payload, &p) != nil || p.Repo.FullName == "" {
+		return
+	}
+	if p.Repo.Topics != nil && p.Action != "deleted" {
+		c.set(p.Repo.FullName, *p.Repo.Topics)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, p.Repo.FullName)
+}
+
+// routeByTopics drops the routes that want topics the event's repo doesn't
+// have. Topics are only fetched when some route wants them - if they can't
+// be, routes wanting topics are dropped.
+func (r *Responder) routeByTopics(ctx context.Context, fullName string, routes []Route) []Route {
+	needed := false
+	for _, rt := range routes {
+		if len(rt.Topics) > 0 {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return routes
+	}
+
+	var topics []string
+	if fullName != "" {
+		var err error
+		topics, err = r.topics.get(ctx, GitHubClient(ctx), fullName)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("skipping topic-routed handlers")
+		}
+	}
+	filtered := make([]Route, 0, len(routes))
+	for _, rt := range routes {
+		if topic, ok := rt.matchedTopic(topics); ok {
+			if topic != "" {
+				rt = rt.annotate("topic:"+topic, "topic", topic)
+			}
+			filtered = append(filtered, rt)
+		}
+	}
+	return filtered
+}
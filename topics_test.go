@@ -0,0 +1,71 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWantsTopics(t *testing.T) {
+	assert.True(t, Route{}.wantsTopics(nil))
+	assert.True(t, Route{Topics: []string{"service"}}.wantsTopics([]string{"go", "Service"}))
+	assert.False(t, Route{Topics: []string{"service"}}.wantsTopics([]string{"library"}))
+	assert.False(t, Route{Topics: []string{"service"}}.wantsTopics(nil))
+
+	topic, ok := Route{Topics: []string{"lib", "service"}}.matchedTopic([]string{"Service"})
+	assert.True(t, ok)
+	assert.Equal(t, "service", topic)
+}
+
+func TestTopicCache(t *testing.T) {
+	var fetches int32
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		assert.Equal(t, "/repos/foo/bar/topics", req.URL.Path)
+		_, _ = w.Write([]byte(`{"names": ["service"]}`))
+	})
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &r.topics
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	topics, err := c.get(ctx, r.ghclient, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"service"}, topics)
+	_, _ = c.get(ctx, r.ghclient, "foo/bar")
+	assert.EqualValues(t, 1, fetches)
+
+	// expired
+	now = now.Add(topicCacheTTL)
+	_, _ = c.get(ctx, r.ghclient, "foo/bar")
+	assert.EqualValues(t, 2, fetches)
+
+	// repository events update the cache
+	c.track("repository", []byte(`{"action":"edited","repository":{"full_name":"foo/bar","topics":["library"]}}`))
+	topics, _ = c.get(ctx, r.ghclient, "foo/bar")
+	assert.Equal(t, []string{"library"}, topics)
+	assert.EqualValues(t, 2, fetches)
+
+	c.track("repository", []byte(`{"action":"renamed","repository":{"full_name":"foo/bar"}}`))
+	_, _ = c.get(ctx, r.ghclient, "foo/bar")
+	assert.EqualValues(t, 3, fetches)
+
+	_, err = c.get(ctx, r.ghclient, "bogus")
+	assert.Error(t, err)
+}
+
+func TestRouteByTopics(t *testing.T) {
+	r := testResponder(t, func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"names": ["service"]}`))
+	})
+	ctx := withGitHubClient(context.Background(), r.ghclient)
+	routes := []Route{{}, {Topics: []string{"service"}}, {Topics: []string{"library"}}}
+
+	assert.Len(t, r.routeByTopics(ctx, "foo/bar", routes), 2)
+	assert.Len(t, r.routeByTopics(ctx, "", routes), 1)
+	assert.Len(t, r.routeByTopics(ctx, "foo/bar", routes[:1]), 1)
+}
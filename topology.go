@@ -0,0 +1,276 @@
+package responder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// masked - what secrets are replaced with in a Topology
+const masked = "***"
+
+// Topology - the Responder's effective processing pipeline: the hooks
+// deliveries arrive through, the stages they pass on the way, the handlers
+// they're routed to, and the sinks that also receive them. Secrets are
+// masked, so it can be shared with review tooling, or compared between
+// deployments (or over time) to detect configuration drift.
+type Topology struct {
+	Hooks []TopologyHook `json:"hooks"`
+	// Stages - the configured processing stages, in the order deliveries
+	// pass through them
+	Stages   []TopologyStage   `json:"stages"`
+	Handlers []TopologyHandler `json:"handlers"`
+	Sinks    []TopologyStage   `json:"sinks"`
+}
+
+// TopologyHook - an endpoint, and the hooks that deliver to it
+type TopologyHook struct {
+	Endpoint int `json:"endpoint"`
+	// URL - the callback URL. Unguessable (random or derived) paths are
+	// masked.
+	URL          string               `json:"url"`
+	PathStrategy CallbackPathStrategy `json:"pathStrategy"`
+	Repos        []string             `json:"repos,omitempty"`
+	Events       []string             `json:"events"`
+	// Config - extra hook config fields (see Options.HookConfig), with
+	// secret-looking values masked
+	Config map[string]interface{} `json:"config,omitempty"`
+	Secret string                 `json:"secret,omitempty"`
+}
+
+// TopologyStage - a processing stage or sink, and its configuration
+type TopologyStage struct {
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// TopologyHandler - a handler, and the rules routing deliveries to it
+type TopologyHandler struct {
+	Name     string   `json:"name"`
+	Endpoint int      `json:"endpoint"`
+	Enabled  bool     `json:"enabled"`
+	Events   []string `json:"events,omitempty"`
+	Filters  []string `json:"filters,omitempty"`
+	Topics   []string `json:"topics,omitempty"`
+	// SamplePercent - the percentage of matching deliveries routed, when
+	// sampled
+	SamplePercent *float64 `json:"samplePercent,omitempty"`
+	// Middleware - how many middleware (see Use) wrap the handler
+	Middleware int `json:"middleware,omitempty"`
+}
+
+// Topology - the Responder's effective processing pipeline
+func (r *Responder) Topology() Topology {
+	t := Topology{
+		Hooks:    []TopologyHook{},
+		Stages:   r.topologyStages(),
+		Handlers: []TopologyHandler{},
+		Sinks:    r.topologySinks(),
+	}
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+	for i, ep := range r.endpoints {
+		hook := TopologyHook{
+			Endpoint:     i,
+			URL:          r.maskedCallbackURL(ep),
+			PathStrategy: r.pathStrategyOrDefault(),
+			Events:       ep.subscription(r.events),
+			Config:       maskedConfig(r.hookConfig),
+		}
+		if r.endpointSecret(i) != "" {
+			hook.Secret = masked
+		}
+		for _, repo := range ep.repos {
+			name := repo.owner + "/" + repo.name
+			if repo.owner == "" {
+				name = strconv.FormatInt(repo.id, 10)
+			}
+			hook.Repos = append(hook.Repos, name)
+		}
+		t.Hooks = append(t.Hooks, hook)
+
+		for _, rt := range ep.namedRoutes() {
+			h := TopologyHandler{
+				Name:       rt.name,
+				Endpoint:   i,
+				Enabled:    !r.disabledHandlers[rt.name],
+				Events:     rt.Events,
+				Topics:     rt.Topics,
+				Middleware: len(r.middleware),
+			}
+			for _, f := range rt.Filters {
+				h.Filters = append(h.Filters, f.Name)
+			}
+			if rt.Sampling != nil {
+				p := rt.Sampling.Percent
+				h.SamplePercent = &p
+			}
+			t.Handlers = append(t.Handlers, h)
+		}
+	}
+	return t
+}
+
+// maskedCallbackURL - the endpoint's callback URL, with the path masked
+// unless it's a fixed one
+func (r *Responder) maskedCallbackURL(ep *endpoint) string {
+	if r.pathStrategyOrDefault() == FixedCallbackPaths {
+		return ep.callbackURL
+	}
+	i := strings.LastIndex(ep.callbackURL, "/")
+	if i < 0 {
+		return masked
+	}
+	return ep.callbackURL[:i+1] + masked
+}
+
+// maskedConfig - a copy of the hook config, with values of keys that look
+// like they hold secrets masked
+func maskedConfig(config map[string]interface{}) map[string]interface{} {
+	if len(config) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		lk := strings.ToLower(k)
+		switch {
+		case strings.Contains(lk, "secret"), strings.Contains(lk, "token"),
+			strings.Contains(lk, "password"), strings.Contains(lk, "key"):
+			out[k] = masked
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// topologyStages - the configured stages, in ServeHTTP's order
+func (r *Responder) topologyStages() []TopologyStage {
+	stages := []TopologyStage{}
+	add := func(name string, config map[string]interface{}) {
+		stages = append(stages, TopologyStage{Name: name, Config: config})
+	}
+	if r.callbackIP != nil {
+		add("ipFilter", ipFilterConfig(r.callbackIP))
+	}
+	if r.hookIPs != nil {
+		add("githubHookIPs", map[string]interface{}{"refresh": r.hookIPs.refresh.String()})
+	}
+	if l := r.rateLimit; l != nil {
+		config := map[string]interface{}{}
+		if l.rate > 0 {
+			config["perSecond"], config["burst"] = l.rate, l.burst
+		}
+		if l.perIP > 0 {
+			config["perIPPerSecond"], config["perIPBurst"] = l.perIP, l.perIPBurst
+		}
+		add("rateLimit", config)
+	}
+	validate := map[string]interface{}{"signature": true}
+	if r.relayToken != "" {
+		validate["relayToken"] = masked
+	}
+	if r.maxPayload > 0 {
+		validate["maxPayloadSize"] = r.maxPayload
+	}
+	add("validate", validate)
+	if r.dedupTTL > 0 {
+		add("dedup", map[string]interface{}{"ttl": r.dedupTTL.String()})
+	}
+	if len(r.shims) > 0 || len(r.pins) > 0 {
+		config := map[string]interface{}{"shims": len(r.shims)}
+		if len(r.pins) > 0 {
+			config["pinnedFields"] = r.pins
+		}
+		add("normalize", config)
+	}
+	add("route", map[string]interface{}{"dispatchPings": r.dispatchPings})
+	if r.hydrate {
+		add("hydrate", nil)
+	}
+	if len(r.maintenance) > 0 {
+		var windows []string
+		for _, w := range r.maintenance {
+			windows = append(windows, w.Start.UTC().Format(time.RFC3339)+"/"+w.End.UTC().Format(time.RFC3339))
+		}
+		add("maintenance", map[string]interface{}{"windows": windows})
+	}
+	if r.inFlight != nil && r.inFlight.max > 0 {
+		add("inFlightLimit", map[string]interface{}{"max": r.inFlight.max})
+	}
+	if r.pool != nil {
+		add("queue", map[string]interface{}{"maxQueued": r.pool.max})
+	}
+	if r.sync {
+		add("synchronous", nil)
+	}
+	if r.deliveryBudget > 0 {
+		add("budget", map[string]interface{}{"budget": r.deliveryBudget.String()})
+	}
+	return stages
+}
+
+// topologySinks - where deliveries go, besides the handlers
+func (r *Responder) topologySinks() []TopologyStage {
+	sinks := []TopologyStage{}
+	add := func(name string, config map[string]interface{}) {
+		sinks = append(sinks, TopologyStage{Name: name, Config: config})
+	}
+	if r.recorder != nil {
+		add("recorder", map[string]interface{}{"type": fmt.Sprintf("%T", r.recorder)})
+	}
+	if len(r.shadows) > 0 {
+		add("shadows", map[string]interface{}{"handlers": len(r.shadows)})
+	}
+	if r.status != nil {
+		add("statusTracking", map[string]interface{}{"max": r.status.max})
+	}
+	if r.wal != nil {
+		add("writeAheadLog", map[string]interface{}{"type": fmt.Sprintf("%T", r.wal)})
+	}
+	if r.queueSnapshot != "" {
+		add("queueSnapshot", map[string]interface{}{"path": r.queueSnapshot})
+	}
+	if r.crash != nil {
+		add("crashReports", nil)
+	}
+	return sinks
+}
+
+// ipFilterConfig - the filter's ranges, sorted so they compare equal
+// however they were given
+func ipFilterConfig(f *IPFilter) map[string]interface{} {
+	config := map[string]interface{}{}
+	for name, nets := range map[string][]*net.IPNet{"allow": f.Allow, "deny": f.Deny} {
+		if len(nets) == 0 {
+			continue
+		}
+		ranges := make([]string, len(nets))
+		for i, n := range nets {
+			ranges[i] = n.String()
+		}
+		sort.Strings(ranges)
+		config[name] = ranges
+	}
+	return config
+}
+
+// EnableTopology - serve the Responder's Topology as JSON at
+// <prefix>topology, to addresses allowed by the metrics IP filter
+func (r *Responder) EnableTopology() {
+	r.topologyEndpoint = true
+}
+
+func (r *Responder) topologyHandler(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(resp).Encode(r.Topology())
+}
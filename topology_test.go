@@ -0,0 +1,81 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopology(t *testing.T) {
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {}
+	r := &Responder{
+		domain:       "example.com",
+		pathPrefix:   "/",
+		hookSecret:   "s3cret",
+		pathStrategy: StableCallbackPaths,
+		hookConfig:   map[string]interface{}{"insecure_ssl": "0", "api_key": "hunter2"},
+		relayToken:   "relay",
+		dedupTTL:     time.Hour,
+		maxPayload:   DefaultMaxPayloadSize,
+		metricsIP:    DefaultMetricsIPFilter,
+		callbackIP:   mustIPFilter([]string{"192.30.252.0/22", "140.82.112.0/20"}, nil),
+	}
+	ep := r.newEndpoint([]repository{{owner: "foo", name: "bar"}, {id: 42}}, []HookHandler{h})
+	r.endpoints = []*endpoint{ep}
+	ep.routes = append(ep.routes, Route{
+		Name:     "deploy",
+		Events:   []string{"push"},
+		Filters:  []Filter{{Name: "master"}},
+		Topics:   []string{"service"},
+		Sampling: &Sampling{Percent: 10},
+		Handler:  h,
+	})
+	assert.NoError(t, r.DisableHandler("deploy"))
+
+	top := r.Topology()
+	if assert.Len(t, top.Hooks, 1) {
+		hook := top.Hooks[0]
+		assert.Equal(t, "https://example.com/gh-callback/***", hook.URL)
+		assert.Equal(t, StableCallbackPaths, hook.PathStrategy)
+		assert.Equal(t, []string{"foo/bar", "42"}, hook.Repos)
+		assert.Equal(t, masked, hook.Secret)
+		assert.Equal(t, map[string]interface{}{"insecure_ssl": "0", "api_key": masked}, hook.Config)
+	}
+	assert.Equal(t, []TopologyStage{
+		{Name: "ipFilter", Config: map[string]interface{}{"allow": []string{"140.82.112.0/20", "192.30.252.0/22"}}},
+		{Name: "validate", Config: map[string]interface{}{"signature": true, "relayToken": masked, "maxPayloadSize": int64(DefaultMaxPayloadSize)}},
+		{Name: "dedup", Config: map[string]interface{}{"ttl": "1h0m0s"}},
+		{Name: "route", Config: map[string]interface{}{"dispatchPings": false}},
+	}, top.Stages)
+	ten := 10.0
+	assert.Equal(t, []TopologyHandler{
+		{Name: "0.0", Enabled: true},
+		{Name: "deploy", Events: []string{"push"}, Filters: []string{"master"}, Topics: []string{"service"}, SamplePercent: &ten},
+	}, top.Handlers)
+	assert.Empty(t, top.Sinks)
+
+	b, err := json.Marshal(top)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "s3cret")
+	assert.NotContains(t, string(b), "hunter2")
+	assert.NotContains(t, string(b), "relay\"")
+
+	r.EnableTopology()
+	mux := r.Handler()
+	req := httptest.NewRequest(http.MethodGet, "/topology", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, string(b), resp.Body.String())
+
+	req.RemoteAddr = "203.0.113.1:1234"
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
@@ -0,0 +1,149 @@
+package responder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// pushSummary - the fields of a push event that show whether GitHub left
+// commits out of the payload
+type pushSummary struct {
+	Size    int               `json:"size"`
+	Before  string            `json:"before"`
+	After   string            `json:"after"`
+	Commits []json.RawMessage `json:"commits"`
+	Repo    struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// truncated - whether GitHub left fields out of the payload because they were
+// too big. Only pushes are detected: their commits list is capped, with the
+// full count in 'size'.
+func truncated(eventType string, payload []byte) bool {
+	if eventType != "push" {
+		return false
+	}
+	p := pushSummary{}
+	if json.Unmarshal(payload, &p) != nil {
+		return false
+	}
+	return p.Size > len(p.Commits)
+}
+
+// hydrating wraps the routes' handlers so that, before any runs, commits
+// GitHub left out of a push payload are fetched from the API and put back.
+// Hydration happens once per delivery, in the handlers' goroutines, so
+// GitHub isn't kept waiting. When it fails, handlers get the payload as
+// delivered. DeliveryInfo.Truncated stays set unless every commit was
+// fetched.
+func (r *Responder) hydrating(ctx context.Context, di *DeliveryInfo, eventType string, payload []byte, routes []Route) []Route {
+	if !truncated(eventType, payload) {
+		return routes
+	}
+	di.Truncated = true
+	if !r.hydrate {
+		return routes
+	}
+
+	var (
+		once     sync.Once
+		hydrated = payload
+		complete bool
+	)
+	run := func(ctx context.Context) {
+		log := zerolog.Ctx(ctx)
+		p, err := hydratePush(ctx, GitHubClient(ctx), payload)
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to hydrate truncated payload")
+			return
+		}
+		hydrated, complete = p, !truncated(eventType, p)
+		log.Debug().Bool("complete", complete).Msg("hydrated truncated payload")
+	}
+
+	wrapped := make([]Route, len(routes))
+	for i, rt := range routes {
+		h := rt.Handler
+		wrapped[i] = rt
+		wrapped[i].Handler = func(ctx context.Context, eventType, deliveryID string, _ []byte) {
+			once.Do(func() { run(ctx) })
+			if info := GetDeliveryInfo(ctx); complete && info != nil {
+				// the delivery info is shared, so handlers get a copy
+				hydratedInfo := *info
+				hydratedInfo.Truncated = false
+				ctx = withDeliveryInfo(ctx, &hydratedInfo)
+			}
+			h(ctx, eventType, deliveryID, hydrated)
+		}
+	}
+	return wrapped
+}
+
+// hydratePush replaces a push payload's commits with those between its
+// before and after commits, from the API. GitHub's compare API returns at
+// most 250 commits.
+func hydratePush(ctx context.Context, client *github.Client, payload []byte) ([]byte, error) {
+	p := pushSummary{}
+	err := json.Unmarshal(payload, &p)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse push")
+	}
+	parts := strings.SplitN(p.Repo.FullName, "/", 2)
+	switch {
+	case client == nil:
+		return nil, errors.New("no GitHub client")
+	case len(parts) != 2:
+		return nil, errors.New("push has no repository")
+	case strings.Trim(p.Before, "0") == "" || strings.Trim(p.After, "0") == "":
+		return nil, errors.New("can't compare a push creating or deleting a branch")
+	}
+	cmp, _, err := client.Repositories.CompareCommits(ctx, parts[0], parts[1], p.Before, p.After)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compare commits")
+	}
+
+	commits := make([]interface{}, len(cmp.Commits))
+	for i, c := range cmp.Commits {
+		commits[i] = pushCommit(c)
+	}
+	fields := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	err = dec.Decode(&fields)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse push")
+	}
+	fields["commits"] = commits
+	return json.Marshal(fields)
+}
+
+// pushCommit - the commit, as it appears in push payloads. The changed files
+// aren't available from the compare API, so are omitted.
+func pushCommit(c github.RepositoryCommit) map[string]interface{} {
+	person := func(a *github.CommitAuthor, u *github.User) map[string]interface{} {
+		m := map[string]interface{}{"name": a.GetName(), "email": a.GetEmail()}
+		if u.GetLogin() != "" {
+			m["username"] = u.GetLogin()
+		}
+		return m
+	}
+	commit := c.GetCommit()
+	return map[string]interface{}{
+		"id":        c.GetSHA(),
+		"tree_id":   commit.GetTree().GetSHA(),
+		"distinct":  true,
+		"message":   commit.GetMessage(),
+		"timestamp": commit.GetAuthor().GetDate(),
+		"url":       c.GetHTMLURL(),
+		"author":    person(commit.GetAuthor(), c.GetAuthor()),
+		"committer": person(commit.GetCommitter(), c.GetCommitter()),
+	}
+}
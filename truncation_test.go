@@ -0,0 +1,74 @@
+package responder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncated(t *testing.T) {
+	assert.False(t, truncated("push", []byte(`{"size":1,"commits":[{}]}`)))
+	assert.True(t, truncated("push", []byte(`{"size":21,"commits":[{}]}`)))
+	assert.False(t, truncated("issues", []byte(`{"size":21,"commits":[{}]}`)))
+	assert.False(t, truncated("push", []byte(`not json`)))
+}
+
+func TestHydrating(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/repos/foo/bar/compare/aaa...bbb", req.URL.Path)
+		fmt.Fprint(w, `{"commits":[
+			{"sha":"1","commit":{"message":"one","author":{"name":"A","email":"a@example.com"}},"author":{"login":"a"}},
+			{"sha":"2","commit":{"message":"two"}}
+		]}`)
+	}))
+	defer srv.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	payload := []byte(`{"size":2,"before":"aaa","after":"bbb","commits":[{"id":"2"}],"repository":{"full_name":"foo/bar","id":12345678901}}`)
+	type result struct {
+		payload   []byte
+		truncated bool
+	}
+	got := make(chan result, 2)
+	h := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		got <- result{payload, GetDeliveryInfo(ctx).Truncated}
+	}
+	routes := []Route{{Handler: h}, {Handler: h}}
+
+	// without hydration, handlers just learn it's truncated
+	r := &Responder{}
+	di := &DeliveryInfo{}
+	ctx := withDeliveryInfo(withGitHubClient(context.Background(), client), di)
+	dispatch(ctx, r.hydrating(ctx, di, "push", payload, routes), "push", "abc", payload).Wait()
+	res := <-got
+	<-got
+	assert.True(t, res.truncated)
+	assert.Equal(t, payload, res.payload)
+
+	r.hydrate = true
+	di = &DeliveryInfo{}
+	ctx = withDeliveryInfo(withGitHubClient(context.Background(), client), di)
+	dispatch(ctx, r.hydrating(ctx, di, "push", payload, routes), "push", "abc", payload).Wait()
+	for range routes {
+		res = <-got
+		assert.False(t, res.truncated)
+		p := pushSummary{}
+		assert.NoError(t, json.Unmarshal(res.payload, &p))
+		assert.Len(t, p.Commits, 2)
+		assert.Contains(t, string(res.payload), `"username":"a"`)
+		// large numbers survive
+		assert.Contains(t, string(res.payload), `12345678901`)
+	}
+	assert.True(t, di.Truncated, "the shared info shouldn't change")
+
+	// untruncated payloads are left alone
+	assert.Equal(t, len(routes), len(r.hydrating(ctx, di, "issues", payload, routes)))
+}
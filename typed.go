@@ -0,0 +1,111 @@
+package responder
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// On - handle the events of the handler's type, which must be a
+// func(context.Context, *github.XxxEvent) error for any of go-github's
+// event types - e.g. func(context.Context, *github.ReleaseEvent) error.
+// Returned errors are logged and reported with ReportFailure. The handler's
+// type is checked when it's added, rather than at compile time, as the
+// module predates generics. See OnPush.
+func (r *Responder) On(handler interface{}) error {
+	h := reflect.ValueOf(handler)
+	t := h.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 1 ||
+		t.In(0) != contextType || t.Out(0) != errorType {
+		return errors.Errorf("invalid handler %s - must be a func(context.Context, *github.XxxEvent) error", t)
+	}
+	eventType, err := eventTypeOf(t.In(1))
+	if err != nil {
+		return err
+	}
+	r.onEvent(eventType, func(ctx context.Context, event interface{}) {
+		out := h.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(event)})
+		if err, _ := out[0].Interface().(error); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("handler failed")
+			ReportFailure(ctx, err)
+		}
+	})
+	return nil
+}
+
+// eventTypeOf - the webhook event type go-github parses into the type, e.g.
+// "pull_request" for *github.PullRequestEvent
+func eventTypeOf(t reflect.Type) (string, error) {
+	if t.Kind() != reflect.Ptr || t.Elem().PkgPath() != reflect.TypeOf(github.PushEvent{}).PkgPath() {
+		return "", errors.Errorf("invalid event type %s - must be a pointer to a go-github event", t)
+	}
+	name := strings.TrimSuffix(t.Elem().Name(), "Event")
+	snake := &strings.Builder{}
+	for i, c := range name {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				snake.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		snake.WriteRune(c)
+	}
+	event, err := github.ParseWebHook(snake.String(), []byte("{}"))
+	if err != nil || reflect.TypeOf(event) != t {
+		return "", errors.Errorf("invalid event type %s - not a webhook event", t)
+	}
+	return snake.String(), nil
+}
+
+// OnPush - handle push events with the parsed event. Like the other On*
+// methods, this adds a route to the Responder's primary endpoint, so it
+// must be called before Register and Listen, and hooks subscribe to the
+// event when registered with no explicit events.
+func (r *Responder) OnPush(handler func(ctx context.Context, event *github.PushEvent)) {
+	r.onEvent("push", func(ctx context.Context, event interface{}) {
+		handler(ctx, event.(*github.PushEvent))
+	})
+}
+
+// OnPullRequest - handle pull_request events with the parsed event. See OnPush.
+func (r *Responder) OnPullRequest(handler func(ctx context.Context, event *github.PullRequestEvent)) {
+	r.onEvent("pull_request", func(ctx context.Context, event interface{}) {
+		handler(ctx, event.(*github.PullRequestEvent))
+	})
+}
+
+// OnIssueComment - handle issue_comment events with the parsed event. See
+// OnPush.
+func (r *Responder) OnIssueComment(handler func(ctx context.Context, event *github.IssueCommentEvent)) {
+	r.onEvent("issue_comment", func(ctx context.Context, event interface{}) {
+		handler(ctx, event.(*github.IssueCommentEvent))
+	})
+}
+
+// onEvent routes the event type to the handler, parsing the payload first.
+// Payloads that can't be parsed are logged and reported as failures.
+func (r *Responder) onEvent(eventType string, handler func(ctx context.Context, event interface{})) {
+	r.endpoints[0].routes = append(r.endpoints[0].routes, Route{
+		Events: []string{eventType},
+		Handler: func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			event, err := github.ParseWebHook(eventType, payload)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to parse %s event", eventType)
+				zerolog.Ctx(ctx).Error().Err(err).Msg("")
+				ReportFailure(ctx, err)
+				return
+			}
+			handler(ctx, event)
+		},
+	})
+}
@@ -0,0 +1,73 @@
+package responder
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnEvent(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, nil)}
+
+	pushes := make(chan string, 1)
+	comments := make(chan string, 1)
+	r.OnPush(func(ctx context.Context, event *github.PushEvent) {
+		pushes <- event.GetRef()
+	})
+	r.OnIssueComment(func(ctx context.Context, event *github.IssueCommentEvent) {
+		comments <- event.GetComment().GetBody()
+	})
+	r.OnPullRequest(func(ctx context.Context, event *github.PullRequestEvent) {
+		t.Error("no pull_request events were sent")
+	})
+	assert.ElementsMatch(t, []string{"issue_comment", "pull_request", "push"}, r.endpoints[0].subscription(nil))
+
+	ctx := context.Background()
+	routes := r.endpoints[0].namedRoutes()
+	dispatch(ctx, routes, "push", "a", []byte(`{"ref":"refs/heads/master"}`)).Wait()
+	assert.Equal(t, "refs/heads/master", <-pushes)
+	dispatch(ctx, routes, "issue_comment", "b", []byte(`{"comment":{"body":"hi"}}`)).Wait()
+	assert.Equal(t, "hi", <-comments)
+
+	// unparseable payloads don't reach the handler
+	dispatch(ctx, routes, "push", "c", []byte(`not json`)).Wait()
+	assert.Empty(t, pushes)
+}
+
+func TestOn(t *testing.T) {
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, nil)}
+
+	assert.Error(t, r.On("not a func"))
+	assert.Error(t, r.On(func(ctx context.Context, event *github.PushEvent) {}))
+	assert.Error(t, r.On(func(ctx context.Context, event github.PushEvent) error { return nil }))
+	assert.Error(t, r.On(func(ctx context.Context, event *github.Repository) error { return nil }))
+
+	releases := make(chan string, 1)
+	assert.NoError(t, r.On(func(ctx context.Context, event *github.ReleaseEvent) error {
+		releases <- event.GetAction()
+		return nil
+	}))
+	assert.NoError(t, r.On(func(ctx context.Context, event *github.DeploymentStatusEvent) error { return nil }))
+	assert.NoError(t, r.On(func(ctx context.Context, event *github.PullRequestReviewCommentEvent) error { return nil }))
+	assert.ElementsMatch(t, []string{"release", "deployment_status", "pull_request_review_comment"}, r.endpoints[0].subscription(nil))
+
+	dispatch(context.Background(), r.endpoints[0].namedRoutes(), "release", "a", []byte(`{"action":"published"}`)).Wait()
+	assert.Equal(t, "published", <-releases)
+}
+
+func TestEventTypeOf(t *testing.T) {
+	for name, event := range map[string]interface{}{
+		"push":                      &github.PushEvent{},
+		"check_run":                 &github.CheckRunEvent{},
+		"installation_repositories": &github.InstallationRepositoriesEvent{},
+	} {
+		got, err := eventTypeOf(reflect.TypeOf(event))
+		assert.NoError(t, err)
+		assert.Equal(t, name, got)
+	}
+}
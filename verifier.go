@@ -0,0 +1,55 @@
+package responder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/google/go-github/v24/github"
+	"github.com/rs/zerolog/log"
+)
+
+// maxVerifiedPayload - GitHub caps payloads at 25MB
+const maxVerifiedPayload = 25 << 20
+
+// NewVerifierProxy - a handler that validates webhook deliveries' signatures
+// against the secret (and the sender's address against filter, if given),
+// then forwards the raw request unchanged to upstream. This gives services
+// that can't validate deliveries themselves validation without code
+// changes, e.g. as a sidecar. Invalid deliveries are rejected with 400, and
+// never reach upstream.
+func NewVerifierProxy(secret []byte, upstream *url.URL, filter *IPFilter) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	var h http.Handler = http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(resp, req.Body, maxVerifiedPayload))
+		if err != nil {
+			http.Error(resp, "failed to read payload", http.StatusBadRequest)
+			return
+		}
+
+		// validate a copy, so the original body can be forwarded as-is
+		check := &http.Request{Header: req.Header, Body: ioutil.NopCloser(bytes.NewReader(body))}
+		_, err = github.ValidatePayload(check, secret)
+		l := log.With().
+			Str("eventType", github.WebHookType(req)).
+			Str("deliveryID", github.DeliveryID(req)).
+			Logger()
+		if err != nil {
+			err = signatureError(err)
+			l.Warn().Err(err).Str("remoteAddr", req.RemoteAddr).Msg("invalid delivery - not forwarding")
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		l.Debug().Msg("forwarding verified delivery")
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		proxy.ServeHTTP(resp, req)
+	})
+	if filter != nil {
+		h = filter.Middleware(h)
+	}
+	return h
+}
@@ -0,0 +1,53 @@
+package responder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifierProxy(t *testing.T) {
+	received := make(chan []byte, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "push", req.Header.Get("X-GitHub-Event"))
+		received <- b
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	secret := []byte("s3cr3t")
+	payload := []byte(`{"ref": "refs/heads/main"}`)
+	newReq := func(sig string) *http.Request {
+		req := httptest.NewRequest("POST", "/hook", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature", sig)
+		return req
+	}
+
+	h := NewVerifierProxy(secret, u, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newReq(sign(payload, secret)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, payload, <-received)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, newReq(sign(payload, []byte("wrong"))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, received)
+
+	filter, err := NewIPFilter([]string{"10.0.0.0/8"}, nil)
+	assert.NoError(t, err)
+	h = NewVerifierProxy(secret, u, filter)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, newReq(sign(payload, secret)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, received)
+}
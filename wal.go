@@ -0,0 +1,156 @@
+package responder
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// walNamespace - the store namespace of the write-ahead log
+	walNamespace = "responder.wal"
+	// maxWALAttempts - how many times a logged delivery is run before it's
+	// dropped, so a delivery that crashes the process can't do so forever
+	maxWALAttempts = 3
+)
+
+// walEntry - a delivery in the write-ahead log
+type walEntry struct {
+	// Endpoint - the index of the endpoint it was delivered to
+	Endpoint int       `json:"endpoint"`
+	Delivery *Delivery `json:"delivery"`
+	// Attempts - how many times its handlers have been started
+	Attempts int `json:"attempts"`
+}
+
+// EnableWriteAheadLog - persist each delivery to the store before its
+// handlers run, and remove it once they've all finished without failing
+// (see ReportFailure). Deliveries left in the log - because the process
+// crashed or was stopped mid-delivery, or a handler failed - are run again
+// when RunScheduler starts (as RegisterAndListen does), up to 3 times in
+// all. This gives at-least-once handling, so handlers should be idempotent.
+//
+// Use a persistent store, e.g. a FileStateStore.
+func (r *Responder) EnableWriteAheadLog(s StateStore) {
+	r.wal = s
+}
+
+// writeAhead - log the delivery, returning its log key, or "" if it
+// couldn't be (or there's no log)
+func (r *Responder) writeAhead(log zerolog.Logger, ep int, d *Delivery) string {
+	if r.wal == nil {
+		return ""
+	}
+	key := r.newID()
+	err := r.putWAL(key, walEntry{Endpoint: ep, Delivery: d, Attempts: 1})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to write delivery ahead - it won't be retried if handling fails")
+		return ""
+	}
+	return key
+}
+
+func (r *Responder) putWAL(key string, e walEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode delivery")
+	}
+	b, err = compress(r.compression, b)
+	if err != nil {
+		return err
+	}
+	return r.wal.Put(walNamespace, key, b)
+}
+
+// settle - once the handlers are done, remove the delivery from the log,
+// unless one failed
+func (r *Responder) settle(log zerolog.Logger, key string, wg *sync.WaitGroup, failures *handlerResult) {
+	if key == "" {
+		return
+	}
+	wg.Wait()
+	if failures.err != nil {
+		log.Warn().Err(failures.err).Msg("handler failed - keeping delivery in the write-ahead log, to retry")
+		return
+	}
+	err := r.wal.Delete(walNamespace, key)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to remove delivery from the write-ahead log")
+	}
+}
+
+// replayWAL - run the deliveries left in the write-ahead log, waiting for
+// them to finish
+func (r *Responder) replayWAL() {
+	if r.wal == nil {
+		return
+	}
+	keys, err := r.wal.Keys(walNamespace)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to read the write-ahead log")
+		return
+	}
+	var wg sync.WaitGroup
+	for _, k := range keys {
+		b, err := r.wal.Get(walNamespace, k)
+		if err != nil || b == nil {
+			continue
+		}
+		e := walEntry{}
+		b, err = decompress(b)
+		if err == nil {
+			err = json.Unmarshal(b, &e)
+		}
+		if err == nil && (e.Delivery == nil || e.Endpoint < 0 || e.Endpoint >= len(r.endpoints)) {
+			err = errors.New("no such delivery or endpoint")
+		}
+		if err != nil || e.Attempts >= maxWALAttempts {
+			log.Error().Err(err).
+				Str("walKey", k).
+				Int("attempts", e.Attempts).
+				Msg("dropping delivery from the write-ahead log")
+			_ = r.wal.Delete(walNamespace, k)
+			continue
+		}
+		// the rest are left in the log for the next start
+		if !r.srv.begin() {
+			break
+		}
+		e.Attempts++
+		err = r.putWAL(k, e)
+		if err != nil {
+			r.srv.end()
+			log.Error().Err(err).Str("walKey", k).Msg("failed to update the write-ahead log - not retrying delivery")
+			continue
+		}
+		wg.Add(1)
+		go func(k string, e walEntry) {
+			defer wg.Done()
+			defer r.srv.end()
+			r.retryDelivery(k, e)
+		}(k, e)
+	}
+	wg.Wait()
+}
+
+// retryDelivery runs a logged delivery's handlers again
+func (r *Responder) retryDelivery(key string, e walEntry) {
+	d := e.Delivery
+	info := parseEventInfo(d.Payload)
+	l := info.withFields(log.With().
+		Str("eventType", d.EventType).
+		Str("deliveryID", d.DeliveryID).
+		Int("attempt", e.Attempts)).Logger()
+	l.Info().Msg("Retrying delivery from the write-ahead log")
+
+	di := parseDeliveryInfo(d.Headers)
+	di.EventType, di.DeliveryID = d.EventType, d.DeliveryID
+	di.InstallationID = info.Installation.ID
+	hctx, routes := r.route(r.handlerContext(l, di, d), l, di, info.Repo.FullName, r.endpoints[e.Endpoint].namedRoutes(), d)
+	routes = r.guarded(routes, d.EventType, d.DeliveryID)
+	hctx, failures := collectFailures(hctx)
+	r.settle(l, key, dispatch(hctx, routes, d.EventType, d.DeliveryID, d.Payload), failures)
+}
@@ -0,0 +1,85 @@
+package responder
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAheadLog(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	action := func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		if runs == 1 {
+			ReportFailure(ctx, errors.New("flaky"))
+		}
+	}
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	r.endpoints = []*endpoint{r.newEndpoint(nil, []HookHandler{action})}
+	assert.Equal(t, "", r.writeAhead(log.Logger, 0, &Delivery{}))
+
+	s := NewMemoryStateStore()
+	r.EnableWriteAheadLog(s)
+	d := &Delivery{EventType: "push", DeliveryID: "abc", Headers: http.Header{}, Payload: []byte(`{}`)}
+	key := r.writeAhead(log.Logger, 0, d)
+	assert.NotEmpty(t, key)
+
+	// the first run fails, so the delivery stays in the log
+	ctx, failures := collectFailures(context.Background())
+	r.settle(log.Logger, key, dispatch(ctx, r.endpoints[0].namedRoutes(), "push", "abc", d.Payload), failures)
+	keys, _ := s.Keys(walNamespace)
+	assert.Equal(t, []string{key}, keys)
+
+	// and is retried
+	r.replayWAL()
+	assert.Equal(t, 2, runs)
+	keys, _ = s.Keys(walNamespace)
+	assert.Empty(t, keys)
+
+	// deliveries that keep failing are dropped
+	_ = r.putWAL("x", walEntry{Delivery: d, Attempts: maxWALAttempts})
+	r.replayWAL()
+	keys, _ = s.Keys(walNamespace)
+	assert.Empty(t, keys)
+	assert.Equal(t, 2, runs)
+}
+
+func TestWriteAheadLogRouting(t *testing.T) {
+	var mu sync.Mutex
+	runs := map[string][]string{}
+	record := func(name string) HookHandler {
+		return func(ctx context.Context, eventType, deliveryID string, payload []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			runs[name] = GetRouteAnnotations(ctx).Rules
+		}
+	}
+	match := func(ok bool) Filter {
+		return Filter{Name: "f", Match: func(context.Context, string, []byte) (bool, map[string]string) { return ok, nil }}
+	}
+	r := &Responder{domain: "example.com", pathPrefix: "/"}
+	ep := r.newEndpoint(nil, nil)
+	ep.routes = []Route{
+		{Name: "accepted", Handler: record("accepted"), Filters: []Filter{match(true)}},
+		{Name: "rejected", Handler: record("rejected"), Filters: []Filter{match(false)}},
+		{Name: "disabled", Handler: record("disabled")},
+	}
+	r.endpoints = []*endpoint{ep}
+	assert.NoError(t, r.DisableHandler("disabled"))
+
+	r.EnableWriteAheadLog(NewMemoryStateStore())
+	d := &Delivery{EventType: "push", DeliveryID: "abc", Headers: http.Header{}, Payload: []byte(`{}`)}
+	r.writeAhead(log.Logger, 0, d)
+
+	// replayed, the delivery's routed as it was when first received
+	r.replayWAL()
+	assert.Equal(t, map[string][]string{"accepted": {"filter:f"}}, runs)
+}